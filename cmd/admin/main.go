@@ -1,11 +1,618 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"log"
+	"os"
+	"questions-vote/internal/db"
+	"questions-vote/internal/dedup"
+	"questions-vote/internal/models"
+	"questions-vote/internal/quality"
+	"sort"
+	"strings"
 )
 
 func main() {
+	var (
+		command      = flag.String("command", "", "Command to run: position-bias, voter-quality, list-users, set-role, ban, shadow-ban, unban, allow, disallow, set-access, notify, broadcasts, question-changes, results, author-results, find-duplicates, duplicates, search")
+		tournamentID = flag.Int("tournament-id", 0, "Tournament ID (defaults to the active tournament)")
+		minVotes     = flag.Int("min-votes", 20, "Minimum number of votes for a user to be listed")
+		recompute    = flag.Bool("recompute", false, "Recompute voter quality from all votes before listing")
+		showAll      = flag.Bool("all", false, "List every scored user, not only flagged ones")
+		userID       = flag.Int64("user-id", 0, "Telegram user ID")
+		role         = flag.String("role", "", "User role: admin, voter")
+		status       = flag.String("status", "", "Only list users with this status: active, banned, shadow_banned")
+		reason       = flag.String("reason", "", "Reason for a ban, kept for other admins")
+		inviteOnly   = flag.Bool("invite-only", false, "Only allowlisted users and holders of the invite code may vote")
+		inviteCode   = flag.String("invite-code", "", "Invite code accepted by /join and /start")
+		text         = flag.String("text", "", "Text of the notification, or words to search for")
+		audience     = flag.String("audience", models.AudienceVoters, "Recipients of the notification: voters, all")
+		questionID   = flag.Int("question-id", 0, "Question ID (internal)")
+		limit        = flag.Int("limit", 20, "Number of questions to list")
+		minQuestions = flag.Int("min-questions", 3, "Minimum number of questions for an author to be listed")
+		authors      = flag.String("authors", "", "Only questions by these authors (comma-separated IDs or names)")
+		editors      = flag.String("editors", "", "Only questions edited by these editors (comma-separated IDs or names)")
+		threshold    = flag.Float64("threshold", dedup.DefaultThreshold, "Share of common word triples above which questions are duplicates")
+	)
+	flag.Parse()
+
+	if *command == "" {
+		printUsage()
+		os.Exit(1)
+	}
+
 	log.Println("Starting questions-vote admin...")
 
-	log.Println("Admin tool ready")
+	err := db.Initialize()
+	if err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer db.Close()
+
+	err = db.Migrate()
+	if err != nil {
+		log.Fatalf("Failed to migrate database: %v", err)
+	}
+
+	switch *command {
+	case "position-bias":
+		err = runPositionBias(*tournamentID, *minVotes)
+	case "voter-quality":
+		err = runVoterQuality(*tournamentID, *recompute, *showAll)
+	case "list-users":
+		err = runListUsers(*role, *status)
+	case "set-role":
+		err = runSetRole(*userID, *role)
+	case "ban":
+		err = runSetStatus(*userID, models.StatusBanned, *reason)
+	case "shadow-ban":
+		err = runSetStatus(*userID, models.StatusShadowBanned, *reason)
+	case "unban":
+		err = runSetStatus(*userID, models.StatusActive, "")
+	case "allow":
+		err = runAllow(*tournamentID, *userID, true)
+	case "disallow":
+		err = runAllow(*tournamentID, *userID, false)
+	case "set-access":
+		err = runSetAccess(*tournamentID, *inviteOnly, *inviteCode)
+	case "notify":
+		err = runNotify(*tournamentID, *audience, *text)
+	case "question-changes":
+		err = runQuestionChanges(*questionID)
+	case "broadcasts":
+		err = runListBroadcasts()
+	case "results":
+		err = runResults(*tournamentID, *limit, *authors, *editors)
+	case "author-results":
+		err = runAuthorResults(*tournamentID, *minQuestions)
+	case "find-duplicates":
+		err = runFindDuplicates(*threshold)
+	case "duplicates":
+		err = runListDuplicates(*limit)
+	case "search":
+		err = runSearch(*tournamentID, *text, *limit)
+	default:
+		fmt.Printf("Unknown command: %s\n", *command)
+		printUsage()
+		os.Exit(1)
+	}
+
+	if err != nil {
+		log.Fatalf("Command failed: %v", err)
+	}
+}
+
+func printUsage() {
+	fmt.Println("Questions Vote Admin")
+	fmt.Println()
+	fmt.Println("Usage:")
+	fmt.Println("  admin -command=position-bias [-tournament-id=ID] [-min-votes=20]")
+	fmt.Println("    Shows how often the question shown first wins, overall and per user")
+	fmt.Println()
+	fmt.Println("  admin -command=voter-quality [-tournament-id=ID] [-recompute] [-all]")
+	fmt.Println("    Lists users whose voting looks random, too fast or like pressing one button")
+	fmt.Println()
+	fmt.Println("  admin -command=list-users [-role=admin] [-status=banned]")
+	fmt.Println("    Lists users of the bot")
+	fmt.Println()
+	fmt.Println("  admin -command=set-role -user-id=ID -role=admin|voter")
+	fmt.Println("    Changes the role of a user")
+	fmt.Println()
+	fmt.Println("  admin -command=ban|shadow-ban|unban -user-id=ID [-reason=TEXT]")
+	fmt.Println("    Blocks a user, records their votes without counting them, or reinstates them")
+	fmt.Println()
+	fmt.Println("  admin -command=allow|disallow -user-id=ID [-tournament-id=ID]")
+	fmt.Println("    Adds a user to or removes them from the allowlist of a tournament")
+	fmt.Println()
+	fmt.Println("  admin -command=set-access [-tournament-id=ID] [-invite-only] [-invite-code=CODE]")
+	fmt.Println("    Opens a tournament to everyone or restricts it to invited users")
+	fmt.Println()
+	fmt.Println("  admin -command=notify -text=TEXT [-audience=voters|all] [-tournament-id=ID]")
+	fmt.Println("    Queues a message for the voters of a tournament or for every user; the bot sends it")
+	fmt.Println()
+	fmt.Println("  admin -command=broadcasts")
+	fmt.Println("    Shows the delivery log of recent notifications")
+	fmt.Println()
+	fmt.Println("  admin -command=question-changes [-question-id=ID]")
+	fmt.Println("    Shows what re-imports changed in questions")
+	fmt.Println()
+	fmt.Println("  admin -command=results [-tournament-id=ID] [-limit=20] [-authors=LIST] [-editors=LIST]")
+	fmt.Println("    Lists the top questions with their authors, optionally only by some authors or editors")
+	fmt.Println()
+	fmt.Println("  admin -command=author-results [-tournament-id=ID] [-min-questions=3]")
+	fmt.Println("    Ranks authors by the average rating of their questions")
+	fmt.Println()
+	fmt.Println("  admin -command=find-duplicates [-threshold=0.7]")
+	fmt.Println("    Groups questions repeated in several packages, by equal text or similar wording")
+	fmt.Println()
+	fmt.Println("  admin -command=duplicates [-limit=20]")
+	fmt.Println("    Lists the largest groups of duplicate questions, the earliest copy first")
+	fmt.Println()
+	fmt.Println("  admin -command=search -text=WORDS [-tournament-id=ID] [-limit=20]")
+	fmt.Println("    Finds questions by text, answer, comment or source, with their tournament ratings")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  admin -command=position-bias")
+	fmt.Println("  admin -command=position-bias -tournament-id=3 -min-votes=50")
+	fmt.Println("  admin -command=voter-quality -recompute")
+	fmt.Println("  admin -command=shadow-ban -user-id=123456789 -reason=\"same button\"")
+	fmt.Println("  admin -command=set-access -invite-only -invite-code=club2024")
+	fmt.Println("  admin -command=notify -text=\"Осталось 3 дня!\"")
+	fmt.Println("  admin -command=results -authors=\"Иван Петров\"")
+	fmt.Println("  admin -command=search -text=\"менделеев водк\"")
+}
+
+// resolveTournamentID returns the given tournament ID or the ID of the active tournament
+func resolveTournamentID(tournamentID int) (int, error) {
+	if tournamentID != 0 {
+		return tournamentID, nil
+	}
+
+	tournament, err := models.NewTournamentRepository().FindActiveTournament()
+	if err != nil {
+		return 0, fmt.Errorf("failed to find active tournament: %w", err)
+	}
+
+	return tournament.ID, nil
+}
+
+func runPositionBias(tournamentID, minVotes int) error {
+	tournamentID, err := resolveTournamentID(tournamentID)
+	if err != nil {
+		return err
+	}
+
+	voteRepo := models.NewVoteRepository()
+	overall, err := voteRepo.GetPositionStats(tournamentID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Tournament %d: %d votes, %d skipped\n", tournamentID, overall.Votes, overall.Skips)
+	fmt.Printf("First question won %d times, second %d times\n", overall.FirstWins, overall.SecondWins)
+	fmt.Printf("First position win rate: %.1f%% (advantage %.1f rating points)\n",
+		overall.FirstWinRate()*100, overall.Advantage())
+	fmt.Println()
+
+	users, err := voteRepo.GetPositionStatsByUser(tournamentID, minVotes)
+	if err != nil {
+		return err
+	}
+
+	if len(users) == 0 {
+		fmt.Printf("No users with at least %d votes\n", minVotes)
+		return nil
+	}
+
+	fmt.Printf("%-15s %-8s %-8s %-8s %-8s %-10s\n", "User", "Votes", "First", "Second", "Skips", "First %")
+	fmt.Println(strings.Repeat("-", 62))
+	for _, stats := range users {
+		fmt.Printf("%-15d %-8d %-8d %-8d %-8d %-10.1f\n",
+			stats.UserID, stats.Votes, stats.FirstWins, stats.SecondWins, stats.Skips, stats.FirstWinRate()*100)
+	}
+
+	return nil
+}
+
+func runVoterQuality(tournamentID int, recompute, showAll bool) error {
+	tournamentID, err := resolveTournamentID(tournamentID)
+	if err != nil {
+		return err
+	}
+
+	if recompute {
+		qualities, err := quality.Refresh(tournamentID, 0)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Recomputed quality of %d users\n", len(qualities))
+	}
+
+	users, err := models.NewVoterQualityRepository().List(tournamentID, !showAll)
+	if err != nil {
+		return err
+	}
+
+	if len(users) == 0 {
+		fmt.Printf("No users to show for tournament %d\n", tournamentID)
+		return nil
+	}
+
+	fmt.Printf("%-15s %-8s %-10s %-10s %-8s %-8s %-12s %s\n",
+		"User", "Votes", "Agreement", "Interval", "Streak", "Skips", "Reliability", "Flags")
+	fmt.Println(strings.Repeat("-", 100))
+	for _, q := range users {
+		fmt.Printf("%-15d %-8d %-10.2f %-10.1f %-8d %-8.2f %-12.2f %s\n",
+			q.UserID, q.Votes, q.Agreement, q.MedianInterval, q.LongestStreak, q.SkipRatio,
+			q.Reliability, strings.Join(q.Flags, ", "))
+	}
+
+	return nil
+}
+
+func runListUsers(role, status string) error {
+	users, err := models.NewUserRepository().List(role, status)
+	if err != nil {
+		return err
+	}
+
+	if len(users) == 0 {
+		fmt.Println("No users found")
+		return nil
+	}
+
+	fmt.Printf("%-15s %-20s %-20s %-8s %-15s %s\n", "ID", "Username", "Name", "Role", "Status", "Reason")
+	fmt.Println(strings.Repeat("-", 100))
+	for _, u := range users {
+		fmt.Printf("%-15d %-20s %-20s %-8s %-15s %s\n", u.ID, u.Username, u.FirstName, u.Role, u.Status, u.StatusReason)
+	}
+
+	return nil
+}
+
+func runSetRole(userID int64, role string) error {
+	if userID == 0 || role == "" {
+		return fmt.Errorf("user-id and role are required")
+	}
+
+	err := models.NewUserRepository().SetRole(userID, role)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("User %d is now %s\n", userID, role)
+	return nil
+}
+
+func runSetStatus(userID int64, status, reason string) error {
+	if userID == 0 {
+		return fmt.Errorf("user-id is required")
+	}
+
+	err := models.NewUserRepository().SetStatus(userID, status, reason)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("User %d is now %s\n", userID, status)
+	return nil
+}
+
+func runAllow(tournamentID int, userID int64, allow bool) error {
+	if userID == 0 {
+		return fmt.Errorf("user-id is required")
+	}
+
+	tournamentID, err := resolveTournamentID(tournamentID)
+	if err != nil {
+		return err
+	}
+
+	tournamentRepo := models.NewTournamentRepository()
+	if allow {
+		err = tournamentRepo.AllowUser(tournamentID, userID)
+	} else {
+		err = tournamentRepo.DisallowUser(tournamentID, userID)
+	}
+	if err != nil {
+		return err
+	}
+
+	allowed, err := tournamentRepo.ListAllowedUsers(tournamentID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Tournament %d allowlist has %d users\n", tournamentID, len(allowed))
+	return nil
+}
+
+func runSetAccess(tournamentID int, inviteOnly bool, inviteCode string) error {
+	tournamentID, err := resolveTournamentID(tournamentID)
+	if err != nil {
+		return err
+	}
+
+	if !inviteOnly && inviteCode != "" {
+		return fmt.Errorf("invite-code only makes sense with invite-only")
+	}
+
+	err = models.NewTournamentRepository().SetInviteOnly(tournamentID, inviteOnly, inviteCode)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case !inviteOnly:
+		fmt.Printf("Tournament %d is open to everyone\n", tournamentID)
+	case inviteCode == "":
+		fmt.Printf("Tournament %d is open to allowlisted users only\n", tournamentID)
+	default:
+		fmt.Printf("Tournament %d is open to allowlisted users and holders of code %s\n", tournamentID, inviteCode)
+	}
+
+	return nil
+}
+
+func runNotify(tournamentID int, audience, text string) error {
+	if text == "" {
+		return fmt.Errorf("text is required")
+	}
+
+	broadcast := &models.Broadcast{
+		Audience: audience,
+		Text:     text,
+	}
+
+	if audience == models.AudienceVoters {
+		tournamentID, err := resolveTournamentID(tournamentID)
+		if err != nil {
+			return err
+		}
+		broadcast.TournamentID = &tournamentID
+	}
+
+	repo := models.NewBroadcastRepository()
+	id, err := repo.Create(broadcast)
+	if err != nil {
+		return err
+	}
+
+	progress, err := repo.GetProgress(id)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Queued notification %d for %d users, the bot will send it within a minute\n", id, progress.Total())
+	return nil
+}
+
+func runListBroadcasts() error {
+	repo := models.NewBroadcastRepository()
+	broadcasts, err := repo.ListRecent(20)
+	if err != nil {
+		return err
+	}
+
+	if len(broadcasts) == 0 {
+		fmt.Println("No notifications sent yet")
+		return nil
+	}
+
+	fmt.Printf("%-5s %-17s %-8s %-8s %-8s %-8s %-8s %-8s %-8s %s\n",
+		"ID", "Created", "Audience", "Status", "Total", "Sent", "Pending", "Failed", "Blocked", "Skipped")
+	fmt.Println(strings.Repeat("-", 100))
+	for _, b := range broadcasts {
+		progress, err := repo.GetProgress(b.ID)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("%-5d %-17s %-8s %-8s %-8d %-8d %-8d %-8d %-8d %d\n",
+			b.ID, b.CreatedAt.Format("2006-01-02 15:04"), b.Audience, b.Status, progress.Total(),
+			progress[models.DeliverySent], progress[models.DeliveryPending], progress[models.DeliveryFailed],
+			progress[models.DeliveryBlocked], progress[models.DeliverySkipped])
+	}
+
+	return nil
+}
+
+func runQuestionChanges(questionID int) error {
+	repo := models.NewQuestionChangeRepository()
+
+	var changes []*models.QuestionChange
+	var err error
+	if questionID != 0 {
+		changes, err = repo.GetByQuestion(questionID)
+	} else {
+		changes, err = repo.ListRecent(50)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(changes) == 0 {
+		fmt.Println("No question changes recorded")
+		return nil
+	}
+
+	for _, c := range changes {
+		fmt.Printf("%s  question %d, %s:\n    - %s\n    + %s\n",
+			c.ChangedAt.Format("2006-01-02 15:04"), c.QuestionID, c.Field, c.OldValue, c.NewValue)
+	}
+
+	return nil
+}
+
+func runResults(tournamentID, limit int, authors, editors string) error {
+	tournamentID, err := resolveTournamentID(tournamentID)
+	if err != nil {
+		return err
+	}
+
+	authorRepo := models.NewAuthorRepository()
+	filter, err := authorRepo.ParseFilter(authors, editors)
+	if err != nil {
+		return err
+	}
+
+	questions, err := models.NewTournamentQuestionRepository().GetAllQuestions(tournamentID)
+	if err != nil {
+		return err
+	}
+
+	byID := make(map[int]*models.TournamentQuestion)
+	ids := make([]int, len(questions))
+	for i, tq := range questions {
+		byID[tq.QuestionID] = tq
+		ids[i] = tq.QuestionID
+	}
+
+	ids, err = authorRepo.ApplyFilter(filter, ids)
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		fmt.Printf("No matching questions in tournament %d\n", tournamentID)
+		return nil
+	}
+
+	sort.Slice(ids, func(i, j int) bool { return byID[ids[i]].Rating > byID[ids[j]].Rating })
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+
+	fmt.Printf("%-5s %-8s %-8s %-8s %-8s %-30s %s\n", "#", "Question", "Rating", "Matches", "Wins", "Authors", "Package")
+	fmt.Println(strings.Repeat("-", 100))
+	for i, id := range ids {
+		credits, err := authorRepo.GetCredits(id)
+		if err != nil {
+			return err
+		}
+
+		names := make([]string, len(credits.Authors))
+		for j, author := range credits.Authors {
+			names[j] = author.Name
+		}
+		place := credits.PackageTitle
+		if credits.TourNumber > 0 && credits.Number > 0 {
+			place += fmt.Sprintf(", tour %d, question %d", credits.TourNumber, credits.Number)
+		}
+
+		tq := byID[id]
+		fmt.Printf("%-5d %-8d %-8.1f %-8d %-8d %-30s %s\n",
+			i+1, id, tq.Rating, tq.Matches, tq.Wins, strings.Join(names, ", "), place)
+	}
+
+	return nil
+}
+
+func runAuthorResults(tournamentID, minQuestions int) error {
+	tournamentID, err := resolveTournamentID(tournamentID)
+	if err != nil {
+		return err
+	}
+
+	results, err := models.NewAuthorRepository().GetAuthorResults(tournamentID, minQuestions)
+	if err != nil {
+		return err
+	}
+
+	if len(results) == 0 {
+		fmt.Printf("No authors with at least %d questions in tournament %d\n", minQuestions, tournamentID)
+		return nil
+	}
+
+	fmt.Printf("%-8s %-30s %-10s %-10s %-10s %-8s %-8s\n", "ID", "Author", "Questions", "Average", "Best", "Matches", "Wins")
+	fmt.Println(strings.Repeat("-", 90))
+	for _, r := range results {
+		fmt.Printf("%-8d %-30s %-10d %-10.1f %-10.1f %-8d %-8d\n",
+			r.Author.ID, r.Author.Name, r.Questions, r.AverageRating, r.BestRating, r.Matches, r.Wins)
+	}
+
+	return nil
+}
+
+func runFindDuplicates(threshold float64) error {
+	summary, err := dedup.Rebuild(threshold)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Checked %d questions: %d clusters, %d duplicates\n", summary.Questions, summary.Clusters, summary.Duplicates)
+	return nil
+}
+
+func runListDuplicates(limit int) error {
+	clusters, err := models.NewDuplicateRepository().ListClusters(limit)
+	if err != nil {
+		return err
+	}
+
+	if len(clusters) == 0 {
+		fmt.Println("No duplicates found; run -command=find-duplicates after importing")
+		return nil
+	}
+
+	for _, cluster := range clusters {
+		fmt.Printf("Cluster %d, %d questions:\n", cluster.ID, len(cluster.Members))
+		for _, m := range cluster.Members {
+			date := ""
+			if m.EndDate != nil {
+				date = m.EndDate.Format("2006-01-02")
+			}
+
+			text := []rune(strings.Join(strings.Fields(m.Question), " "))
+			if len(text) > 60 {
+				text = append(text[:60], '…')
+			}
+
+			fmt.Printf("  %-8d %4.0f%%  %-10s %-30s %s\n", m.QuestionID, m.Similarity*100, date, m.PackageTitle, string(text))
+		}
+	}
+
+	return nil
+}
+
+func runSearch(tournamentID int, text string, limit int) error {
+	if strings.TrimSpace(text) == "" {
+		return fmt.Errorf("text is required")
+	}
+
+	// Ratings are optional: without an active tournament the questions are listed without them
+	if tournamentID == 0 {
+		if tournament, err := models.NewTournamentRepository().FindActiveTournament(); err == nil {
+			tournamentID = tournament.ID
+		}
+	}
+
+	results, err := models.NewQuestionRepository().Search(text, tournamentID, limit)
+	if err != nil {
+		return err
+	}
+
+	if len(results) == 0 {
+		fmt.Println("No questions found")
+		return nil
+	}
+
+	fmt.Printf("%-8s %-7s %-9s %s\n", "ID", "Rating", "Wins", "Question")
+	for _, r := range results {
+		rating, wins := "-", "-"
+		if r.Rating != nil {
+			rating = fmt.Sprintf("%.0f", *r.Rating)
+			wins = fmt.Sprintf("%d/%d", r.Wins, r.Matches)
+		}
+
+		preview := []rune(strings.Join(strings.Fields(r.Question.Question), " "))
+		if len(preview) > 60 {
+			preview = append(preview[:60], '…')
+		}
+
+		fmt.Printf("%-8d %-7s %-9s %s\n", r.Question.ID, rating, wins, string(preview))
+		fmt.Printf("%-26s Ответ: %s\n", "", r.Question.Answer)
+	}
+
+	return nil
 }
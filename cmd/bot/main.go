@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
 	"log"
+	"os"
+	"os/signal"
 	"questions-vote/internal/db"
 	"questions-vote/internal/handlers"
+	"syscall"
 
 	"github.com/joho/godotenv"
 )
@@ -22,6 +26,11 @@ func main() {
 	}
 	defer db.Close()
 
+	err = db.Migrate()
+	if err != nil {
+		log.Fatalf("Failed to migrate database: %v", err)
+	}
+
 	log.Println("Database initialized successfully")
 
 	botHandler, err := handlers.NewBotHandler()
@@ -29,7 +38,11 @@ func main() {
 		log.Fatalf("Failed to create bot handler: %v", err)
 	}
 
-	err = botHandler.Run()
+	// Stop gracefully on deploys and machine stops so that state is saved
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err = botHandler.Run(ctx)
 	if err != nil {
 		log.Fatalf("Bot failed to run: %v", err)
 	}
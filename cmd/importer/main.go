@@ -1,23 +1,36 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"questions-vote/internal/db"
 	"questions-vote/internal/importer"
 	"questions-vote/internal/models"
+	"strings"
+	"syscall"
+	"time"
 )
 
 func main() {
 	var (
-		command   = flag.String("command", "", "Command to run: list-packages, import-package, import-year")
+		command   = flag.String("command", "", "Command to run: list-packages, sync-packages, import-package, import-year, import-runs, record-snapshot, import-snapshot, validate, reindex-search")
 		firstPage = flag.Int("first-page", 1, "First page to process for list-packages")
-		lastPage  = flag.Int("last-page", 349, "Last page to process for list-packages")
+		lastPage  = flag.Int("last-page", 349, "Last page to process for list-packages and sync-packages")
 		packageID = flag.Int("package-id", 0, "Package ID to import for import-package")
 		year      = flag.Int("year", 0, "Year to import for import-year")
-		rewrite   = flag.Bool("rewrite", true, "Rewrite existing questions when importing packages")
+		rewrite   = flag.Bool("rewrite", true, "Update questions that are already stored and remove unused ones gone from the site")
+		rps       = flag.Float64("rps", 1, "Maximum requests per second to gotquestions.online (0 for no limit)")
+		timeout   = flag.Duration("timeout", 30*time.Second, "Timeout of a single HTTP request")
+		retries   = flag.Int("retries", 4, "Retries of a failed HTTP request")
+		workers   = flag.Int("workers", importer.DefaultWorkers, "Packages imported at the same time for import-year")
+		runID     = flag.Int("run-id", 0, "Import run to resume for import-year (default: the latest run of the year)")
+		resume    = flag.Bool("resume", false, "Resume an interrupted import-year run instead of starting a new one")
+		retry     = flag.Bool("retry-failed", false, "Resume an import-year run and retry its failed packages")
+		snapshot  = flag.String("snapshot", "", "Snapshot directory (or .zip archive for import-snapshot)")
 	)
 	flag.Parse()
 
@@ -26,6 +39,12 @@ func main() {
 		os.Exit(1)
 	}
 
+	clientConfig := importer.DefaultClientConfig()
+	clientConfig.RequestsPer = *rps
+	clientConfig.Timeout = *timeout
+	clientConfig.MaxRetries = *retries
+	importer.SetDefaultClient(importer.NewClient(clientConfig))
+
 	// Initialize database
 	err := db.Initialize()
 	if err != nil {
@@ -33,19 +52,47 @@ func main() {
 	}
 	defer db.Close()
 
+	err = db.Migrate()
+	if err != nil {
+		log.Fatalf("Failed to migrate database: %v", err)
+	}
+
 	switch *command {
 	case "list-packages":
 		err = runListPackages(*firstPage, *lastPage)
+	case "sync-packages":
+		err = runSyncPackages(*firstPage, *lastPage)
 	case "import-package":
 		if *packageID == 0 {
 			log.Fatal("Package ID is required for import-package command")
 		}
 		err = runImportPackage(*packageID, *rewrite)
 	case "import-year":
-		if *year == 0 {
+		if *year == 0 && *runID == 0 {
 			log.Fatal("Year is required for import-year command")
 		}
-		err = runImportYear(*year, *rewrite)
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		err = runImportYear(ctx, *year, *rewrite, *workers, *runID, *resume, *retry)
+		stop()
+	case "import-runs":
+		err = runListImportRuns()
+	case "record-snapshot":
+		if *snapshot == "" {
+			log.Fatal("Snapshot directory is required for record-snapshot command")
+		}
+		err = runRecordSnapshot(*snapshot, *packageID, *year, *firstPage, *lastPage)
+	case "import-snapshot":
+		if *snapshot == "" {
+			log.Fatal("Snapshot is required for import-snapshot command")
+		}
+		err = runImportSnapshot(*snapshot, *rewrite)
+	case "validate":
+		if *packageID == 0 && *year == 0 {
+			log.Fatal("Package ID or year is required for validate command")
+		}
+		err = runValidate(*packageID, *year)
+	case "reindex-search":
+		err = runReindexSearch()
 	default:
 		fmt.Printf("Unknown command: %s\n", *command)
 		printUsage()
@@ -64,16 +111,48 @@ func printUsage() {
 	fmt.Println("  importer -command=list-packages [-first-page=1] [-last-page=337]")
 	fmt.Println("    Updates the list of packages in the database")
 	fmt.Println()
+	fmt.Println("  importer -command=sync-packages [-last-page=349]")
+	fmt.Println("    Adds new and updates changed packages, walking from the newest page until a page has no changes")
+	fmt.Println()
 	fmt.Println("  importer -command=import-package -package-id=ID [-rewrite=true]")
 	fmt.Println("    Fetches questions for a specific package ID")
 	fmt.Println()
-	fmt.Println("  importer -command=import-year -year=YEAR [-rewrite=true]")
+	fmt.Println("  importer -command=import-year -year=YEAR [-rewrite=true] [-workers=4]")
 	fmt.Println("    Fetches questions from all packages for a specific year")
 	fmt.Println()
+	fmt.Println("  importer -command=import-year -year=YEAR -resume [-retry-failed] [-run-id=ID]")
+	fmt.Println("    Continues an interrupted import run, optionally retrying failed packages")
+	fmt.Println()
+	fmt.Println("  importer -command=import-runs")
+	fmt.Println("    Lists recent import runs and their progress")
+	fmt.Println()
+	fmt.Println("  importer -command=record-snapshot -snapshot=DIR [-package-id=ID | -year=YEAR | -first-page=1 -last-page=10]")
+	fmt.Println("    Saves package pages and pictures (or list pages) to a snapshot directory")
+	fmt.Println()
+	fmt.Println("  importer -command=import-snapshot -snapshot=DIR|ARCHIVE.zip [-rewrite=true]")
+	fmt.Println("    Imports the list pages and packages saved in a snapshot, without network access")
+	fmt.Println()
+	fmt.Println("  importer -command=validate -package-id=ID | -year=YEAR")
+	fmt.Println("    Checks stored questions for empty answers, missing sources, failed pictures and HTML")
+	fmt.Println("    artifacts, stores the findings and prints them; imports validate their package too")
+	fmt.Println()
+	fmt.Println("  importer -command=reindex-search")
+	fmt.Println("    Rebuilds the full-text search index of questions (needs a build with -tags sqlite_fts5)")
+	fmt.Println()
+	fmt.Println("Network options (all commands):")
+	fmt.Println("  -rps=1       Maximum requests per second")
+	fmt.Println("  -timeout=30s Timeout of a single request")
+	fmt.Println("  -retries=4   Retries of a request failing with a network error, 429 or 5xx")
+	fmt.Println()
 	fmt.Println("Examples:")
 	fmt.Println("  importer -command=list-packages")
+	fmt.Println("  importer -command=sync-packages")
 	fmt.Println("  importer -command=import-package -package-id=5220")
 	fmt.Println("  importer -command=import-year -year=2022")
+	fmt.Println("  importer -command=import-year -year=2022 -retry-failed")
+	fmt.Println("  importer -command=record-snapshot -snapshot=snapshots/5220 -package-id=5220")
+	fmt.Println("  importer -command=import-snapshot -snapshot=snapshots/5220")
+	fmt.Println("  importer -command=validate -year=2022")
 }
 
 func runListPackages(firstPage, lastPage int) error {
@@ -89,6 +168,24 @@ func runListPackages(firstPage, lastPage int) error {
 	return nil
 }
 
+func runSyncPackages(firstPage, lastPage int) error {
+	lister := importer.NewPackageLister(firstPage, lastPage)
+	summary, err := lister.Sync()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Checked %d pages: %d new, %d changed packages\n", summary.Pages, len(summary.New), len(summary.Changed))
+	for _, pkg := range summary.New {
+		fmt.Printf("  new      %-6d %s (%s)\n", pkg.GotQuestionsID, pkg.Title, pkg.EndDate.Format("2006-01-02"))
+	}
+	for _, change := range summary.Changed {
+		fmt.Printf("  changed  %-6d %s: %s\n", change.Package.GotQuestionsID, change.Package.Title, strings.Join(change.Fields, ", "))
+	}
+
+	return nil
+}
+
 func runImportPackage(packageID int, rewrite bool) error {
 	log.Printf("Starting import of package %d (rewrite: %v)", packageID, rewrite)
 
@@ -102,65 +199,221 @@ func runImportPackage(packageID int, rewrite bool) error {
 	return nil
 }
 
-func runImportYear(year int, rewrite bool) error {
-	log.Printf("Starting import of all packages for year %d (rewrite: %v)", year, rewrite)
+func runImportYear(ctx context.Context, year int, rewrite bool, workers, runID int, resume, retryFailed bool) error {
+	yearImporter := importer.NewYearImporter(workers)
 
-	// Get all packages for the year
-	repo := models.NewPackageRepository()
-	packages, err := repo.GetPackagesByYear(year)
+	if resume || retryFailed || runID != 0 {
+		if runID == 0 {
+			run, err := yearImporter.FindLatestRun(year)
+			if err != nil {
+				return fmt.Errorf("failed to find import run for year %d: %w", year, err)
+			}
+			if run == nil {
+				return fmt.Errorf("no import run to resume for year %d", year)
+			}
+			runID = run.ID
+		}
+
+		summary, err := yearImporter.Resume(ctx, runID, retryFailed)
+		return reportImportSummary(summary, err)
+	}
+
+	log.Printf("Starting import of all packages for year %d (rewrite: %v, workers: %d)", year, rewrite, workers)
+
+	summary, err := yearImporter.Start(ctx, year, rewrite)
+	return reportImportSummary(summary, err)
+}
+
+// reportImportSummary prints the outcome of an import run
+func reportImportSummary(summary *importer.ImportSummary, err error) error {
+	if summary == nil {
+		return err
+	}
+
+	run := summary.Run
+	progress := summary.Progress
+	fmt.Printf("Import run %d, year %d: %s in %v\n", run.ID, run.Year, run.Status, summary.Duration.Round(time.Second))
+	fmt.Printf("  Packages: %d\n", progress.Total())
+	fmt.Printf("  Imported: %d\n", progress[models.ImportItemDone])
+	fmt.Printf("  Skipped:  %d\n", progress[models.ImportItemSkipped])
+	fmt.Printf("  Failed:   %d\n", progress[models.ImportItemFailed])
+	if left := progress[models.ImportItemPending] + progress[models.ImportItemRunning]; left > 0 {
+		fmt.Printf("  Left:     %d\n", left)
+	}
+
+	for _, item := range summary.Failed {
+		fmt.Printf("    package %d (%d attempts): %s\n", item.PackageID, item.Attempts, item.Error)
+	}
+
+	if err != nil {
+		fmt.Printf("Interrupted; continue with -resume -run-id=%d\n", run.ID)
+		return err
+	}
+	if len(summary.Failed) > 0 {
+		fmt.Printf("Retry the failed packages with -retry-failed -run-id=%d\n", run.ID)
+		return fmt.Errorf("completed with %d errors out of %d packages", len(summary.Failed), progress.Total())
+	}
+
+	return nil
+}
+
+func runListImportRuns() error {
+	repo := models.NewImportRunRepository()
+	runs, err := repo.ListRecent(20)
 	if err != nil {
-		return fmt.Errorf("failed to get packages for year %d: %w", year, err)
+		return fmt.Errorf("failed to list import runs: %w", err)
 	}
 
-	if len(packages) == 0 {
-		log.Printf("No packages found for year %d", year)
+	if len(runs) == 0 {
+		fmt.Println("No import runs")
 		return nil
 	}
 
-	log.Printf("Found %d packages for year %d", len(packages), year)
+	fmt.Printf("%-5s %-6s %-8s %-8s %-20s %s\n", "ID", "Year", "Rewrite", "Status", "Started", "Progress")
+	for _, run := range runs {
+		progress, err := repo.GetProgress(run.ID)
+		if err != nil {
+			return fmt.Errorf("failed to get progress of run %d: %w", run.ID, err)
+		}
 
-	// Check if packages already have questions (if not rewriting)
-	questionRepo := models.NewQuestionRepository()
+		fmt.Printf("%-5d %-6d %-8v %-8s %-20s done %d, skipped %d, failed %d, left %d of %d\n",
+			run.ID, run.Year, run.Rewrite, run.Status, run.CreatedAt.Format("2006-01-02 15:04:05"),
+			progress[models.ImportItemDone], progress[models.ImportItemSkipped], progress[models.ImportItemFailed],
+			progress[models.ImportItemPending]+progress[models.ImportItemRunning], progress.Total())
+	}
 
-	successCount := 0
-	errorCount := 0
+	return nil
+}
 
-	for i, pkg := range packages {
-		log.Printf("Processing package %d/%d: %d (%s)", i+1, len(packages), pkg.GotQuestionsID, pkg.Title)
+func runRecordSnapshot(dir string, packageID, year, firstPage, lastPage int) error {
+	recorder := importer.NewRecordingSource(importer.DefaultSource(), dir)
 
-		// Check if package already has questions
-		if !rewrite {
-			hasQuestions, err := questionRepo.HasQuestionsFromPackage(pkg.GotQuestionsID)
+	var packageIDs []int
+	switch {
+	case packageID != 0:
+		packageIDs = []int{packageID}
+	case year != 0:
+		packages, err := models.NewPackageRepository().GetPackagesByYear(year)
+		if err != nil {
+			return fmt.Errorf("failed to get packages for year %d: %w", year, err)
+		}
+		for _, pkg := range packages {
+			packageIDs = append(packageIDs, pkg.GotQuestionsID)
+		}
+	default:
+		for page := firstPage; page <= lastPage; page++ {
+			err := importer.RecordListPage(recorder, page)
 			if err != nil {
-				log.Printf("Failed to check if package %d has questions: %v", pkg.GotQuestionsID, err)
-				continue
-			}
-			if hasQuestions {
-				log.Printf("Skipping package %d (already has questions)", pkg.GotQuestionsID)
-				continue
+				return err
 			}
 		}
+		log.Printf("Recorded list pages %d-%d to %s", firstPage, lastPage, dir)
+		return nil
+	}
+
+	failed := 0
+	for _, id := range packageIDs {
+		err := importer.RecordPackage(recorder, id)
+		if err != nil {
+			log.Printf("%v", err)
+			failed++
+		}
+	}
+
+	log.Printf("Recorded %d packages to %s", len(packageIDs)-failed, dir)
+	if failed > 0 {
+		return fmt.Errorf("failed to record %d of %d packages", failed, len(packageIDs))
+	}
+	return nil
+}
+
+func runImportSnapshot(path string, rewrite bool) error {
+	snapshot, err := importer.OpenSnapshot(path)
+	if err != nil {
+		return err
+	}
+	defer snapshot.Close()
+
+	summary, err := importer.ImportSnapshot(snapshot, rewrite)
+	if err != nil {
+		return fmt.Errorf("failed to import snapshot: %w", err)
+	}
+
+	fmt.Printf("Imported %d list pages and %d packages from %s\n", summary.ListPages, summary.Packages, path)
+	for id, err := range summary.Failed {
+		fmt.Printf("  package %d: %v\n", id, err)
+	}
+
+	if len(summary.Failed) > 0 {
+		return fmt.Errorf("failed to import %d packages", len(summary.Failed))
+	}
+	return nil
+}
+
+func runValidate(packageID, year int) error {
+	var packages []*models.Package
+	if packageID != 0 {
+		pkg, err := models.NewPackageRepository().FindByGotQuestionsID(packageID)
+		if err != nil {
+			return fmt.Errorf("failed to find package %d: %w", packageID, err)
+		}
+		packages = []*models.Package{pkg}
+	} else {
+		var err error
+		packages, err = models.NewPackageRepository().GetPackagesByYear(year)
+		if err != nil {
+			return fmt.Errorf("failed to get packages for year %d: %w", year, err)
+		}
+	}
 
-		// Import the package
-		parser := importer.NewPackageParser(pkg.GotQuestionsID, rewrite)
-		err = parser.ImportPackage()
+	counts := make(map[string]int)
+	flagged := 0
+	for _, pkg := range packages {
+		issues, err := importer.ValidatePackage(pkg.GotQuestionsID)
 		if err != nil {
-			log.Printf("Failed to import package %d: %v", pkg.GotQuestionsID, err)
-			errorCount++
+			return fmt.Errorf("failed to validate package %d: %w", pkg.GotQuestionsID, err)
+		}
+		if len(issues) == 0 {
 			continue
 		}
 
-		successCount++
+		byQuestion := make(map[int][]string)
+		var questionIDs []int
+		for _, issue := range issues {
+			if byQuestion[issue.QuestionID] == nil {
+				questionIDs = append(questionIDs, issue.QuestionID)
+			}
+			text := issue.Kind
+			if issue.Detail != "" {
+				text += " (" + issue.Detail + ")"
+			}
+			byQuestion[issue.QuestionID] = append(byQuestion[issue.QuestionID], text)
+			counts[issue.Kind]++
+		}
+		flagged += len(questionIDs)
+
+		fmt.Printf("Package %d %s: %d questions with issues\n", pkg.GotQuestionsID, pkg.Title, len(questionIDs))
+		for _, id := range questionIDs {
+			fmt.Printf("  question %-6d %s\n", id, strings.Join(byQuestion[id], ", "))
+		}
+	}
 
-		// Sleep between packages to avoid rate limiting
-		importer.SleepAround(1.0, 0.7)
+	fmt.Printf("Checked %d packages: %d questions with issues\n", len(packages), flagged)
+	for _, kind := range models.IssueKinds {
+		if counts[kind] > 0 {
+			fmt.Printf("  %-15s %d\n", kind, counts[kind])
+		}
 	}
 
-	log.Printf("Year %d import completed: %d successful, %d errors", year, successCount, errorCount)
+	return nil
+}
 
-	if errorCount > 0 {
-		return fmt.Errorf("completed with %d errors out of %d packages", errorCount, len(packages))
+func runReindexSearch() error {
+	count, err := db.RebuildSearchIndex()
+	if err != nil {
+		return err
 	}
 
+	fmt.Printf("Indexed %d questions for search\n", count)
 	return nil
 }
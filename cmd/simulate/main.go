@@ -0,0 +1,142 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"questions-vote/internal/models"
+	"questions-vote/internal/simulation"
+	"strings"
+)
+
+func main() {
+	defaults := simulation.DefaultConfig()
+
+	var (
+		questions              = flag.Int("questions", defaults.Questions, "Number of synthetic questions")
+		votes                  = flag.Int("votes", defaults.Votes, "Number of votes to simulate")
+		checkEvery             = flag.Int("check-every", defaults.CheckEvery, "Measure the ranking every N votes")
+		targetPrecision        = flag.Float64("target-precision", defaults.TargetPrecision, "Precision of the top N that counts as recovered")
+		qualitySpread          = flag.Float64("quality-spread", defaults.QualitySpread, "Standard deviation of latent question quality in rating points")
+		seed                   = flag.Int64("seed", defaults.Seed, "Seed for question quality and voter behaviour")
+		runs                   = flag.Int("runs", 1, "Number of runs with consecutive seeds to average over")
+		selection              = flag.String("selection", defaults.Tournament.SelectionStrategy, "Pair selection strategy: random, adaptive")
+		initialK               = flag.Float64("initial-k", defaults.Tournament.InitialK, "K-factor during the initial phase")
+		minimumK               = flag.Float64("minimum-k", defaults.Tournament.MinimumK, "K-factor after the transition phase")
+		stdDevMultiplier       = flag.Float64("std-dev-multiplier", defaults.Tournament.StdDevMultiplier, "Threshold distance below the top N in standard deviations")
+		initialPhaseMatches    = flag.Int("initial-phase-matches", defaults.Tournament.InitialPhaseMatches, "Matches before a question qualifies")
+		transitionPhaseMatches = flag.Int("transition-phase-matches", defaults.Tournament.TransitionPhaseMatches, "Matches before K drops to the minimum")
+		topN                   = flag.Int("top-n", defaults.Tournament.TopN, "Size of the shortlist")
+		voters                 = flag.Int("voters", defaults.Voters.Count, "Number of distinct voters")
+		positionBias           = flag.Float64("position-bias", defaults.Voters.PositionBias, "Extra probability of picking the first question")
+		skipRate               = flag.Float64("skip-rate", defaults.Voters.SkipRate, "Probability of skipping a pair")
+		minAccuracy            = flag.Float64("min-accuracy", defaults.Voters.MinAccuracy, "Lowest share of votes cast by quality rather than at random")
+		maxAccuracy            = flag.Float64("max-accuracy", defaults.Voters.MaxAccuracy, "Highest share of votes cast by quality rather than at random")
+	)
+	flag.Parse()
+
+	if *selection != models.SelectionRandom && *selection != models.SelectionAdaptive {
+		fmt.Printf("Unknown selection strategy: %s\n", *selection)
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	if *runs < 1 {
+		log.Fatal("runs must be at least 1")
+	}
+
+	cfg := simulation.Config{
+		Questions:       *questions,
+		Votes:           *votes,
+		CheckEvery:      *checkEvery,
+		TargetPrecision: *targetPrecision,
+		QualitySpread:   *qualitySpread,
+		Voters: simulation.VoterModel{
+			Count:        *voters,
+			PositionBias: *positionBias,
+			SkipRate:     *skipRate,
+			MinAccuracy:  *minAccuracy,
+			MaxAccuracy:  *maxAccuracy,
+		},
+		Tournament: models.Tournament{
+			Name:                   "Simulation",
+			InitialK:               *initialK,
+			MinimumK:               *minimumK,
+			StdDevMultiplier:       *stdDevMultiplier,
+			InitialPhaseMatches:    *initialPhaseMatches,
+			TransitionPhaseMatches: *transitionPhaseMatches,
+			TopN:                   *topN,
+			BandSize:               defaults.Tournament.BandSize,
+			SelectionStrategy:      *selection,
+		},
+	}
+
+	err := runSimulations(cfg, *seed, *runs)
+	if err != nil {
+		log.Fatalf("Simulation failed: %v", err)
+	}
+}
+
+func runSimulations(cfg simulation.Config, firstSeed int64, runs int) error {
+	var results []*simulation.Result
+
+	for i := 0; i < runs; i++ {
+		cfg.Seed = firstSeed + int64(i)
+
+		result, err := simulation.Run(cfg)
+		if err != nil {
+			return fmt.Errorf("run with seed %d: %w", cfg.Seed, err)
+		}
+		results = append(results, result)
+	}
+
+	printCheckpoints(results)
+	printSummary(cfg, results)
+	return nil
+}
+
+func printCheckpoints(results []*simulation.Result) {
+	fmt.Printf("%-10s %-10s %-12s %-10s\n", "Votes", "Skips", "Precision", "Retained")
+	fmt.Println(strings.Repeat("-", 45))
+
+	for i, checkpoint := range results[0].Checkpoints {
+		var skips, precision, retained float64
+		for _, result := range results {
+			skips += float64(result.Checkpoints[i].Skips)
+			precision += result.Checkpoints[i].Precision
+			retained += result.Checkpoints[i].TopNRetained
+		}
+
+		n := float64(len(results))
+		fmt.Printf("%-10d %-10.0f %-12.3f %-10.3f\n", checkpoint.Votes, skips/n, precision/n, retained/n)
+	}
+}
+
+func printSummary(cfg simulation.Config, results []*simulation.Result) {
+	var precision, retained float64
+	var recovered, votesNeeded int
+
+	for _, result := range results {
+		precision += result.FinalPrecision()
+		retained += result.FinalTopNRetained()
+		if result.VotesToRecover > 0 {
+			recovered++
+			votesNeeded += result.VotesToRecover
+		}
+	}
+
+	n := float64(len(results))
+	fmt.Println()
+	fmt.Printf("Selection: %s, runs: %d\n", cfg.Tournament.SelectionStrategy, len(results))
+	fmt.Printf("Final precision of top %d: %.3f\n", cfg.Tournament.TopN, precision/n)
+	fmt.Printf("Share of true top %d in contention: %.3f\n", cfg.Tournament.TopN, retained/n)
+
+	if recovered == 0 {
+		fmt.Printf("Precision %.2f was not reached within %d votes\n", cfg.TargetPrecision, cfg.Votes)
+		return
+	}
+
+	fmt.Printf("Votes needed for precision %.2f: %d on average (reached in %d of %d runs)\n",
+		cfg.TargetPrecision, votesNeeded/recovered, recovered, len(results))
+}
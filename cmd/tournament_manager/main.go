@@ -14,11 +14,20 @@ import (
 
 func main() {
 	var (
-		command         = flag.String("command", "", "Command to run: create-tournament, list-tournaments, activate-tournament, deactivate-tournament")
+		command         = flag.String("command", "", "Command to run: create-tournament, list-tournaments, activate-tournament, deactivate-tournament, set-end-date")
 		earliestDate    = flag.String("earliest-date", "", "Earliest package date (YYYY-MM-DD)")
 		lastDate        = flag.String("last-date", "", "Last package date (YYYY-MM-DD)")
 		tournamentTitle = flag.String("title", "", "Tournament title")
 		tournamentID    = flag.String("id", "", "Tournament ID")
+		selection       = flag.String("selection", models.SelectionRandom, "Pair selection strategy for create-tournament: random, adaptive")
+		correctPosition = flag.Bool("position-correction", false, "Correct ratings for the measured first-position advantage")
+		weightVotes     = flag.Bool("weight-votes", false, "Weight votes by the measured reliability of the voter")
+		inviteCode      = flag.String("invite-code", "", "Make the tournament invite-only with this invite code")
+		authors         = flag.String("authors", "", "Only questions by these authors (comma-separated IDs or names)")
+		editors         = flag.String("editors", "", "Only questions from packages or tours edited by these editors (comma-separated IDs or names)")
+		excludeIssues   = flag.String("exclude-issues", "", "Leave out questions flagged by the import validation: all or a comma-separated list of issue kinds")
+		dedup           = flag.Bool("dedup", false, "Keep one question of each cluster of duplicates found by admin -command=find-duplicates")
+		endsAt          = flag.String("ends-at", "", "When the tournament closes (YYYY-MM-DD or \"YYYY-MM-DD HH:MM\", local time)")
 	)
 	flag.Parse()
 
@@ -33,12 +42,30 @@ func main() {
 	}
 	defer db.Close()
 
+	err = db.Migrate()
+	if err != nil {
+		log.Fatalf("Failed to migrate database: %v", err)
+	}
+
 	switch *command {
 	case "create-tournament":
 		if *earliestDate == "" || *lastDate == "" || *tournamentTitle == "" {
 			log.Fatal("earliest-date, last-date, and title are required for create-tournament command")
 		}
-		err = runCreateTournament(*earliestDate, *lastDate, *tournamentTitle)
+		err = runCreateTournament(createOptions{
+			earliestDate:    *earliestDate,
+			lastDate:        *lastDate,
+			title:           *tournamentTitle,
+			selection:       *selection,
+			correctPosition: *correctPosition,
+			weightVotes:     *weightVotes,
+			inviteCode:      *inviteCode,
+			authors:         *authors,
+			editors:         *editors,
+			excludeIssues:   *excludeIssues,
+			dedup:           *dedup,
+			endsAt:          *endsAt,
+		})
 	case "list-tournaments":
 		err = runListTournaments()
 	case "activate-tournament":
@@ -51,6 +78,11 @@ func main() {
 			log.Fatal("id is required for deactivate-tournament command")
 		}
 		err = runDeactivateTournament(*tournamentID)
+	case "set-end-date":
+		if *tournamentID == "" {
+			log.Fatal("id is required for set-end-date command")
+		}
+		err = runSetEndDate(*tournamentID, *endsAt)
 	default:
 		fmt.Printf("Unknown command: %s\n", *command)
 		printUsage()
@@ -66,8 +98,16 @@ func printUsage() {
 	fmt.Println("Questions Vote Tournament Manager")
 	fmt.Println()
 	fmt.Println("Usage:")
-	fmt.Println("  tournament_manager -command=create-tournament -earliest-date=YYYY-MM-DD -last-date=YYYY-MM-DD -title=\"Tournament Name\"")
+	fmt.Println("  tournament_manager -command=create-tournament -earliest-date=YYYY-MM-DD -last-date=YYYY-MM-DD -title=\"Tournament Name\" [-selection=random] [-position-correction] [-weight-votes] [-invite-code=CODE] [-authors=LIST] [-editors=LIST] [-exclude-issues=all] [-dedup] [-ends-at=YYYY-MM-DD]")
 	fmt.Println("    Creates a new tournament with questions from packages between the specified dates")
+	fmt.Println("    -selection=adaptive prefers informative pairs near the top-N boundary")
+	fmt.Println("    -position-correction discounts wins of the question shown first by the measured advantage")
+	fmt.Println("    -weight-votes scales each vote by the reliability of the voter")
+	fmt.Println("    -invite-code makes the tournament invite-only, users join with /join CODE")
+	fmt.Println("    -authors and -editors keep only questions by the authors or edited by the editors (IDs or names)")
+	fmt.Println("    -exclude-issues leaves out questions flagged by importer -command=validate (all, or kinds such as empty_answer,missing_image)")
+	fmt.Println("    -dedup keeps one copy of questions repeated in several packages, the earliest if it is in the range")
+	fmt.Println("    -ends-at makes the bot remind voters before and close the tournament at that time")
 	fmt.Println()
 	fmt.Println("  tournament_manager -command=list-tournaments")
 	fmt.Println("    Lists all tournaments with their status")
@@ -78,20 +118,57 @@ func printUsage() {
 	fmt.Println("  tournament_manager -command=deactivate-tournament -id=TOURNAMENT_ID")
 	fmt.Println("    Deactivates a tournament by ID")
 	fmt.Println()
+	fmt.Println("  tournament_manager -command=set-end-date -id=TOURNAMENT_ID [-ends-at=\"YYYY-MM-DD HH:MM\"]")
+	fmt.Println("    Sets when a tournament closes, without -ends-at the tournament never closes by itself")
+	fmt.Println()
 	fmt.Println("Examples:")
 	fmt.Println("  tournament_manager -command=create-tournament -earliest-date=2023-01-01 -last-date=2023-12-31 -title=\"2023 Tournament\"")
+	fmt.Println("  tournament_manager -command=create-tournament -earliest-date=2023-01-01 -last-date=2023-12-31 -title=\"Вопросы Ивана Петрова\" -authors=\"Иван Петров\"")
 	fmt.Println("  tournament_manager -command=list-tournaments")
 	fmt.Println("  tournament_manager -command=activate-tournament -id=1")
 	fmt.Println("  tournament_manager -command=deactivate-tournament -id=1")
+	fmt.Println("  tournament_manager -command=set-end-date -id=1 -ends-at=\"2024-06-30 21:00\"")
+}
+
+// parseEndsAt parses a date or a date with time in local time, an empty value means no end
+func parseEndsAt(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	for _, layout := range []string{"2006-01-02 15:04", "2006-01-02"} {
+		endsAt, err := time.ParseInLocation(layout, value, time.Local)
+		if err == nil {
+			return &endsAt, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid end date %q, expected YYYY-MM-DD or \"YYYY-MM-DD HH:MM\"", value)
 }
 
-func runCreateTournament(earliestDateStr, lastDateStr, title string) error {
-	earliestDate, err := time.Parse("2006-01-02", earliestDateStr)
+// createOptions are the command line options of create-tournament
+type createOptions struct {
+	earliestDate    string // YYYY-MM-DD
+	lastDate        string // YYYY-MM-DD
+	title           string
+	selection       string
+	correctPosition bool
+	weightVotes     bool
+	inviteCode      string
+	authors         string // comma-separated IDs or names
+	editors         string // comma-separated IDs or names
+	excludeIssues   string // all or comma-separated issue kinds
+	dedup           bool
+	endsAt          string // see parseEndsAt
+}
+
+// runCreateTournament collects the questions first and creates the tournament only if some are left
+func runCreateTournament(opts createOptions) error {
+	earliestDate, err := time.Parse("2006-01-02", opts.earliestDate)
 	if err != nil {
 		return fmt.Errorf("invalid earliest date format: %w", err)
 	}
 
-	lastDate, err := time.Parse("2006-01-02", lastDateStr)
+	lastDate, err := time.Parse("2006-01-02", opts.lastDate)
 	if err != nil {
 		return fmt.Errorf("invalid last date format: %w", err)
 	}
@@ -100,26 +177,31 @@ func runCreateTournament(earliestDateStr, lastDateStr, title string) error {
 		return fmt.Errorf("last date must be after earliest date")
 	}
 
-	log.Printf("Creating tournament '%s' with packages from %s to %s", title, earliestDateStr, lastDateStr)
+	if opts.selection != models.SelectionRandom && opts.selection != models.SelectionAdaptive {
+		return fmt.Errorf("unknown selection strategy: %s", opts.selection)
+	}
 
-	tournament := &models.Tournament{
-		Name:                   title,
-		InitialK:               64.0,
-		MinimumK:               16.0,
-		StdDevMultiplier:       1.5,
-		InitialPhaseMatches:    5,
-		TransitionPhaseMatches: 10,
-		TopN:                   100,
-		BandSize:               200,
+	endsAt, err := parseEndsAt(opts.endsAt)
+	if err != nil {
+		return err
+	}
+	if endsAt != nil && !endsAt.After(time.Now()) {
+		return fmt.Errorf("end date %s is in the past", opts.endsAt)
 	}
 
-	tournamentRepo := models.NewTournamentRepository()
-	tournamentID, err := tournamentRepo.Create(tournament)
+	authorRepo := models.NewAuthorRepository()
+	filter, err := authorRepo.ParseFilter(opts.authors, opts.editors)
 	if err != nil {
-		return fmt.Errorf("failed to create tournament: %w", err)
+		return fmt.Errorf("invalid author filter: %w", err)
 	}
 
-	log.Printf("Tournament created with ID: %d", tournamentID)
+	issueKinds, err := models.ParseIssueKinds(opts.excludeIssues)
+	if err != nil {
+		return err
+	}
+	issueRepo := models.NewQuestionIssueRepository()
+
+	log.Printf("Creating tournament '%s' with packages from %s to %s", opts.title, opts.earliestDate, opts.lastDate)
 
 	packageRepo := models.NewPackageRepository()
 	packages, err := packageRepo.GetPackagesByDateRange(earliestDate, lastDate)
@@ -128,31 +210,80 @@ func runCreateTournament(earliestDateStr, lastDateStr, title string) error {
 	}
 
 	if len(packages) == 0 {
-		log.Println("No packages found in the specified date range")
-		return nil
+		return fmt.Errorf("no packages found in the specified date range")
 	}
 
 	log.Printf("Found %d packages in date range", len(packages))
 
 	questionRepo := models.NewQuestionRepository()
 	var allQuestionIDs []int
+	excluded := 0
 
 	for _, pkg := range packages {
 		questionIDs, err := questionRepo.GetQuestionIDsFromPackage(pkg.GotQuestionsID)
+		if err == nil {
+			questionIDs, err = authorRepo.ApplyFilter(filter, questionIDs)
+		}
+		var kept []int
+		if err == nil {
+			kept, err = issueRepo.ExcludeFlagged(issueKinds, questionIDs)
+		}
 		if err != nil {
 			log.Printf("Warning: failed to get questions from package %d (%s): %v", pkg.GotQuestionsID, pkg.Title, err)
 			continue
 		}
-		allQuestionIDs = append(allQuestionIDs, questionIDs...)
-		log.Printf("Added %d questions from package %d (%s)", len(questionIDs), pkg.GotQuestionsID, pkg.Title)
+		excluded += len(questionIDs) - len(kept)
+		allQuestionIDs = append(allQuestionIDs, kept...)
+		log.Printf("Added %d questions from package %d (%s)", len(kept), pkg.GotQuestionsID, pkg.Title)
+	}
+
+	if excluded > 0 {
+		log.Printf("Left out %d questions flagged by the import validation", excluded)
+	}
+
+	if opts.dedup {
+		unique, err := models.NewDuplicateRepository().KeepOnePerCluster(allQuestionIDs)
+		if err != nil {
+			return fmt.Errorf("failed to remove duplicates: %w", err)
+		}
+		log.Printf("Left out %d duplicates of other questions", len(allQuestionIDs)-len(unique))
+		allQuestionIDs = unique
 	}
 
 	if len(allQuestionIDs) == 0 {
+		if !filter.Empty() {
+			return fmt.Errorf("no questions by the given authors or editors found in packages within date range")
+		}
 		return fmt.Errorf("no questions found in packages within date range")
 	}
 
 	log.Printf("Total questions to add to tournament: %d", len(allQuestionIDs))
 
+	tournament := &models.Tournament{
+		Name:                   opts.title,
+		InitialK:               64.0,
+		MinimumK:               16.0,
+		StdDevMultiplier:       1.5,
+		InitialPhaseMatches:    5,
+		TransitionPhaseMatches: 10,
+		TopN:                   100,
+		BandSize:               200,
+		SelectionStrategy:      opts.selection,
+		PositionBiasCorrection: opts.correctPosition,
+		WeightVotes:            opts.weightVotes,
+		InviteOnly:             opts.inviteCode != "",
+		InviteCode:             opts.inviteCode,
+		EndsAt:                 endsAt,
+	}
+
+	tournamentRepo := models.NewTournamentRepository()
+	tournamentID, err := tournamentRepo.Create(tournament)
+	if err != nil {
+		return fmt.Errorf("failed to create tournament: %w", err)
+	}
+
+	log.Printf("Tournament created with ID: %d", tournamentID)
+
 	tournamentQuestionRepo := models.NewTournamentQuestionRepository()
 	err = tournamentQuestionRepo.CreateTournamentQuestions(tournamentID, allQuestionIDs, 1500.0)
 	if err != nil {
@@ -164,7 +295,7 @@ func runCreateTournament(earliestDateStr, lastDateStr, title string) error {
 		return fmt.Errorf("failed to update tournament questions count: %w", err)
 	}
 
-	log.Printf("Tournament '%s' created successfully with %d questions", title, len(allQuestionIDs))
+	log.Printf("Tournament '%s' created successfully with %d questions", opts.title, len(allQuestionIDs))
 	return nil
 }
 
@@ -180,14 +311,14 @@ func runListTournaments() error {
 		return nil
 	}
 
-	fmt.Printf("%-5s %-30s %-10s %-15s\n", "ID", "Name", "Active", "Questions")
-	fmt.Println(strings.Repeat("-", 65))
+	fmt.Printf("%-5s %-30s %-10s %-15s %-10s\n", "ID", "Name", "Active", "Questions", "Selection")
+	fmt.Println(strings.Repeat("-", 76))
 	for _, tournament := range tournaments {
 		status := "No"
 		if tournament.Active {
 			status = "Yes"
 		}
-		fmt.Printf("%-5d %-30s %-10s %-15d\n", tournament.ID, tournament.Name, status, tournament.QuestionsCount)
+		fmt.Printf("%-5d %-30s %-10s %-15d %-10s\n", tournament.ID, tournament.Name, status, tournament.QuestionsCount, tournament.SelectionStrategy)
 	}
 
 	return nil
@@ -224,3 +355,28 @@ func runDeactivateTournament(tournamentIDStr string) error {
 	log.Printf("Tournament with ID %d deactivated successfully", tournamentID)
 	return nil
 }
+
+func runSetEndDate(tournamentIDStr, endsAtStr string) error {
+	tournamentID, err := strconv.Atoi(tournamentIDStr)
+	if err != nil {
+		return fmt.Errorf("invalid tournament ID: %w", err)
+	}
+
+	endsAt, err := parseEndsAt(endsAtStr)
+	if err != nil {
+		return err
+	}
+
+	tournamentRepo := models.NewTournamentRepository()
+	err = tournamentRepo.SetEndsAt(tournamentID, endsAt)
+	if err != nil {
+		return err
+	}
+
+	if endsAt == nil {
+		log.Printf("Tournament with ID %d no longer has an end date", tournamentID)
+	} else {
+		log.Printf("Tournament with ID %d ends at %s", tournamentID, endsAt.Format("2006-01-02 15:04"))
+	}
+	return nil
+}
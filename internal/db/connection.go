@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -18,12 +19,30 @@ func Initialize() error {
 		dbPath = "questions.db"
 	}
 
+	return InitializeWithPath(dbPath)
+}
+
+// InitializeWithPath sets up the database connection for the given SQLite path
+func InitializeWithPath(dbPath string) error {
+	dsn := dbPath
+	if dbPath != ":memory:" && !strings.Contains(dbPath, "?") {
+		// Wait for concurrent writers, such as importer workers, instead of failing with "database is locked".
+		// Transactions take the write lock when they begin: a transaction that reads first and then
+		// writes cannot wait for the lock and fails at once if another one has written meanwhile.
+		dsn += "?_busy_timeout=5000&_txlock=immediate"
+	}
+
 	var err error
-	DB, err = sql.Open("sqlite3", dbPath)
+	DB, err = sql.Open("sqlite3", dsn)
 	if err != nil {
 		return fmt.Errorf("failed to open database: %w", err)
 	}
 
+	if dbPath == ":memory:" {
+		// Every connection to :memory: gets its own empty database
+		DB.SetMaxOpenConns(1)
+	}
+
 	err = DB.Ping()
 	if err != nil {
 		return fmt.Errorf("failed to ping database: %w", err)
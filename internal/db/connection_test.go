@@ -0,0 +1,80 @@
+package db
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// TestFileTransactionsWaitForEachOther tests that transactions that read before they write
+// wait for each other on a database file instead of failing with "database is locked"
+func TestFileTransactionsWaitForEachOther(t *testing.T) {
+	err := InitializeWithPath(filepath.Join(t.TempDir(), "questions.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer Close()
+
+	_, err = DB.Exec(`CREATE TABLE counters (id INTEGER PRIMARY KEY, value INTEGER NOT NULL)`)
+	if err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	_, err = DB.Exec(`INSERT INTO counters (id, value) VALUES (1, 0)`)
+	if err != nil {
+		t.Fatalf("Failed to insert counter: %v", err)
+	}
+
+	const workers, rounds = 8, 20
+	errs := make(chan error, workers*rounds)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				errs <- incrementCounter()
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Concurrent transaction failed: %v", err)
+		}
+	}
+
+	var value int
+	err = DB.QueryRow(`SELECT value FROM counters WHERE id = 1`).Scan(&value)
+	if err != nil {
+		t.Fatalf("Failed to read counter: %v", err)
+	}
+	if value != workers*rounds {
+		t.Errorf("Expected counter %d, got %d", workers*rounds, value)
+	}
+}
+
+// incrementCounter reads the counter and writes it back increased in one transaction
+func incrementCounter() error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var value int
+	err = tx.QueryRow(`SELECT value FROM counters WHERE id = 1`).Scan(&value)
+	if err != nil {
+		return fmt.Errorf("failed to read counter: %w", err)
+	}
+
+	_, err = tx.Exec(`UPDATE counters SET value = ? WHERE id = 1`, value+1)
+	if err != nil {
+		return fmt.Errorf("failed to write counter: %w", err)
+	}
+
+	return tx.Commit()
+}
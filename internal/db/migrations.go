@@ -0,0 +1,389 @@
+package db
+
+import (
+	"fmt"
+	"log"
+)
+
+// migration is a single schema change applied in order of its version
+type migration struct {
+	version    int
+	statements []string
+}
+
+// migrations lists all schema changes. Append new entries, never edit applied ones.
+var migrations = []migration{
+	{
+		version: 1,
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS packages (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				gotquestions_id INTEGER UNIQUE,
+				title TEXT,
+				start_date DATETIME,
+				end_date DATETIME,
+				questions_count INTEGER
+			)`,
+			`CREATE TABLE IF NOT EXISTS questions (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				gotquestions_id INTEGER,
+				question TEXT,
+				answer TEXT,
+				accepted_answer TEXT,
+				comment TEXT,
+				handout_str TEXT,
+				source TEXT,
+				author_id INTEGER,
+				package_id INTEGER,
+				difficulty REAL,
+				is_incorrect INTEGER DEFAULT 0
+			)`,
+			`CREATE TABLE IF NOT EXISTS images (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				question_id INTEGER,
+				image_url TEXT,
+				data BLOB,
+				mime_type TEXT
+			)`,
+			`CREATE TABLE IF NOT EXISTS tournaments (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				title TEXT,
+				initial_k REAL,
+				minimum_k REAL,
+				std_dev_multiplier REAL,
+				initial_phase_matches INTEGER,
+				transition_phase_matches INTEGER,
+				top_n INTEGER,
+				questions_count INTEGER,
+				band_size INTEGER,
+				state INTEGER DEFAULT 0
+			)`,
+			`CREATE TABLE IF NOT EXISTS tournament_questions (
+				tournament_id INTEGER,
+				question_id INTEGER,
+				rating REAL,
+				matches INTEGER DEFAULT 0,
+				wins INTEGER DEFAULT 0,
+				PRIMARY KEY (tournament_id, question_id)
+			)`,
+			`CREATE TABLE IF NOT EXISTS votes (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER,
+				question1_id INTEGER,
+				question2_id INTEGER,
+				tournament_id INTEGER,
+				selected_id INTEGER,
+				timestamp DATETIME DEFAULT CURRENT_TIMESTAMP
+			)`,
+		},
+	},
+	{
+		version: 2,
+		statements: []string{
+			`ALTER TABLE tournaments ADD COLUMN selection_strategy TEXT NOT NULL DEFAULT 'random'`,
+		},
+	},
+	{
+		version: 3,
+		statements: []string{
+			`ALTER TABLE tournaments ADD COLUMN position_bias_correction INTEGER NOT NULL DEFAULT 0`,
+			`ALTER TABLE votes ADD COLUMN swapped INTEGER NOT NULL DEFAULT 0`,
+		},
+	},
+	{
+		version: 4,
+		statements: []string{
+			`ALTER TABLE tournaments ADD COLUMN weight_votes INTEGER NOT NULL DEFAULT 0`,
+			`CREATE TABLE IF NOT EXISTS voter_quality (
+				tournament_id INTEGER NOT NULL,
+				user_id INTEGER NOT NULL,
+				votes INTEGER NOT NULL,
+				agreement REAL NOT NULL,
+				median_interval REAL NOT NULL,
+				longest_streak INTEGER NOT NULL,
+				skip_ratio REAL NOT NULL,
+				reliability REAL NOT NULL,
+				flags TEXT NOT NULL DEFAULT '',
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				PRIMARY KEY (tournament_id, user_id)
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_votes_tournament_user ON votes (tournament_id, user_id)`,
+		},
+	},
+	{
+		version: 5,
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS users (
+				id INTEGER PRIMARY KEY,
+				username TEXT NOT NULL DEFAULT '',
+				first_name TEXT NOT NULL DEFAULT '',
+				role TEXT NOT NULL DEFAULT 'voter',
+				status TEXT NOT NULL DEFAULT 'active',
+				status_reason TEXT NOT NULL DEFAULT '',
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			)`,
+			`ALTER TABLE tournaments ADD COLUMN invite_only INTEGER NOT NULL DEFAULT 0`,
+			`ALTER TABLE tournaments ADD COLUMN invite_code TEXT NOT NULL DEFAULT ''`,
+			`CREATE TABLE IF NOT EXISTS tournament_allowlist (
+				tournament_id INTEGER NOT NULL,
+				user_id INTEGER NOT NULL,
+				added_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				PRIMARY KEY (tournament_id, user_id)
+			)`,
+			`ALTER TABLE votes ADD COLUMN excluded INTEGER NOT NULL DEFAULT 0`,
+			// Voters from before this migration become users, so that they can be found and moderated
+			`INSERT OR IGNORE INTO users (id) SELECT DISTINCT user_id FROM votes WHERE user_id IS NOT NULL`,
+		},
+	},
+	{
+		version: 6,
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS reports (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL,
+				tournament_id INTEGER,
+				text TEXT NOT NULL,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			)`,
+		},
+	},
+	{
+		version: 7,
+		statements: []string{
+			`ALTER TABLE users ADD COLUMN notifications INTEGER NOT NULL DEFAULT 1`,
+			`CREATE TABLE IF NOT EXISTS broadcasts (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				tournament_id INTEGER,
+				audience TEXT NOT NULL,
+				text TEXT NOT NULL,
+				status TEXT NOT NULL DEFAULT 'pending',
+				created_by INTEGER NOT NULL DEFAULT 0,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				finished_at DATETIME
+			)`,
+			`CREATE TABLE IF NOT EXISTS broadcast_deliveries (
+				broadcast_id INTEGER NOT NULL,
+				user_id INTEGER NOT NULL,
+				status TEXT NOT NULL DEFAULT 'pending',
+				attempts INTEGER NOT NULL DEFAULT 0,
+				error TEXT NOT NULL DEFAULT '',
+				sent_at DATETIME,
+				next_attempt_at DATETIME,
+				PRIMARY KEY (broadcast_id, user_id)
+			)`,
+		},
+	},
+	{
+		version: 8,
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS rate_limits (
+				scope TEXT NOT NULL,
+				key INTEGER NOT NULL,
+				tokens REAL NOT NULL,
+				updated_at DATETIME NOT NULL,
+				PRIMARY KEY (scope, key)
+			)`,
+		},
+	},
+	{
+		version: 9,
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS import_runs (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				year INTEGER NOT NULL,
+				rewrite INTEGER NOT NULL DEFAULT 0,
+				status TEXT NOT NULL DEFAULT 'running',
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				finished_at DATETIME
+			)`,
+			`CREATE TABLE IF NOT EXISTS import_items (
+				run_id INTEGER NOT NULL,
+				package_id INTEGER NOT NULL,
+				status TEXT NOT NULL DEFAULT 'pending',
+				attempts INTEGER NOT NULL DEFAULT 0,
+				error TEXT NOT NULL DEFAULT '',
+				updated_at DATETIME,
+				PRIMARY KEY (run_id, package_id)
+			)`,
+		},
+	},
+	{
+		version: 10,
+		statements: []string{
+			`CREATE INDEX IF NOT EXISTS idx_questions_gotquestions_id ON questions(gotquestions_id)`,
+			`CREATE INDEX IF NOT EXISTS idx_images_question_id ON images(question_id)`,
+			`CREATE TABLE IF NOT EXISTS question_changes (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				question_id INTEGER NOT NULL,
+				field TEXT NOT NULL,
+				old_value TEXT,
+				new_value TEXT,
+				changed_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_question_changes_question ON question_changes(question_id)`,
+		},
+	},
+	{
+		version: 11,
+		statements: []string{
+			`ALTER TABLE questions ADD COLUMN number INTEGER NOT NULL DEFAULT 0`,
+			`ALTER TABLE questions ADD COLUMN tour_number INTEGER NOT NULL DEFAULT 0`,
+			`ALTER TABLE questions ADD COLUMN taken_down_reason TEXT NOT NULL DEFAULT ''`,
+		},
+	},
+	{
+		version: 12,
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS authors (
+				id INTEGER PRIMARY KEY,
+				name TEXT NOT NULL
+			)`,
+			`CREATE TABLE IF NOT EXISTS question_authors (
+				question_id INTEGER NOT NULL,
+				author_id INTEGER NOT NULL,
+				position INTEGER NOT NULL DEFAULT 0,
+				PRIMARY KEY (question_id, author_id)
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_question_authors_author ON question_authors(author_id)`,
+			`CREATE TABLE IF NOT EXISTS tours (
+				package_id INTEGER NOT NULL,
+				number INTEGER NOT NULL,
+				title TEXT NOT NULL DEFAULT '',
+				PRIMARY KEY (package_id, number)
+			)`,
+			`CREATE TABLE IF NOT EXISTS editors (
+				package_id INTEGER NOT NULL,
+				tour_number INTEGER NOT NULL DEFAULT 0,
+				author_id INTEGER NOT NULL,
+				PRIMARY KEY (package_id, tour_number, author_id)
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_editors_author ON editors(author_id)`,
+		},
+	},
+	{
+		version: 13,
+		statements: []string{
+			`ALTER TABLE tournaments ADD COLUMN ends_at DATETIME`,
+			`ALTER TABLE tournaments ADD COLUMN reminder_sent INTEGER NOT NULL DEFAULT 0`,
+			`CREATE TABLE IF NOT EXISTS job_runs (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				job TEXT NOT NULL,
+				owner TEXT NOT NULL,
+				status TEXT NOT NULL DEFAULT 'running',
+				output TEXT NOT NULL DEFAULT '',
+				error TEXT NOT NULL DEFAULT '',
+				started_at DATETIME NOT NULL,
+				finished_at DATETIME
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_job_runs_job ON job_runs(job, id)`,
+			`CREATE TABLE IF NOT EXISTS job_locks (
+				job TEXT PRIMARY KEY,
+				owner TEXT NOT NULL,
+				expires_at DATETIME NOT NULL
+			)`,
+		},
+	},
+	{
+		version: 14,
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS question_issues (
+				question_id INTEGER NOT NULL,
+				package_id INTEGER NOT NULL,
+				kind TEXT NOT NULL,
+				detail TEXT NOT NULL DEFAULT '',
+				found_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				PRIMARY KEY (question_id, kind)
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_question_issues_package ON question_issues(package_id)`,
+		},
+	},
+	{
+		version: 15,
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS question_duplicates (
+				question_id INTEGER PRIMARY KEY,
+				cluster_id INTEGER NOT NULL,
+				similarity REAL NOT NULL DEFAULT 1
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_question_duplicates_cluster ON question_duplicates(cluster_id)`,
+		},
+	},
+	{
+		version: 16,
+		statements: []string{
+			`ALTER TABLE images ADD COLUMN telegram_file_id TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE images ADD COLUMN telegram_file_type TEXT NOT NULL DEFAULT ''`,
+		},
+	},
+}
+
+// Migrate brings the database schema up to date
+func Migrate() error {
+	err := migrateTo(migrations[len(migrations)-1].version)
+	if err != nil {
+		return err
+	}
+	return setupSearch()
+}
+
+// migrateTo applies the migrations up to and including the given version
+func migrateTo(version int) error {
+	if DB == nil {
+		return fmt.Errorf("database is not initialized")
+	}
+
+	_, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	var current int
+	err = DB.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current)
+	if err != nil {
+		return fmt.Errorf("failed to get schema version: %w", err)
+	}
+
+	for _, m := range migrations {
+		if m.version <= current || m.version > version {
+			continue
+		}
+
+		err = applyMigration(m)
+		if err != nil {
+			return fmt.Errorf("failed to apply migration %d: %w", m.version, err)
+		}
+
+		log.Printf("Applied schema migration %d", m.version)
+	}
+
+	return nil
+}
+
+// applyMigration runs all statements of a migration in a single transaction
+func applyMigration(m migration) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, statement := range m.statements {
+		_, err = tx.Exec(statement)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(`INSERT INTO schema_migrations (version) VALUES (?)`, m.version)
+	if err != nil {
+		return fmt.Errorf("failed to record migration: %w", err)
+	}
+
+	return tx.Commit()
+}
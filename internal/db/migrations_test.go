@@ -0,0 +1,57 @@
+package db
+
+import "testing"
+
+// TestUsersMigrationBackfillsVoters tests that everyone who voted before users were
+// introduced gets a user record
+func TestUsersMigrationBackfillsVoters(t *testing.T) {
+	err := InitializeWithPath(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer Close()
+
+	err = migrateTo(4)
+	if err != nil {
+		t.Fatalf("Failed to migrate to version 4: %v", err)
+	}
+
+	for _, userID := range []any{101, 102, 101, nil} {
+		_, err = DB.Exec(`INSERT INTO votes (user_id, question1_id, question2_id, tournament_id, selected_id) VALUES (?, 1, 2, 1, 1)`, userID)
+		if err != nil {
+			t.Fatalf("Failed to insert vote: %v", err)
+		}
+	}
+
+	err = Migrate()
+	if err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	rows, err := DB.Query(`SELECT id, role, status FROM users ORDER BY id`)
+	if err != nil {
+		t.Fatalf("Failed to query users: %v", err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		var role, status string
+		err = rows.Scan(&id, &role, &status)
+		if err != nil {
+			t.Fatalf("Failed to scan user: %v", err)
+		}
+		if role != "voter" || status != "active" {
+			t.Errorf("Expected user %d to be an active voter, got %s and %s", id, role, status)
+		}
+		ids = append(ids, id)
+	}
+	if err = rows.Err(); err != nil {
+		t.Fatalf("Failed to read users: %v", err)
+	}
+
+	if len(ids) != 2 || ids[0] != 101 || ids[1] != 102 {
+		t.Errorf("Expected users 101 and 102, got %v", ids)
+	}
+}
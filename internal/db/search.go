@@ -0,0 +1,151 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// searchAvailable is set by Migrate when SQLite supports FTS5
+var searchAvailable bool
+
+// SearchAvailable reports whether the full-text index of questions can be used. It needs
+// SQLite with FTS5, which go-sqlite3 builds with the sqlite_fts5 build tag.
+func SearchAvailable() bool {
+	return searchAvailable
+}
+
+// searchFolder folds ё into е, which the unicode61 tokenizer keeps apart
+var searchFolder = strings.NewReplacer("ё", "е", "Ё", "Е")
+
+// FoldSearchText prepares text for the search index, and queries the same way
+func FoldSearchText(text string) string {
+	return searchFolder.Replace(text)
+}
+
+// setupSearch creates and fills the full-text index of questions if SQLite supports FTS5,
+// and rebuilds it if its number of questions differs from the questions table.
+// The index is a separate FTS5 table keyed by question ID that the importer keeps in sync,
+// so binaries built without FTS5 can still use the database.
+func setupSearch() error {
+	var enabled bool
+	err := DB.QueryRow(`SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&enabled)
+	if err != nil {
+		return fmt.Errorf("failed to check for FTS5: %w", err)
+	}
+
+	searchAvailable = enabled
+	if !enabled {
+		log.Println("Full-text search is disabled: SQLite is built without FTS5 (build with -tags sqlite_fts5)")
+		return nil
+	}
+
+	var exists int
+	err = DB.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'questions_fts'`).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to check for the search index: %w", err)
+	}
+
+	if exists == 0 {
+		_, err = DB.Exec(`
+			CREATE VIRTUAL TABLE questions_fts USING fts5(
+				question, answer, comment, source,
+				tokenize = 'unicode61 remove_diacritics 2'
+			)
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to create the search index: %w", err)
+		}
+	} else {
+		// A binary built without FTS5 stores questions without indexing them
+		var indexed, stored int
+		err = DB.QueryRow(`SELECT (SELECT COUNT(*) FROM questions_fts), (SELECT COUNT(*) FROM questions)`).Scan(&indexed, &stored)
+		if err != nil {
+			return fmt.Errorf("failed to count indexed questions: %w", err)
+		}
+		if indexed == stored {
+			return nil
+		}
+		log.Printf("The search index has %d of %d questions, rebuilding it", indexed, stored)
+	}
+
+	count, err := RebuildSearchIndex()
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Built the search index of %d questions", count)
+	return nil
+}
+
+// RebuildSearchIndex indexes every stored question again and returns how many there are
+func RebuildSearchIndex() (int, error) {
+	if !searchAvailable {
+		return 0, fmt.Errorf("full-text search is not available: build with -tags sqlite_fts5")
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`DELETE FROM questions_fts`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to clear the search index: %w", err)
+	}
+
+	rows, err := tx.Query(`
+		SELECT id, COALESCE(question, ''), COALESCE(answer, ''), COALESCE(comment, ''), COALESCE(source, '')
+		FROM questions
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read questions: %w", err)
+	}
+
+	type document struct {
+		id                                int
+		question, answer, comment, source string
+	}
+	var docs []document
+	for rows.Next() {
+		var d document
+		if err := rows.Scan(&d.id, &d.question, &d.answer, &d.comment, &d.source); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan question: %w", err)
+		}
+		docs = append(docs, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read questions: %w", err)
+	}
+
+	for _, d := range docs {
+		err = IndexQuestion(tx, d.id, d.question, d.answer, d.comment, d.source)
+		if err != nil {
+			return 0, fmt.Errorf("failed to index question %d: %w", d.id, err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, fmt.Errorf("failed to commit the search index: %w", err)
+	}
+	return len(docs), nil
+}
+
+// IndexQuestion replaces a question in the search index
+func IndexQuestion(tx *sql.Tx, id int, question, answer, comment, source string) error {
+	_, err := tx.Exec(`DELETE FROM questions_fts WHERE rowid = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO questions_fts (rowid, question, answer, comment, source) VALUES (?, ?, ?, ?, ?)`,
+		id, FoldSearchText(question), FoldSearchText(answer), FoldSearchText(comment), FoldSearchText(source),
+	)
+	return err
+}
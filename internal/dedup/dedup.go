@@ -0,0 +1,311 @@
+package dedup
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"questions-vote/internal/models"
+	"strings"
+	"unicode"
+)
+
+const (
+	// ShingleSize is how many consecutive words make a shingle
+	ShingleSize = 3
+	// Bands and Rows split a MinHash signature of Bands*Rows values for locality-sensitive
+	// hashing: texts agreeing on all rows of any band become candidates
+	Bands = 16
+	Rows  = 4
+	// DefaultThreshold is the shingle similarity above which two questions are duplicates
+	DefaultThreshold = 0.7
+)
+
+// Document is a text to deduplicate
+type Document struct {
+	ID   int
+	Text string
+}
+
+// Member is a document in a cluster with its highest similarity to another member
+type Member struct {
+	ID         int
+	Similarity float64 // 1 for texts that are the same after normalization
+}
+
+// Cluster is a group of duplicate documents in the order they were given
+type Cluster struct {
+	Members []Member
+}
+
+// Normalize lowercases a text, folds ё into е and replaces punctuation with single spaces,
+// so that re-typeset copies of a question compare equal
+func Normalize(text string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range strings.ToLower(text) {
+		if r == 'ё' {
+			r = 'е'
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteRune(r)
+			space = false
+		} else {
+			space = true
+		}
+	}
+	return b.String()
+}
+
+// Hash returns the hash of a normalized text
+func Hash(normalized string) string {
+	sum := sha1.Sum([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// Shingles returns the set of hashed word shingles of a normalized text. A text shorter than
+// a shingle is a single shingle.
+func Shingles(normalized string) map[uint64]bool {
+	words := strings.Fields(normalized)
+	shingles := make(map[uint64]bool)
+	if len(words) == 0 {
+		return shingles
+	}
+
+	for i := 0; i+ShingleSize <= len(words) || i == 0; i++ {
+		end := min(i+ShingleSize, len(words))
+		h := fnv.New64a()
+		h.Write([]byte(strings.Join(words[i:end], " ")))
+		shingles[h.Sum64()] = true
+	}
+	return shingles
+}
+
+// Jaccard returns the share of shingles two sets have in common
+func Jaccard(a, b map[uint64]bool) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+
+	common := 0
+	for s := range a {
+		if b[s] {
+			common++
+		}
+	}
+	return float64(common) / float64(len(a)+len(b)-common)
+}
+
+// seeds make the hash functions of a signature, fixed so that signatures are reproducible
+var seeds = func() [Bands * Rows]uint64 {
+	var s [Bands * Rows]uint64
+	x := uint64(0x9e3779b97f4a7c15)
+	for i := range s {
+		x = mix(x + uint64(i))
+		s[i] = x
+	}
+	return s
+}()
+
+// mix is the splitmix64 finalizer
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// Signature returns the MinHash signature of a shingle set. The share of equal values in two
+// signatures estimates the Jaccard similarity of the sets.
+func Signature(shingles map[uint64]bool) [Bands * Rows]uint64 {
+	var sig [Bands * Rows]uint64
+	for i := range sig {
+		sig[i] = ^uint64(0)
+	}
+	for s := range shingles {
+		for i, seed := range seeds {
+			if h := mix(s ^ seed); h < sig[i] {
+				sig[i] = h
+			}
+		}
+	}
+	return sig
+}
+
+// bandKeys hashes each band of a signature together with its number
+func bandKeys(sig [Bands * Rows]uint64) [Bands]uint64 {
+	var keys [Bands]uint64
+	for band := range keys {
+		key := mix(uint64(band) + 1)
+		for _, value := range sig[band*Rows : (band+1)*Rows] {
+			key = mix(key ^ value)
+		}
+		keys[band] = key
+	}
+	return keys
+}
+
+// Find clusters documents whose normalized texts are equal or whose shingle similarity is at
+// least threshold. Clusters and their members keep the order of docs, so the first member
+// of a cluster is the earliest document.
+func Find(docs []Document, threshold float64) []*Cluster {
+	uf := newUnionFind(len(docs))
+	similarity := make([]float64, len(docs))
+	link := func(i, j int, s float64) {
+		uf.union(i, j)
+		similarity[i] = max(similarity[i], s)
+		similarity[j] = max(similarity[j], s)
+	}
+
+	// Equal texts first, so that only one copy of each takes part in the similarity search
+	var normalized []string
+	var unique []int
+	byHash := make(map[string]int)
+	for i, doc := range docs {
+		text := Normalize(doc.Text)
+		normalized = append(normalized, text)
+		if text == "" {
+			continue
+		}
+
+		hash := Hash(text)
+		if first, ok := byHash[hash]; ok {
+			link(first, i, 1)
+			continue
+		}
+		byHash[hash] = i
+		unique = append(unique, i)
+	}
+
+	shingles := make(map[int]map[uint64]bool)
+	shinglesOf := func(i int) map[uint64]bool {
+		if shingles[i] == nil {
+			shingles[i] = Shingles(normalized[i])
+		}
+		return shingles[i]
+	}
+
+	buckets := make(map[uint64][]int)
+	for _, i := range unique {
+		for _, key := range bandKeys(Signature(Shingles(normalized[i]))) {
+			buckets[key] = append(buckets[key], i)
+		}
+	}
+
+	compared := make(map[[2]int]bool)
+	for _, bucket := range buckets {
+		for a := 0; a < len(bucket); a++ {
+			for b := a + 1; b < len(bucket); b++ {
+				pair := [2]int{bucket[a], bucket[b]}
+				if compared[pair] {
+					continue
+				}
+				compared[pair] = true
+
+				if s := Jaccard(shinglesOf(pair[0]), shinglesOf(pair[1])); s >= threshold {
+					link(pair[0], pair[1], s)
+				}
+			}
+		}
+	}
+
+	var clusters []*Cluster
+	byRoot := make(map[int]*Cluster)
+	for i, doc := range docs {
+		if similarity[i] == 0 {
+			continue
+		}
+
+		root := uf.find(i)
+		cluster := byRoot[root]
+		if cluster == nil {
+			cluster = &Cluster{}
+			byRoot[root] = cluster
+			clusters = append(clusters, cluster)
+		}
+		cluster.Members = append(cluster.Members, Member{ID: doc.ID, Similarity: similarity[i]})
+	}
+	return clusters
+}
+
+// unionFind tracks which documents are in the same cluster
+type unionFind struct {
+	parent []int
+}
+
+func newUnionFind(n int) *unionFind {
+	parent := make([]int, n)
+	for i := range parent {
+		parent[i] = i
+	}
+	return &unionFind{parent: parent}
+}
+
+func (u *unionFind) find(i int) int {
+	for u.parent[i] != i {
+		u.parent[i] = u.parent[u.parent[i]]
+		i = u.parent[i]
+	}
+	return i
+}
+
+// union joins two clusters under the root of the earlier document
+func (u *unionFind) union(i, j int) {
+	a, b := u.find(i), u.find(j)
+	if a == b {
+		return
+	}
+	if b < a {
+		a, b = b, a
+	}
+	u.parent[b] = a
+}
+
+// Summary is the outcome of a duplicate detection over all questions
+type Summary struct {
+	Questions  int
+	Clusters   int
+	Duplicates int // questions that are not the representative of their cluster
+}
+
+// Rebuild finds duplicates among all stored questions and replaces the stored clusters. The
+// representative of a cluster is the copy from the package that ended first.
+func Rebuild(threshold float64) (*Summary, error) {
+	if threshold <= 0 || threshold > 1 {
+		return nil, fmt.Errorf("similarity threshold must be in (0, 1], got %v", threshold)
+	}
+
+	questions, err := models.NewQuestionRepository().ListInPublicationOrder()
+	if err != nil {
+		return nil, err
+	}
+
+	docs := make([]Document, len(questions))
+	for i, q := range questions {
+		docs[i] = Document{ID: q.ID, Text: q.Question + "\n" + q.Answer}
+	}
+
+	summary := &Summary{Questions: len(questions)}
+	var stored []*models.DuplicateCluster
+	for _, cluster := range Find(docs, threshold) {
+		c := &models.DuplicateCluster{ID: cluster.Members[0].ID}
+		for _, member := range cluster.Members {
+			c.Members = append(c.Members, &models.DuplicateMember{QuestionID: member.ID, Similarity: member.Similarity})
+		}
+		stored = append(stored, c)
+		summary.Clusters++
+		summary.Duplicates += len(cluster.Members) - 1
+	}
+
+	err = models.NewDuplicateRepository().Replace(stored)
+	if err != nil {
+		return nil, err
+	}
+
+	return summary, nil
+}
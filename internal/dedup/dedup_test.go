@@ -0,0 +1,150 @@
+package dedup
+
+import (
+	"questions-vote/internal/db"
+	"questions-vote/internal/models"
+	"questions-vote/internal/testutil"
+	"slices"
+	"testing"
+)
+
+const (
+	original = "Во время путешествия по Италии этот писатель жаловался в письмах, что местная кухня слишком острая, " +
+		"а вино слишком кислое. Назовите этого писателя, автора известного романа о войне."
+	retyped = "ВО ВРЕМЯ путешествия по Италии этот писатель жаловался в письмах, что местная кухня — слишком острая, " +
+		"а вино слишком кислое... Назовите этого писателя, автора известного романа о войне!"
+	edited = "Во время путешествия по Италии этот писатель жаловался в письмах, что местная кухня слишком пресная, " +
+		"а вино слишком кислое. Назовите этого писателя, автора известного романа о войне."
+	other = "Этот город на Волге в разные годы назывался Царицыном и Сталинградом. Назовите его нынешнее название."
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Ёлка — «зелёная»!", "елка зеленая"},
+		{"  Ответ:\n\tМосква (город) ", "ответ москва город"},
+		{"1812 год", "1812 год"},
+		{"…", ""},
+	}
+
+	for _, tt := range tests {
+		if got := Normalize(tt.text); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+
+	if Hash(Normalize(original)) != Hash(Normalize(retyped)) {
+		t.Error("Expected a re-typeset copy to have the same hash")
+	}
+}
+
+func TestShinglesAndJaccard(t *testing.T) {
+	if n := len(Shingles("один два")); n != 1 {
+		t.Errorf("Expected a short text to be one shingle, got %d", n)
+	}
+	if n := len(Shingles("один два три четыре")); n != 2 {
+		t.Errorf("Expected 2 shingles, got %d", n)
+	}
+
+	a := Shingles(Normalize(original))
+	if s := Jaccard(a, a); s != 1 {
+		t.Errorf("Expected a text to be identical to itself, got %v", s)
+	}
+	if s := Jaccard(a, Shingles(Normalize(edited))); s < DefaultThreshold || s >= 1 {
+		t.Errorf("Expected an edited copy to be similar, got %v", s)
+	}
+	if s := Jaccard(a, Shingles(Normalize(other))); s > 0.1 {
+		t.Errorf("Expected different questions to differ, got %v", s)
+	}
+}
+
+func TestFind(t *testing.T) {
+	docs := []Document{
+		{ID: 10, Text: other},
+		{ID: 11, Text: original},
+		{ID: 12, Text: ""},
+		{ID: 13, Text: retyped},
+		{ID: 14, Text: "Назовите столицу Франции."},
+		{ID: 15, Text: edited},
+		{ID: 16, Text: ""},
+	}
+
+	clusters := Find(docs, DefaultThreshold)
+	if len(clusters) != 1 {
+		t.Fatalf("Expected 1 cluster, got %d", len(clusters))
+	}
+
+	members := clusters[0].Members
+	if len(members) != 3 || members[0].ID != 11 || members[1].ID != 13 || members[2].ID != 15 {
+		t.Fatalf("Expected questions 11, 13 and 15 in order, got %+v", members)
+	}
+	if members[0].Similarity != 1 || members[1].Similarity != 1 || members[2].Similarity >= 1 {
+		t.Errorf("Expected exact copies at 1 and the edited copy below, got %+v", members)
+	}
+
+	if clusters := Find(docs, 0.95); len(clusters) != 1 || len(clusters[0].Members) != 2 {
+		t.Errorf("Expected only the exact copies with a high threshold, got %+v", clusters)
+	}
+}
+
+func TestRebuild(t *testing.T) {
+	testutil.OpenDB(t)
+
+	// Package 2 ended first, so its copy represents the cluster
+	for _, stmt := range []string{
+		`INSERT INTO packages (gotquestions_id, title, end_date) VALUES (1, 'Синхрон', '2023-05-01 00:00:00')`,
+		`INSERT INTO packages (gotquestions_id, title, end_date) VALUES (2, 'Кубок', '2023-03-01 00:00:00')`,
+		`INSERT INTO questions (id, question, answer, package_id) VALUES (1, '` + original + `', 'Хемингуэй', 1)`,
+		`INSERT INTO questions (id, question, answer, package_id) VALUES (2, '` + other + `', 'Волгоград', 1)`,
+		`INSERT INTO questions (id, question, answer, package_id) VALUES (3, '` + retyped + `', 'Хемингуэй', 2)`,
+		`INSERT INTO questions (id, question, answer, package_id) VALUES (4, '` + edited + `', 'Хемингуэй', 2)`,
+	} {
+		if _, err := db.GetDB().Exec(stmt); err != nil {
+			t.Fatalf("Failed to insert fixture: %v", err)
+		}
+	}
+
+	summary, err := Rebuild(DefaultThreshold)
+	if err != nil {
+		t.Fatalf("Rebuild failed: %v", err)
+	}
+	if summary.Questions != 4 || summary.Clusters != 1 || summary.Duplicates != 2 {
+		t.Errorf("Unexpected summary %+v", summary)
+	}
+
+	repo := models.NewDuplicateRepository()
+	clusters, err := repo.ListClusters(10)
+	if err != nil {
+		t.Fatalf("ListClusters failed: %v", err)
+	}
+	if len(clusters) != 1 || clusters[0].ID != 3 || len(clusters[0].Members) != 3 || clusters[0].Members[0].QuestionID != 3 {
+		t.Fatalf("Expected one cluster represented by question 3, got %+v", clusters)
+	}
+	if clusters[0].Members[0].PackageTitle != "Кубок" || clusters[0].Members[0].EndDate == nil {
+		t.Errorf("Expected the package of the representative, got %+v", clusters[0].Members[0])
+	}
+
+	tests := []struct {
+		ids  []int
+		want []int
+	}{
+		{[]int{1, 2, 3, 4}, []int{2, 3}},
+		{[]int{4, 1, 2}, []int{4, 2}},
+		{[]int{2}, []int{2}},
+	}
+	for _, tt := range tests {
+		kept, err := repo.KeepOnePerCluster(tt.ids)
+		if err != nil {
+			t.Fatalf("KeepOnePerCluster failed: %v", err)
+		}
+		if !slices.Equal(kept, tt.want) {
+			t.Errorf("KeepOnePerCluster(%v) = %v, want %v", tt.ids, kept, tt.want)
+		}
+	}
+
+	if _, err := Rebuild(0); err == nil {
+		t.Error("Expected a zero threshold to be rejected")
+	}
+}
@@ -0,0 +1,138 @@
+package elo
+
+import (
+	"fmt"
+	"math"
+	"questions-vote/internal/models"
+	"sort"
+)
+
+const (
+	// adaptiveBaseUncertainty is the assumed rating standard deviation of a question with no matches
+	adaptiveBaseUncertainty = 350.0
+	// adaptiveOpponentCandidates is how many of the most informative opponents are considered
+	adaptiveOpponentCandidates = 5
+	// adaptiveMinWeight keeps questions far from the boundary selectable
+	adaptiveMinWeight = 0.001
+)
+
+// selectAdaptivePair selects the pair whose outcome is expected to tell the most
+// about which questions belong to the top N.
+//
+// Questions that have not finished the initial phase are still played first.
+// After that, the first question is sampled by the probability that it sits on the
+// wrong side of the top-N boundary, and the opponent is chosen among those with the
+// highest expected information gain: close expected outcome and high combined uncertainty.
+func (e *ELO) selectAdaptivePair() (int, int, error) {
+	questions, err := e.ratings.GetAllQuestions(e.TournamentID)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get tournament questions: %w", err)
+	}
+
+	if len(questions) < 2 {
+		return 0, 0, &NotEnoughQuestionsError{TournamentID: e.TournamentID, Available: len(questions)}
+	}
+
+	sort.Slice(questions, func(i, j int) bool {
+		return questions[i].Rating > questions[j].Rating
+	})
+
+	boundary := adaptiveBoundary(questions, e.TopN)
+
+	first := e.pickAdaptiveFirst(questions, boundary)
+	second := e.pickAdaptiveOpponent(questions, first, boundary)
+
+	return first.QuestionID, second.QuestionID, nil
+}
+
+// adaptiveBoundary returns the rating separating the top N from the rest
+func adaptiveBoundary(sorted []*models.TournamentQuestion, topN int) float64 {
+	if topN <= 0 || topN >= len(sorted) {
+		return sorted[len(sorted)-1].Rating
+	}
+
+	return (sorted[topN-1].Rating + sorted[topN].Rating) / 2
+}
+
+// pickAdaptiveFirst picks the question the next match should be about
+func (e *ELO) pickAdaptiveFirst(questions []*models.TournamentQuestion, boundary float64) *models.TournamentQuestion {
+	var fewestMatches []*models.TournamentQuestion
+	for _, tq := range questions {
+		if tq.Matches >= e.InitialPhaseMatches {
+			continue
+		}
+		if len(fewestMatches) > 0 && tq.Matches > fewestMatches[0].Matches {
+			continue
+		}
+		if len(fewestMatches) > 0 && tq.Matches < fewestMatches[0].Matches {
+			fewestMatches = fewestMatches[:0]
+		}
+		fewestMatches = append(fewestMatches, tq)
+	}
+
+	if len(fewestMatches) > 0 {
+		return fewestMatches[e.rng.Intn(len(fewestMatches))]
+	}
+
+	weights := make([]float64, len(questions))
+	total := 0.0
+	for i, tq := range questions {
+		weights[i] = boundaryAmbiguity(tq, boundary) + adaptiveMinWeight
+		total += weights[i]
+	}
+
+	target := e.rng.Float64() * total
+	for i, weight := range weights {
+		target -= weight
+		if target <= 0 {
+			return questions[i]
+		}
+	}
+
+	return questions[len(questions)-1]
+}
+
+// pickAdaptiveOpponent picks an opponent for first among the most informative candidates
+func (e *ELO) pickAdaptiveOpponent(questions []*models.TournamentQuestion, first *models.TournamentQuestion, boundary float64) *models.TournamentQuestion {
+	type candidate struct {
+		tq   *models.TournamentQuestion
+		gain float64
+	}
+
+	firstVariance := math.Pow(uncertainty(first), 2)
+
+	candidates := make([]candidate, 0, len(questions)-1)
+	for _, tq := range questions {
+		if tq.QuestionID == first.QuestionID {
+			continue
+		}
+
+		p := expectedScore(first.Rating, tq.Rating)
+		gain := p * (1 - p) * (firstVariance + math.Pow(uncertainty(tq), 2)) *
+			(boundaryAmbiguity(tq, boundary) + adaptiveMinWeight)
+		candidates = append(candidates, candidate{tq: tq, gain: gain})
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].gain > candidates[j].gain
+	})
+
+	limit := min(adaptiveOpponentCandidates, len(candidates))
+	return candidates[e.rng.Intn(limit)].tq
+}
+
+// uncertainty estimates the standard deviation of a question's rating
+func uncertainty(tq *models.TournamentQuestion) float64 {
+	return adaptiveBaseUncertainty / math.Sqrt(float64(1+tq.Matches))
+}
+
+// boundaryAmbiguity is the probability that a question is on the wrong side of the top-N boundary
+func boundaryAmbiguity(tq *models.TournamentQuestion, boundary float64) float64 {
+	z := math.Abs(tq.Rating-boundary) / uncertainty(tq)
+	return 0.5 * math.Erfc(z/math.Sqrt2)
+}
+
+// expectedScore returns the probability that a question rated a beats one rated b
+func expectedScore(a, b float64) float64 {
+	return 1.0 / (1.0 + math.Pow(10, (b-a)/400))
+}
@@ -2,14 +2,12 @@ package elo
 
 import (
 	"fmt"
+	"log"
 	"math"
+	"math/rand"
 	"questions-vote/internal/models"
 	"sync"
-)
-
-var (
-	retries int
-	mu      sync.Mutex
+	"time"
 )
 
 // ELO implements the ELO rating system for tournament questions
@@ -22,102 +20,175 @@ type ELO struct {
 	TransitionPhaseMatches int
 	TopN                   int
 	BandSize               int
-
+	SelectionStrategy      string
+	PositionBiasCorrection bool
+	WeightVotes            bool
+
+	mu                     sync.Mutex
+	metrics                SelectionMetrics
+	advantage              float64
+	advantageUpdatedAt     time.Time
+	ratings                ratingStore
 	tournamentQuestionRepo *models.TournamentQuestionRepository
+	voteRepo               *models.VoteRepository
+	rng                    *rand.Rand
+}
+
+const (
+	// positionAdvantageRefresh is how often the first-position advantage is re-measured
+	positionAdvantageRefresh = 5 * time.Minute
+	// positionAdvantageMinVotes is how many decided votes are needed before correcting
+	positionAdvantageMinVotes = 100
+	// maxPositionAdvantage caps the correction in rating points
+	maxPositionAdvantage = 100.0
+)
+
+// SelectionMetrics counts pair selections made for a tournament
+type SelectionMetrics struct {
+	Selections int // pairs selected successfully
+	Fallbacks  int // selections that had to widen the pool because it held fewer than 2 questions
+	Failures   int // selections that returned an error
 }
 
-// New creates a new ELO instance from a tournament
+// NotEnoughQuestionsError is returned when a tournament has fewer than two questions to compare
+type NotEnoughQuestionsError struct {
+	TournamentID int
+	Available    int
+}
+
+func (e *NotEnoughQuestionsError) Error() string {
+	return fmt.Sprintf("tournament %d has %d questions, need at least 2 to select a pair", e.TournamentID, e.Available)
+}
+
+// New creates a new ELO instance from a tournament that reads ratings from the database
 func New(tournament *models.Tournament) *ELO {
-	return &ELO{
-		TournamentID:           tournament.ID,
-		InitialK:               tournament.InitialK,
-		MinimumK:               tournament.MinimumK,
-		StdDevMultiplier:       tournament.StdDevMultiplier,
-		InitialPhaseMatches:    tournament.InitialPhaseMatches,
-		TransitionPhaseMatches: tournament.TransitionPhaseMatches,
-		TopN:                   tournament.TopN,
-		BandSize:               tournament.BandSize,
-		tournamentQuestionRepo: models.NewTournamentQuestionRepository(),
+	repo := models.NewTournamentQuestionRepository()
+	e := &ELO{
+		ratings:                repo,
+		tournamentQuestionRepo: repo,
+		voteRepo:               models.NewVoteRepository(),
+		rng:                    rand.New(rand.NewSource(time.Now().UnixNano())),
 	}
+	e.configure(tournament)
+	return e
 }
 
-// SelectPair selects two questions for comparison based on ELO algorithm
-func (e *ELO) SelectPair() (int, int, error) {
-	mu.Lock()
-	defer mu.Unlock()
+// NewWithIndex creates a new ELO instance that reads ratings from an in-memory index
+func NewWithIndex(tournament *models.Tournament, index *RatingIndex) *ELO {
+	e := New(tournament)
+	e.ratings = index
+	return e
+}
 
-	return e.selectPairInternal()
+// Seed makes the pairs selected from now on reproducible
+func (e *ELO) Seed(seed int64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rng = rand.New(rand.NewSource(seed))
 }
 
-func (e *ELO) selectPairInternal() (int, int, error) {
-	unqualifiedCount, err := e.tournamentQuestionRepo.CountUnqualifiedQuestions(e.TournamentID, e.InitialPhaseMatches)
-	if err != nil {
-		return 0, 0, fmt.Errorf("failed to count unqualified questions: %w", err)
-	}
+// configure copies the tournament parameters into the ELO instance
+func (e *ELO) configure(tournament *models.Tournament) {
+	e.TournamentID = tournament.ID
+	e.InitialK = tournament.InitialK
+	e.MinimumK = tournament.MinimumK
+	e.StdDevMultiplier = tournament.StdDevMultiplier
+	e.InitialPhaseMatches = tournament.InitialPhaseMatches
+	e.TransitionPhaseMatches = tournament.TransitionPhaseMatches
+	e.TopN = tournament.TopN
+	e.BandSize = tournament.BandSize
+	e.SelectionStrategy = tournament.SelectionStrategy
+	e.PositionBiasCorrection = tournament.PositionBiasCorrection
+	e.WeightVotes = tournament.WeightVotes
+}
+
+// SelectPair selects two distinct questions for comparison based on ELO algorithm
+func (e *ELO) SelectPair() (int, int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
 
 	var first, second int
+	var err error
 
-	if unqualifiedCount > 1 {
-		first, second, err = e.selectTwoUnqualified()
-	} else if unqualifiedCount == 1 {
-		first, err = e.selectUnqualified()
-		if err != nil {
-			return 0, 0, err
-		}
-		second, err = e.selectAny()
+	if e.SelectionStrategy == models.SelectionAdaptive {
+		first, second, err = e.selectAdaptivePair()
 	} else {
-		first, second, err = e.selectTwoQualified()
+		first, second, err = e.selectRandomPair()
 	}
 
 	if err != nil {
+		e.metrics.Failures++
 		return 0, 0, err
 	}
 
-	if first == second {
-		retries++
-		return e.selectPairInternal()
+	e.metrics.Selections++
+	return first, second, nil
+}
+
+// Metrics returns a snapshot of the selection metrics of this tournament
+func (e *ELO) Metrics() SelectionMetrics {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.metrics
+}
+
+func (e *ELO) selectRandomPair() (int, int, error) {
+	unqualifiedCount, err := e.ratings.CountUnqualifiedQuestions(e.TournamentID, e.InitialPhaseMatches)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to count unqualified questions: %w", err)
 	}
 
-	return first, second, nil
+	if unqualifiedCount > 1 {
+		return e.selectTwoUnqualified()
+	}
+
+	if unqualifiedCount == 1 {
+		return e.selectUnqualifiedAndAny()
+	}
+
+	return e.selectTwoQualified()
 }
 
 // selectTwoUnqualified selects two unqualified questions
 func (e *ELO) selectTwoUnqualified() (int, int, error) {
 	maxMatches := e.InitialPhaseMatches - 1
 
-	first, err := e.tournamentQuestionRepo.GetRandomQuestion(e.TournamentID, 0, math.MaxFloat64, maxMatches)
+	questions, err := e.ratings.GetRandomQuestions(e.TournamentID, 0, math.MaxFloat64, maxMatches, 2, e.rng)
 	if err != nil {
-		return 0, 0, fmt.Errorf("failed to get first unqualified question: %w", err)
+		return 0, 0, fmt.Errorf("failed to get unqualified questions: %w", err)
 	}
 
-	second, err := e.tournamentQuestionRepo.GetRandomQuestion(e.TournamentID, 0, math.MaxFloat64, maxMatches)
-	if err != nil {
-		return 0, 0, fmt.Errorf("failed to get second unqualified question: %w", err)
+	if len(questions) < 2 {
+		return e.selectFallback()
 	}
 
-	return first.QuestionID, second.QuestionID, nil
+	return questions[0].QuestionID, questions[1].QuestionID, nil
 }
 
-// selectUnqualified selects one unqualified question
-func (e *ELO) selectUnqualified() (int, error) {
+// selectUnqualifiedAndAny pairs the only unqualified question with any other question
+func (e *ELO) selectUnqualifiedAndAny() (int, int, error) {
 	maxMatches := e.InitialPhaseMatches - 1
 
-	question, err := e.tournamentQuestionRepo.GetRandomQuestion(e.TournamentID, 0, math.MaxFloat64, maxMatches)
+	unqualified, err := e.ratings.GetRandomQuestions(e.TournamentID, 0, math.MaxFloat64, maxMatches, 1, e.rng)
 	if err != nil {
-		return 0, fmt.Errorf("failed to get unqualified question: %w", err)
+		return 0, 0, fmt.Errorf("failed to get unqualified question: %w", err)
 	}
 
-	return question.QuestionID, nil
-}
+	if len(unqualified) == 0 {
+		return e.selectFallback()
+	}
 
-// selectAny selects any question
-func (e *ELO) selectAny() (int, error) {
-	question, err := e.tournamentQuestionRepo.GetRandomQuestion(e.TournamentID, 0, math.MaxFloat64, math.MaxInt32)
+	first := unqualified[0].QuestionID
+	others, err := e.ratings.GetRandomQuestions(e.TournamentID, 0, math.MaxFloat64, math.MaxInt32, 1, e.rng, first)
 	if err != nil {
-		return 0, fmt.Errorf("failed to get any question: %w", err)
+		return 0, 0, fmt.Errorf("failed to get any question: %w", err)
 	}
 
-	return question.QuestionID, nil
+	if len(others) == 0 {
+		return 0, 0, &NotEnoughQuestionsError{TournamentID: e.TournamentID, Available: 1}
+	}
+
+	return first, others[0].QuestionID, nil
 }
 
 // selectTwoQualified selects two qualified questions above threshold
@@ -127,17 +198,32 @@ func (e *ELO) selectTwoQualified() (int, int, error) {
 		return 0, 0, fmt.Errorf("failed to calculate threshold: %w", err)
 	}
 
-	first, err := e.tournamentQuestionRepo.GetRandomQuestion(e.TournamentID, threshold, math.MaxFloat64, math.MaxInt32)
+	questions, err := e.ratings.GetRandomQuestions(e.TournamentID, threshold, math.MaxFloat64, math.MaxInt32, 2, e.rng)
 	if err != nil {
-		return 0, 0, fmt.Errorf("failed to get first qualified question: %w", err)
+		return 0, 0, fmt.Errorf("failed to get qualified questions: %w", err)
+	}
+
+	if len(questions) < 2 {
+		return e.selectFallback()
 	}
 
-	second, err := e.tournamentQuestionRepo.GetRandomQuestion(e.TournamentID, threshold, math.MaxFloat64, math.MaxInt32)
+	return questions[0].QuestionID, questions[1].QuestionID, nil
+}
+
+// selectFallback selects any two questions when the preferred pool is too small
+func (e *ELO) selectFallback() (int, int, error) {
+	e.metrics.Fallbacks++
+
+	questions, err := e.ratings.GetRandomQuestions(e.TournamentID, math.Inf(-1), math.MaxFloat64, math.MaxInt32, 2, e.rng)
 	if err != nil {
-		return 0, 0, fmt.Errorf("failed to get second qualified question: %w", err)
+		return 0, 0, fmt.Errorf("failed to get any questions: %w", err)
+	}
+
+	if len(questions) < 2 {
+		return 0, 0, &NotEnoughQuestionsError{TournamentID: e.TournamentID, Available: len(questions)}
 	}
 
-	return first.QuestionID, second.QuestionID, nil
+	return questions[0].QuestionID, questions[1].QuestionID, nil
 }
 
 // calculateKFactor calculates the K-factor for a tournament question
@@ -151,25 +237,86 @@ func (e *ELO) calculateKFactor(tq *models.TournamentQuestion) float64 {
 	}
 }
 
+// MatchResult is the outcome of a vote between two questions
+type MatchResult struct {
+	WinnerID         int
+	LoserID          int
+	WinnerShownFirst bool
+	Weight           float64 // share of the usual rating change, e.g. the voter's reliability
+}
+
 // RecordWinner records the winner and updates ELO ratings
 func (e *ELO) RecordWinner(winnerID, loserID int) error {
-	winner, err := e.tournamentQuestionRepo.Find(e.TournamentID, winnerID)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.recordWinner(winnerID, loserID, 0, 1)
+}
+
+// Record records the outcome of a vote for a pair shown in a known order.
+// With position bias correction enabled, a win from the first position is worth
+// less and a win from the second position more, by the advantage measured over all votes.
+// With vote weighting enabled, the rating change is scaled by the result's weight.
+func (e *ELO) Record(result MatchResult) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	advantage := 0.0
+	if e.PositionBiasCorrection {
+		advantage = e.positionAdvantage()
+		if !result.WinnerShownFirst {
+			advantage = -advantage
+		}
+	}
+
+	weight := 1.0
+	if e.WeightVotes {
+		weight = math.Max(0, math.Min(1, result.Weight))
+	}
+
+	return e.recordWinner(result.WinnerID, result.LoserID, advantage, weight)
+}
+
+// positionAdvantage returns the measured first-position advantage, refreshing it periodically
+func (e *ELO) positionAdvantage() float64 {
+	if time.Since(e.advantageUpdatedAt) < positionAdvantageRefresh {
+		return e.advantage
+	}
+
+	stats, err := e.voteRepo.GetPositionStats(e.TournamentID)
+	if err != nil {
+		log.Printf("Failed to refresh position advantage for tournament %d: %v", e.TournamentID, err)
+		return e.advantage
+	}
+
+	e.advantage = 0
+	if stats.FirstWins+stats.SecondWins >= positionAdvantageMinVotes {
+		e.advantage = math.Max(-maxPositionAdvantage, math.Min(maxPositionAdvantage, stats.Advantage()))
+	}
+	e.advantageUpdatedAt = time.Now()
+
+	return e.advantage
+}
+
+// recordWinner updates ratings, treating the winner as advantage points stronger than its rating
+// and scaling the change by weight
+func (e *ELO) recordWinner(winnerID, loserID int, advantage, weight float64) error {
+	winner, err := e.ratings.Find(e.TournamentID, winnerID)
 	if err != nil {
 		return fmt.Errorf("failed to find winner: %w", err)
 	}
 
-	loser, err := e.tournamentQuestionRepo.Find(e.TournamentID, loserID)
+	loser, err := e.ratings.Find(e.TournamentID, loserID)
 	if err != nil {
 		return fmt.Errorf("failed to find loser: %w", err)
 	}
 
-	// Update match counts
+	// Count this match for the K-factors
 	winner.Matches++
 	loser.Matches++
-	winner.Wins++
 
 	// Calculate expected winner probability
-	expectedWinner := 1.0 / (1.0 + math.Pow(10, (loser.Rating-winner.Rating)/400))
+	expectedWinner := 1.0 / (1.0 + math.Pow(10, (loser.Rating-winner.Rating-advantage)/400))
 
 	// Calculate K-factors
 	winnerK := e.calculateKFactor(winner)
@@ -177,21 +324,12 @@ func (e *ELO) RecordWinner(winnerID, loserID int) error {
 	kFactor := (winnerK + loserK) / 2
 
 	// Calculate rating change
-	ratingChange := kFactor * (1 - expectedWinner)
-
-	// Update ratings
-	winner.Rating += ratingChange
-	loser.Rating -= ratingChange
-
-	// Save changes
-	err = e.tournamentQuestionRepo.Save(winner)
-	if err != nil {
-		return fmt.Errorf("failed to save winner: %w", err)
-	}
+	ratingChange := weight * kFactor * (1 - expectedWinner)
 
-	err = e.tournamentQuestionRepo.Save(loser)
+	// Save changes as increments, so that concurrent writers do not overwrite each other
+	err = e.ratings.RecordMatch(e.TournamentID, winnerID, loserID, ratingChange)
 	if err != nil {
-		return fmt.Errorf("failed to save loser: %w", err)
+		return fmt.Errorf("failed to record match: %w", err)
 	}
 
 	return nil
@@ -199,12 +337,12 @@ func (e *ELO) RecordWinner(winnerID, loserID int) error {
 
 // GetTopItems returns the top N questions by rating
 func (e *ELO) GetTopItems(n int) ([]*models.TournamentQuestion, error) {
-	return e.tournamentQuestionRepo.GetTopQuestions(e.TournamentID, n)
+	return e.ratings.GetTopQuestions(e.TournamentID, n)
 }
 
 // CalculateThreshold calculates the rating threshold for question selection
 func (e *ELO) CalculateThreshold() (float64, error) {
-	ratingsCount, stdDev, err := e.tournamentQuestionRepo.GetStatsForQualified(e.TournamentID, e.InitialPhaseMatches)
+	ratingsCount, stdDev, err := e.ratings.GetStatsForQualified(e.TournamentID, e.InitialPhaseMatches)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get stats for qualified: %w", err)
 	}
@@ -213,7 +351,7 @@ func (e *ELO) CalculateThreshold() (float64, error) {
 		return math.Inf(-1), nil
 	}
 
-	topNThreshold, err := e.tournamentQuestionRepo.GetRatingAtPosition(e.TournamentID, e.TopN)
+	topNThreshold, err := e.ratings.GetRatingAtPosition(e.TournamentID, e.TopN)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get rating at position: %w", err)
 	}
@@ -223,12 +361,12 @@ func (e *ELO) CalculateThreshold() (float64, error) {
 
 // GetQuestionsStats returns statistics for two questions
 func (e *ELO) GetQuestionsStats(q1ID, q2ID int) ([]models.QuestionStats, error) {
-	q1, err := e.tournamentQuestionRepo.Find(e.TournamentID, q1ID)
+	q1, err := e.ratings.Find(e.TournamentID, q1ID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to find question 1: %w", err)
 	}
 
-	q2, err := e.tournamentQuestionRepo.Find(e.TournamentID, q2ID)
+	q2, err := e.ratings.Find(e.TournamentID, q2ID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to find question 2: %w", err)
 	}
@@ -241,9 +379,7 @@ func (e *ELO) GetQuestionsStats(q1ID, q2ID int) ([]models.QuestionStats, error)
 
 // GetStatistics returns comprehensive tournament statistics
 func (e *ELO) GetStatistics() (map[string]interface{}, error) {
-	mu.Lock()
-	currentRetries := retries
-	mu.Unlock()
+	metrics := e.Metrics()
 
 	threshold, err := e.CalculateThreshold()
 	if err != nil {
@@ -260,7 +396,7 @@ func (e *ELO) GetStatistics() (map[string]interface{}, error) {
 		return nil, fmt.Errorf("failed to get rating distribution: %w", err)
 	}
 
-	unqualifiedCount, err := e.tournamentQuestionRepo.CountUnqualifiedQuestions(e.TournamentID, e.InitialPhaseMatches)
+	unqualifiedCount, err := e.ratings.CountUnqualifiedQuestions(e.TournamentID, e.InitialPhaseMatches)
 	if err != nil {
 		return nil, fmt.Errorf("failed to count unqualified questions: %w", err)
 	}
@@ -275,22 +411,10 @@ func (e *ELO) GetStatistics() (map[string]interface{}, error) {
 		"above_threshold":   aboveThresholdCount,
 		"unqualified":       unqualifiedCount,
 		"distribution":      ratingDistribution,
-		"retries":           currentRetries,
+		"selections":        metrics.Selections,
+		"fallbacks":         metrics.Fallbacks,
+		"failures":          metrics.Failures,
 		"total_matches":     totalMatches,
 		"total_wins":        totalWins,
 	}, nil
 }
-
-// GetRetryCount returns the current retry count (for testing)
-func GetRetryCount() int {
-	mu.Lock()
-	defer mu.Unlock()
-	return retries
-}
-
-// ResetRetryCount resets the retry count (for testing)
-func ResetRetryCount() {
-	mu.Lock()
-	defer mu.Unlock()
-	retries = 0
-}
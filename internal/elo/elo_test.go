@@ -1,17 +1,15 @@
 package elo
 
 import (
+	"errors"
 	"math"
-	"os"
 	"questions-vote/internal/db"
 	"questions-vote/internal/models"
+	"questions-vote/internal/testutil"
 	"testing"
-
-	_ "github.com/mattn/go-sqlite3"
 )
 
 const (
-	testDBPath             = "test_elo.db"
 	initialRating          = 1500.0
 	initialK               = 64.0
 	minimumK               = 16.0
@@ -22,88 +20,8 @@ const (
 	bandSize               = 200
 )
 
-// setupTestDB creates a test database and tables
-func setupTestDB(t *testing.T) {
-	// Remove existing test database
-	os.Remove(testDBPath)
-
-	// Set test database path
-	os.Setenv("DATABASE_URL", testDBPath)
-
-	// Initialize database
-	err := db.Initialize()
-	if err != nil {
-		t.Fatalf("Failed to initialize test database: %v", err)
-	}
-
-	// Create tables
-	createTables(t)
-}
-
-// createTables creates the necessary tables for testing
-func createTables(t *testing.T) {
-	database := db.GetDB()
-
-	// Create tournaments table
-	_, err := database.Exec(`
-		CREATE TABLE tournaments (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			title TEXT,
-			initial_k REAL,
-			minimum_k REAL,
-			std_dev_multiplier REAL,
-			initial_phase_matches INTEGER,
-			transition_phase_matches INTEGER,
-			top_n INTEGER,
-			questions_count INTEGER,
-			band_size INTEGER,
-			state INTEGER DEFAULT 0
-		)
-	`)
-	if err != nil {
-		t.Fatalf("Failed to create tournaments table: %v", err)
-	}
-
-	// Create tournament_questions table
-	_, err = database.Exec(`
-		CREATE TABLE tournament_questions (
-			tournament_id INTEGER,
-			question_id INTEGER,
-			rating REAL,
-			matches INTEGER DEFAULT 0,
-			wins INTEGER DEFAULT 0,
-			PRIMARY KEY (tournament_id, question_id)
-		)
-	`)
-	if err != nil {
-		t.Fatalf("Failed to create tournament_questions table: %v", err)
-	}
-
-	// Create votes table
-	_, err = database.Exec(`
-		CREATE TABLE votes (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			user_id INTEGER,
-			question1_id INTEGER,
-			question2_id INTEGER,
-			tournament_id INTEGER,
-			selected_id INTEGER,
-			timestamp DATETIME DEFAULT CURRENT_TIMESTAMP
-		)
-	`)
-	if err != nil {
-		t.Fatalf("Failed to create votes table: %v", err)
-	}
-}
-
-// teardownTestDB cleans up the test database
-func teardownTestDB(t *testing.T) {
-	db.Close()
-	os.Remove(testDBPath)
-}
-
 // createTestTournament creates a tournament with test questions
-func createTestTournament(t *testing.T, questionCount int) (*models.Tournament, []int) {
+func createTestTournament(t testing.TB, questionCount int) (*models.Tournament, []int) {
 	database := db.GetDB()
 
 	// Insert tournament
@@ -155,11 +73,7 @@ func createTestTournament(t *testing.T, questionCount int) (*models.Tournament,
 
 // TestELOIntegration tests the complete ELO system workflow
 func TestELOIntegration(t *testing.T) {
-	setupTestDB(t)
-	defer teardownTestDB(t)
-
-	// Reset retry count
-	ResetRetryCount()
+	testutil.OpenDB(t)
 
 	// Create tournament with 15 questions
 	tournament, questionIDs := createTestTournament(t, 15)
@@ -325,9 +239,15 @@ func TestELOIntegration(t *testing.T) {
 		}
 	}
 
-	// Check retry count (should be low with 15 questions and 20 votes)
-	retryCount := GetRetryCount()
-	t.Logf("Retry count: %d", retryCount)
+	// Check selection metrics (every vote selected a pair, none failed)
+	metrics := eloSystem.Metrics()
+	if metrics.Selections != votesRun {
+		t.Errorf("Expected %d selections, got %d", votesRun, metrics.Selections)
+	}
+
+	if metrics.Failures != 0 {
+		t.Errorf("Expected no failed selections, got %d", metrics.Failures)
+	}
 
 	// Verify threshold calculation works
 	threshold, err := eloSystem.CalculateThreshold()
@@ -357,8 +277,7 @@ func contains(slice []int, value int) bool {
 
 // TestELOThresholdCalculation tests the threshold calculation with edge cases
 func TestELOThresholdCalculation(t *testing.T) {
-	setupTestDB(t)
-	defer teardownTestDB(t)
+	testutil.OpenDB(t)
 
 	// Create tournament with fewer questions than TopN
 	tournament, _ := createTestTournament(t, 3)
@@ -377,8 +296,7 @@ func TestELOThresholdCalculation(t *testing.T) {
 
 // TestELORatingChanges tests that ratings change appropriately
 func TestELORatingChanges(t *testing.T) {
-	setupTestDB(t)
-	defer teardownTestDB(t)
+	testutil.OpenDB(t)
 
 	tournament, _ := createTestTournament(t, 5)
 	eloSystem := New(tournament)
@@ -442,3 +360,226 @@ func TestELORatingChanges(t *testing.T) {
 		t.Errorf("Loser should have 0 wins, got %d", q2Updated.Wins)
 	}
 }
+
+// TestAdaptiveSelectPair tests that the adaptive strategy plays every question in the initial phase
+func TestAdaptiveSelectPair(t *testing.T) {
+	testutil.OpenDB(t)
+
+	tournament, questionIDs := createTestTournament(t, 10)
+	tournament.SelectionStrategy = models.SelectionAdaptive
+	eloSystem := New(tournament)
+
+	for i := 0; i < len(questionIDs); i++ {
+		q1, q2, err := eloSystem.SelectPair()
+		if err != nil {
+			t.Fatalf("Failed to select pair on vote %d: %v", i+1, err)
+		}
+
+		if q1 == q2 {
+			t.Fatalf("Vote %d: Same question selected twice: %d", i+1, q1)
+		}
+
+		if !contains(questionIDs, q1) || !contains(questionIDs, q2) {
+			t.Fatalf("Vote %d: Invalid question IDs selected: %d, %d", i+1, q1, q2)
+		}
+
+		err = eloSystem.RecordWinner(q1, q2)
+		if err != nil {
+			t.Fatalf("Failed to record winner on vote %d: %v", i+1, err)
+		}
+	}
+
+	repo := models.NewTournamentQuestionRepository()
+	questions, err := repo.GetAllQuestions(tournament.ID)
+	if err != nil {
+		t.Fatalf("Failed to get tournament questions: %v", err)
+	}
+
+	for _, tq := range questions {
+		if tq.Matches == 0 {
+			t.Errorf("Question %d was never played after %d votes", tq.QuestionID, len(questionIDs))
+		}
+	}
+}
+
+// TestSelectPairNotEnoughQuestions tests that a pool with fewer than two questions returns a typed error
+func TestSelectPairNotEnoughQuestions(t *testing.T) {
+	testutil.OpenDB(t)
+
+	for _, strategy := range []string{models.SelectionRandom, models.SelectionAdaptive} {
+		tournament, _ := createTestTournament(t, 1)
+		tournament.SelectionStrategy = strategy
+		eloSystem := New(tournament)
+
+		_, _, err := eloSystem.SelectPair()
+
+		var notEnough *NotEnoughQuestionsError
+		if !errors.As(err, &notEnough) {
+			t.Fatalf("%s: expected NotEnoughQuestionsError, got %v", strategy, err)
+		}
+
+		if notEnough.Available != 1 {
+			t.Errorf("%s: expected 1 available question, got %d", strategy, notEnough.Available)
+		}
+
+		if eloSystem.Metrics().Failures != 1 {
+			t.Errorf("%s: expected 1 failed selection, got %d", strategy, eloSystem.Metrics().Failures)
+		}
+	}
+}
+
+// TestRegistryMetricsPerTournament tests that metrics are kept separately for each tournament
+func TestRegistryMetricsPerTournament(t *testing.T) {
+	testutil.OpenDB(t)
+
+	first, _ := createTestTournament(t, 10)
+	second, _ := createTestTournament(t, 10)
+	registry := NewRegistry()
+
+	for i := 0; i < 3; i++ {
+		_, _, err := registry.Get(first).SelectPair()
+		if err != nil {
+			t.Fatalf("Failed to select pair: %v", err)
+		}
+	}
+
+	_, _, err := registry.Get(second).SelectPair()
+	if err != nil {
+		t.Fatalf("Failed to select pair: %v", err)
+	}
+
+	if registry.Get(first) != registry.Get(first) {
+		t.Error("Expected the same ELO instance for the same tournament")
+	}
+
+	if got := registry.Get(first).Metrics().Selections; got != 3 {
+		t.Errorf("Expected 3 selections in first tournament, got %d", got)
+	}
+
+	if got := registry.Get(second).Metrics().Selections; got != 1 {
+		t.Errorf("Expected 1 selection in second tournament, got %d", got)
+	}
+}
+
+// TestRegistryDrop tests that a dropped tournament gets a fresh instance with current ratings
+func TestRegistryDrop(t *testing.T) {
+	testutil.OpenDB(t)
+
+	tournament, _ := createTestTournament(t, 10)
+	registry := NewIndexedRegistry()
+	cached := registry.Get(tournament)
+
+	err := models.NewTournamentQuestionRepository().RecordMatch(tournament.ID, 1, 2, 50)
+	if err != nil {
+		t.Fatalf("Failed to record match: %v", err)
+	}
+
+	registry.Drop(tournament.ID)
+	reloaded := registry.Get(tournament)
+	if reloaded == cached {
+		t.Fatal("Expected a new ELO instance after Drop")
+	}
+
+	top, err := reloaded.GetTopItems(1)
+	if err != nil {
+		t.Fatalf("Failed to get top items: %v", err)
+	}
+	if len(top) != 1 || top[0].QuestionID != 1 || top[0].Rating != initialRating+50 {
+		t.Errorf("Expected question 1 on top after reloading, got %+v", top)
+	}
+}
+
+// TestRecordCorrectsPositionBias tests that wins from the first position count less
+// once voters are measured to prefer the first question
+func TestRecordCorrectsPositionBias(t *testing.T) {
+	testutil.OpenDB(t)
+
+	tournament, _ := createTestTournament(t, 4)
+	voteRepo := models.NewVoteRepository()
+
+	// 150 decided votes, 100 of them for the first question: a 2:1 first-position preference
+	for i := 0; i < 150; i++ {
+		selected := 3
+		if i%3 == 2 {
+			selected = 4
+		}
+		err := voteRepo.Create(int64(i), 3, 4, tournament.ID, &selected, false, false)
+		if err != nil {
+			t.Fatalf("Failed to create vote: %v", err)
+		}
+	}
+
+	stats, err := voteRepo.GetPositionStats(tournament.ID)
+	if err != nil {
+		t.Fatalf("Failed to get position stats: %v", err)
+	}
+
+	if stats.FirstWins != 100 || stats.SecondWins != 50 {
+		t.Errorf("Expected 100 first and 50 second wins, got %d and %d", stats.FirstWins, stats.SecondWins)
+	}
+
+	tournament.PositionBiasCorrection = true
+	eloSystem := New(tournament)
+	repo := models.NewTournamentQuestionRepository()
+
+	err = eloSystem.Record(MatchResult{WinnerID: 1, LoserID: 2, WinnerShownFirst: true, Weight: 1})
+	if err != nil {
+		t.Fatalf("Failed to record first-position winner: %v", err)
+	}
+
+	firstPositionWinner, _ := repo.Find(tournament.ID, 1)
+
+	err = eloSystem.Record(MatchResult{WinnerID: 3, LoserID: 4, WinnerShownFirst: false, Weight: 1})
+	if err != nil {
+		t.Fatalf("Failed to record second-position winner: %v", err)
+	}
+
+	secondPositionWinner, _ := repo.Find(tournament.ID, 3)
+
+	firstGain := firstPositionWinner.Rating - initialRating
+	secondGain := secondPositionWinner.Rating - initialRating
+	uncorrectedGain := initialK / 2
+
+	if firstGain >= uncorrectedGain {
+		t.Errorf("First-position win should gain less than %.2f, gained %.2f", uncorrectedGain, firstGain)
+	}
+
+	if secondGain <= uncorrectedGain {
+		t.Errorf("Second-position win should gain more than %.2f, gained %.2f", uncorrectedGain, secondGain)
+	}
+}
+
+// TestRecordWeightsVotes tests that an unreliable vote moves ratings less when weighting is enabled
+func TestRecordWeightsVotes(t *testing.T) {
+	testutil.OpenDB(t)
+
+	tournament, _ := createTestTournament(t, 4)
+	tournament.WeightVotes = true
+	eloSystem := New(tournament)
+	repo := models.NewTournamentQuestionRepository()
+
+	err := eloSystem.Record(MatchResult{WinnerID: 1, LoserID: 2, Weight: 1})
+	if err != nil {
+		t.Fatalf("Failed to record reliable vote: %v", err)
+	}
+
+	err = eloSystem.Record(MatchResult{WinnerID: 3, LoserID: 4, Weight: 0.25})
+	if err != nil {
+		t.Fatalf("Failed to record unreliable vote: %v", err)
+	}
+
+	reliable, _ := repo.Find(tournament.ID, 1)
+	unreliable, _ := repo.Find(tournament.ID, 3)
+
+	reliableGain := reliable.Rating - initialRating
+	unreliableGain := unreliable.Rating - initialRating
+
+	if math.Abs(unreliableGain-reliableGain/4) > 1e-9 {
+		t.Errorf("Expected a quarter of %.2f for a vote with weight 0.25, got %.2f", reliableGain, unreliableGain)
+	}
+
+	if unreliable.Matches != 1 || unreliable.Wins != 1 {
+		t.Errorf("Weighted vote should still count as a match and a win, got %d matches and %d wins",
+			unreliable.Matches, unreliable.Wins)
+	}
+}
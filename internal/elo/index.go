@@ -0,0 +1,375 @@
+package elo
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"questions-vote/internal/models"
+	"sort"
+	"sync"
+)
+
+// ratingStore is the access to tournament ratings that selection and rating updates need.
+// It is implemented by models.TournamentQuestionRepository and by RatingIndex.
+type ratingStore interface {
+	Find(tournamentID, questionID int) (*models.TournamentQuestion, error)
+	RecordMatch(tournamentID, winnerID, loserID int, ratingChange float64) error
+	CountUnqualifiedQuestions(tournamentID, qualificationCutoff int) (int, error)
+	GetStatsForQualified(tournamentID, initialPhaseMatches int) (int, float64, error)
+	GetRatingAtPosition(tournamentID, position int) (float64, error)
+	GetRandomQuestions(tournamentID int, minRating, maxRating float64, maxMatches, n int, rng *rand.Rand, excludeIDs ...int) ([]*models.TournamentQuestion, error)
+	GetTopQuestions(tournamentID, n int) ([]*models.TournamentQuestion, error)
+	GetAllQuestions(tournamentID int) ([]*models.TournamentQuestion, error)
+}
+
+// RatingIndex keeps the questions of one tournament in memory, ordered by rating
+// and bucketed by number of matches. Saves are written through to the database.
+type RatingIndex struct {
+	tournamentID int
+	repo         *models.TournamentQuestionRepository
+
+	mu        sync.RWMutex
+	byRating  []*models.TournamentQuestion // rating descending, question ID ascending on ties
+	byID      map[int]*models.TournamentQuestion
+	buckets   [][]int     // question IDs by number of matches
+	bucketPos map[int]int // position of a question ID in its bucket
+	sum       []float64   // sum of ratings by number of matches
+	sumSq     []float64   // sum of squared ratings by number of matches
+}
+
+// LoadRatingIndex builds the index of a tournament from tournament_questions
+func LoadRatingIndex(tournamentID int) (*RatingIndex, error) {
+	repo := models.NewTournamentQuestionRepository()
+
+	questions, err := repo.GetAllQuestions(tournamentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load rating index for tournament %d: %w", tournamentID, err)
+	}
+
+	idx := &RatingIndex{
+		tournamentID: tournamentID,
+		repo:         repo,
+		byRating:     questions,
+		byID:         make(map[int]*models.TournamentQuestion, len(questions)),
+		bucketPos:    make(map[int]int, len(questions)),
+	}
+
+	sort.Slice(idx.byRating, func(i, j int) bool {
+		return ranksBefore(idx.byRating[i], idx.byRating[j])
+	})
+
+	for _, tq := range questions {
+		idx.byID[tq.QuestionID] = tq
+		idx.addToBucket(tq)
+	}
+
+	return idx, nil
+}
+
+// Len returns the number of questions in the index
+func (idx *RatingIndex) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.byRating)
+}
+
+// Find returns a copy of a tournament question
+func (idx *RatingIndex) Find(tournamentID, questionID int) (*models.TournamentQuestion, error) {
+	if err := idx.checkTournament(tournamentID); err != nil {
+		return nil, err
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	tq, exists := idx.byID[questionID]
+	if !exists {
+		return nil, fmt.Errorf("tournament question not found")
+	}
+
+	copied := *tq
+	return &copied, nil
+}
+
+// RecordMatch writes the outcome of a match to the database and moves both questions in the index
+func (idx *RatingIndex) RecordMatch(tournamentID, winnerID, loserID int, ratingChange float64) error {
+	if err := idx.checkTournament(tournamentID); err != nil {
+		return err
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	winner, loser := idx.byID[winnerID], idx.byID[loserID]
+	if winner == nil || loser == nil {
+		return fmt.Errorf("questions %d and %d are not both in the rating index", winnerID, loserID)
+	}
+
+	err := idx.repo.RecordMatch(tournamentID, winnerID, loserID, ratingChange)
+	if err != nil {
+		return err
+	}
+
+	idx.applyMatch(winner, ratingChange, 1)
+	idx.applyMatch(loser, -ratingChange, 0)
+	return nil
+}
+
+// CountUnqualifiedQuestions returns count of questions with matches < qualification cutoff
+func (idx *RatingIndex) CountUnqualifiedQuestions(tournamentID, qualificationCutoff int) (int, error) {
+	if err := idx.checkTournament(tournamentID); err != nil {
+		return 0, err
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	count := 0
+	for matches := 0; matches < qualificationCutoff && matches < len(idx.buckets); matches++ {
+		count += len(idx.buckets[matches])
+	}
+
+	return count, nil
+}
+
+// GetStatsForQualified returns count and standard deviation of qualified questions
+func (idx *RatingIndex) GetStatsForQualified(tournamentID, initialPhaseMatches int) (int, float64, error) {
+	if err := idx.checkTournament(tournamentID); err != nil {
+		return 0, 0, err
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	var count int
+	var sumRating, sumSquares float64
+	for matches := max(initialPhaseMatches, 0); matches < len(idx.buckets); matches++ {
+		count += len(idx.buckets[matches])
+		sumRating += idx.sum[matches]
+		sumSquares += idx.sumSq[matches]
+	}
+
+	var stdDev float64
+	if count > 1 {
+		mean := sumRating / float64(count)
+		variance := (sumSquares - sumRating*mean) / float64(count-1)
+		if variance >= 0 {
+			stdDev = math.Sqrt(variance)
+		}
+	}
+
+	return count, stdDev, nil
+}
+
+// GetRatingAtPosition returns the rating at a specific position (0-indexed)
+func (idx *RatingIndex) GetRatingAtPosition(tournamentID, position int) (float64, error) {
+	if err := idx.checkTournament(tournamentID); err != nil {
+		return 0, err
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	if position < 0 || position >= len(idx.byRating) {
+		return 0, fmt.Errorf("failed to get rating at position: position %d out of range", position)
+	}
+
+	return idx.byRating[position].Rating, nil
+}
+
+// GetRandomQuestions returns up to n distinct random questions matching the criteria, drawn with rng
+func (idx *RatingIndex) GetRandomQuestions(tournamentID int, minRating, maxRating float64, maxMatches, n int, rng *rand.Rand, excludeIDs ...int) ([]*models.TournamentQuestion, error) {
+	if err := idx.checkTournament(tournamentID); err != nil {
+		return nil, err
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	excluded := make(map[int]bool, len(excludeIDs))
+	for _, id := range excludeIDs {
+		excluded[id] = true
+	}
+
+	lo := sort.Search(len(idx.byRating), func(i int) bool {
+		return idx.byRating[i].Rating <= maxRating
+	})
+	hi := sort.Search(len(idx.byRating), func(i int) bool {
+		return idx.byRating[i].Rating < minRating
+	})
+
+	var candidate func(i int) *models.TournamentQuestion
+	var count int
+
+	switch {
+	case maxMatches >= len(idx.buckets)-1:
+		// Every number of matches qualifies, sample positions in the rating range
+		count = max(hi-lo, 0)
+		candidate = func(i int) *models.TournamentQuestion {
+			return idx.byRating[lo+i]
+		}
+	case lo == 0 && hi == len(idx.byRating):
+		// Every rating qualifies, sample from the buckets of few matches
+		for matches := 0; matches <= maxMatches; matches++ {
+			count += len(idx.buckets[matches])
+		}
+		candidate = func(i int) *models.TournamentQuestion {
+			for matches := 0; ; matches++ {
+				if i < len(idx.buckets[matches]) {
+					return idx.byID[idx.buckets[matches][i]]
+				}
+				i -= len(idx.buckets[matches])
+			}
+		}
+	default:
+		var matching []*models.TournamentQuestion
+		for _, tq := range idx.byRating[lo:max(hi, lo)] {
+			if tq.Matches <= maxMatches {
+				matching = append(matching, tq)
+			}
+		}
+		count = len(matching)
+		candidate = func(i int) *models.TournamentQuestion {
+			return matching[i]
+		}
+	}
+
+	var questions []*models.TournamentQuestion
+	for _, i := range sampleDistinct(rng, count, n+len(excludeIDs)) {
+		tq := candidate(i)
+		if excluded[tq.QuestionID] {
+			continue
+		}
+
+		copied := *tq
+		questions = append(questions, &copied)
+		if len(questions) == n {
+			break
+		}
+	}
+
+	return questions, nil
+}
+
+// GetTopQuestions returns the top N questions by rating
+func (idx *RatingIndex) GetTopQuestions(tournamentID, n int) ([]*models.TournamentQuestion, error) {
+	if err := idx.checkTournament(tournamentID); err != nil {
+		return nil, err
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	return copyQuestions(idx.byRating[:min(n, len(idx.byRating))]), nil
+}
+
+// GetAllQuestions returns every question of the tournament ordered by rating
+func (idx *RatingIndex) GetAllQuestions(tournamentID int) ([]*models.TournamentQuestion, error) {
+	if err := idx.checkTournament(tournamentID); err != nil {
+		return nil, err
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	return copyQuestions(idx.byRating), nil
+}
+
+func (idx *RatingIndex) checkTournament(tournamentID int) error {
+	if tournamentID != idx.tournamentID {
+		return fmt.Errorf("rating index of tournament %d cannot serve tournament %d", idx.tournamentID, tournamentID)
+	}
+	return nil
+}
+
+// applyMatch adds a match to a question and moves it to its new place
+func (idx *RatingIndex) applyMatch(tq *models.TournamentQuestion, ratingChange float64, wins int) {
+	idx.removeFromBucket(tq)
+	position := idx.position(tq)
+	idx.byRating = append(idx.byRating[:position], idx.byRating[position+1:]...)
+
+	tq.Rating += ratingChange
+	tq.Matches++
+	tq.Wins += wins
+
+	position = idx.position(tq)
+	idx.byRating = append(idx.byRating, nil)
+	copy(idx.byRating[position+1:], idx.byRating[position:])
+	idx.byRating[position] = tq
+	idx.addToBucket(tq)
+}
+
+// position returns where tq is or would be inserted in byRating
+func (idx *RatingIndex) position(tq *models.TournamentQuestion) int {
+	return sort.Search(len(idx.byRating), func(i int) bool {
+		return !ranksBefore(idx.byRating[i], tq)
+	})
+}
+
+func (idx *RatingIndex) addToBucket(tq *models.TournamentQuestion) {
+	for len(idx.buckets) <= tq.Matches {
+		idx.buckets = append(idx.buckets, nil)
+		idx.sum = append(idx.sum, 0)
+		idx.sumSq = append(idx.sumSq, 0)
+	}
+
+	idx.bucketPos[tq.QuestionID] = len(idx.buckets[tq.Matches])
+	idx.buckets[tq.Matches] = append(idx.buckets[tq.Matches], tq.QuestionID)
+	idx.sum[tq.Matches] += tq.Rating
+	idx.sumSq[tq.Matches] += tq.Rating * tq.Rating
+}
+
+func (idx *RatingIndex) removeFromBucket(tq *models.TournamentQuestion) {
+	bucket := idx.buckets[tq.Matches]
+	position := idx.bucketPos[tq.QuestionID]
+	last := bucket[len(bucket)-1]
+
+	bucket[position] = last
+	idx.bucketPos[last] = position
+	idx.buckets[tq.Matches] = bucket[:len(bucket)-1]
+	delete(idx.bucketPos, tq.QuestionID)
+
+	idx.sum[tq.Matches] -= tq.Rating
+	idx.sumSq[tq.Matches] -= tq.Rating * tq.Rating
+}
+
+// ranksBefore orders questions by rating descending, then by question ID
+func ranksBefore(a, b *models.TournamentQuestion) bool {
+	if a.Rating != b.Rating {
+		return a.Rating > b.Rating
+	}
+	return a.QuestionID < b.QuestionID
+}
+
+// sampleDistinct returns up to n distinct random integers in [0, count) in random order
+func sampleDistinct(rng *rand.Rand, count, n int) []int {
+	if n >= count {
+		return rng.Perm(count)
+	}
+
+	// Floyd's algorithm keeps the work proportional to n rather than count
+	chosen := make(map[int]bool, n)
+	result := make([]int, 0, n)
+	for j := count - n; j < count; j++ {
+		t := rng.Intn(j + 1)
+		if chosen[t] {
+			t = j
+		}
+		chosen[t] = true
+		result = append(result, t)
+	}
+
+	rng.Shuffle(len(result), func(i, j int) {
+		result[i], result[j] = result[j], result[i]
+	})
+	return result
+}
+
+func copyQuestions(questions []*models.TournamentQuestion) []*models.TournamentQuestion {
+	copied := make([]*models.TournamentQuestion, len(questions))
+	for i, tq := range questions {
+		c := *tq
+		copied[i] = &c
+	}
+	return copied
+}
@@ -0,0 +1,241 @@
+package elo
+
+import (
+	"math"
+	"math/rand"
+	"questions-vote/internal/db"
+	"questions-vote/internal/models"
+	"questions-vote/internal/testutil"
+	"testing"
+)
+
+// TestRatingIndexMatchesDatabase tests that the index answers like the SQL queries after rating updates
+func TestRatingIndexMatchesDatabase(t *testing.T) {
+	testutil.OpenDB(t)
+
+	tournament, _ := createTestTournament(t, 30)
+	repo := models.NewTournamentQuestionRepository()
+
+	index, err := LoadRatingIndex(tournament.ID)
+	if err != nil {
+		t.Fatalf("Failed to load rating index: %v", err)
+	}
+
+	indexed := NewWithIndex(tournament, index)
+	for i := 0; i < 60; i++ {
+		q1, q2, err := indexed.SelectPair()
+		if err != nil {
+			t.Fatalf("Failed to select pair on vote %d: %v", i+1, err)
+		}
+
+		err = indexed.RecordWinner(min(q1, q2), max(q1, q2))
+		if err != nil {
+			t.Fatalf("Failed to record winner on vote %d: %v", i+1, err)
+		}
+	}
+
+	unqualifiedDB, _ := repo.CountUnqualifiedQuestions(tournament.ID, initialPhaseMatches)
+	unqualifiedIndex, _ := index.CountUnqualifiedQuestions(tournament.ID, initialPhaseMatches)
+	if unqualifiedDB != unqualifiedIndex {
+		t.Errorf("Unqualified count: database %d, index %d", unqualifiedDB, unqualifiedIndex)
+	}
+
+	countDB, stdDevDB, _ := repo.GetStatsForQualified(tournament.ID, initialPhaseMatches)
+	countIndex, stdDevIndex, _ := index.GetStatsForQualified(tournament.ID, initialPhaseMatches)
+	if countDB != countIndex || math.Abs(stdDevDB-stdDevIndex) > 1e-6 {
+		t.Errorf("Qualified stats: database (%d, %.4f), index (%d, %.4f)", countDB, stdDevDB, countIndex, stdDevIndex)
+	}
+
+	for _, position := range []int{0, topN, 29} {
+		ratingDB, _ := repo.GetRatingAtPosition(tournament.ID, position)
+		ratingIndex, _ := index.GetRatingAtPosition(tournament.ID, position)
+		if math.Abs(ratingDB-ratingIndex) > 1e-9 {
+			t.Errorf("Rating at position %d: database %.4f, index %.4f", position, ratingDB, ratingIndex)
+		}
+	}
+
+	reloaded, err := LoadRatingIndex(tournament.ID)
+	if err != nil {
+		t.Fatalf("Failed to reload rating index: %v", err)
+	}
+
+	all, _ := index.GetAllQuestions(tournament.ID)
+	allReloaded, _ := reloaded.GetAllQuestions(tournament.ID)
+	for i := range all {
+		if *all[i] != *allReloaded[i] {
+			t.Errorf("Position %d: index %+v, rebuilt %+v", i, *all[i], *allReloaded[i])
+		}
+	}
+}
+
+// TestRatingIndexRandomQuestions tests the filters and distinctness of random picks
+func TestRatingIndexRandomQuestions(t *testing.T) {
+	testutil.OpenDB(t)
+
+	tournament, _ := createTestTournament(t, 10)
+	repo := models.NewTournamentQuestionRepository()
+
+	for id := 1; id <= 10; id++ {
+		err := repo.Save(&models.TournamentQuestion{
+			TournamentID: tournament.ID,
+			QuestionID:   id,
+			Rating:       1400 + float64(id)*10,
+			Matches:      id % 4,
+		})
+		if err != nil {
+			t.Fatalf("Failed to save question %d: %v", id, err)
+		}
+	}
+
+	index, err := LoadRatingIndex(tournament.ID)
+	if err != nil {
+		t.Fatalf("Failed to load rating index: %v", err)
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	tests := []struct {
+		name       string
+		minRating  float64
+		maxRating  float64
+		maxMatches int
+		exclude    []int
+		expected   int
+	}{
+		{"everything", math.Inf(-1), math.MaxFloat64, math.MaxInt32, nil, 10},
+		{"few matches", 0, math.MaxFloat64, 1, nil, 5},
+		{"rating range", 1450, 1480, math.MaxInt32, nil, 4},
+		{"rating range and matches", 1450, 1480, 1, nil, 2},
+		{"excluded", math.Inf(-1), math.MaxFloat64, math.MaxInt32, []int{1, 2}, 8},
+		{"empty range", 1600, math.MaxFloat64, math.MaxInt32, nil, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			questions, err := index.GetRandomQuestions(tournament.ID, test.minRating, test.maxRating, test.maxMatches, 100, rng, test.exclude...)
+			if err != nil {
+				t.Fatalf("Failed to get random questions: %v", err)
+			}
+
+			if len(questions) != test.expected {
+				t.Errorf("Expected %d questions, got %d", test.expected, len(questions))
+			}
+
+			seen := make(map[int]bool)
+			for _, tq := range questions {
+				if seen[tq.QuestionID] {
+					t.Errorf("Question %d returned twice", tq.QuestionID)
+				}
+				seen[tq.QuestionID] = true
+
+				if tq.Rating < test.minRating || tq.Rating > test.maxRating || tq.Matches > test.maxMatches {
+					t.Errorf("Question %+v does not match the criteria", *tq)
+				}
+
+				if contains(test.exclude, tq.QuestionID) {
+					t.Errorf("Excluded question %d returned", tq.QuestionID)
+				}
+			}
+		})
+	}
+
+	_, err = index.GetRandomQuestions(tournament.ID+1, 0, math.MaxFloat64, math.MaxInt32, 2, rng)
+	if err == nil {
+		t.Error("Expected error when querying another tournament")
+	}
+}
+
+// TestRatingIndexRecordMatch tests that match results are added to what is in the database
+// and that questions outside the index are rejected before anything is written
+func TestRatingIndexRecordMatch(t *testing.T) {
+	testutil.OpenDB(t)
+
+	tournament, _ := createTestTournament(t, 4)
+	repo := models.NewTournamentQuestionRepository()
+
+	index, err := LoadRatingIndex(tournament.ID)
+	if err != nil {
+		t.Fatalf("Failed to load rating index: %v", err)
+	}
+
+	// Another process records a match after the index was loaded
+	err = repo.RecordMatch(tournament.ID, 1, 2, 20)
+	if err != nil {
+		t.Fatalf("Failed to record match in the database: %v", err)
+	}
+
+	err = index.RecordMatch(tournament.ID, 1, 3, 10)
+	if err != nil {
+		t.Fatalf("Failed to record match in the index: %v", err)
+	}
+
+	winner, err := repo.Find(tournament.ID, 1)
+	if err != nil {
+		t.Fatalf("Failed to find winner: %v", err)
+	}
+	if winner.Rating != initialRating+30 || winner.Matches != 2 || winner.Wins != 2 {
+		t.Errorf("Expected both matches in the database, got %+v", *winner)
+	}
+
+	loser, err := index.Find(tournament.ID, 3)
+	if err != nil {
+		t.Fatalf("Failed to find loser in the index: %v", err)
+	}
+	if loser.Rating != initialRating-10 || loser.Matches != 1 || loser.Wins != 0 {
+		t.Errorf("Expected the loss in the index, got %+v", *loser)
+	}
+
+	err = index.RecordMatch(tournament.ID, 4, 99, 10)
+	if err == nil {
+		t.Error("Expected error for a question outside the index")
+	}
+
+	untouched, err := repo.Find(tournament.ID, 4)
+	if err != nil {
+		t.Fatalf("Failed to find question: %v", err)
+	}
+	if untouched.Rating != initialRating || untouched.Matches != 0 {
+		t.Errorf("Expected no change to the database for a rejected match, got %+v", *untouched)
+	}
+}
+
+// benchmarkSelectPair plays one tournament in which every question has qualified
+func benchmarkSelectPair(b *testing.B, questionCount int, indexed bool) {
+	testutil.OpenDB(b)
+
+	tournament, _ := createTestTournament(b, questionCount)
+	_, err := db.GetDB().Exec(`
+		UPDATE tournament_questions
+		SET matches = ?, rating = 1000 + (question_id % 1000)
+		WHERE tournament_id = ?
+	`, initialPhaseMatches, tournament.ID)
+	if err != nil {
+		b.Fatalf("Failed to qualify questions: %v", err)
+	}
+
+	eloSystem := New(tournament)
+	if indexed {
+		index, err := LoadRatingIndex(tournament.ID)
+		if err != nil {
+			b.Fatalf("Failed to load rating index: %v", err)
+		}
+		eloSystem = NewWithIndex(tournament, index)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		q1, q2, err := eloSystem.SelectPair()
+		if err != nil {
+			b.Fatalf("Failed to select pair: %v", err)
+		}
+
+		err = eloSystem.RecordWinner(q1, q2)
+		if err != nil {
+			b.Fatalf("Failed to record winner: %v", err)
+		}
+	}
+}
+
+func BenchmarkSelectPairSQL1k(b *testing.B)    { benchmarkSelectPair(b, 1000, false) }
+func BenchmarkSelectPairIndex1k(b *testing.B)  { benchmarkSelectPair(b, 1000, true) }
+func BenchmarkSelectPairSQL20k(b *testing.B)   { benchmarkSelectPair(b, 20000, false) }
+func BenchmarkSelectPairIndex20k(b *testing.B) { benchmarkSelectPair(b, 20000, true) }
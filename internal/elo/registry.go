@@ -0,0 +1,80 @@
+package elo
+
+import (
+	"log"
+	"questions-vote/internal/models"
+	"sync"
+)
+
+// Registry keeps one ELO instance per tournament so that selection locks and
+// metrics are shared by every caller working with the same tournament.
+// With indexing enabled each instance reads ratings from an in-memory RatingIndex.
+type Registry struct {
+	mu        sync.Mutex
+	indexed   bool
+	instances map[int]*ELO
+}
+
+// NewRegistry creates an empty registry whose instances read ratings from the database
+func NewRegistry() *Registry {
+	return &Registry{
+		instances: make(map[int]*ELO),
+	}
+}
+
+// NewIndexedRegistry creates an empty registry whose instances keep ratings in memory
+func NewIndexedRegistry() *Registry {
+	r := NewRegistry()
+	r.indexed = true
+	return r
+}
+
+// Get returns the ELO instance for a tournament, creating it on first use.
+// The instance picks up any parameter changes made to the tournament since.
+func (r *Registry) Get(tournament *models.Tournament) *ELO {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, exists := r.instances[tournament.ID]
+	if !exists {
+		e = r.create(tournament)
+		r.instances[tournament.ID] = e
+		return e
+	}
+
+	e.mu.Lock()
+	e.configure(tournament)
+	e.mu.Unlock()
+
+	return e
+}
+
+// Drop forgets the instance of a tournament, so that the next Get loads its ratings again.
+// Call it when a tournament is activated or closed, as its ratings may have changed meanwhile.
+func (r *Registry) Drop(tournamentID int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.instances, tournamentID)
+}
+
+// Warm creates the instances of the given tournaments ahead of the first request
+func (r *Registry) Warm(tournaments []*models.Tournament) {
+	for _, tournament := range tournaments {
+		r.Get(tournament)
+	}
+}
+
+func (r *Registry) create(tournament *models.Tournament) *ELO {
+	if !r.indexed {
+		return New(tournament)
+	}
+
+	index, err := LoadRatingIndex(tournament.ID)
+	if err != nil {
+		log.Printf("Falling back to database ratings for tournament %d: %v", tournament.ID, err)
+		return New(tournament)
+	}
+
+	log.Printf("Loaded rating index for tournament %d with %d questions", tournament.ID, index.Len())
+	return NewWithIndex(tournament, index)
+}
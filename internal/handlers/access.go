@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"questions-vote/internal/models"
+	"questions-vote/internal/services"
+
+	"github.com/mymmrac/telego"
+	tu "github.com/mymmrac/telego/telegoutil"
+)
+
+// identify registers the sender of an update and tells banned users that the bot is closed to them
+func (h *BotHandler) identify(from *telego.User, chatID int64) (*models.User, bool) {
+	user, err := h.userService.Touch(from.ID, from.Username, from.FirstName)
+	if err != nil {
+		log.Printf("Failed to identify user %d: %v", from.ID, err)
+		return nil, false
+	}
+
+	if user.Status == models.StatusBanned {
+		log.Printf("Ignoring update from banned user %d", user.ID)
+		h.sendText(chatID, "Доступ к боту для вас закрыт.")
+		return user, false
+	}
+
+	return user, true
+}
+
+// checkAccess tells the user why they cannot vote in the active tournament, if they cannot
+func (h *BotHandler) checkAccess(user *models.User, chatID int64) bool {
+	err := h.userService.CheckAccess(user)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, services.ErrNotInvited):
+		h.sendText(chatID, "Этот турнир только по приглашениям. Если у вас есть код, отправьте /join КОД.")
+		return false
+	case errors.Is(err, services.ErrBanned):
+		h.sendText(chatID, "Доступ к боту для вас закрыт.")
+		return false
+	}
+
+	// Other failures, such as no active tournament, are reported by the voting handlers
+	log.Printf("Failed to check access of user %d: %v", user.ID, err)
+	return true
+}
+
+// handleJoin handles /join CODE and /start CODE, adding the user to an invite-only tournament
+func (h *BotHandler) handleJoin(bot *telego.Bot, update telego.Update, code string) {
+	chatID := update.Message.Chat.ID
+
+	tournament, err := h.userService.Join(update.Message.From.ID, code)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidInviteCode) {
+			h.sendText(chatID, "Код приглашения не подошёл. Проверьте его и попробуйте ещё раз.")
+			return
+		}
+		log.Printf("Failed to join tournament: %v", err)
+		h.sendText(chatID, "Извините, произошла ошибка. Попробуйте позже.")
+		return
+	}
+
+	if tournament.InviteOnly {
+		log.Printf("User %d joined tournament %d with an invite code", update.Message.From.ID, tournament.ID)
+		h.sendText(chatID, "Приглашение принято! Отправьте /vote, чтобы начать голосовать.")
+	}
+}
+
+// sendText sends a plain text message, logging failures
+func (h *BotHandler) sendText(chatID int64, text string) {
+	_, err := h.bot.SendMessage(context.Background(), &telego.SendMessageParams{
+		ChatID: tu.ID(chatID),
+		Text:   text,
+	})
+	if err != nil {
+		log.Printf("Failed to send message: %v", err)
+	}
+}
+
+// answerCallback acknowledges a callback query so the button stops spinning
+func (h *BotHandler) answerCallback(callbackQueryID string) {
+	err := h.bot.AnswerCallbackQuery(context.Background(), &telego.AnswerCallbackQueryParams{
+		CallbackQueryID: callbackQueryID,
+	})
+	if err != nil {
+		log.Printf("Failed to answer callback query: %v", err)
+	}
+}
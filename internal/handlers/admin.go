@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"questions-vote/internal/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mymmrac/telego"
+)
+
+const (
+	// recentReportsLimit is how many reports /reports shows
+	recentReportsLimit = 10
+	// reportPreviewLength keeps /reports under Telegram's message length limit
+	reportPreviewLength = 300
+	// recentBroadcastsLimit is how many broadcasts /broadcasts shows
+	recentBroadcastsLimit = 5
+	// broadcastPreviewLength is how much of a broadcast /broadcasts shows
+	broadcastPreviewLength = 100
+	// jobHistoryLimit is how many runs /jobs NAME shows
+	jobHistoryLimit = 10
+)
+
+// parseAdminIDs parses a comma-separated list of Telegram user IDs
+func parseAdminIDs(value string) (map[int64]bool, error) {
+	ids := make(map[int64]bool)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		id, err := strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid admin ID %q: %w", part, err)
+		}
+		ids[id] = true
+	}
+	return ids, nil
+}
+
+// isAdmin reports whether a user is listed in ADMIN_IDS or has the admin role
+func (h *BotHandler) isAdmin(user *models.User) bool {
+	return h.adminIDs[user.ID] || user.IsAdmin()
+}
+
+// handleAdminCommand runs an organizer command, ignoring unknown commands
+func (h *BotHandler) handleAdminCommand(user *models.User, update telego.Update, command, argument string) {
+	chatID := update.Message.Chat.ID
+
+	switch command {
+	case "/admin":
+		h.sendText(chatID, "Команды организатора:\n"+
+			"/tournaments — список турниров\n"+
+			"/activate ID — включить турнир\n"+
+			"/deactivate ID — выключить турнир\n"+
+			"/stats [ID] — статистика турнира (по умолчанию активного)\n"+
+			"/reports — последние сообщения от участников\n"+
+			"/notify ТЕКСТ — написать всем, кто голосовал в активном турнире\n"+
+			"/broadcast ТЕКСТ — написать всем пользователям бота\n"+
+			"/broadcasts — ход последних рассылок\n"+
+			"/jobs [ИМЯ] — фоновые задачи и история их запусков")
+	case "/tournaments":
+		h.handleListTournaments(chatID)
+	case "/activate", "/deactivate":
+		h.handleSetTournamentActive(chatID, argument, command == "/activate")
+	case "/stats":
+		h.handleStatistics(chatID, argument)
+	case "/reports":
+		h.handleRecentReports(chatID)
+	case "/notify":
+		h.handleBroadcast(user, chatID, models.AudienceVoters, argument)
+	case "/broadcast":
+		h.handleBroadcast(user, chatID, models.AudienceAll, argument)
+	case "/broadcasts":
+		h.handleListBroadcasts(chatID)
+	case "/jobs":
+		h.handleJobs(chatID, argument)
+	}
+}
+
+// handleListTournaments handles /tournaments
+func (h *BotHandler) handleListTournaments(chatID int64) {
+	tournaments, err := h.adminService.ListTournaments()
+	if err != nil {
+		log.Printf("Failed to list tournaments: %v", err)
+		h.sendText(chatID, "Не удалось получить список турниров.")
+		return
+	}
+
+	if len(tournaments) == 0 {
+		h.sendText(chatID, "Турниров пока нет.")
+		return
+	}
+
+	var lines []string
+	for _, t := range tournaments {
+		status := "выключен"
+		if t.Active {
+			status = "активен"
+		}
+		lines = append(lines, fmt.Sprintf("#%d %s — %s, %d вопросов, отбор пар: %s",
+			t.ID, t.Name, status, t.QuestionsCount, t.SelectionStrategy))
+	}
+
+	h.sendText(chatID, strings.Join(lines, "\n"))
+}
+
+// handleSetTournamentActive handles /activate ID and /deactivate ID
+func (h *BotHandler) handleSetTournamentActive(chatID int64, argument string, activate bool) {
+	tournamentID, err := strconv.Atoi(argument)
+	if err != nil {
+		h.sendText(chatID, "Укажите ID турнира, например: /activate 3")
+		return
+	}
+
+	var tournament *models.Tournament
+	if activate {
+		tournament, err = h.adminService.ActivateTournament(tournamentID)
+	} else {
+		tournament, err = h.adminService.DeactivateTournament(tournamentID)
+	}
+	if err != nil {
+		log.Printf("Failed to change state of tournament %d: %v", tournamentID, err)
+		h.sendText(chatID, fmt.Sprintf("Не получилось: %v", err))
+		return
+	}
+
+	if activate {
+		h.sendText(chatID, fmt.Sprintf("Турнир #%d %s включён.", tournament.ID, tournament.Name))
+	} else {
+		h.sendText(chatID, fmt.Sprintf("Турнир #%d %s выключен.", tournament.ID, tournament.Name))
+	}
+}
+
+// handleStatistics handles /stats [ID]
+func (h *BotHandler) handleStatistics(chatID int64, argument string) {
+	tournamentID := 0
+	if argument != "" {
+		var err error
+		tournamentID, err = strconv.Atoi(argument)
+		if err != nil {
+			h.sendText(chatID, "Укажите ID турнира, например: /stats 3")
+			return
+		}
+	}
+
+	tournament, stats, err := h.adminService.GetStatistics(tournamentID)
+	if err != nil {
+		log.Printf("Failed to get tournament statistics: %v", err)
+		h.sendText(chatID, fmt.Sprintf("Не удалось получить статистику: %v", err))
+		return
+	}
+
+	h.sendText(chatID, formatStatistics(tournament, stats))
+}
+
+// formatStatistics formats the result of ELO.GetStatistics for organizers
+func formatStatistics(tournament *models.Tournament, stats map[string]interface{}) string {
+	lines := []string{
+		fmt.Sprintf("Турнир #%d %s", tournament.ID, tournament.Name),
+		fmt.Sprintf("Вопросов: %d, из них не сыграли %d матчей: %v",
+			tournament.QuestionsCount, tournament.InitialPhaseMatches, stats["unqualified"]),
+		fmt.Sprintf("Голосов с выбором: %v", stats["total_wins"]),
+		fmt.Sprintf("Порог рейтинга: %.1f, выше порога: %v", stats["current_threshold"], stats["above_threshold"]),
+		fmt.Sprintf("Подборов пар: %v, запасной вариант: %v, неудачных: %v",
+			stats["selections"], stats["fallbacks"], stats["failures"]),
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// handleRecentReports handles /reports
+func (h *BotHandler) handleRecentReports(chatID int64) {
+	reports, err := h.adminService.GetRecentReports(recentReportsLimit)
+	if err != nil {
+		log.Printf("Failed to get reports: %v", err)
+		h.sendText(chatID, "Не удалось получить сообщения участников.")
+		return
+	}
+
+	if len(reports) == 0 {
+		h.sendText(chatID, "Сообщений от участников пока нет.")
+		return
+	}
+
+	var lines []string
+	for _, r := range reports {
+		text := []rune(r.Text)
+		if len(text) > reportPreviewLength {
+			text = append(text[:reportPreviewLength], '…')
+		}
+		lines = append(lines, fmt.Sprintf("%s, пользователь %d:\n%s",
+			r.CreatedAt.Format("2006-01-02 15:04"), r.UserID, string(text)))
+	}
+
+	h.sendText(chatID, strings.Join(lines, "\n\n"))
+}
+
+// handleJobs handles /jobs, listing scheduled jobs, and /jobs NAME, showing the runs of a job
+func (h *BotHandler) handleJobs(chatID int64, argument string) {
+	if h.scheduler == nil {
+		h.sendText(chatID, "Фоновые задачи выключены (SCHEDULER=off).")
+		return
+	}
+
+	if argument != "" {
+		runs, err := h.scheduler.History(argument, jobHistoryLimit)
+		if err != nil {
+			log.Printf("Failed to get history of job %s: %v", argument, err)
+			h.sendText(chatID, fmt.Sprintf("Не удалось получить историю задачи: %v", err))
+			return
+		}
+		if len(runs) == 0 {
+			h.sendText(chatID, fmt.Sprintf("Задача %s ещё не запускалась.", argument))
+			return
+		}
+
+		lines := []string{argument + ":"}
+		for _, run := range runs {
+			lines = append(lines, formatJobRun(run))
+		}
+		h.sendText(chatID, strings.Join(lines, "\n"))
+		return
+	}
+
+	statuses, err := h.scheduler.Status()
+	if err != nil {
+		log.Printf("Failed to get job statuses: %v", err)
+		h.sendText(chatID, "Не удалось получить список задач.")
+		return
+	}
+
+	var lines []string
+	for _, status := range statuses {
+		last := "ещё не запускалась"
+		if status.LastRun != nil {
+			last = formatJobRun(status.LastRun)
+		}
+		lines = append(lines, fmt.Sprintf("%s (%s), следующий запуск %s\nпоследний: %s",
+			status.Job.Name, status.Job.Schedule, status.NextRun.Format("2006-01-02 15:04"), last))
+	}
+
+	h.sendText(chatID, strings.Join(lines, "\n\n")+"\n\nИстория задачи: /jobs ИМЯ")
+}
+
+// formatJobRun describes a run of a scheduled job
+func formatJobRun(run *models.JobRun) string {
+	line := fmt.Sprintf("%s %s", run.StartedAt.Local().Format("2006-01-02 15:04"), run.Status)
+	if run.FinishedAt != nil {
+		line += fmt.Sprintf(" за %s", run.FinishedAt.Sub(run.StartedAt).Round(time.Second))
+	}
+	if run.Output != "" {
+		line += ", " + run.Output
+	}
+	if run.Error != "" {
+		line += ", ошибка: " + run.Error
+	}
+	return line
+}
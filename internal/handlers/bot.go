@@ -5,11 +5,18 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"questions-vote/internal/elo"
+	"questions-vote/internal/models"
+	"questions-vote/internal/notifier"
+	"questions-vote/internal/scheduler"
 	"questions-vote/internal/services"
 	"questions-vote/pkg/ratelimiter"
-	"time"
+	"strings"
+	"sync"
+	"unicode"
 
 	"github.com/mymmrac/telego"
+	ta "github.com/mymmrac/telego/telegoapi"
 )
 
 // BotHandler handles all bot operations
@@ -17,7 +24,12 @@ type BotHandler struct {
 	bot             *telego.Bot
 	questionService *services.QuestionService
 	voteService     *services.VoteService
-	rateLimiter     *ratelimiter.RateLimiter
+	userService     *services.UserService
+	adminService    *services.AdminService
+	notifier        *notifier.Notifier
+	scheduler       *scheduler.Scheduler // nil when SCHEDULER=off
+	rateLimiter     ratelimiter.ScopedLimiter
+	adminIDs        map[int64]bool
 }
 
 // NewBotHandler creates a new bot handler
@@ -27,17 +39,49 @@ func NewBotHandler() (*BotHandler, error) {
 		return nil, fmt.Errorf("TELEGRAM_TOKEN environment variable is required")
 	}
 
-	bot, err := telego.NewBot(token)
+	caller := &limitedCaller{
+		caller:   ta.DefaultFastHTTPCaller,
+		outgoing: ratelimiter.NewOutgoing(ratelimiter.TelegramGlobal),
+	}
+
+	bot, err := telego.NewBot(token, telego.WithAPICaller(caller))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create bot: %w", err)
 	}
 
-	return &BotHandler{
+	adminIDs, err := parseAdminIDs(os.Getenv("ADMIN_IDS"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ADMIN_IDS: %w", err)
+	}
+
+	rateLimiter, err := newUserRateLimiter(os.Getenv("RATE_LIMIT_STORE"))
+	if err != nil {
+		return nil, err
+	}
+
+	eloRegistry := elo.NewIndexedRegistry()
+	activeTournaments, err := models.NewTournamentRepository().ListActiveTournaments()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list active tournaments: %w", err)
+	}
+	eloRegistry.Warm(activeTournaments)
+
+	h := &BotHandler{
 		bot:             bot,
-		questionService: services.NewQuestionService(),
-		voteService:     services.NewVoteService(),
-		rateLimiter:     ratelimiter.New(5 * time.Second), // 5 second cooldown
-	}, nil
+		questionService: services.NewQuestionService(eloRegistry),
+		voteService:     services.NewVoteService(eloRegistry),
+		userService:     services.NewUserService(),
+		adminService:    services.NewAdminService(eloRegistry),
+		notifier:        notifier.New(&broadcastSender{bot: bot}, notifier.DefaultInterval),
+		rateLimiter:     rateLimiter,
+		adminIDs:        adminIDs,
+	}
+
+	if os.Getenv("SCHEDULER") != "off" {
+		h.scheduler = scheduler.New(scheduler.DefaultJobs(h.notifier.Enqueue, eloRegistry.Drop)...)
+	}
+
+	return h, nil
 }
 
 // GetQuestionsCount returns the total number of questions
@@ -49,36 +93,118 @@ func (h *BotHandler) GetQuestionsCount() (int, error) {
 	return count, nil
 }
 
-// Run starts the bot
-func (h *BotHandler) Run() error {
-	updates, err := h.bot.UpdatesViaLongPolling(context.Background(), nil)
+// Run starts the bot and processes updates until the context is cancelled
+func (h *BotHandler) Run(ctx context.Context) error {
+	updates, err := h.bot.UpdatesViaLongPolling(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("failed to get updates: %w", err)
 	}
 
+	var background sync.WaitGroup
+	background.Add(2)
+	go func() {
+		defer background.Done()
+		h.notifier.Run(ctx)
+	}()
+	go func() {
+		defer background.Done()
+		h.rateLimiter.Run(ctx, rateLimitMaintenanceInterval)
+	}()
+	if h.scheduler != nil {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			h.scheduler.Run(ctx)
+		}()
+	}
+
 	log.Println("Bot is running...")
 
 	for update := range updates {
 		go h.processUpdate(update)
 	}
 
+	// Updates stop when the context is cancelled; let background jobs save their state
+	background.Wait()
+	log.Println("Bot stopped")
+
 	return nil
 }
 
 // processUpdate processes a single update
 func (h *BotHandler) processUpdate(update telego.Update) {
 	if update.Message != nil {
-		switch update.Message.Text {
+		if update.Message.From == nil {
+			return
+		}
+
+		user, ok := h.identify(update.Message.From, update.Message.Chat.ID)
+		if !ok {
+			return
+		}
+
+		command, argument := parseCommand(update.Message.Text)
+
+		switch command {
 		case "/start":
+			if argument != "" {
+				h.handleJoin(h.bot, update, argument)
+			}
 			h.handleStart(h.bot, update)
+		case "/join":
+			h.handleJoin(h.bot, update, argument)
 		case "/vote":
-			h.handleVote(h.bot, update)
+			if h.checkAccess(user, update.Message.Chat.ID) {
+				h.handleVote(h.bot, update)
+			}
+		case "/report":
+			h.handleReport(h.bot, update, argument)
+		case "/settings":
+			h.handleSettings(user, update.Message.Chat.ID)
+		default:
+			if h.isAdmin(user) {
+				h.handleAdminCommand(user, update, command, argument)
+			}
 		}
 	} else if update.CallbackQuery != nil {
+		if !h.allowCallback(update.CallbackQuery) {
+			return
+		}
+
 		if update.CallbackQuery.Data != "" &&
 			len(update.CallbackQuery.Data) > 5 &&
 			update.CallbackQuery.Data[:5] == "vote_" {
+			chatID := update.CallbackQuery.Message.GetChat().ID
+			user, ok := h.identify(&update.CallbackQuery.From, chatID)
+			if !ok || !h.checkAccess(user, chatID) {
+				h.answerCallback(update.CallbackQuery.ID)
+				return
+			}
 			h.handleCallback(h.bot, update)
+		} else if strings.HasPrefix(update.CallbackQuery.Data, "settings_") {
+			if _, ok := h.identify(&update.CallbackQuery.From, update.CallbackQuery.Message.GetChat().ID); !ok {
+				h.answerCallback(update.CallbackQuery.ID)
+				return
+			}
+			h.handleSettingsCallback(update.CallbackQuery)
+		}
+	} else if update.InlineQuery != nil {
+		if ok, _ := h.rateLimiter.Allow(scopeSearch, update.InlineQuery.From.ID); !ok {
+			return
 		}
+		h.handleInlineQuery(update.InlineQuery)
 	}
 }
+
+// parseCommand splits a message into a command without the bot mention and its argument
+func parseCommand(text string) (string, string) {
+	text = strings.TrimSpace(text)
+
+	end := strings.IndexFunc(text, unicode.IsSpace)
+	if end == -1 {
+		end = len(text)
+	}
+
+	command, _, _ := strings.Cut(text[:end], "@")
+	return command, strings.TrimSpace(text[end:])
+}
@@ -47,12 +47,12 @@ func (h *BotHandler) handleStart(bot *telego.Bot, update telego.Update) {
 func (h *BotHandler) handleVote(bot *telego.Bot, update telego.Update) {
 	chatID := update.Message.Chat.ID
 
-	canSendIn := h.rateLimiter.CanSendInSeconds(chatID)
-	if canSendIn > 0 {
-		log.Printf("Rate limited for chat %d, can send in %d seconds", chatID, canSendIn)
+	ok, wait := h.rateLimiter.Allow(scopeVote, update.Message.From.ID)
+	if !ok {
+		log.Printf("Rate limited /vote from user %d, can send in %v", update.Message.From.ID, wait)
 		_, err := bot.SendMessage(context.Background(), &telego.SendMessageParams{
 			ChatID: tu.ID(chatID),
-			Text:   fmt.Sprintf("Пожалуйста, подождите %d секунд перед следующим голосованием.", canSendIn),
+			Text:   fmt.Sprintf("Пожалуйста, подождите %d сек. перед следующим голосованием.", secondsToWait(wait)),
 		})
 		if err != nil {
 			log.Printf("Failed to send rate limit message: %v", err)
@@ -65,10 +65,36 @@ func (h *BotHandler) handleVote(bot *telego.Bot, update telego.Update) {
 		log.Printf("Failed to send vote questions: %v", err)
 		_, sendErr := bot.SendMessage(context.Background(), &telego.SendMessageParams{
 			ChatID: tu.ID(chatID),
-			Text:   "Извините, произошла ошибка при получении вопросов. Попробуйте позже.",
+			Text:   h.getVoteErrorMessage(err, "Извините, произошла ошибка при получении вопросов. Попробуйте позже."),
 		})
 		if sendErr != nil {
 			log.Printf("Failed to send error message: %v", sendErr)
 		}
 	}
 }
+
+// handleReport handles /report TEXT, passing a message on to the organizers
+func (h *BotHandler) handleReport(bot *telego.Bot, update telego.Update, text string) {
+	chatID := update.Message.Chat.ID
+
+	if text == "" {
+		h.sendText(chatID, "Напишите сообщение после команды, например: /report В вопросе 2 неверный ответ")
+		return
+	}
+
+	ok, wait := h.rateLimiter.Allow(scopeReport, update.Message.From.ID)
+	if !ok {
+		log.Printf("Rate limited /report from user %d, can send in %v", update.Message.From.ID, wait)
+		h.sendText(chatID, fmt.Sprintf("Вы уже отправили несколько сообщений. Попробуйте через %d мин.", (secondsToWait(wait)+59)/60))
+		return
+	}
+
+	err := h.userService.Report(update.Message.From.ID, text)
+	if err != nil {
+		log.Printf("Failed to save report: %v", err)
+		h.sendText(chatID, "Извините, не удалось сохранить сообщение. Попробуйте позже.")
+		return
+	}
+
+	h.sendText(chatID, "Спасибо! Передали сообщение организаторам.")
+}
@@ -1,12 +1,24 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"questions-vote/internal/elo"
 	"questions-vote/internal/models"
 	"strings"
 )
 
+// getVoteErrorMessage explains why questions could not be sent, falling back to a generic message
+func (h *BotHandler) getVoteErrorMessage(err error, fallback string) string {
+	var notEnough *elo.NotEnoughQuestionsError
+	if errors.As(err, &notEnough) {
+		return "В турнире пока недостаточно вопросов для голосования. Загляните позже."
+	}
+
+	return fallback
+}
+
 // formatQuestion formats a question for display
 func (h *BotHandler) formatQuestion(question *models.Question, number int) string {
 	var parts []string
@@ -36,6 +48,70 @@ func (h *BotHandler) formatQuestion(question *models.Question, number int) strin
 	return strings.Join(parts, "\n")
 }
 
+// formatCredits says who wrote and edited a question. It is shown only after voting,
+// so that known names do not sway the vote.
+func (h *BotHandler) formatCredits(credits *models.QuestionCredits, number int) string {
+	var parts []string
+
+	if len(credits.Authors) > 0 {
+		label := "автор"
+		if len(credits.Authors) > 1 {
+			label = "авторы"
+		}
+		parts = append(parts, fmt.Sprintf("%s — %s", label, joinNames(credits.Authors)))
+	}
+
+	if credits.PackageTitle != "" {
+		place := fmt.Sprintf("«%s»", credits.PackageTitle)
+		if credits.TourNumber > 0 {
+			place += fmt.Sprintf(", тур %d", credits.TourNumber)
+		}
+		if credits.Number > 0 {
+			place += fmt.Sprintf(", вопрос %d", credits.Number)
+		}
+		parts = append(parts, place)
+	}
+
+	if len(credits.Editors) > 0 {
+		label := "редактор"
+		if len(credits.Editors) > 1 {
+			label = "редакторы"
+		}
+		parts = append(parts, fmt.Sprintf("%s — %s", label, joinNames(credits.Editors)))
+	}
+
+	if len(parts) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("Вопрос %d: %s.", number, strings.Join(parts, "; "))
+}
+
+// joinNames lists the names of authors or editors
+func joinNames(authors []models.Author) string {
+	names := make([]string, len(authors))
+	for i, author := range authors {
+		names[i] = author.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// getCreditsMessage returns the credits of both questions of a pair
+func (h *BotHandler) getCreditsMessage(q1ID, q2ID int) string {
+	credits, err := h.questionService.GetCredits(q1ID, q2ID)
+	if err != nil {
+		log.Printf("Failed to get question credits: %v", err)
+		return ""
+	}
+
+	var lines []string
+	for i, c := range credits {
+		if line := h.formatCredits(c, i+1); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
 // getConfirmationMessage returns confirmation message after voting
 func (h *BotHandler) getConfirmationMessage(q1ID, q2ID int, selectedID *int) string {
 	if selectedID == nil {
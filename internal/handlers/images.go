@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"questions-vote/internal/models"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/mymmrac/telego"
+	tu "github.com/mymmrac/telego/telegoutil"
+)
+
+const (
+	// maxCaptionLength is Telegram's limit on a caption, counted after HTML parsing
+	maxCaptionLength = 1024
+	// maxPhotoSize is the largest picture Telegram accepts as a photo
+	maxPhotoSize = 10 << 20
+	// maxDocumentSize is the largest file a bot can upload
+	maxDocumentSize = 50 << 20
+)
+
+// photoTypes are the formats Telegram shows as photos; other pictures are sent as files
+var photoTypes = map[string]string{
+	"image/jpeg": "jpg",
+	"image/png":  "png",
+	"image/webp": "webp",
+}
+
+// fileExtensions name the uploads of pictures sent as files
+var fileExtensions = map[string]string{
+	"image/gif":     "gif",
+	"image/svg+xml": "svg",
+	"image/bmp":     "bmp",
+	"image/tiff":    "tiff",
+}
+
+// htmlTag matches the markup that Telegram does not count in caption length
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// captionLength returns the length of an HTML text as Telegram counts it, in UTF-16 units
+func captionLength(text string) int {
+	return len(utf16.Encode([]rune(html.UnescapeString(htmlTag.ReplaceAllString(text, "")))))
+}
+
+// handoutType returns the content type of a picture, trusting the stored MIME type only if
+// it names an image, or "" if the data is not a picture
+func handoutType(img *models.Image) string {
+	contentType := img.MimeType
+	if !strings.HasPrefix(contentType, "image/") {
+		contentType = http.DetectContentType(img.Data)
+	}
+	contentType, _, _ = strings.Cut(contentType, ";")
+	if !strings.HasPrefix(contentType, "image/") {
+		return ""
+	}
+	return contentType
+}
+
+// uploadMethod returns how a picture is uploaded: as a photo, as a file, or not at all ("")
+func uploadMethod(img *models.Image) string {
+	if img == nil || len(img.Data) == 0 {
+		return ""
+	}
+
+	contentType := handoutType(img)
+	switch {
+	case contentType == "":
+		return ""
+	case photoTypes[contentType] != "" && len(img.Data) <= maxPhotoSize:
+		return models.TelegramPhoto
+	case len(img.Data) <= maxDocumentSize:
+		return models.TelegramDocument
+	default:
+		return ""
+	}
+}
+
+// handoutFile returns the upload of a picture, named by its type
+func handoutFile(img *models.Image) telego.InputFile {
+	contentType := handoutType(img)
+	extension := photoTypes[contentType]
+	if extension == "" {
+		extension = fileExtensions[contentType]
+	}
+	if extension == "" {
+		extension = strings.TrimPrefix(contentType, "image/")
+	}
+	return tu.FileFromBytes(img.Data, "handout."+extension)
+}
+
+// sendHandout sends the picture of a question with a caption, reusing the file uploaded
+// before. A photo Telegram rejects is sent as a file. It reports whether the picture was sent.
+func (h *BotHandler) sendHandout(chatID int64, question *models.Question, caption string) bool {
+	img := question.Image
+
+	if img.TelegramFileID != "" {
+		_, err := h.sendFile(chatID, img.TelegramFileType, tu.FileFromID(img.TelegramFileID), caption)
+		if err == nil {
+			return true
+		}
+		log.Printf("Failed to send cached image of question %d, uploading it again: %v", question.ID, err)
+	}
+
+	method := uploadMethod(img)
+	if method == models.TelegramPhoto {
+		fileID, err := h.sendFile(chatID, models.TelegramPhoto, handoutFile(img), caption)
+		if err == nil {
+			h.rememberImage(question, fileID, models.TelegramPhoto)
+			return true
+		}
+		log.Printf("Failed to send image of question %d as a photo, sending it as a file: %v", question.ID, err)
+
+		if len(img.Data) <= maxDocumentSize {
+			method = models.TelegramDocument
+		}
+	}
+
+	if method == models.TelegramDocument {
+		fileID, err := h.sendFile(chatID, models.TelegramDocument, handoutFile(img), caption)
+		if err == nil {
+			h.rememberImage(question, fileID, models.TelegramDocument)
+			return true
+		}
+		log.Printf("Failed to send image of question %d as a file: %v", question.ID, err)
+	}
+
+	return false
+}
+
+// sendFile sends a photo or a file and returns its Telegram file ID
+func (h *BotHandler) sendFile(chatID int64, method string, file telego.InputFile, caption string) (string, error) {
+	if method == models.TelegramDocument {
+		msg, err := h.bot.SendDocument(context.Background(), &telego.SendDocumentParams{
+			ChatID:    tu.ID(chatID),
+			Document:  file,
+			Caption:   caption,
+			ParseMode: telego.ModeHTML,
+		})
+		if err != nil {
+			return "", err
+		}
+		if msg.Document == nil {
+			return "", nil
+		}
+		return msg.Document.FileID, nil
+	}
+
+	msg, err := h.bot.SendPhoto(context.Background(), &telego.SendPhotoParams{
+		ChatID:    tu.ID(chatID),
+		Photo:     file,
+		Caption:   caption,
+		ParseMode: telego.ModeHTML,
+	})
+	if err != nil {
+		return "", err
+	}
+	return photoFileID(msg), nil
+}
+
+// photoFileID returns the file ID of the largest size of a sent photo
+func photoFileID(msg *telego.Message) string {
+	if msg == nil || len(msg.Photo) == 0 {
+		return ""
+	}
+	return msg.Photo[len(msg.Photo)-1].FileID
+}
+
+// rememberImage caches the file ID of an uploaded picture
+func (h *BotHandler) rememberImage(question *models.Question, fileID, fileType string) {
+	if fileID == "" || (fileID == question.Image.TelegramFileID && fileType == question.Image.TelegramFileType) {
+		return
+	}
+
+	question.Image.TelegramFileID = fileID
+	question.Image.TelegramFileType = fileType
+	err := h.questionService.RememberImageFile(question.ID, fileID, fileType)
+	if err != nil {
+		log.Printf("Failed to remember image of question %d: %v", question.ID, err)
+	}
+}
+
+// sendHandoutGroup sends the pictures of a pair as one album labelled with the question
+// numbers. Clients may show the captions of an album only when a photo is opened, so the
+// question texts are sent as messages after it. It only does so when both pictures are
+// photos, and reports whether the album was sent.
+func (h *BotHandler) sendHandoutGroup(chatID int64, questions []*models.Question) bool {
+	var media []telego.InputMedia
+	for i, q := range questions {
+		if q.Image == nil {
+			return false
+		}
+
+		var file telego.InputFile
+		switch {
+		case q.Image.TelegramFileID != "" && q.Image.TelegramFileType == models.TelegramPhoto:
+			file = tu.FileFromID(q.Image.TelegramFileID)
+		case q.Image.TelegramFileID == "" && uploadMethod(q.Image) == models.TelegramPhoto:
+			file = handoutFile(q.Image)
+		default:
+			return false
+		}
+
+		media = append(media, &telego.InputMediaPhoto{
+			Type:      telego.MediaTypePhoto,
+			Media:     file,
+			Caption:   handoutLabel(i + 1),
+			ParseMode: telego.ModeHTML,
+		})
+	}
+
+	messages, err := h.bot.SendMediaGroup(context.Background(), &telego.SendMediaGroupParams{
+		ChatID: tu.ID(chatID),
+		Media:  media,
+	})
+	if err != nil {
+		log.Printf("Failed to send images as an album, sending them one by one: %v", err)
+		return false
+	}
+
+	for i, msg := range messages {
+		if i < len(questions) {
+			h.rememberImage(questions[i], photoFileID(&msg), models.TelegramPhoto)
+		}
+	}
+	return true
+}
+
+// handoutLabel captions a picture sent apart from the text of its question
+func handoutLabel(number int) string {
+	return fmt.Sprintf("<b>Раздаточный материал к вопросу %d</b>", number)
+}
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"questions-vote/internal/models"
+	"questions-vote/internal/notifier"
+	"strings"
+	"time"
+
+	"github.com/mymmrac/telego"
+	"github.com/mymmrac/telego/telegoapi"
+	tu "github.com/mymmrac/telego/telegoutil"
+)
+
+// broadcastSender delivers broadcasts through the bot
+type broadcastSender struct {
+	bot *telego.Bot
+}
+
+// Send sends a message and translates Telegram errors for the notifier
+func (s *broadcastSender) Send(ctx context.Context, userID int64, text string) error {
+	_, err := s.bot.SendMessage(ctx, &telego.SendMessageParams{
+		ChatID: tu.ID(userID),
+		Text:   text,
+	})
+	if err == nil {
+		return nil
+	}
+
+	var apiErr *telegoapi.Error
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+
+	sendErr := &notifier.SendError{Err: err}
+	if apiErr.Parameters != nil && apiErr.Parameters.RetryAfter > 0 {
+		sendErr.RetryAfter = time.Duration(apiErr.Parameters.RetryAfter) * time.Second
+	}
+	// 403 means the user blocked the bot or deleted their account
+	sendErr.Blocked = apiErr.ErrorCode == http.StatusForbidden
+
+	return sendErr
+}
+
+// handleSettings handles /settings, showing the notification preference with a button to change it
+func (h *BotHandler) handleSettings(user *models.User, chatID int64) {
+	text, keyboard := settingsMessage(user.Notifications)
+
+	_, err := h.bot.SendMessage(context.Background(), &telego.SendMessageParams{
+		ChatID:      tu.ID(chatID),
+		Text:        text,
+		ReplyMarkup: keyboard,
+	})
+	if err != nil {
+		log.Printf("Failed to send settings: %v", err)
+	}
+}
+
+// handleSettingsCallback handles the button that turns notifications on or off
+func (h *BotHandler) handleSettingsCallback(query *telego.CallbackQuery) {
+	enabled := query.Data == "settings_notify_1"
+
+	err := h.userService.SetNotifications(query.From.ID, enabled)
+	if err != nil {
+		log.Printf("Failed to change notifications of user %d: %v", query.From.ID, err)
+		h.answerCallback(query.ID)
+		return
+	}
+
+	h.answerCallback(query.ID)
+
+	text, keyboard := settingsMessage(enabled)
+	_, err = h.bot.EditMessageText(context.Background(), &telego.EditMessageTextParams{
+		ChatID:      tu.ID(query.Message.GetChat().ID),
+		MessageID:   query.Message.GetMessageID(),
+		Text:        text,
+		ReplyMarkup: keyboard,
+	})
+	if err != nil {
+		log.Printf("Failed to edit settings message: %v", err)
+	}
+}
+
+// settingsMessage describes the notification preference and offers to change it
+func settingsMessage(notifications bool) (string, *telego.InlineKeyboardMarkup) {
+	if notifications {
+		return "Уведомления включены: мы напишем, когда начнётся новый этап или появятся результаты.",
+			tu.InlineKeyboard(tu.InlineKeyboardRow(
+				tu.InlineKeyboardButton("Отключить уведомления").WithCallbackData("settings_notify_0"),
+			))
+	}
+
+	return "Уведомления отключены.",
+		tu.InlineKeyboard(tu.InlineKeyboardRow(
+			tu.InlineKeyboardButton("Включить уведомления").WithCallbackData("settings_notify_1"),
+		))
+}
+
+// handleBroadcast handles /broadcast TEXT and /notify TEXT, queueing the text for every user
+// or for the voters of the active tournament
+func (h *BotHandler) handleBroadcast(user *models.User, chatID int64, audience, text string) {
+	if text == "" {
+		h.sendText(chatID, "Добавьте текст после команды, например: /notify Осталось 3 дня!")
+		return
+	}
+
+	broadcast := &models.Broadcast{
+		Audience:  audience,
+		Text:      text,
+		CreatedBy: user.ID,
+	}
+
+	if audience == models.AudienceVoters {
+		tournament, err := h.adminService.GetActiveTournament()
+		if err != nil {
+			log.Printf("Failed to find active tournament for broadcast: %v", err)
+			h.sendText(chatID, "Нет активного турнира, чьим участникам можно написать.")
+			return
+		}
+		broadcast.TournamentID = &tournament.ID
+	}
+
+	id, err := h.notifier.Enqueue(broadcast)
+	if err != nil {
+		log.Printf("Failed to queue broadcast: %v", err)
+		h.sendText(chatID, "Не удалось поставить рассылку в очередь.")
+		return
+	}
+
+	progress, err := h.adminService.GetBroadcastProgress(id)
+	if err != nil {
+		log.Printf("Failed to get broadcast progress: %v", err)
+	}
+
+	h.sendText(chatID, fmt.Sprintf("Рассылка #%d поставлена в очередь: %d получателей. Ход рассылки: /broadcasts",
+		id, progress.Total()))
+}
+
+// handleListBroadcasts handles /broadcasts, showing the delivery log of recent broadcasts
+func (h *BotHandler) handleListBroadcasts(chatID int64) {
+	broadcasts, err := h.adminService.ListRecentBroadcasts(recentBroadcastsLimit)
+	if err != nil {
+		log.Printf("Failed to list broadcasts: %v", err)
+		h.sendText(chatID, "Не удалось получить список рассылок.")
+		return
+	}
+
+	if len(broadcasts) == 0 {
+		h.sendText(chatID, "Рассылок пока не было.")
+		return
+	}
+
+	var lines []string
+	for _, b := range broadcasts {
+		progress, err := h.adminService.GetBroadcastProgress(b.ID)
+		if err != nil {
+			log.Printf("Failed to get progress of broadcast %d: %v", b.ID, err)
+			continue
+		}
+
+		preview := []rune(b.Text)
+		if len(preview) > broadcastPreviewLength {
+			preview = append(preview[:broadcastPreviewLength], '…')
+		}
+
+		lines = append(lines, fmt.Sprintf("#%d %s, %s: доставлено %d из %d, ждут %d, ошибок %d, заблокировали бота %d, отписались %d\n%s",
+			b.ID, b.CreatedAt.Format("2006-01-02 15:04"), b.Status,
+			progress[models.DeliverySent], progress.Total(), progress[models.DeliveryPending],
+			progress[models.DeliveryFailed], progress[models.DeliveryBlocked], progress[models.DeliverySkipped],
+			string(preview)))
+	}
+
+	h.sendText(chatID, strings.Join(lines, "\n\n"))
+}
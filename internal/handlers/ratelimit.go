@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"questions-vote/internal/models"
+	"questions-vote/pkg/ratelimiter"
+	"strings"
+	"time"
+
+	"github.com/mymmrac/telego"
+	ta "github.com/mymmrac/telego/telegoapi"
+)
+
+// Actions limited per user
+const (
+	scopeVote     ratelimiter.Scope = "vote"     // the /vote command
+	scopeCallback ratelimiter.Scope = "callback" // vote and settings buttons
+	scopeReport   ratelimiter.Scope = "report"   // the /report command
+	scopeSearch   ratelimiter.Scope = "search"   // inline queries, sent while the user types
+)
+
+// userLimits are the limits of each user action
+var userLimits = map[ratelimiter.Scope]ratelimiter.Limit{
+	scopeVote:     {Burst: 1, Every: 5 * time.Second},
+	scopeCallback: {Burst: 5, Every: 2 * time.Second},
+	scopeReport:   {Burst: 3, Every: 10 * time.Minute},
+	scopeSearch:   {Burst: 10, Every: time.Second},
+}
+
+// rateLimitMaintenanceInterval is how often limits of idle users are forgotten
+// and, with a persistent store, the rest are saved
+const rateLimitMaintenanceInterval = time.Minute
+
+// newUserRateLimiter creates the per-user limiter. With store "sqlite" limits are
+// saved to the database and survive restarts, by default they are kept in memory.
+func newUserRateLimiter(store string) (ratelimiter.ScopedLimiter, error) {
+	switch store {
+	case "", "memory":
+		return ratelimiter.NewScoped(userLimits), nil
+	case "sqlite":
+		limiter, err := ratelimiter.NewPersistent(userLimits, models.NewRateLimitRepository())
+		if err != nil {
+			return nil, fmt.Errorf("failed to restore rate limits: %w", err)
+		}
+		return limiter, nil
+	}
+
+	return nil, fmt.Errorf("unknown RATE_LIMIT_STORE %q, expected memory or sqlite", store)
+}
+
+// limitedCaller makes every request to the Bot API except polling for updates
+// wait for the shared outgoing limit
+type limitedCaller struct {
+	caller   ta.Caller
+	outgoing *ratelimiter.Outgoing
+}
+
+// Call waits for the outgoing limit and performs the request
+func (c *limitedCaller) Call(ctx context.Context, url string, data *ta.RequestData) (*ta.Response, error) {
+	if !strings.HasSuffix(url, "/getUpdates") {
+		if err := c.outgoing.Wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	return c.caller.Call(ctx, url, data)
+}
+
+// allowCallback takes a callback token for the user, asking them to slow down if there is none
+func (h *BotHandler) allowCallback(query *telego.CallbackQuery) bool {
+	ok, wait := h.rateLimiter.Allow(scopeCallback, query.From.ID)
+	if ok {
+		return true
+	}
+
+	log.Printf("Rate limited callback from user %d, can retry in %v", query.From.ID, wait)
+	err := h.bot.AnswerCallbackQuery(context.Background(), &telego.AnswerCallbackQueryParams{
+		CallbackQueryID: query.ID,
+		Text:            fmt.Sprintf("Слишком быстро! Попробуйте через %d сек.", secondsToWait(wait)),
+	})
+	if err != nil {
+		log.Printf("Failed to answer callback query: %v", err)
+	}
+
+	return false
+}
+
+// secondsToWait rounds a wait up to whole seconds for messages to users
+func secondsToWait(wait time.Duration) int {
+	return int((wait + time.Second - 1) / time.Second)
+}
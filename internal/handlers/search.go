@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"questions-vote/internal/models"
+	"strconv"
+	"strings"
+
+	"github.com/mymmrac/telego"
+)
+
+const (
+	// inlineResultsLimit is how many questions an inline query returns
+	inlineResultsLimit = 20
+	// inlineTitleLength keeps result titles to a line in the Telegram client
+	inlineTitleLength = 80
+	// inlineCacheSeconds is how long Telegram may reuse the results of a query
+	inlineCacheSeconds = 60
+)
+
+// handleInlineQuery handles "@bot text", finding questions by text with their ratings
+// in the active tournament
+func (h *BotHandler) handleInlineQuery(query *telego.InlineQuery) {
+	user, err := h.userService.Touch(query.From.ID, query.From.Username, query.From.FirstName)
+	if err != nil {
+		log.Printf("Failed to identify user %d: %v", query.From.ID, err)
+		return
+	}
+	if user.Status == models.StatusBanned {
+		h.answerInline(query.ID, nil)
+		return
+	}
+
+	if strings.TrimSpace(query.Query) == "" {
+		h.answerInline(query.ID, nil)
+		return
+	}
+
+	results, err := h.questionService.Search(query.Query, inlineResultsLimit)
+	if err != nil {
+		if !errors.Is(err, models.ErrSearchUnavailable) {
+			log.Printf("Failed to search questions for %q: %v", query.Query, err)
+		}
+		h.answerInline(query.ID, nil)
+		return
+	}
+
+	var articles []telego.InlineQueryResult
+	for _, result := range results {
+		q := result.Question
+		articles = append(articles, &telego.InlineQueryResultArticle{
+			Type:        telego.ResultTypeArticle,
+			ID:          strconv.Itoa(q.ID),
+			Title:       truncate(strings.Join(strings.Fields(q.Question), " "), inlineTitleLength),
+			Description: h.formatSearchRating(result),
+			InputMessageContent: &telego.InputTextMessageContent{
+				MessageText: h.formatQuestion(q, q.Number) + "\n\n" + h.formatSearchRating(result),
+				ParseMode:   telego.ModeHTML,
+			},
+		})
+	}
+
+	h.answerInline(query.ID, articles)
+}
+
+// formatSearchRating describes how a found question does in the active tournament
+func (h *BotHandler) formatSearchRating(result *models.SearchResult) string {
+	if result.Rating == nil {
+		return "не участвует в текущем турнире"
+	}
+	return fmt.Sprintf("рейтинг %.0f, %s в %s", *result.Rating, h.inflectWins(result.Wins), h.inflectMatches(result.Matches))
+}
+
+// answerInline sends the results of an inline query, an empty list showing nothing found
+func (h *BotHandler) answerInline(queryID string, results []telego.InlineQueryResult) {
+	if results == nil {
+		results = []telego.InlineQueryResult{}
+	}
+
+	err := h.bot.AnswerInlineQuery(context.Background(), &telego.AnswerInlineQueryParams{
+		InlineQueryID: queryID,
+		Results:       results,
+		CacheTime:     inlineCacheSeconds,
+	})
+	if err != nil {
+		log.Printf("Failed to answer inline query: %v", err)
+	}
+}
+
+// truncate shortens a text to at most n runes, marking the cut with an ellipsis
+func truncate(text string, n int) string {
+	runes := []rune(text)
+	if len(runes) <= n {
+		return text
+	}
+	return string(runes[:n-1]) + "…"
+}
@@ -2,9 +2,11 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"questions-vote/internal/models"
+	"questions-vote/internal/services"
 	"strconv"
 	"strings"
 
@@ -14,30 +16,38 @@ import (
 
 // sendVoteQuestions sends a pair of questions for voting
 func (h *BotHandler) sendVoteQuestions(chatID int64) error {
-	questions, err := h.questionService.GetQuestions()
+	pair, err := h.questionService.GetQuestions()
 	if err != nil {
 		return fmt.Errorf("failed to get questions: %w", err)
 	}
 
-	if len(questions) < 2 {
-		return fmt.Errorf("not enough questions available")
-	}
+	q1, q2 := pair.First, pair.Second
 
-	q1, q2 := questions[0], questions[1]
+	// Two pictures go out as one album, so that they are seen side by side
+	if h.sendHandoutGroup(chatID, []*models.Question{q1, q2}) {
+		err = h.sendQuestionText(chatID, h.formatQuestion(q1, 1))
+		if err != nil {
+			return fmt.Errorf("failed to send first question: %w", err)
+		}
 
-	err = h.sendQuestion(chatID, q1, 1)
-	if err != nil {
-		return fmt.Errorf("failed to send first question: %w", err)
-	}
+		err = h.sendQuestionText(chatID, h.formatQuestion(q2, 2))
+		if err != nil {
+			return fmt.Errorf("failed to send second question: %w", err)
+		}
+	} else {
+		err = h.sendQuestion(chatID, q1, 1)
+		if err != nil {
+			return fmt.Errorf("failed to send first question: %w", err)
+		}
 
-	err = h.sendQuestion(chatID, q2, 2)
-	if err != nil {
-		return fmt.Errorf("failed to send second question: %w", err)
+		err = h.sendQuestion(chatID, q2, 2)
+		if err != nil {
+			return fmt.Errorf("failed to send second question: %w", err)
+		}
 	}
 
 	// Send voting keyboard
-	keyboard := h.createVoteKeyboard(q1.ID, q2.ID)
-	h.rateLimiter.Record(chatID)
+	keyboard := h.createVoteKeyboard(q1.ID, q2.ID, pair.Swapped)
 
 	_, err = h.bot.SendMessage(context.Background(), &telego.SendMessageParams{
 		ChatID:      tu.ID(chatID),
@@ -48,22 +58,34 @@ func (h *BotHandler) sendVoteQuestions(chatID int64) error {
 	return err
 }
 
-// sendQuestion sends a single formatted question
+// sendQuestion sends a single formatted question, as the caption of its picture if it fits
 func (h *BotHandler) sendQuestion(chatID int64, question *models.Question, number int) error {
-	if question.HandoutImg != "" {
-		_, err := h.bot.SendPhoto(context.Background(), &telego.SendPhotoParams{
-			ChatID: tu.ID(chatID),
-			Photo:  tu.FileFromBytes([]byte(question.HandoutImg), "handout.jpg"),
-		})
-		if err != nil {
-			log.Printf("Failed to send handout image: %v", err)
+	questionText := h.formatQuestion(question, number)
+
+	if question.Image != nil {
+		fits := captionLength(questionText) <= maxCaptionLength
+		caption := handoutLabel(number)
+		if fits {
+			caption = questionText
+		}
+
+		sent := h.sendHandout(chatID, question, caption)
+		if sent && fits {
+			return nil
+		}
+		if !sent {
+			questionText += "\n\n<i>Картинку к вопросу не удалось показать.</i>"
 		}
 	}
 
-	questionText := h.formatQuestion(question, number)
+	return h.sendQuestionText(chatID, questionText)
+}
+
+// sendQuestionText sends the formatted text of a question
+func (h *BotHandler) sendQuestionText(chatID int64, text string) error {
 	_, err := h.bot.SendMessage(context.Background(), &telego.SendMessageParams{
 		ChatID:    tu.ID(chatID),
-		Text:      questionText,
+		Text:      text,
 		ParseMode: telego.ModeHTML,
 		LinkPreviewOptions: &telego.LinkPreviewOptions{
 			IsDisabled: true,
@@ -74,23 +96,28 @@ func (h *BotHandler) sendQuestion(chatID int64, question *models.Question, numbe
 }
 
 // createVoteKeyboard creates inline keyboard for voting
-func (h *BotHandler) createVoteKeyboard(q1ID, q2ID int) *telego.InlineKeyboardMarkup {
+func (h *BotHandler) createVoteKeyboard(q1ID, q2ID int, swapped bool) *telego.InlineKeyboardMarkup {
+	swappedFlag := 0
+	if swapped {
+		swappedFlag = 1
+	}
+
 	return &telego.InlineKeyboardMarkup{
 		InlineKeyboard: [][]telego.InlineKeyboardButton{
 			{
 				{
 					Text:         "Первый",
-					CallbackData: fmt.Sprintf("vote_%d_%d_1", q1ID, q2ID),
+					CallbackData: fmt.Sprintf("vote_%d_%d_1_%d", q1ID, q2ID, swappedFlag),
 				},
 				{
 					Text:         "Второй",
-					CallbackData: fmt.Sprintf("vote_%d_%d_2", q1ID, q2ID),
+					CallbackData: fmt.Sprintf("vote_%d_%d_2_%d", q1ID, q2ID, swappedFlag),
 				},
 			},
 			{
 				{
 					Text:         "Не могу выбрать",
-					CallbackData: fmt.Sprintf("vote_%d_%d_0", q1ID, q2ID),
+					CallbackData: fmt.Sprintf("vote_%d_%d_0_%d", q1ID, q2ID, swappedFlag),
 				},
 			},
 		},
@@ -101,9 +128,10 @@ func (h *BotHandler) createVoteKeyboard(q1ID, q2ID int) *telego.InlineKeyboardMa
 func (h *BotHandler) handleCallback(bot *telego.Bot, update telego.Update) {
 	query := update.CallbackQuery
 
-	// Parse callback data: "vote_q1id_q2id_choice"
+	// Parse callback data: "vote_q1id_q2id_choice_swapped", buttons sent before
+	// presentation order was randomized have no swapped flag
 	parts := strings.Split(query.Data, "_")
-	if len(parts) != 4 {
+	if len(parts) != 4 && len(parts) != 5 {
 		log.Printf("Invalid callback data: %s", query.Data)
 		return
 	}
@@ -126,6 +154,8 @@ func (h *BotHandler) handleCallback(bot *telego.Bot, update telego.Update) {
 		return
 	}
 
+	swapped := len(parts) == 5 && parts[4] == "1"
+
 	var selectedID *int
 	switch choice {
 	case 1:
@@ -135,7 +165,11 @@ func (h *BotHandler) handleCallback(bot *telego.Bot, update telego.Update) {
 	}
 	// choice == 0 means skip (selectedID remains nil)
 
-	err = h.voteService.SaveVote(query.From.ID, q1ID, q2ID, selectedID)
+	err = h.voteService.SaveVote(query.From.ID, q1ID, q2ID, selectedID, swapped)
+	if errors.Is(err, services.ErrBanned) || errors.Is(err, services.ErrNotInvited) {
+		h.answerCallback(query.ID)
+		return
+	}
 	if err != nil {
 		log.Printf("Failed to save vote: %v", err)
 	}
@@ -145,6 +179,9 @@ func (h *BotHandler) handleCallback(bot *telego.Bot, update telego.Update) {
 		statsMessage := h.getQuestionStatsMessage(q1ID, q2ID, selectedID)
 		response += " " + statsMessage
 	}
+	if credits := h.getCreditsMessage(q1ID, q2ID); credits != "" {
+		response += "\n\n" + credits
+	}
 
 	err = bot.AnswerCallbackQuery(context.Background(), &telego.AnswerCallbackQueryParams{
 		CallbackQueryID: query.ID,
@@ -168,7 +205,7 @@ func (h *BotHandler) handleCallback(bot *telego.Bot, update telego.Update) {
 		log.Printf("Failed to send next vote questions: %v", err)
 		_, sendErr := bot.SendMessage(context.Background(), &telego.SendMessageParams{
 			ChatID: tu.ID(chatID),
-			Text:   "Произошла ошибка при получении следующих вопросов. Попробуйте команду /vote снова.",
+			Text:   h.getVoteErrorMessage(err, "Произошла ошибка при получении следующих вопросов. Попробуйте команду /vote снова."),
 		})
 		if sendErr != nil {
 			log.Printf("Failed to send error message: %v", sendErr)
@@ -0,0 +1,233 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"questions-vote/pkg/ratelimiter"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// BaseURL is the site questions and packages are imported from
+var BaseURL = "https://gotquestions.online"
+
+// ClientConfig configures the importer HTTP client
+type ClientConfig struct {
+	Timeout     time.Duration // timeout of a single request
+	MaxRetries  int           // retries after the first attempt
+	BaseDelay   time.Duration // backoff before the first retry, doubled for every next one
+	MaxDelay    time.Duration // upper bound of a single backoff or Retry-After wait
+	RequestsPer float64       // requests per second across all callers, 0 for no cap
+	UserAgent   string
+}
+
+// DefaultClientConfig returns the settings used when importing from gotquestions.online
+func DefaultClientConfig() ClientConfig {
+	return ClientConfig{
+		Timeout:     30 * time.Second,
+		MaxRetries:  4,
+		BaseDelay:   time.Second,
+		MaxDelay:    time.Minute,
+		RequestsPer: 1,
+		UserAgent:   "questions-vote-importer/1.0",
+	}
+}
+
+// Client fetches pages and images with timeouts, retries with backoff and a request rate cap
+type Client struct {
+	config   ClientConfig
+	http     *http.Client
+	outgoing *ratelimiter.Outgoing
+	sleep    func(ctx context.Context, d time.Duration) error
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// NewClient creates an importer HTTP client
+func NewClient(config ClientConfig) *Client {
+	client := &Client{
+		config: config,
+		http:   &http.Client{Timeout: config.Timeout},
+		sleep:  sleepContext,
+		rand:   rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	if config.RequestsPer > 0 {
+		every := time.Duration(float64(time.Second) / config.RequestsPer)
+		client.outgoing = ratelimiter.NewOutgoing(ratelimiter.Limit{Burst: 1, Every: every})
+	}
+	return client
+}
+
+var (
+	defaultClientMu sync.RWMutex
+	defaultClient   = NewClient(DefaultClientConfig())
+)
+
+// DefaultClient returns the client shared by the importer
+func DefaultClient() *Client {
+	defaultClientMu.RLock()
+	defer defaultClientMu.RUnlock()
+	return defaultClient
+}
+
+// SetDefaultClient replaces the client shared by the importer
+func SetDefaultClient(client *Client) {
+	defaultClientMu.Lock()
+	defer defaultClientMu.Unlock()
+	defaultClient = client
+}
+
+// StatusError is returned when a request ends with an unexpected HTTP status
+type StatusError struct {
+	URL        string
+	StatusCode int
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("HTTP %d when fetching %s", e.StatusCode, e.URL)
+}
+
+// Get fetches url and returns the response body and content type. Network errors,
+// 429 and 5xx responses are retried with exponential backoff, honouring Retry-After.
+func (c *Client) Get(ctx context.Context, url string) ([]byte, string, error) {
+	var lastErr error
+
+	for attempt := 0; attempt <= c.config.MaxRetries; attempt++ {
+		if attempt > 0 {
+			delay := c.backoff(attempt)
+			var statusErr *StatusError
+			if errors.As(lastErr, &statusErr) && statusErr.RetryAfter > 0 {
+				delay = statusErr.RetryAfter
+				if c.config.MaxDelay > 0 {
+					delay = min(delay, c.config.MaxDelay)
+				}
+			}
+			if err := c.sleep(ctx, delay); err != nil {
+				return nil, "", err
+			}
+		}
+
+		body, contentType, err := c.do(ctx, url)
+		if err == nil {
+			return body, contentType, nil
+		}
+		if ctx.Err() != nil {
+			return nil, "", ctx.Err()
+		}
+
+		lastErr = err
+		if !retryable(err) {
+			break
+		}
+	}
+
+	return nil, "", lastErr
+}
+
+// do makes a single rate-limited request
+func (c *Client) do(ctx context.Context, url string) ([]byte, string, error) {
+	if c.outgoing != nil {
+		if err := c.outgoing.Wait(ctx); err != nil {
+			return nil, "", err
+		}
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create request for %s: %w", url, err)
+	}
+	if c.config.UserAgent != "" {
+		request.Header.Set("User-Agent", c.config.UserAgent)
+	}
+
+	response, err := c.http.Do(request)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to fetch URL %s: %w", url, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		// Drain the body so the connection can be reused
+		io.Copy(io.Discard, response.Body)
+		return nil, "", &StatusError{
+			URL:        url,
+			StatusCode: response.StatusCode,
+			RetryAfter: parseRetryAfter(response.Header.Get("Retry-After"), time.Now()),
+		}
+	}
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read response from %s: %w", url, err)
+	}
+
+	return body, response.Header.Get("Content-Type"), nil
+}
+
+// backoff returns the delay before the given retry: BaseDelay doubled for every
+// previous retry and capped at MaxDelay, randomized between half and all of it
+func (c *Client) backoff(attempt int) time.Duration {
+	delay := float64(c.config.BaseDelay) * math.Pow(2, float64(attempt-1))
+	if c.config.MaxDelay > 0 {
+		delay = math.Min(delay, float64(c.config.MaxDelay))
+	}
+
+	c.mu.Lock()
+	jitter := c.rand.Float64()
+	c.mu.Unlock()
+
+	return time.Duration(delay/2 + jitter*delay/2)
+}
+
+// retryable reports whether a failed request is worth repeating
+func retryable(err error) bool {
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		return true
+	}
+	return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= 500
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := date.Sub(now); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
+
+// sleepContext waits for d or until the context is cancelled
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,186 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSite stands in for gotquestions.online, answering with the given statuses in turn
+type fakeSite struct {
+	mu         sync.Mutex
+	statuses   []int
+	retryAfter string
+	requests   int
+	userAgents []string
+	times      []time.Time
+}
+
+func (s *fakeSite) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.userAgents = append(s.userAgents, r.Header.Get("User-Agent"))
+	s.times = append(s.times, time.Now())
+
+	status := http.StatusOK
+	if s.requests < len(s.statuses) {
+		status = s.statuses[s.requests]
+	}
+	s.requests++
+
+	if status == http.StatusTooManyRequests && s.retryAfter != "" {
+		w.Header().Set("Retry-After", s.retryAfter)
+	}
+	w.Header().Set("Content-Type", "text/html")
+	w.WriteHeader(status)
+	w.Write([]byte("<html>ok</html>"))
+}
+
+// newTestClient creates a client without a rate cap that records its sleeps instead of waiting
+func newTestClient(maxRetries int) (*Client, *[]time.Duration) {
+	config := DefaultClientConfig()
+	config.MaxRetries = maxRetries
+	config.RequestsPer = 0
+	config.Timeout = 5 * time.Second
+
+	var sleeps []time.Duration
+	client := NewClient(config)
+	client.sleep = func(ctx context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		return ctx.Err()
+	}
+	return client, &sleeps
+}
+
+// TestGetRetriesServerErrors tests that 5xx responses are retried with growing backoff
+func TestGetRetriesServerErrors(t *testing.T) {
+	site := &fakeSite{statuses: []int{http.StatusServiceUnavailable, http.StatusBadGateway}}
+	server := httptest.NewServer(site)
+	defer server.Close()
+
+	client, sleeps := newTestClient(4)
+	body, contentType, err := client.Get(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("Expected success after retries, got %v", err)
+	}
+	if string(body) != "<html>ok</html>" || contentType != "text/html" {
+		t.Errorf("Unexpected response %q with content type %q", body, contentType)
+	}
+	if site.requests != 3 {
+		t.Errorf("Expected 3 requests, got %d", site.requests)
+	}
+
+	if len(*sleeps) != 2 {
+		t.Fatalf("Expected 2 backoffs, got %v", *sleeps)
+	}
+	first, second := (*sleeps)[0], (*sleeps)[1]
+	if first < 500*time.Millisecond || first > time.Second {
+		t.Errorf("First backoff should be between 0.5s and 1s, got %v", first)
+	}
+	if second < time.Second || second > 2*time.Second {
+		t.Errorf("Second backoff should be between 1s and 2s, got %v", second)
+	}
+}
+
+// TestGetHonoursRetryAfter tests that a 429 waits as long as the server asks
+func TestGetHonoursRetryAfter(t *testing.T) {
+	site := &fakeSite{statuses: []int{http.StatusTooManyRequests}, retryAfter: "7"}
+	server := httptest.NewServer(site)
+	defer server.Close()
+
+	client, sleeps := newTestClient(2)
+	if _, _, err := client.Get(context.Background(), server.URL); err != nil {
+		t.Fatalf("Expected success after Retry-After, got %v", err)
+	}
+
+	if len(*sleeps) != 1 || (*sleeps)[0] != 7*time.Second {
+		t.Errorf("Expected a single 7s wait, got %v", *sleeps)
+	}
+}
+
+// TestGetGivesUp tests that retries stop after MaxRetries and client errors are not retried
+func TestGetGivesUp(t *testing.T) {
+	site := &fakeSite{statuses: []int{500, 500, 500, 500, 500}}
+	server := httptest.NewServer(site)
+	defer server.Close()
+
+	client, _ := newTestClient(2)
+	_, _, err := client.Get(context.Background(), server.URL)
+
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != 500 {
+		t.Fatalf("Expected the last HTTP 500 error, got %v", err)
+	}
+	if site.requests != 3 {
+		t.Errorf("Expected 1 attempt and 2 retries, got %d requests", site.requests)
+	}
+
+	notFound := &fakeSite{statuses: []int{http.StatusNotFound}}
+	server404 := httptest.NewServer(notFound)
+	defer server404.Close()
+
+	if _, _, err := client.Get(context.Background(), server404.URL); err == nil {
+		t.Fatal("Expected HTTP 404 to fail")
+	}
+	if notFound.requests != 1 {
+		t.Errorf("HTTP 404 should not be retried, got %d requests", notFound.requests)
+	}
+}
+
+// TestGetSendsUserAgentAndSpacesRequests tests the User-Agent header and the requests-per-second cap
+func TestGetSendsUserAgentAndSpacesRequests(t *testing.T) {
+	site := &fakeSite{}
+	server := httptest.NewServer(site)
+	defer server.Close()
+
+	config := DefaultClientConfig()
+	config.RequestsPer = 20
+	client := NewClient(config)
+
+	for i := 0; i < 3; i++ {
+		if _, _, err := client.Get(context.Background(), server.URL); err != nil {
+			t.Fatalf("Request %d failed: %v", i+1, err)
+		}
+	}
+
+	for _, userAgent := range site.userAgents {
+		if userAgent != config.UserAgent {
+			t.Errorf("Expected User-Agent %q, got %q", config.UserAgent, userAgent)
+		}
+	}
+
+	// Allow for timer slack below the 50ms interval
+	for i := 1; i < len(site.times); i++ {
+		if gap := site.times[i].Sub(site.times[i-1]); gap < 40*time.Millisecond {
+			t.Errorf("Requests %d and %d were only %v apart", i, i+1, gap)
+		}
+	}
+}
+
+// TestParseRetryAfter tests both forms of the Retry-After header
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value    string
+		expected time.Duration
+	}{
+		{"", 0},
+		{"120", 2 * time.Minute},
+		{"-5", 0},
+		{"Mon, 01 Jan 2024 12:00:30 GMT", 30 * time.Second},
+		{"Mon, 01 Jan 2024 11:00:00 GMT", 0},
+		{"soon", 0},
+	}
+
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, now); got != tt.expected {
+			t.Errorf("parseRetryAfter(%q) = %v, expected %v", tt.value, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,490 @@
+package importer
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Next.js App Router pages stream their React Server Components payload ("flight data")
+// through inline scripts calling self.__next_f.push([type, "chunk"]). The chunks of type 1
+// joined together form rows of the form ID:TAG DATA, one per line, except for text rows
+// (tag T) that carry their length in bytes instead of ending with a newline.
+
+// pushCall starts every inline script call that carries flight data
+const pushCall = "self.__next_f.push("
+
+// Flight chunk types
+const (
+	flightBootstrap = 0 // start of the stream, no data
+	flightData      = 1 // part of the payload
+	flightFormState = 2 // form state, not part of the payload
+	flightBinary    = 3 // part of the payload, base64 encoded
+)
+
+// maxReferenceDepth bounds how deep row references are followed
+const maxReferenceDepth = 64
+
+// FlightRow is a row of the flight payload
+type FlightRow struct {
+	ID   string // hexadecimal row ID
+	Tag  string // "" for JSON rows, "T" for text, "I" for imports, "HL" for hints, ...
+	Data string
+}
+
+// FlightData is a parsed flight payload
+type FlightData struct {
+	Rows []FlightRow
+
+	byID      map[string]int
+	values    map[string]any
+	resolving map[string]bool
+}
+
+// ParseFlightHTML extracts the flight payload from the inline scripts of an HTML page and parses it
+func ParseFlightHTML(page io.Reader) (*FlightData, error) {
+	doc, err := goquery.NewDocumentFromReader(page)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse HTML: %w", err)
+	}
+
+	var payload strings.Builder
+	chunks := 0
+	doc.Find("script").EachWithBreak(func(i int, s *goquery.Selection) bool {
+		text := s.Text()
+		if !strings.Contains(text, pushCall) {
+			return true
+		}
+		chunks++
+
+		var script string
+		script, err = flightPayload(text)
+		if err != nil {
+			err = fmt.Errorf("script %d: %w", i, err)
+			return false
+		}
+		payload.WriteString(script)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if chunks == 0 {
+		return nil, fmt.Errorf("no Next.js flight data in page")
+	}
+
+	return ParseFlight(payload.String())
+}
+
+// flightPayload returns the payload carried by the push calls of a script
+func flightPayload(script string) (string, error) {
+	var payload strings.Builder
+
+	for pos := 0; ; {
+		start := strings.Index(script[pos:], pushCall)
+		if start < 0 {
+			return payload.String(), nil
+		}
+		pos += start + len(pushCall)
+
+		chunkType, chunk, n, err := parsePushArgument(script[pos:])
+		if err != nil {
+			return "", err
+		}
+		pos += n
+
+		switch chunkType {
+		case flightData:
+			payload.WriteString(chunk)
+		case flightBinary:
+			data, err := base64.StdEncoding.DecodeString(chunk)
+			if err != nil {
+				return "", fmt.Errorf("invalid binary flight chunk: %w", err)
+			}
+			payload.Write(data)
+		}
+	}
+}
+
+// parsePushArgument parses the [type] or [type, "chunk"] argument of a push call and
+// returns the chunk type, the decoded chunk and the number of bytes consumed
+func parsePushArgument(s string) (int, string, int, error) {
+	pos := skipSpace(s, 0)
+	if pos >= len(s) || s[pos] != '[' {
+		return 0, "", 0, errors.New("expected [ after push(")
+	}
+	pos = skipSpace(s, pos+1)
+
+	digits := pos
+	for pos < len(s) && s[pos] >= '0' && s[pos] <= '9' {
+		pos++
+	}
+	chunkType, err := strconv.Atoi(s[digits:pos])
+	if err != nil {
+		return 0, "", 0, errors.New("expected a chunk type in push()")
+	}
+	pos = skipSpace(s, pos)
+
+	var chunk string
+	if pos < len(s) && s[pos] == ',' {
+		pos = skipSpace(s, pos+1)
+		var n int
+		chunk, n, err = readJSString(s[pos:])
+		if err != nil {
+			return 0, "", 0, err
+		}
+		pos = skipSpace(s, pos+n)
+	}
+
+	if pos >= len(s) || s[pos] != ']' {
+		return 0, "", 0, errors.New("expected ] in push()")
+	}
+	pos = skipSpace(s, pos+1)
+	if pos >= len(s) || s[pos] != ')' {
+		return 0, "", 0, errors.New("expected ) after push argument")
+	}
+
+	return chunkType, chunk, pos + 1, nil
+}
+
+func skipSpace(s string, pos int) int {
+	for pos < len(s) && (s[pos] == ' ' || s[pos] == '\t' || s[pos] == '\n' || s[pos] == '\r') {
+		pos++
+	}
+	return pos
+}
+
+// readJSString reads a quoted JavaScript string literal at the start of s and returns
+// its value and the number of bytes consumed
+func readJSString(s string) (string, int, error) {
+	if s == "" || (s[0] != '"' && s[0] != '\'') {
+		return "", 0, errors.New("expected a string literal")
+	}
+	quote := s[0]
+
+	for pos := 1; pos < len(s); pos++ {
+		switch s[pos] {
+		case '\\':
+			pos++
+		case '\n', '\r':
+			return "", 0, errors.New("unescaped line break in string literal")
+		case quote:
+			value, err := decodeJSString(s[1:pos])
+			if err != nil {
+				return "", 0, err
+			}
+			return value, pos + 1, nil
+		}
+	}
+
+	return "", 0, errors.New("unterminated string literal")
+}
+
+// decodeJSString decodes the escape sequences of the body of a JavaScript string literal
+func decodeJSString(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+
+	var b strings.Builder
+	var units []uint16 // UTF-16 code units from \u escapes, joined to pair surrogates
+
+	flush := func() {
+		if len(units) > 0 {
+			b.WriteString(string(utf16.Decode(units)))
+			units = units[:0]
+		}
+	}
+
+	for i := 0; i < len(s); {
+		if s[i] != '\\' {
+			flush()
+			r, size := utf8.DecodeRuneInString(s[i:])
+			b.WriteRune(r)
+			i += size
+			continue
+		}
+
+		if i+1 >= len(s) {
+			return "", errors.New("string literal ends with a backslash")
+		}
+		escape := s[i+1]
+		i += 2
+
+		if escape == 'u' {
+			unit, n, err := decodeUnicodeEscape(s[i:])
+			if err != nil {
+				return "", err
+			}
+			i += n
+			if unit > 0xFFFF {
+				flush()
+				b.WriteRune(rune(unit))
+			} else {
+				units = append(units, uint16(unit))
+			}
+			continue
+		}
+		flush()
+
+		switch escape {
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'v':
+			b.WriteByte('\v')
+		case '0':
+			if i < len(s) && s[i] >= '0' && s[i] <= '9' {
+				return "", errors.New("octal escapes are not supported")
+			}
+			b.WriteByte(0)
+		case 'x':
+			if i+2 > len(s) {
+				return "", errors.New("truncated \\x escape")
+			}
+			value, err := strconv.ParseUint(s[i:i+2], 16, 8)
+			if err != nil {
+				return "", fmt.Errorf("invalid \\x escape %q", s[i:i+2])
+			}
+			b.WriteRune(rune(value))
+			i += 2
+		case '\r':
+			// Line continuation, \r\n counts as one line break
+			if i < len(s) && s[i] == '\n' {
+				i++
+			}
+		case '\n':
+			// Line continuation
+		case '1', '2', '3', '4', '5', '6', '7', '8', '9':
+			return "", errors.New("octal escapes are not supported")
+		default:
+			// Any other escaped character stands for itself, including multi-byte ones
+			r, size := utf8.DecodeRuneInString(s[i-1:])
+			i += size - 1
+			if r == '\u2028' || r == '\u2029' {
+				continue // line continuation
+			}
+			b.WriteRune(r)
+		}
+	}
+	flush()
+
+	return b.String(), nil
+}
+
+// decodeUnicodeEscape decodes the XXXX or {X...} part of a \u escape and returns the
+// code unit or code point and the number of bytes consumed
+func decodeUnicodeEscape(s string) (uint32, int, error) {
+	if strings.HasPrefix(s, "{") {
+		end := strings.IndexByte(s, '}')
+		if end < 2 || end > 7 {
+			return 0, 0, errors.New("invalid \\u{} escape")
+		}
+		value, err := strconv.ParseUint(s[1:end], 16, 32)
+		if err != nil || value > utf8.MaxRune {
+			return 0, 0, fmt.Errorf("invalid \\u{} escape %q", s[:end+1])
+		}
+		return uint32(value), end + 1, nil
+	}
+
+	if len(s) < 4 {
+		return 0, 0, errors.New("truncated \\u escape")
+	}
+	value, err := strconv.ParseUint(s[:4], 16, 16)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid \\u escape %q", s[:4])
+	}
+	return uint32(value), 4, nil
+}
+
+// ParseFlight splits a flight payload into rows
+func ParseFlight(payload string) (*FlightData, error) {
+	data := &FlightData{
+		byID:      make(map[string]int),
+		values:    make(map[string]any),
+		resolving: make(map[string]bool),
+	}
+
+	for pos := 0; pos < len(payload); {
+		if payload[pos] == '\n' {
+			pos++
+			continue
+		}
+
+		colon := strings.IndexByte(payload[pos:], ':')
+		if colon <= 0 || !isHex(payload[pos:pos+colon]) {
+			return nil, fmt.Errorf("invalid flight row at byte %d", pos)
+		}
+		row := FlightRow{ID: payload[pos : pos+colon]}
+		pos += colon + 1
+
+		tagStart := pos
+		for pos < len(payload) && payload[pos] >= 'A' && payload[pos] <= 'Z' {
+			pos++
+		}
+		row.Tag = payload[tagStart:pos]
+
+		if row.Tag == "T" {
+			comma := strings.IndexByte(payload[pos:], ',')
+			if comma <= 0 {
+				return nil, fmt.Errorf("text row %s has no length", row.ID)
+			}
+			length, err := strconv.ParseUint(payload[pos:pos+comma], 16, 32)
+			if err != nil {
+				return nil, fmt.Errorf("text row %s has an invalid length", row.ID)
+			}
+			pos += comma + 1
+			if uint64(len(payload)-pos) < length {
+				return nil, fmt.Errorf("text row %s is truncated", row.ID)
+			}
+			row.Data = payload[pos : pos+int(length)]
+			pos += int(length)
+		} else {
+			end := strings.IndexByte(payload[pos:], '\n')
+			if end < 0 {
+				end = len(payload) - pos
+			}
+			row.Data = payload[pos : pos+end]
+			pos += end
+		}
+
+		data.byID[row.ID] = len(data.Rows)
+		data.Rows = append(data.Rows, row)
+	}
+
+	return data, nil
+}
+
+func isHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// Value returns the decoded value of a row, with references to other rows resolved.
+// Text rows are strings; rows other than JSON and text rows are an error.
+func (f *FlightData) Value(id string) (any, error) {
+	return f.value(id, 0)
+}
+
+func (f *FlightData) value(id string, depth int) (any, error) {
+	if value, ok := f.values[id]; ok {
+		return value, nil
+	}
+
+	index, ok := f.byID[id]
+	if !ok {
+		return nil, fmt.Errorf("flight row %s not found", id)
+	}
+	row := f.Rows[index]
+
+	switch row.Tag {
+	case "T":
+		f.values[id] = row.Data
+		return row.Data, nil
+	case "":
+	default:
+		return nil, fmt.Errorf("flight row %s is not data (tag %s)", id, row.Tag)
+	}
+
+	var raw any
+	err := json.Unmarshal([]byte(row.Data), &raw)
+	if err != nil {
+		return nil, fmt.Errorf("flight row %s is not valid JSON: %w", id, err)
+	}
+
+	f.resolving[id] = true
+	value := f.resolve(raw, depth)
+	delete(f.resolving, id)
+
+	f.values[id] = value
+	return value, nil
+}
+
+// resolve replaces references to other rows ("$1a", "$@1a") with their values and unescapes "$$"
+func (f *FlightData) resolve(value any, depth int) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			v[key] = f.resolve(item, depth)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = f.resolve(item, depth)
+		}
+	case string:
+		if strings.HasPrefix(v, "$$") {
+			return v[1:]
+		}
+		id := strings.TrimPrefix(strings.TrimPrefix(v, "$"), "@")
+		if !strings.HasPrefix(v, "$") || !isHex(id) || depth >= maxReferenceDepth || f.resolving[id] {
+			return v
+		}
+		if resolved, err := f.value(id, depth+1); err == nil {
+			return resolved
+		}
+	}
+	return value
+}
+
+// Values returns the values of all JSON rows that decode, in payload order
+func (f *FlightData) Values() []any {
+	var values []any
+	for _, row := range f.Rows {
+		if row.Tag != "" {
+			continue
+		}
+		if value, err := f.Value(row.ID); err == nil {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// Find returns the first value stored under key anywhere in the payload
+func (f *FlightData) Find(key string) (any, error) {
+	for _, value := range f.Values() {
+		if found, err := FindKeyInData(value, key); err == nil {
+			return found, nil
+		}
+	}
+	return nil, fmt.Errorf("key '%s' not found in flight data", key)
+}
+
+// Decode finds the first value stored under key and decodes it into target, e.g. a struct
+func (f *FlightData) Decode(key string, target any) error {
+	value, err := f.Find(key)
+	if err != nil {
+		return err
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", key, err)
+	}
+
+	err = json.Unmarshal(encoded, target)
+	if err != nil {
+		return fmt.Errorf("failed to decode %s: %w", key, err)
+	}
+	return nil
+}
@@ -0,0 +1,246 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// jsQuote writes s as a JavaScript string literal with every non-ASCII character escaped
+// as \uXXXX, the way Next.js inlines flight chunks
+func jsQuote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r < 0x80:
+			b.WriteRune(r)
+		default:
+			for _, unit := range utf16.Encode([]rune{r}) {
+				fmt.Fprintf(&b, `\u%04x`, unit)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// flightPage renders a page streaming payload in the given chunks
+func flightPage(chunks ...string) string {
+	var b strings.Builder
+	b.WriteString(`<html><body><script>(self.__next_f=self.__next_f||[]).push([0])</script>`)
+	for _, chunk := range chunks {
+		fmt.Fprintf(&b, "<script>self.__next_f.push([1,%s])</script>\n", jsQuote(chunk))
+	}
+	b.WriteString(`</body></html>`)
+	return b.String()
+}
+
+// TestParseFlightHTML tests chunk concatenation, escapes, row kinds and references
+func TestParseFlightHTML(t *testing.T) {
+	title := "Кубок «Весна» 😀"
+	payload := "1:HL[\"/_next/static/css/app.css\",\"style\"]\n" +
+		"2:I[4021,[\"static/chunks/4021.js\"],\"Pack\"]\n" +
+		fmt.Sprintf("3:T%x,%s", len(title), title) +
+		"a:[\"$\",\"$L2\",null,{\"pack\":{\"id\":5220,\"title\":\"$3\",\"tours\":\"$b\"}}]\n" +
+		"b:[{\"number\":1,\"questions\":[{\"id\":1,\"text\":\"Вопрос\\nвторая строка\",\"answer\":\"$$100\"}]}]\n"
+
+	// Split the payload in the middle of rows
+	first, second := strings.Index(payload, "style"), strings.Index(payload, `"tours"`)
+	page := flightPage(payload[:first], payload[first:second], payload[second:])
+
+	flight, err := ParseFlightHTML(strings.NewReader(page))
+	if err != nil {
+		t.Fatalf("Failed to parse flight data: %v", err)
+	}
+
+	if len(flight.Rows) != 5 {
+		t.Fatalf("Expected 5 rows, got %d: %+v", len(flight.Rows), flight.Rows)
+	}
+	if flight.Rows[0].Tag != "HL" || flight.Rows[1].Tag != "I" || flight.Rows[2].Tag != "T" {
+		t.Errorf("Unexpected row tags %q %q %q", flight.Rows[0].Tag, flight.Rows[1].Tag, flight.Rows[2].Tag)
+	}
+	if flight.Rows[2].Data != title {
+		t.Errorf("Expected text row %q, got %q", title, flight.Rows[2].Data)
+	}
+
+	var pack struct {
+		ID    int    `json:"id"`
+		Title string `json:"title"`
+		Tours []struct {
+			Number    int `json:"number"`
+			Questions []struct {
+				ID     int    `json:"id"`
+				Text   string `json:"text"`
+				Answer string `json:"answer"`
+			} `json:"questions"`
+		} `json:"tours"`
+	}
+	if err := flight.Decode("pack", &pack); err != nil {
+		t.Fatalf("Failed to decode pack: %v", err)
+	}
+
+	if pack.ID != 5220 || pack.Title != title {
+		t.Errorf("Unexpected pack %d %q", pack.ID, pack.Title)
+	}
+	if len(pack.Tours) != 1 || len(pack.Tours[0].Questions) != 1 {
+		t.Fatalf("Expected the referenced tours to be resolved, got %+v", pack.Tours)
+	}
+	question := pack.Tours[0].Questions[0]
+	if question.Text != "Вопрос\nвторая строка" || question.Answer != "$100" {
+		t.Errorf("Unexpected question %+v", question)
+	}
+
+	if _, err := flight.Value("2"); err == nil {
+		t.Error("Import rows should not decode as data")
+	}
+}
+
+// TestParseFlightCycle tests that rows referencing each other do not loop forever
+func TestParseFlightCycle(t *testing.T) {
+	flight, err := ParseFlight("a:{\"next\":\"$b\"}\nb:{\"next\":\"$a\"}\n")
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	value, err := flight.Value("a")
+	if err != nil {
+		t.Fatalf("Failed to get value: %v", err)
+	}
+	next := value.(map[string]any)["next"].(map[string]any)
+	if next["next"] != "$a" {
+		t.Errorf("Expected the cycle to stop at the unresolved reference, got %v", next["next"])
+	}
+}
+
+// TestParseFlightErrors tests that malformed payloads are reported
+func TestParseFlightErrors(t *testing.T) {
+	for _, payload := range []string{
+		"zz:{}\n",
+		":{}\n",
+		"1:T10,short",
+		"1:Tzz,text",
+		"no colon",
+	} {
+		if _, err := ParseFlight(payload); err == nil {
+			t.Errorf("Expected an error for %q", payload)
+		}
+	}
+
+	for _, page := range []string{
+		`<script>self.__next_f.push([1,"unterminated])</script>`,
+		`<script>self.__next_f.push([1,"\u12"])</script>`,
+		`<script>self.__next_f.push("5:{}")</script>`,
+		`<html>no flight data</html>`,
+	} {
+		if _, err := ParseFlightHTML(strings.NewReader(page)); err == nil {
+			t.Errorf("Expected an error for %q", page)
+		}
+	}
+}
+
+// TestDecodeJSString tests JavaScript escape sequences
+func TestDecodeJSString(t *testing.T) {
+	tests := []struct {
+		literal  string
+		expected string
+	}{
+		{`plain`, "plain"},
+		{`\"quoted\" \'single\' \\ \/`, `"quoted" 'single' \ /`},
+		{`\n\r\t\b\f\v\0`, "\n\r\t\b\f\v\x00"},
+		{`\u0041\u00e9\u041a`, "AéК"},
+		{`\uD83D\uDE00`, "😀"},
+		{`\u{1F600}`, "😀"},
+		{`\x41\xe9`, "Aé"},
+		{"line\\\ncontinued", "linecontinued"},
+		{`\uD83D!`, "\uFFFD!"},
+		{`\q`, "q"},
+		{`Вопрос`, "Вопрос"},
+	}
+
+	for _, tt := range tests {
+		got, err := decodeJSString(tt.literal)
+		if err != nil || got != tt.expected {
+			t.Errorf("decodeJSString(%q) = %q, %v; expected %q", tt.literal, got, err, tt.expected)
+		}
+	}
+
+	for _, literal := range []string{`\u12`, `\uZZZZ`, `\x4`, `trailing\`, `\u{110000}`, `\01`, `\7`} {
+		if _, err := decodeJSString(literal); err == nil {
+			t.Errorf("Expected an error for %q", literal)
+		}
+	}
+}
+
+// FuzzDecodeJSString tests that any string encoded as a JSON literal, which is valid
+// JavaScript, decodes back to itself
+func FuzzDecodeJSString(f *testing.F) {
+	for _, seed := range []string{"", "plain", "Вопрос «в кавычках»", "😀", "\x00\n\t\"\\", "<script>&"} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, s string) {
+		if !utf8.ValidString(s) {
+			return
+		}
+
+		encoded, err := json.Marshal(s)
+		if err != nil {
+			t.Fatalf("Failed to encode: %v", err)
+		}
+
+		decoded, err := decodeJSString(string(encoded[1 : len(encoded)-1]))
+		if err != nil {
+			t.Fatalf("Failed to decode %s: %v", encoded, err)
+		}
+		if decoded != s {
+			t.Errorf("Round trip of %q gave %q", s, decoded)
+		}
+
+		// Arbitrary literal bodies must not panic
+		decodeJSString(s)
+	})
+}
+
+// FuzzParseFlight tests that malformed payloads never panic or loop
+func FuzzParseFlight(f *testing.F) {
+	f.Add("1:{\"a\":\"$2\"}\n2:[1,2,3]\n")
+	f.Add("3:T5,hello4:HL[]\n")
+	f.Add("a:\"$a\"\n")
+	f.Add("1:T")
+	f.Add("1:{\"x\":\"$@1\"}")
+
+	f.Fuzz(func(t *testing.T, payload string) {
+		flight, err := ParseFlight(payload)
+		if err != nil {
+			return
+		}
+		flight.Values()
+		flight.Find("pack")
+	})
+}
+
+// FuzzParseFlightHTML tests that malformed pages never panic
+func FuzzParseFlightHTML(f *testing.F) {
+	f.Add(flightPage("1:{\"pack\":{\"id\":1}}\n"))
+	f.Add(`<script>self.__next_f.push([1,"a:\u0022x\u0022\n"])</script>`)
+	f.Add(`<script>self.__next_f.push([3,"MTp7fQo="])</script>`)
+	f.Add(`<script>self.__next_f.push([1, 'single\'quoted'])</script>`)
+	f.Add(`<script>self.__next_f.push(`)
+
+	f.Fuzz(func(t *testing.T, page string) {
+		flight, err := ParseFlightHTML(strings.NewReader(page))
+		if err != nil {
+			return
+		}
+		flight.Find("pack")
+	})
+}
@@ -0,0 +1,328 @@
+package importer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"questions-vote/internal/models"
+	"strings"
+	"time"
+)
+
+// ValidationError describes a field of gotquestions.online data that is missing or malformed
+type ValidationError struct {
+	PackageID int    // 0 if the package is not known
+	Item      string // the part of the package, e.g. "question 90001"; empty for the package itself
+	Field     string
+	Problem   string
+}
+
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	if e.PackageID != 0 {
+		fmt.Fprintf(&b, "package %d", e.PackageID)
+	} else {
+		b.WriteString("package")
+	}
+	if e.Item != "" {
+		b.WriteString(", " + e.Item)
+	}
+	fmt.Fprintf(&b, ": field %s %s", e.Field, e.Problem)
+	return b.String()
+}
+
+// gotquestionsTime is a timestamp as gotquestions.online writes it, without a time zone
+type gotquestionsTime struct {
+	time.Time
+	raw string // the value that failed to parse, if any
+}
+
+// UnmarshalJSON parses timestamps like 2022-03-12T00:00:00 as UTC. A value that is not
+// a date is kept for validation to report.
+func (t *gotquestionsTime) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+
+	var value string
+	err := json.Unmarshal(data, &value)
+	if err != nil {
+		return err
+	}
+
+	for _, layout := range []string{"2006-01-02T15:04:05", time.RFC3339, "2006-01-02"} {
+		parsed, err := time.ParseInLocation(layout, value, time.UTC)
+		if err == nil {
+			t.Time = parsed
+			return nil
+		}
+	}
+	t.raw = value
+	return nil
+}
+
+// problem describes why the timestamp is not set
+func (t *gotquestionsTime) problem() string {
+	if t.raw != "" {
+		return fmt.Sprintf("is not a date: %q", t.raw)
+	}
+	return "is missing"
+}
+
+// PersonData is an author or editor
+type PersonData struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// PackListEntry is a package on a page of the package list
+type PackListEntry struct {
+	ID             int              `json:"id"`
+	Title          string           `json:"title"`
+	StartDate      gotquestionsTime `json:"startDate"`
+	EndDate        gotquestionsTime `json:"endDate"`
+	QuestionsCount int              `json:"questions"`
+}
+
+// PackData is a package page
+type PackData struct {
+	ID        int              `json:"id"`
+	Title     string           `json:"title"`
+	StartDate gotquestionsTime `json:"startDate"`
+	EndDate   gotquestionsTime `json:"endDate"`
+	Editors   []PersonData     `json:"editors"`
+	Tours     []TourData       `json:"tours"`
+}
+
+// TourData is a tour of a package. Questions are decoded one by one, so that a broken
+// question does not lose the rest of the package.
+type TourData struct {
+	ID        int               `json:"id"`
+	Number    int               `json:"number"`
+	Title     string            `json:"title"`
+	Editors   []PersonData      `json:"editors"`
+	Questions []json.RawMessage `json:"questions"`
+}
+
+// QuestionData is a question of a package
+type QuestionData struct {
+	ID              int          `json:"id"`
+	Number          int          `json:"number"`
+	Text            string       `json:"text"`
+	Answer          string       `json:"answer"`
+	Zachet          string       `json:"zachet"`
+	Comment         string       `json:"comment"`
+	Source          string       `json:"source"`
+	RazdatkaText    string       `json:"razdatkaText"`
+	RazdatkaPic     string       `json:"razdatkaPic"`
+	Complexity      *float64     `json:"complexity"`
+	TakenDown       bool         `json:"takenDown"`
+	TakenDownReason string       `json:"takenDownReason"`
+	Authors         []PersonData `json:"authors"`
+}
+
+// decodeData decodes a value found in the page data into target. Type mismatches are
+// reported as validation errors naming the field.
+func decodeData(value any, target any, packageID int, item string) error {
+	var raw []byte
+	if message, ok := value.(json.RawMessage); ok {
+		raw = message
+	} else {
+		var err error
+		raw, err = json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s: %w", item, err)
+		}
+	}
+
+	err := json.Unmarshal(raw, target)
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return &ValidationError{
+			PackageID: packageID,
+			Item:      item,
+			Field:     typeErr.Field,
+			Problem:   fmt.Sprintf("should be %s, got %s", typeErr.Type, typeErr.Value),
+		}
+	}
+	if err != nil {
+		return &ValidationError{PackageID: packageID, Item: item, Field: "?", Problem: err.Error()}
+	}
+	return nil
+}
+
+// DecodePackListEntry decodes and validates a package from the package list
+func DecodePackListEntry(value any) (*PackListEntry, error) {
+	entry := &PackListEntry{}
+	err := decodeData(value, entry, 0, "")
+	if err != nil {
+		return nil, err
+	}
+
+	invalid := func(field, problem string) error {
+		return &ValidationError{PackageID: entry.ID, Field: field, Problem: problem}
+	}
+	switch {
+	case entry.ID <= 0:
+		return nil, invalid("id", "is missing")
+	case entry.Title == "":
+		return nil, invalid("title", "is empty")
+	case entry.StartDate.IsZero():
+		return nil, invalid("startDate", entry.StartDate.problem())
+	case entry.EndDate.IsZero():
+		return nil, invalid("endDate", entry.EndDate.problem())
+	}
+
+	return entry, nil
+}
+
+// Package converts the entry into a package
+func (e *PackListEntry) Package() *models.Package {
+	return &models.Package{
+		GotQuestionsID: e.ID,
+		Title:          e.Title,
+		StartDate:      e.StartDate.Time,
+		EndDate:        e.EndDate.Time,
+		QuestionsCount: e.QuestionsCount,
+	}
+}
+
+// DecodePack decodes a package page
+func DecodePack(value any, packageID int) (*PackData, error) {
+	pack := &PackData{}
+	err := decodeData(value, pack, packageID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	if pack.Tours == nil {
+		return nil, &ValidationError{PackageID: packageID, Field: "tours", Problem: "is missing"}
+	}
+	return pack, nil
+}
+
+// Questions decodes and validates the questions of all tours. Questions that fail are
+// left out and their errors returned.
+func (p *PackData) Questions(packageID int) ([]*models.Question, []error) {
+	var questions []*models.Question
+	var errs []error
+
+	for tourIndex, tour := range p.Tours {
+		tourNumber := tour.Number
+		if tourNumber == 0 {
+			tourNumber = tourIndex + 1
+		}
+
+		for questionIndex, raw := range tour.Questions {
+			item := fmt.Sprintf("tour %d, question #%d", tourNumber, questionIndex+1)
+
+			data := &QuestionData{}
+			err := decodeData(raw, data, packageID, item)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+
+			question, err := data.question(packageID, tourNumber)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			questions = append(questions, question)
+		}
+	}
+
+	return questions, errs
+}
+
+// question validates the data and converts it into a question of the package
+func (q *QuestionData) question(packageID, tourNumber int) (*models.Question, error) {
+	invalid := func(field, problem string) error {
+		return &ValidationError{
+			PackageID: packageID,
+			Item:      fmt.Sprintf("question %d", q.ID),
+			Field:     field,
+			Problem:   problem,
+		}
+	}
+	// Empty texts and answers are stored for ValidatePackage to report
+	if q.ID <= 0 {
+		return nil, invalid("id", "is missing")
+	}
+
+	var authorID *int
+	if len(q.Authors) > 0 {
+		authorID = &q.Authors[0].ID
+	}
+	authors, err := people(q.Authors, packageID, fmt.Sprintf("question %d", q.ID), "authors")
+	if err != nil {
+		return nil, err
+	}
+
+	difficulty := 0.0
+	if q.Complexity != nil {
+		difficulty = *q.Complexity
+	}
+	takenDown := q.TakenDown
+
+	return &models.Question{
+		GotQuestionsID:  q.ID,
+		Number:          q.Number,
+		TourNumber:      tourNumber,
+		Question:        q.Text,
+		Answer:          q.Answer,
+		AcceptedAnswer:  q.Zachet,
+		Comment:         q.Comment,
+		HandoutStr:      q.RazdatkaText,
+		HandoutImg:      q.RazdatkaPic,
+		Source:          q.Source,
+		AuthorID:        authorID,
+		PackageID:       &packageID,
+		Difficulty:      &difficulty,
+		IsIncorrect:     &takenDown,
+		TakenDownReason: q.TakenDownReason,
+		Authors:         authors,
+	}, nil
+}
+
+// TourList returns the tours of the package with their editors. Editors of the whole
+// package are returned as a tour numbered 0.
+func (p *PackData) TourList(packageID int) ([]*models.Tour, error) {
+	editors, err := people(p.Editors, packageID, "", "editors")
+	if err != nil {
+		return nil, err
+	}
+	tours := []*models.Tour{{PackageID: packageID, Title: p.Title, Editors: editors}}
+
+	for tourIndex, tour := range p.Tours {
+		number := tour.Number
+		if number == 0 {
+			number = tourIndex + 1
+		}
+
+		editors, err := people(tour.Editors, packageID, fmt.Sprintf("tour %d", number), "editors")
+		if err != nil {
+			return nil, err
+		}
+		tours = append(tours, &models.Tour{PackageID: packageID, Number: number, Title: tour.Title, Editors: editors})
+	}
+
+	return tours, nil
+}
+
+// people validates a list of authors or editors
+func people(list []PersonData, packageID int, item, field string) ([]models.Author, error) {
+	var authors []models.Author
+	for i, person := range list {
+		if person.ID <= 0 || strings.TrimSpace(person.Name) == "" {
+			return nil, &ValidationError{
+				PackageID: packageID,
+				Item:      item,
+				Field:     fmt.Sprintf("%s.%d", field, i),
+				Problem:   "has no id or name",
+			}
+		}
+		authors = append(authors, models.Author{ID: person.ID, Name: strings.TrimSpace(person.Name)})
+	}
+	return authors, nil
+}
@@ -0,0 +1,142 @@
+package importer
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// decodeJSON parses a JSON literal into the generic value found in page data
+func decodeJSON(t *testing.T, literal string) any {
+	t.Helper()
+
+	var value any
+	if err := json.Unmarshal([]byte(literal), &value); err != nil {
+		t.Fatalf("Invalid test JSON: %v", err)
+	}
+	return value
+}
+
+// TestDecodePack tests that tours, numbers, authors, editors and reasons are all captured
+func TestDecodePack(t *testing.T) {
+	value := decodeJSON(t, `{
+		"id": 5220, "title": "Кубок", "startDate": "2022-03-12T00:00:00",
+		"editors": [{"id": 3, "name": "Редактор"}],
+		"tours": [
+			{"number": 1, "questions": [{"id": 1, "number": 1, "text": "Вопрос 1", "answer": "Ответ 1"}]},
+			{"number": 2, "editors": [{"id": 4, "name": "Редактор тура"}], "questions": [
+				{"id": 2, "number": 2, "text": "Вопрос 2", "answer": "Ответ 2", "complexity": 2.5,
+				 "takenDown": true, "takenDownReason": "Неточность",
+				 "authors": [{"id": 17, "name": "Первый"}, {"id": 18, "name": "Второй"}]}
+			]}
+		]
+	}`)
+
+	pack, err := DecodePack(value, 5220)
+	if err != nil {
+		t.Fatalf("Failed to decode pack: %v", err)
+	}
+	if len(pack.Editors) != 1 || pack.Editors[0].Name != "Редактор" || pack.StartDate.Day() != 12 {
+		t.Errorf("Unexpected pack %+v", pack)
+	}
+	if len(pack.Tours[1].Editors) != 1 || pack.Tours[1].Editors[0].ID != 4 {
+		t.Errorf("Expected the tour editor, got %+v", pack.Tours[1].Editors)
+	}
+
+	questions, errs := pack.Questions(5220)
+	if len(errs) != 0 || len(questions) != 2 {
+		t.Fatalf("Expected 2 questions, got %d (%v)", len(questions), errs)
+	}
+
+	second := questions[1]
+	if second.TourNumber != 2 || second.Number != 2 || *second.PackageID != 5220 {
+		t.Errorf("Unexpected position %+v", second)
+	}
+	if !*second.IsIncorrect || second.TakenDownReason != "Неточность" || *second.Difficulty != 2.5 {
+		t.Errorf("Unexpected taken down question %+v", second)
+	}
+	if second.AuthorID == nil || *second.AuthorID != 17 {
+		t.Errorf("Expected the first author as the question author, got %v", second.AuthorID)
+	}
+
+	var data QuestionData
+	if err := json.Unmarshal(pack.Tours[1].Questions[0], &data); err != nil || len(data.Authors) != 2 {
+		t.Errorf("Expected both authors, got %+v (%v)", data.Authors, err)
+	}
+}
+
+// TestDecodeValidationErrors tests that errors name the package, the question and the field
+func TestDecodeValidationErrors(t *testing.T) {
+	value := decodeJSON(t, `{"tours": [{"number": 3, "questions": [
+		{"id": 1, "text": "Вопрос", "answer": "Ответ"},
+		{"id": 2, "text": "Вопрос без ответа", "answer": " "},
+		{"id": "3", "text": "Вопрос", "answer": "Ответ"},
+		{"id": 4, "text": "Вопрос", "answer": "Ответ", "authors": [{"id": 17, "name": 5}]},
+		{"text": "Вопрос без номера", "answer": "Ответ"}
+	]}]}`)
+
+	pack, err := DecodePack(value, 5220)
+	if err != nil {
+		t.Fatalf("Failed to decode pack: %v", err)
+	}
+
+	questions, errs := pack.Questions(5220)
+	// An empty answer is left to ValidatePackage to report
+	if len(questions) != 2 || questions[0].GotQuestionsID != 1 || questions[0].TourNumber != 3 ||
+		questions[1].GotQuestionsID != 2 {
+		t.Errorf("Expected the questions with IDs, got %+v", questions)
+	}
+
+	expected := []string{
+		"package 5220, tour 3, question #3: field id should be int, got string",
+		"package 5220, tour 3, question #4: field authors.0.name should be string, got number",
+		"package 5220, question 0: field id is missing",
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("Expected %d errors, got %v", len(expected), errs)
+	}
+	for i, err := range errs {
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) || err.Error() != expected[i] {
+			t.Errorf("Expected %q, got %q", expected[i], err)
+		}
+	}
+
+	_, err = DecodePack(decodeJSON(t, `{"id": 5221}`), 5221)
+	if err == nil || err.Error() != "package 5221: field tours is missing" {
+		t.Errorf("Expected missing tours to be reported, got %v", err)
+	}
+}
+
+// TestDecodePackListEntry tests decoding and validating packages from the package list
+func TestDecodePackListEntry(t *testing.T) {
+	entry, err := DecodePackListEntry(decodeJSON(t,
+		`{"id": 5220, "title": "Кубок", "startDate": "2022-03-12T00:00:00", "endDate": "2022-03-20T00:00:00", "questions": 36}`))
+	if err != nil {
+		t.Fatalf("Failed to decode entry: %v", err)
+	}
+	pkg := entry.Package()
+	if pkg.GotQuestionsID != 5220 || pkg.QuestionsCount != 36 || pkg.EndDate.Day() != 20 {
+		t.Errorf("Unexpected package %+v", pkg)
+	}
+
+	tests := []struct {
+		literal  string
+		expected string
+	}{
+		{`{"id": 1, "title": "", "startDate": "2022-03-12T00:00:00", "endDate": "2022-03-20T00:00:00"}`,
+			"package 1: field title is empty"},
+		{`{"id": 1, "title": "Кубок", "startDate": "2022-03-12T00:00:00"}`,
+			"package 1: field endDate is missing"},
+		{`{"id": 1, "title": "Кубок", "questions": "много"}`,
+			"package: field questions should be int, got string"},
+		{`{"id": 1, "title": "Кубок", "startDate": "12.03.2022"}`,
+			`package 1: field startDate is not a date: "12.03.2022"`},
+	}
+	for _, tt := range tests {
+		_, err := DecodePackListEntry(decodeJSON(t, tt.literal))
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("Expected %q, got %v", tt.expected, err)
+		}
+	}
+}
@@ -1,98 +1,60 @@
 package importer
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"net/http"
-	"regexp"
-	"strings"
-
-	"github.com/PuerkitoBio/goquery"
+	"io"
 )
 
-// ExtractNextJsDataFromURL fetches a URL and extracts Next.js data
+// ExtractNextJsDataFromURL fetches a URL with the shared importer client and extracts Next.js data
 func ExtractNextJsDataFromURL(url string) (any, error) {
-	response, err := http.Get(url)
+	body, _, err := DefaultClient().Get(context.Background(), url)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch URL %s: %w", url, err)
-	}
-
-	if response.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("HTTP %d when fetching %s", response.StatusCode, url)
+		return nil, err
 	}
 
-	defer response.Body.Close()
-
-	script, found := findScriptWithSubstrings(response, []string{"questions", "pack"})
-	if !found {
-		return nil, fmt.Errorf("failed to find <script> with questions")
-	}
+	return ExtractNextJsData(bytes.NewReader(body))
+}
 
-	fixedScript, err := extractJSONFromNextJSPush(script)
+// FetchNextJsData reads a page from a source and extracts its Next.js data
+func FetchNextJsData(ctx context.Context, source PageSource, path string) (any, error) {
+	body, _, err := source.Fetch(ctx, path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to extract JSON from push(): %w", err)
+		return nil, err
 	}
 
-	var parsedJSON any
-	err = json.Unmarshal([]byte(fixedScript), &parsedJSON)
+	data, err := ExtractNextJsData(bytes.NewReader(body))
 	if err != nil {
-		return nil, fmt.Errorf("error parsing JSON: %v", err)
+		return nil, fmt.Errorf("failed to extract data from %s: %w", path, err)
 	}
-
-	return parsedJSON, nil
+	return data, nil
 }
 
-// extractJSONFromNextJSPush extracts the JSON string from a self.__next_f.push() call
-func extractJSONFromNextJSPush(line string) (string, error) {
-	line = strings.TrimSpace(line)
-
-	// Regex to match self.__next_f.push([number, "almost_json_string"])
-	re := regexp.MustCompile(`self\.__next_f\.push\(\[.*?,"(.*)"\]\)`)
-	matches := re.FindStringSubmatch(line)
-
-	if len(matches) < 2 {
-		return "", fmt.Errorf("could not find JSON string in the line")
-	}
-
-	jsonStr := matches[1]
-	// almost_json_string starts with "5:" and ends with \n
-	jsonStr = jsonStr[2 : len(jsonStr)-2]
-	jsonStr = strings.ReplaceAll(jsonStr, `\"`, `"`)
-	jsonStr = strings.ReplaceAll(jsonStr, `\\`, `\`)
-
-	return jsonStr, nil
-}
-
-// findScriptWithSubstrings searches for a script element whose content includes all the given substrings
-func findScriptWithSubstrings(response *http.Response, substrings []string) (string, bool) {
-	doc, err := goquery.NewDocumentFromReader(response.Body)
+// ExtractNextJsData extracts the values of the Next.js flight data of an HTML page, or
+// parses the data directly if the page is a JSON snapshot of it
+func ExtractNextJsData(page io.Reader) (any, error) {
+	content, err := io.ReadAll(page)
 	if err != nil {
-		return "", false
+		return nil, fmt.Errorf("failed to read page: %w", err)
 	}
 
-	var result string
-	found := false
-
-	doc.Find("script").EachWithBreak(func(i int, s *goquery.Selection) bool {
-		scriptContent := strings.TrimSpace(s.Text())
-
-		allPresent := true
-		for _, substring := range substrings {
-			if !strings.Contains(scriptContent, substring) {
-				allPresent = false
-				break
-			}
+	if trimmed := bytes.TrimSpace(content); len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		var data any
+		err = json.Unmarshal(trimmed, &data)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing JSON: %v", err)
 		}
+		return data, nil
+	}
 
-		if allPresent {
-			result = scriptContent
-			found = true
-			return false
-		}
-		return true
-	})
+	flight, err := ParseFlightHTML(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
 
-	return result, found
+	return flight.Values(), nil
 }
 
 // FindKeyInData recursively searches for the key in nested data
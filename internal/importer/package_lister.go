@@ -1,25 +1,26 @@
 package importer
 
 import (
+	"context"
 	"fmt"
 	"log"
-	"math/rand"
 	"questions-vote/internal/models"
-	"time"
 )
 
 // PackageLister fetches and stores package information from gotquestions.online
 type PackageLister struct {
 	FirstPage int
 	LastPage  int
+	Source    PageSource // where list pages are read from
 	repo      *models.PackageRepository
 }
 
-// NewPackageLister creates a new package lister
+// NewPackageLister creates a new package lister reading from the default source
 func NewPackageLister(firstPage, lastPage int) *PackageLister {
 	return &PackageLister{
 		FirstPage: firstPage,
 		LastPage:  lastPage,
+		Source:    DefaultSource(),
 		repo:      models.NewPackageRepository(),
 	}
 }
@@ -39,67 +40,112 @@ func (pl *PackageLister) Run() error {
 			// Continue with next page instead of failing completely
 			continue
 		}
-
-		// Sleep to avoid rate limiting
-		SleepAround(1, 0.5)
 	}
 
 	log.Printf("Completed package listing")
 	return nil
 }
 
-// CreatePackagesFromPage fetches packages from a specific page
+// CreatePackagesFromPage fetches packages from a specific page, storing new ones and
+// updating the ones that changed
 func (pl *PackageLister) CreatePackagesFromPage(page int) error {
-	url := fmt.Sprintf("https://gotquestions.online/?page=%d", page)
+	_, err := pl.syncPage(page, &SyncSummary{})
+	return err
+}
 
-	nextJsData, err := ExtractNextJsDataFromURL(url)
-	if err != nil {
-		return fmt.Errorf("failed to extract data from page %d: %w", page, err)
-	}
+// PackageChange is a stored package whose listing changed
+type PackageChange struct {
+	Package *models.Package // as listed now
+	Fields  []string        // the fields that changed
+}
 
-	packsKeyValue, err := FindKeyInData(nextJsData, "packs")
-	if err != nil {
-		return fmt.Errorf("failed to find packs in page %d: %w", page, err)
+// SyncSummary is the outcome of syncing the package list
+type SyncSummary struct {
+	Pages   int
+	New     []*models.Package
+	Changed []*PackageChange
+}
+
+// Sync walks the list from FirstPage, the newest packages, storing new packages and updating
+// changed ones. It stops after a page with only known, unchanged packages, an empty page or LastPage.
+func (pl *PackageLister) Sync() (*SyncSummary, error) {
+	summary := &SyncSummary{}
+
+	for page := pl.FirstPage; page <= pl.LastPage; page++ {
+		changes, err := pl.syncPage(page, summary)
+		if err != nil {
+			return summary, fmt.Errorf("failed to sync page %d: %w", page, err)
+		}
+		summary.Pages++
+
+		if changes == 0 {
+			break
+		}
 	}
 
-	packs, ok := packsKeyValue.([]any)
-	if !ok {
-		return fmt.Errorf("packs was not a list in page %d: %w", page, err)
+	log.Printf("Synced %d pages: %d new, %d changed packages", summary.Pages, len(summary.New), len(summary.Changed))
+	return summary, nil
+}
+
+// syncPage upserts the packages of a page, adding them to the summary. It returns the number
+// of new and changed packages, or 0 if the page lists no packages.
+func (pl *PackageLister) syncPage(page int, summary *SyncSummary) (int, error) {
+	packages, err := pl.fetchPage(page)
+	if err != nil {
+		return 0, err
 	}
 
-	log.Printf("Found %d packs", len(packs))
-	for _, packInterface := range packs {
-		packDict, ok := packInterface.(map[string]any)
-		if !ok {
-			log.Printf("Skipping invalid package structure on page %d", page)
-			continue
+	changes := 0
+	for _, pkg := range packages {
+		stored, err := pl.repo.Upsert(pkg)
+		if err != nil {
+			return changes, fmt.Errorf("failed to store package %d: %w", pkg.GotQuestionsID, err)
 		}
 
-		pkg, err := models.BuildPackageFromDict(packDict)
-		if err != nil {
-			log.Printf("Failed to build package from data on page %d: %v", page, err)
+		if stored == nil {
+			log.Printf("Inserted package %d", pkg.GotQuestionsID)
+			summary.New = append(summary.New, pkg)
+			changes++
 			continue
 		}
 
-		err = pl.repo.Insert(pkg)
-		if err != nil {
-			log.Printf("Failed to insert package %d: %v", pkg.GotQuestionsID, err)
-			continue
+		if fields := stored.ChangedFields(pkg); len(fields) > 0 {
+			log.Printf("Updated package %d: %v", pkg.GotQuestionsID, fields)
+			summary.Changed = append(summary.Changed, &PackageChange{Package: pkg, Fields: fields})
+			changes++
 		}
-		log.Printf("Inserted package %d", pkg.GotQuestionsID)
 	}
 
-	return nil
+	return changes, nil
 }
 
-// SleepAround sleeps for a random duration around the specified seconds
-func SleepAround(seconds float64, deviation float64) {
-	min := seconds - deviation
-	max := seconds + deviation
-	if min < 0 {
-		min = 0
+// fetchPage reads the packages listed on a page without storing them
+func (pl *PackageLister) fetchPage(page int) ([]*models.Package, error) {
+	nextJsData, err := FetchNextJsData(context.Background(), pl.Source, ListPagePath(page))
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract data from page %d: %w", page, err)
+	}
+
+	packsKeyValue, err := FindKeyInData(nextJsData, "packs")
+	if err != nil {
+		return nil, fmt.Errorf("failed to find packs in page %d: %w", page, err)
+	}
+
+	packs, ok := packsKeyValue.([]any)
+	if !ok {
+		return nil, fmt.Errorf("packs was not a list in page %d", page)
+	}
+
+	log.Printf("Found %d packs", len(packs))
+	var packages []*models.Package
+	for _, packValue := range packs {
+		entry, err := DecodePackListEntry(packValue)
+		if err != nil {
+			log.Printf("Skipping package on page %d: %v", page, err)
+			continue
+		}
+		packages = append(packages, entry.Package())
 	}
 
-	duration := min + rand.Float64()*(max-min)
-	time.Sleep(time.Duration(duration * float64(time.Second)))
+	return packages, nil
 }
@@ -0,0 +1,82 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"questions-vote/internal/testutil"
+	"testing"
+	"testing/fstest"
+)
+
+// listPage renders a list page snapshot with packages numbered from first down to last
+func listPage(t *testing.T, first, last int, titles map[int]string) *fstest.MapFile {
+	t.Helper()
+
+	packs := []map[string]any{}
+	for id := first; id >= last; id-- {
+		title := titles[id]
+		if title == "" {
+			title = fmt.Sprintf("Пакет %d", id)
+		}
+		packs = append(packs, map[string]any{
+			"id": id, "title": title, "questions": 36,
+			"startDate": "2024-05-01T00:00:00", "endDate": "2024-05-10T00:00:00",
+		})
+	}
+
+	data, err := json.Marshal(map[string]any{"packs": packs})
+	if err != nil {
+		t.Fatalf("Failed to encode list page: %v", err)
+	}
+	return &fstest.MapFile{Data: data}
+}
+
+// TestPackageListerSync tests that syncing stops at the first page without changes
+func TestPackageListerSync(t *testing.T) {
+	testutil.OpenDB(t)
+
+	site := fstest.MapFS{
+		"pages/1.json": listPage(t, 6, 5, nil),
+		"pages/2.json": listPage(t, 4, 3, nil),
+		"pages/3.json": listPage(t, 2, 1, nil),
+		"pages/4.json": listPage(t, 0, 1, nil),
+	}
+	lister := NewPackageLister(1, 10)
+	lister.Source = NewSnapshotSource(site)
+
+	summary, err := lister.Sync()
+	if err != nil {
+		t.Fatalf("Failed to sync: %v", err)
+	}
+	if summary.Pages != 4 || len(summary.New) != 6 || len(summary.Changed) != 0 {
+		t.Errorf("Expected the first sync to walk to the empty page, got %d pages, %d new, %d changed",
+			summary.Pages, len(summary.New), len(summary.Changed))
+	}
+
+	summary, err = lister.Sync()
+	if err != nil {
+		t.Fatalf("Failed to sync: %v", err)
+	}
+	if summary.Pages != 1 || len(summary.New) != 0 || len(summary.Changed) != 0 {
+		t.Errorf("Expected an unchanged list to stop after the first page, got %+v", summary)
+	}
+
+	// A new package pushes the others down and an older one is renamed
+	site["pages/1.json"] = listPage(t, 7, 6, nil)
+	site["pages/2.json"] = listPage(t, 5, 4, map[int]string{4: "Пакет 4 (исправлен)"})
+	summary, err = lister.Sync()
+	if err != nil {
+		t.Fatalf("Failed to sync: %v", err)
+	}
+	if summary.Pages != 3 || len(summary.New) != 1 || summary.New[0].GotQuestionsID != 7 {
+		t.Errorf("Expected package 7 to be new after 3 pages, got %d pages, %+v", summary.Pages, summary.New)
+	}
+	if len(summary.Changed) != 1 || summary.Changed[0].Package.GotQuestionsID != 4 ||
+		len(summary.Changed[0].Fields) != 1 || summary.Changed[0].Fields[0] != "title" {
+		t.Errorf("Expected the title of package 4 to change, got %+v", summary.Changed)
+	}
+
+	if title := countRows(t, `SELECT COUNT(*) FROM packages WHERE title = 'Пакет 4 (исправлен)'`); title != 1 {
+		t.Error("Expected the new title to be stored")
+	}
+}
@@ -1,240 +1,567 @@
 package importer
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
 	"questions-vote/internal/db"
 	"questions-vote/internal/models"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // PackageParser fetches and stores questions from a specific package
 type PackageParser struct {
 	PackageID int
-	URL       string
-	Rewrite   bool
+	Path      string      // path of the package page within the source
+	Source    PageSource  // where pages and pictures are read from
+	Rewrite   bool        // update questions that are already stored
+	Stats     ImportStats // what the last import did
 
 	questionRepo *models.QuestionRepository
 }
 
-// NewPackageParser creates a new package parser
+// NewPackageParser creates a new package parser reading from the default source
 func NewPackageParser(packageID int, rewrite bool) *PackageParser {
 	return &PackageParser{
 		PackageID:    packageID,
-		URL:          fmt.Sprintf("https://gotquestions.online/pack/%d", packageID),
+		Path:         PackagePath(packageID),
+		Source:       DefaultSource(),
 		Rewrite:      rewrite,
 		questionRepo: models.NewQuestionRepository(),
 	}
 }
 
-// ImportPackage imports all questions from the package
+// ImportPackage imports all questions from the package. Everything is downloaded first and
+// then written in one transaction, so on any failure the package keeps its previous questions.
+// Questions are matched by their gotquestions.online ID and keep their internal IDs.
 func (pp *PackageParser) ImportPackage() error {
-	log.Printf("Importing package %d from %s", pp.PackageID, pp.URL)
+	log.Printf("Importing package %d from %s", pp.PackageID, pp.Path)
 
-	if pp.Rewrite {
-		err := pp.deleteOldEntries()
-		if err != nil {
-			return fmt.Errorf("failed to delete old entries: %w", err)
-		}
+	content, err := pp.fetchPackage()
+	if err != nil {
+		return err
+	}
+
+	err = pp.storeQuestions(content)
+	if err != nil {
+		return err
 	}
 
-	nextJsData, err := ExtractNextJsDataFromURL(pp.URL)
+	log.Printf("Completed importing package %d: %d new, %d updated, %d unchanged, %d removed, %d kept",
+		pp.PackageID, pp.Stats.Inserted, pp.Stats.Updated, pp.Stats.Unchanged, pp.Stats.Removed, pp.Stats.Kept)
+
+	issues, err := ValidatePackage(pp.PackageID)
 	if err != nil {
-		return fmt.Errorf("failed to extract data from URL %s: %w", pp.URL, err)
+		log.Printf("Failed to validate package %d: %v", pp.PackageID, err)
+	} else if len(issues) > 0 {
+		log.Printf("Package %d has %d data quality issues, see importer -command=validate", pp.PackageID, len(issues))
+	}
+	return nil
+}
+
+// packageContent is everything read from a package page
+type packageContent struct {
+	questions []*models.Question
+	images    map[string]*image // handout pictures keyed by path
+	tours     []*models.Tour    // with editors; tour 0 holds the editors of the whole package
+	skipped   int               // questions that failed to decode or validate
+}
+
+// fetchPackage reads the questions of the package, its tours and pictures without storing them
+func (pp *PackageParser) fetchPackage() (*packageContent, error) {
+	nextJsData, err := FetchNextJsData(context.Background(), pp.Source, pp.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract data from %s: %w", pp.Path, err)
 	}
 
 	packKeyValue, err := FindKeyInData(nextJsData, "pack")
 	if err != nil {
-		return fmt.Errorf("could not find pack key at URL %s: %w", pp.URL, err)
+		return nil, fmt.Errorf("could not find pack key at %s: %w", pp.Path, err)
 	}
 
-	pack, ok := packKeyValue.(map[string]any)
-	if !ok {
-		return fmt.Errorf("pack was not a dict at URL %s", pp.URL)
+	pack, err := DecodePack(packKeyValue, pp.PackageID)
+	if err != nil {
+		return nil, err
 	}
 
-	questions, err := pp.extractQuestions(pack)
+	tours, err := pack.TourList(pp.PackageID)
 	if err != nil {
-		return fmt.Errorf("failed to extract questions: %w", err)
+		log.Printf("Skipping tours and editors: %v", err)
+	}
+
+	questions, errs := pack.Questions(pp.PackageID)
+	for _, err := range errs {
+		log.Printf("Skipping question: %v", err)
 	}
 
 	log.Printf("Found %d questions in package %d", len(questions), pp.PackageID)
 
-	for i, questionDict := range questions {
-		question, err := models.BuildQuestionFromDict(questionDict, pp.PackageID)
-		if err != nil {
-			log.Printf("Failed to build question %d: %v", i, err)
+	return &packageContent{
+		questions: questions,
+		images:    pp.downloadImages(questions),
+		tours:     tours,
+		skipped:   len(errs),
+	}, nil
+}
+
+// image is a downloaded handout picture
+type image struct {
+	data     []byte
+	mimeType string
+}
+
+// downloadImages fetches the handout pictures of the questions, keyed by picture path.
+// A picture that fails to download is logged and left out.
+func (pp *PackageParser) downloadImages(questions []*models.Question) map[string]*image {
+	images := make(map[string]*image)
+	for _, question := range questions {
+		if question.HandoutImg == "" || images[question.HandoutImg] != nil {
 			continue
 		}
 
-		err = pp.insertQuestion(question)
+		data, mimeType, err := pp.downloadImage(question.HandoutImg)
 		if err != nil {
-			log.Printf("Failed to insert question %d: %v", i, err)
+			log.Printf("Failed to download image for question %d: %v", question.GotQuestionsID, err)
 			continue
 		}
+		images[question.HandoutImg] = &image{data: data, mimeType: mimeType}
 	}
+	return images
+}
 
-	log.Printf("Completed importing package %d", pp.PackageID)
-	return nil
+// downloadImage reads a handout picture from the source
+func (pp *PackageParser) downloadImage(handoutImg string) ([]byte, string, error) {
+	imagePath := "/" + strings.TrimPrefix(handoutImg, "/")
+
+	data, mimeType, err := pp.Source.Fetch(context.Background(), imagePath)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to download image %s: %w", imagePath, err)
+	}
+
+	return data, mimeType, nil
 }
 
-// extractQuestions extracts questions from the props data
-func (pp *PackageParser) extractQuestions(pack map[string]interface{}) ([]map[string]interface{}, error) {
-	var allQuestions []map[string]interface{}
+// ImportStats counts what an import did to the questions of a package
+type ImportStats struct {
+	Inserted  int
+	Updated   int
+	Unchanged int
+	Removed   int // gone from the site and deleted
+	Kept      int // gone from the site, but kept because tournaments use them
+}
 
-	toursInterface, ok := pack["tours"]
-	if !ok {
-		return nil, fmt.Errorf("no tours found in pack")
+// questionField is a stored field of a question, formatted for comparison and the change log
+type questionField struct {
+	name  string
+	value string
+}
+
+// questionFields lists the fields of a question that a re-import may change
+func questionFields(q *models.Question) []questionField {
+	var authorID, difficulty, isIncorrect string
+	if q.AuthorID != nil {
+		authorID = strconv.Itoa(*q.AuthorID)
+	}
+	if q.Difficulty != nil {
+		difficulty = strconv.FormatFloat(*q.Difficulty, 'g', -1, 64)
+	}
+	if q.IsIncorrect != nil {
+		isIncorrect = strconv.FormatBool(*q.IsIncorrect)
 	}
 
-	tours, ok := toursInterface.([]interface{})
-	if !ok {
-		return nil, fmt.Errorf("invalid tours structure")
+	return []questionField{
+		{"question", q.Question},
+		{"answer", q.Answer},
+		{"accepted_answer", q.AcceptedAnswer},
+		{"comment", q.Comment},
+		{"handout_str", q.HandoutStr},
+		{"source", q.Source},
+		{"author_id", authorID},
+		{"difficulty", difficulty},
+		{"is_incorrect", isIncorrect},
+		{"taken_down_reason", q.TakenDownReason},
+		{"number", strconv.Itoa(q.Number)},
+		{"tour_number", strconv.Itoa(q.TourNumber)},
 	}
+}
 
-	for _, tourInterface := range tours {
-		tour, ok := tourInterface.(map[string]interface{})
-		if !ok {
-			continue
-		}
+// storeQuestions upserts the questions and their images in one transaction. Stored questions
+// are updated only if Rewrite is set; with Rewrite, stored questions that are gone from the
+// package are deleted unless a tournament uses them or some question failed to parse.
+func (pp *PackageParser) storeQuestions(content *packageContent) error {
+	tx, err := db.GetDB().Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
 
-		questionsInterface, ok := tour["questions"]
-		if !ok {
-			continue
+	stats := ImportStats{}
+	keep := make(map[int]bool)
+	for _, question := range content.questions {
+		stored, err := pp.findStored(tx, question.GotQuestionsID)
+		if err != nil {
+			return err
 		}
 
-		questions, ok := questionsInterface.([]interface{})
-		if !ok {
+		switch {
+		case stored == nil:
+			question.ID, err = pp.insertQuestion(tx, question)
+			stats.Inserted++
+		case !pp.Rewrite:
+			keep[stored.ID] = true
+			stats.Unchanged++
 			continue
+		default:
+			question.ID = stored.ID
+			var changed bool
+			changed, err = pp.updateQuestion(tx, stored, question)
+			if changed {
+				stats.Updated++
+			} else {
+				stats.Unchanged++
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to store question %d: %w", question.GotQuestionsID, err)
 		}
+		keep[question.ID] = true
 
-		for _, questionInterface := range questions {
-			questionDict, ok := questionInterface.(map[string]interface{})
-			if ok {
-				allQuestions = append(allQuestions, questionDict)
-			}
+		err = pp.storeImage(tx, question, content.images[question.HandoutImg])
+		if err != nil {
+			return fmt.Errorf("failed to store image of question %d: %w", question.GotQuestionsID, err)
 		}
-	}
 
-	// Try to get from pack.tours structure
-	// if packInterface, exists := pagePropsPack["pack"]; exists {
+		err = storeAuthors(tx, question)
+		if err != nil {
+			return fmt.Errorf("failed to store authors of question %d: %w", question.GotQuestionsID, err)
+		}
 
-	// } else if tourInterface, exists := pagePropsPack["tour"]; exists {
-	// 	// Try to get from direct tour structure
-	// 	tour, ok := tourInterface.(map[string]interface{})
-	// 	if !ok {
-	// 		return nil, fmt.Errorf("invalid tour structure")
-	// 	}
+		err = indexQuestion(tx, question)
+		if err != nil {
+			return fmt.Errorf("failed to index question %d: %w", question.GotQuestionsID, err)
+		}
+	}
 
-	// 	questionsInterface, ok := tour["questions"]
-	// 	if !ok {
-	// 		return nil, fmt.Errorf("no questions found in tour")
-	// 	}
+	if content.tours != nil {
+		err = pp.storeTours(tx, content.tours)
+		if err != nil {
+			return err
+		}
+	}
 
-	// 	questions, ok := questionsInterface.([]interface{})
-	// 	if !ok {
-	// 		return nil, fmt.Errorf("invalid questions structure")
-	// 	}
+	// A question that failed to parse is not gone from the site, so nothing is removed
+	// until the package parses cleanly
+	if pp.Rewrite && content.skipped > 0 {
+		log.Printf("Not removing stored questions of package %d: %d questions failed to parse", pp.PackageID, content.skipped)
+	} else if pp.Rewrite {
+		err = pp.removeMissing(tx, keep, &stats)
+		if err != nil {
+			return err
+		}
+	}
 
-	// 	for _, questionInterface := range questions {
-	// 		questionDict, ok := questionInterface.(map[string]interface{})
-	// 		if ok {
-	// 			allQuestions = append(allQuestions, questionDict)
-	// 		}
-	// 	}
-	// } else {
-	// 	return nil, fmt.Errorf("no pack or tour data found")
-	// }
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit package %d: %w", pp.PackageID, err)
+	}
 
-	return allQuestions, nil
+	pp.Stats = stats
+	return nil
 }
 
-// deleteOldEntries removes existing questions for this package
-func (pp *PackageParser) deleteOldEntries() error {
-	database := db.GetDB()
-
-	// Delete images first (foreign key constraint)
-	_, err := database.Exec(`
-		DELETE FROM images 
-		WHERE question_id IN (SELECT id FROM questions WHERE package_id = ?)
-	`, pp.PackageID)
+// findStored returns the stored question with the gotquestions.online ID, or nil if there is none
+func (pp *PackageParser) findStored(tx *sql.Tx, gotQuestionsID int) (*models.Question, error) {
+	query := `
+		SELECT id, COALESCE(question, ''), COALESCE(answer, ''), COALESCE(accepted_answer, ''),
+		       COALESCE(comment, ''), COALESCE(handout_str, ''), COALESCE(source, ''),
+		       author_id, difficulty, is_incorrect, number, tour_number, taken_down_reason
+		FROM questions
+		WHERE gotquestions_id = ?
+		ORDER BY id
+		LIMIT 1
+	`
+
+	q := &models.Question{GotQuestionsID: gotQuestionsID}
+	var authorID sql.NullInt64
+	var difficulty sql.NullFloat64
+	var isIncorrect sql.NullBool
+	err := tx.QueryRow(query, gotQuestionsID).Scan(
+		&q.ID, &q.Question, &q.Answer, &q.AcceptedAnswer, &q.Comment, &q.HandoutStr, &q.Source,
+		&authorID, &difficulty, &isIncorrect, &q.Number, &q.TourNumber, &q.TakenDownReason,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
 	if err != nil {
-		return fmt.Errorf("failed to delete old images: %w", err)
+		return nil, fmt.Errorf("failed to find question %d: %w", gotQuestionsID, err)
 	}
 
-	// Delete questions
-	_, err = database.Exec("DELETE FROM questions WHERE package_id = ?", pp.PackageID)
-	if err != nil {
-		return fmt.Errorf("failed to delete old questions: %w", err)
+	if authorID.Valid {
+		id := int(authorID.Int64)
+		q.AuthorID = &id
+	}
+	if difficulty.Valid {
+		q.Difficulty = &difficulty.Float64
+	}
+	if isIncorrect.Valid {
+		q.IsIncorrect = &isIncorrect.Bool
 	}
 
-	return nil
+	return q, nil
 }
 
-// insertQuestion inserts a question and its image if present
-func (pp *PackageParser) insertQuestion(question *models.Question) error {
-	database := db.GetDB()
-
-	// Insert question
-	result, err := database.Exec(`
+// insertQuestion inserts a new question and returns its ID
+func (pp *PackageParser) insertQuestion(tx *sql.Tx, question *models.Question) (int, error) {
+	result, err := tx.Exec(`
 		INSERT INTO questions (
 			gotquestions_id, question, answer, accepted_answer, comment, 
-			handout_str, source, author_id, package_id, difficulty, is_incorrect
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			handout_str, source, author_id, package_id, difficulty, is_incorrect,
+			number, tour_number, taken_down_reason
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`,
 		question.GotQuestionsID, question.Question, question.Answer, question.AcceptedAnswer,
 		question.Comment, question.HandoutStr, question.Source, question.AuthorID,
 		question.PackageID, question.Difficulty, question.IsIncorrect,
+		question.Number, question.TourNumber, question.TakenDownReason,
 	)
 	if err != nil {
-		return fmt.Errorf("failed to insert question: %w", err)
+		return 0, fmt.Errorf("failed to insert question: %w", err)
 	}
 
 	questionID, err := result.LastInsertId()
 	if err != nil {
-		return fmt.Errorf("failed to get question ID: %w", err)
+		return 0, fmt.Errorf("failed to get question ID: %w", err)
 	}
 
-	if question.HandoutImg != "" {
-		err = pp.insertImage(int(questionID), question.HandoutImg)
+	return int(questionID), nil
+}
+
+// updateQuestion updates a stored question in place, logging every field that changed.
+// It reports whether anything changed.
+func (pp *PackageParser) updateQuestion(tx *sql.Tx, stored, question *models.Question) (bool, error) {
+	oldFields := questionFields(stored)
+	changed := false
+	for i, field := range questionFields(question) {
+		if field.value == oldFields[i].value {
+			continue
+		}
+		changed = true
+
+		_, err := tx.Exec(
+			`INSERT INTO question_changes (question_id, field, old_value, new_value, changed_at) VALUES (?, ?, ?, ?, ?)`,
+			stored.ID, field.name, oldFields[i].value, field.value, time.Now(),
+		)
 		if err != nil {
-			log.Printf("Failed to insert image for question %d: %v", questionID, err)
+			return false, fmt.Errorf("failed to log change of %s: %w", field.name, err)
 		}
 	}
 
-	return nil
-}
+	_, err := tx.Exec(`
+		UPDATE questions
+		SET question = ?, answer = ?, accepted_answer = ?, comment = ?, handout_str = ?,
+		    source = ?, author_id = ?, package_id = ?, difficulty = ?, is_incorrect = ?,
+		    number = ?, tour_number = ?, taken_down_reason = ?
+		WHERE id = ?
+	`,
+		question.Question, question.Answer, question.AcceptedAnswer, question.Comment,
+		question.HandoutStr, question.Source, question.AuthorID, question.PackageID,
+		question.Difficulty, question.IsIncorrect,
+		question.Number, question.TourNumber, question.TakenDownReason, stored.ID,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to update question: %w", err)
+	}
 
-// insertImage downloads and inserts an image for a question
-func (pp *PackageParser) insertImage(questionID int, handoutImg string) error {
-	imageURL := fmt.Sprintf("https://gotquestions.online/%s", handoutImg)
+	return changed, nil
+}
 
-	response, err := http.Get(imageURL)
-	if err != nil {
-		return fmt.Errorf("failed to download image from %s: %w", imageURL, err)
+// storeImage replaces the image of a question. A picture that could not be downloaded
+// leaves the stored image alone, or is stored without data for the validation to report
+// if there is none; a question without a picture loses its image.
+func (pp *PackageParser) storeImage(tx *sql.Tx, question *models.Question, img *image) error {
+	if question.HandoutImg != "" && img == nil {
+		_, err := tx.Exec(`
+			INSERT INTO images (question_id, image_url)
+			SELECT ?, ? WHERE NOT EXISTS (SELECT 1 FROM images WHERE question_id = ?)
+		`, question.ID, question.HandoutImg, question.ID)
+		if err != nil {
+			return fmt.Errorf("failed to record missing image: %w", err)
+		}
+		return nil
 	}
-	defer response.Body.Close()
 
-	if response.StatusCode != http.StatusOK {
-		return fmt.Errorf("HTTP %d when downloading image from %s", response.StatusCode, imageURL)
+	// An unchanged picture keeps its row, and with it the file ID of its Telegram upload
+	if img != nil {
+		var unchanged int
+		err := tx.QueryRow(`
+			SELECT COUNT(*) FROM images WHERE question_id = ? AND image_url = ? AND data = ? AND mime_type = ?
+		`, question.ID, question.HandoutImg, img.data, img.mimeType).Scan(&unchanged)
+		if err != nil {
+			return fmt.Errorf("failed to compare image: %w", err)
+		}
+		if unchanged == 1 {
+			return nil
+		}
 	}
 
-	imageData, err := io.ReadAll(response.Body)
+	_, err := tx.Exec(`DELETE FROM images WHERE question_id = ?`, question.ID)
 	if err != nil {
-		return fmt.Errorf("failed to read image data: %w", err)
+		return fmt.Errorf("failed to delete old image: %w", err)
 	}
 
-	mimeType := response.Header.Get("Content-Type")
+	if img == nil {
+		return nil
+	}
 
-	database := db.GetDB()
-	_, err = database.Exec(`
+	_, err = tx.Exec(`
 		INSERT INTO images (question_id, image_url, data, mime_type) 
 		VALUES (?, ?, ?, ?)
-	`, questionID, handoutImg, imageData, mimeType)
+	`, question.ID, question.HandoutImg, img.data, img.mimeType)
 	if err != nil {
 		return fmt.Errorf("failed to insert image data: %w", err)
 	}
 
 	return nil
 }
+
+// upsertAuthor stores an author or editor, renaming them if the site did
+func upsertAuthor(tx *sql.Tx, author models.Author) error {
+	_, err := tx.Exec(`
+		INSERT INTO authors (id, name) VALUES (?, ?)
+		ON CONFLICT(id) DO UPDATE SET name = excluded.name
+	`, author.ID, author.Name)
+	if err != nil {
+		return fmt.Errorf("failed to store author %d: %w", author.ID, err)
+	}
+	return nil
+}
+
+// storeAuthors replaces the credited authors of a question
+func storeAuthors(tx *sql.Tx, question *models.Question) error {
+	_, err := tx.Exec(`DELETE FROM question_authors WHERE question_id = ?`, question.ID)
+	if err != nil {
+		return fmt.Errorf("failed to delete old authors: %w", err)
+	}
+
+	for position, author := range question.Authors {
+		err = upsertAuthor(tx, author)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(
+			`INSERT OR IGNORE INTO question_authors (question_id, author_id, position) VALUES (?, ?, ?)`,
+			question.ID, author.ID, position,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to link author %d: %w", author.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// storeTours replaces the tours of the package and their editors
+func (pp *PackageParser) storeTours(tx *sql.Tx, tours []*models.Tour) error {
+	for _, table := range []string{"tours", "editors"} {
+		_, err := tx.Exec(`DELETE FROM `+table+` WHERE package_id = ?`, pp.PackageID)
+		if err != nil {
+			return fmt.Errorf("failed to delete old %s of package %d: %w", table, pp.PackageID, err)
+		}
+	}
+
+	for _, tour := range tours {
+		if tour.Number > 0 {
+			_, err := tx.Exec(`INSERT OR REPLACE INTO tours (package_id, number, title) VALUES (?, ?, ?)`,
+				pp.PackageID, tour.Number, tour.Title)
+			if err != nil {
+				return fmt.Errorf("failed to store tour %d of package %d: %w", tour.Number, pp.PackageID, err)
+			}
+		}
+
+		for _, editor := range tour.Editors {
+			err := upsertAuthor(tx, editor)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec(`INSERT OR IGNORE INTO editors (package_id, tour_number, author_id) VALUES (?, ?, ?)`,
+				pp.PackageID, tour.Number, editor.ID)
+			if err != nil {
+				return fmt.Errorf("failed to store editor %d of package %d: %w", editor.ID, pp.PackageID, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// indexQuestion replaces the question in the full-text index, if there is one
+func indexQuestion(tx *sql.Tx, question *models.Question) error {
+	if !db.SearchAvailable() {
+		return nil
+	}
+
+	return db.IndexQuestion(tx, question.ID, question.Question, question.Answer, question.Comment, question.Source)
+}
+
+// removeMissing deletes the package's stored questions that are not in keep, unless a tournament uses them
+func (pp *PackageParser) removeMissing(tx *sql.Tx, keep map[int]bool, stats *ImportStats) error {
+	rows, err := tx.Query(`
+		SELECT q.id, EXISTS (SELECT 1 FROM tournament_questions tq WHERE tq.question_id = q.id)
+		FROM questions q
+		WHERE q.package_id = ?
+	`, pp.PackageID)
+	if err != nil {
+		return fmt.Errorf("failed to query stored questions: %w", err)
+	}
+
+	var remove []int
+	for rows.Next() {
+		var id int
+		var used bool
+		if err := rows.Scan(&id, &used); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan stored question: %w", err)
+		}
+		if keep[id] {
+			continue
+		}
+		if used {
+			log.Printf("Keeping question %d of package %d: it is gone from the site but used in tournaments", id, pp.PackageID)
+			stats.Kept++
+			continue
+		}
+		remove = append(remove, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read stored questions: %w", err)
+	}
+
+	for _, id := range remove {
+		if _, err := tx.Exec(`DELETE FROM images WHERE question_id = ?`, id); err != nil {
+			return fmt.Errorf("failed to delete image of question %d: %w", id, err)
+		}
+		if _, err := tx.Exec(`DELETE FROM question_authors WHERE question_id = ?`, id); err != nil {
+			return fmt.Errorf("failed to delete authors of question %d: %w", id, err)
+		}
+		if _, err := tx.Exec(`DELETE FROM question_duplicates WHERE question_id = ?`, id); err != nil {
+			return fmt.Errorf("failed to delete duplicates of question %d: %w", id, err)
+		}
+		if db.SearchAvailable() {
+			if _, err := tx.Exec(`DELETE FROM questions_fts WHERE rowid = ?`, id); err != nil {
+				return fmt.Errorf("failed to remove question %d from the search index: %w", id, err)
+			}
+		}
+		if _, err := tx.Exec(`DELETE FROM questions WHERE id = ?`, id); err != nil {
+			return fmt.Errorf("failed to delete question %d: %w", id, err)
+		}
+		stats.Removed++
+	}
+
+	return nil
+}
@@ -0,0 +1,394 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"questions-vote/internal/db"
+	"questions-vote/internal/models"
+	"questions-vote/internal/testutil"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// packPage renders a package page the way gotquestions.online embeds Next.js data
+func packPage(t *testing.T, questions ...map[string]any) string {
+	t.Helper()
+
+	data, err := json.Marshal(map[string]any{
+		"pack": map[string]any{
+			"tours": []any{map[string]any{"questions": questions}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to encode package: %v", err)
+	}
+
+	escaped := strings.ReplaceAll(strings.ReplaceAll(string(data), `\`, `\\`), `"`, `\"`)
+	return fmt.Sprintf(`<html><body><script>self.__next_f.push([1,"5:%s\n"])</script></body></html>`, escaped)
+}
+
+// packSite serves one package page and its handout pictures, or fails every request
+type packSite struct {
+	mu   sync.Mutex
+	page string
+	down bool
+}
+
+func (s *packSite) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case s.down:
+		w.WriteHeader(http.StatusServiceUnavailable)
+	case strings.HasPrefix(r.URL.Path, "/pack/"):
+		w.Write([]byte(s.page))
+	case strings.HasPrefix(r.URL.Path, "/pics/"):
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("png"))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// useSite points the importer at a test server for the duration of a test
+func useSite(t *testing.T, site http.Handler) {
+	t.Helper()
+
+	server := httptest.NewServer(site)
+	t.Cleanup(server.Close)
+
+	baseURL, client := BaseURL, DefaultClient()
+	t.Cleanup(func() {
+		BaseURL = baseURL
+		SetDefaultClient(client)
+	})
+
+	config := DefaultClientConfig()
+	config.MaxRetries = 0
+	config.RequestsPer = 0
+	BaseURL = server.URL
+	SetDefaultClient(NewClient(config))
+}
+
+func countRows(t *testing.T, query string, args ...any) int {
+	t.Helper()
+
+	var count int
+	err := db.GetDB().QueryRow(query, args...).Scan(&count)
+	if err != nil {
+		t.Fatalf("Failed to count rows: %v", err)
+	}
+	return count
+}
+
+// TestImportPackageKeepsOldDataOnFailure tests that a failed re-import leaves the package as it was
+func TestImportPackageKeepsOldDataOnFailure(t *testing.T) {
+	testutil.OpenDB(t)
+
+	site := &packSite{page: packPage(t,
+		map[string]any{"id": 101, "text": "Вопрос 1", "answer": "Ответ 1", "razdatkaPic": "/pics/1.png"},
+		map[string]any{"id": 102, "text": "Вопрос 2", "answer": "Ответ 2"},
+		map[string]any{"id": 103, "answer": "Вопрос без текста"},
+	)}
+	useSite(t, site)
+
+	parser := NewPackageParser(7, true)
+	if err := parser.ImportPackage(); err != nil {
+		t.Fatalf("Failed to import package: %v", err)
+	}
+
+	if count := countRows(t, `SELECT COUNT(*) FROM questions WHERE package_id = 7`); count != 3 {
+		t.Fatalf("Expected 3 questions, got %d", count)
+	}
+	if count := countRows(t, `SELECT COUNT(*) FROM question_issues WHERE kind = ?`, models.IssueEmptyQuestion); count != 1 {
+		t.Fatalf("Expected the question without text to be flagged, got %d issues", count)
+	}
+	if count := countRows(t, `SELECT COUNT(*) FROM images WHERE mime_type = 'image/png'`); count != 1 {
+		t.Fatalf("Expected 1 image, got %d", count)
+	}
+
+	site.mu.Lock()
+	site.down = true
+	site.mu.Unlock()
+
+	if err := parser.ImportPackage(); err == nil {
+		t.Fatal("Expected the re-import to fail while the site is down")
+	}
+
+	if count := countRows(t, `SELECT COUNT(*) FROM questions WHERE package_id = 7`); count != 3 {
+		t.Errorf("Expected the old questions to be kept, got %d", count)
+	}
+	if count := countRows(t, `SELECT COUNT(*) FROM images`); count != 1 {
+		t.Errorf("Expected the old image to be kept, got %d", count)
+	}
+}
+
+// TestImportPackageReplacesQuestions tests that a successful re-import updates the questions instead of adding to them
+func TestImportPackageReplacesQuestions(t *testing.T) {
+	testutil.OpenDB(t)
+
+	site := &packSite{page: packPage(t,
+		map[string]any{"id": 101, "text": "Вопрос 1", "answer": "Ответ 1", "razdatkaPic": "/pics/1.png"},
+	)}
+	useSite(t, site)
+
+	parser := NewPackageParser(7, true)
+	if err := parser.ImportPackage(); err != nil {
+		t.Fatalf("Failed to import package: %v", err)
+	}
+
+	site.mu.Lock()
+	site.page = packPage(t,
+		map[string]any{"id": 101, "text": "Вопрос 1 (исправлен)", "answer": "Ответ 1"},
+		map[string]any{"id": 104, "text": "Вопрос 4", "answer": "Ответ 4"},
+	)
+	site.mu.Unlock()
+
+	if err := parser.ImportPackage(); err != nil {
+		t.Fatalf("Failed to re-import package: %v", err)
+	}
+
+	if count := countRows(t, `SELECT COUNT(*) FROM questions WHERE package_id = 7`); count != 2 {
+		t.Errorf("Expected 2 questions after the re-import, got %d", count)
+	}
+	if count := countRows(t, `SELECT COUNT(*) FROM questions WHERE question = ?`, "Вопрос 1 (исправлен)"); count != 1 {
+		t.Errorf("Expected the corrected question to be stored")
+	}
+	if count := countRows(t, `SELECT COUNT(*) FROM images`); count != 0 {
+		t.Errorf("Expected the image of the replaced question to be removed, got %d", count)
+	}
+}
+
+// TestImportPackagesConcurrently tests that import workers writing to a database file at the
+// same time wait for each other instead of failing with "database is locked"
+func TestImportPackagesConcurrently(t *testing.T) {
+	testutil.OpenDB(t)
+
+	const packages, workers = 12, 4
+	pages := make(map[string]string)
+	for id := 1; id <= packages; id++ {
+		var questions []map[string]any
+		for i := 1; i <= 10; i++ {
+			questions = append(questions, map[string]any{
+				"id": id*100 + i, "text": fmt.Sprintf("Вопрос %d.%d", id, i), "answer": "Ответ",
+			})
+		}
+		pages[PackagePath(id)] = packPage(t, questions...)
+	}
+	useSite(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, ok := pages[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(page))
+	}))
+
+	// Every package is imported twice, so that the second import updates stored questions
+	ids := make(chan int)
+	errs := make(chan error, 2*packages)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range ids {
+				if err := NewPackageParser(id, true).ImportPackage(); err != nil {
+					errs <- fmt.Errorf("package %d: %w", id, err)
+				}
+			}
+		}()
+	}
+	for round := 0; round < 2; round++ {
+		for id := 1; id <= packages; id++ {
+			ids <- id
+		}
+	}
+	close(ids)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("Failed to import: %v", err)
+	}
+	if count := countRows(t, `SELECT COUNT(*) FROM questions`); count != packages*10 {
+		t.Errorf("Expected %d questions, got %d", packages*10, count)
+	}
+}
+
+// TestImportPackageKeepsQuestionIDs tests that re-imports update questions in place, log changes and spare questions in tournaments
+func TestImportPackageKeepsQuestionIDs(t *testing.T) {
+	testutil.OpenDB(t)
+
+	site := &packSite{page: packPage(t,
+		map[string]any{"id": 101, "text": "Вопрос 1", "answer": "Ответ 1"},
+		map[string]any{"id": 102, "text": "Вопрос 2", "answer": "Ответ 2"},
+		map[string]any{"id": 103, "text": "Вопрос 3", "answer": "Ответ 3"},
+	)}
+	useSite(t, site)
+
+	parser := NewPackageParser(7, true)
+	if err := parser.ImportPackage(); err != nil {
+		t.Fatalf("Failed to import package: %v", err)
+	}
+
+	questionID := func(gotQuestionsID int) int {
+		t.Helper()
+		var id int
+		err := db.GetDB().QueryRow(`SELECT id FROM questions WHERE gotquestions_id = ?`, gotQuestionsID).Scan(&id)
+		if err != nil {
+			t.Fatalf("Failed to find question %d: %v", gotQuestionsID, err)
+		}
+		return id
+	}
+	firstID, secondID := questionID(101), questionID(102)
+
+	// Question 102 is in a running tournament, question 103 is not
+	_, err := db.GetDB().Exec(`INSERT INTO tournament_questions (tournament_id, question_id, rating) VALUES (1, ?, 1500)`, secondID)
+	if err != nil {
+		t.Fatalf("Failed to add question to tournament: %v", err)
+	}
+
+	site.mu.Lock()
+	site.page = packPage(t,
+		map[string]any{"id": 101, "text": "Вопрос 1", "answer": "Ответ 1 (уточнён)", "comment": "Комментарий"},
+	)
+	site.mu.Unlock()
+
+	if err := parser.ImportPackage(); err != nil {
+		t.Fatalf("Failed to re-import package: %v", err)
+	}
+
+	if id := questionID(101); id != firstID {
+		t.Errorf("Expected question 101 to keep ID %d, got %d", firstID, id)
+	}
+	expected := ImportStats{Updated: 1, Removed: 1, Kept: 1}
+	if parser.Stats != expected {
+		t.Errorf("Expected stats %+v, got %+v", expected, parser.Stats)
+	}
+	if count := countRows(t, `SELECT COUNT(*) FROM questions WHERE id = ?`, secondID); count != 1 {
+		t.Error("Question used in a tournament should be kept")
+	}
+	if count := countRows(t, `SELECT COUNT(*) FROM questions WHERE gotquestions_id = 103`); count != 0 {
+		t.Error("Unused question gone from the site should be removed")
+	}
+
+	changes, err := models.NewQuestionChangeRepository().GetByQuestion(firstID)
+	if err != nil {
+		t.Fatalf("Failed to get changes: %v", err)
+	}
+	if len(changes) != 2 {
+		t.Fatalf("Expected answer and comment changes, got %d", len(changes))
+	}
+	if changes[0].Field != "answer" || changes[0].OldValue != "Ответ 1" || changes[0].NewValue != "Ответ 1 (уточнён)" {
+		t.Errorf("Unexpected answer change %+v", changes[0])
+	}
+	if changes[1].Field != "comment" || changes[1].NewValue != "Комментарий" {
+		t.Errorf("Unexpected comment change %+v", changes[1])
+	}
+
+	// Importing the same data again changes nothing
+	if err := parser.ImportPackage(); err != nil {
+		t.Fatalf("Failed to re-import package: %v", err)
+	}
+	if parser.Stats != (ImportStats{Unchanged: 1, Kept: 1}) {
+		t.Errorf("Expected an unchanged package, got %+v", parser.Stats)
+	}
+}
+
+// TestImportPackageKeepsUploadedImages tests that a re-import keeps the Telegram file ID of
+// an unchanged picture and drops it when the picture changes
+func TestImportPackageKeepsUploadedImages(t *testing.T) {
+	testutil.OpenDB(t)
+
+	site := &packSite{page: packPage(t,
+		map[string]any{"id": 101, "text": "Вопрос 1", "answer": "Ответ 1", "razdatkaPic": "/pics/1.png"},
+	)}
+	useSite(t, site)
+
+	parser := NewPackageParser(7, true)
+	if err := parser.ImportPackage(); err != nil {
+		t.Fatalf("Failed to import package: %v", err)
+	}
+
+	id := countRows(t, `SELECT id FROM questions WHERE gotquestions_id = 101`)
+	err := models.NewQuestionRepository().SetImageFile(id, "file-1", models.TelegramPhoto)
+	if err != nil {
+		t.Fatalf("Failed to save file ID: %v", err)
+	}
+
+	questions, err := models.NewQuestionRepository().FindByIDs([]int{id})
+	if err != nil || len(questions) != 1 {
+		t.Fatalf("Failed to find question: %v", err)
+	}
+	img := questions[0].Image
+	if img == nil || string(img.Data) != "png" || img.MimeType != "image/png" || img.TelegramFileID != "file-1" {
+		t.Fatalf("Expected the stored picture with its file ID, got %+v", img)
+	}
+
+	if err := parser.ImportPackage(); err != nil {
+		t.Fatalf("Failed to re-import package: %v", err)
+	}
+	if count := countRows(t, `SELECT COUNT(*) FROM images WHERE telegram_file_id = 'file-1'`); count != 1 {
+		t.Errorf("Expected the file ID of the unchanged picture to be kept")
+	}
+
+	site.mu.Lock()
+	site.page = packPage(t,
+		map[string]any{"id": 101, "text": "Вопрос 1", "answer": "Ответ 1", "razdatkaPic": "/pics/2.png"},
+	)
+	site.mu.Unlock()
+
+	if err := parser.ImportPackage(); err != nil {
+		t.Fatalf("Failed to re-import package: %v", err)
+	}
+	if count := countRows(t, `SELECT COUNT(*) FROM images WHERE question_id = ? AND telegram_file_id = ''`, id); count != 1 {
+		t.Errorf("Expected the changed picture to be uploaded again")
+	}
+}
+
+// TestImportPackageKeepsQuestionsThatFailToParse tests that a re-import does not delete a
+// stored question that is still on the site but fails to parse
+func TestImportPackageKeepsQuestionsThatFailToParse(t *testing.T) {
+	testutil.OpenDB(t)
+
+	site := &packSite{page: packPage(t,
+		map[string]any{"id": 101, "text": "Вопрос 1", "answer": "Ответ 1"},
+		map[string]any{"id": 102, "text": "Вопрос 2", "answer": "Ответ 2"},
+		map[string]any{"id": 103, "text": "Вопрос 3", "answer": "Ответ 3"},
+	)}
+	useSite(t, site)
+
+	parser := NewPackageParser(7, true)
+	if err := parser.ImportPackage(); err != nil {
+		t.Fatalf("Failed to import package: %v", err)
+	}
+
+	site.mu.Lock()
+	site.page = packPage(t,
+		map[string]any{"id": 101, "text": "Вопрос 1 (исправлен)", "answer": "Ответ 1"},
+		map[string]any{"id": 102, "text": []any{"не строка"}, "answer": "Ответ 2"},
+	)
+	site.mu.Unlock()
+
+	if err := parser.ImportPackage(); err != nil {
+		t.Fatalf("Failed to re-import package: %v", err)
+	}
+
+	if count := countRows(t, `SELECT COUNT(*) FROM questions WHERE package_id = 7`); count != 3 {
+		t.Errorf("Expected no questions removed while one fails to parse, got %d stored", count)
+	}
+	if count := countRows(t, `SELECT COUNT(*) FROM questions WHERE gotquestions_id = 102 AND question = ?`, "Вопрос 2"); count != 1 {
+		t.Errorf("Expected the question that failed to parse to keep its stored text")
+	}
+	if count := countRows(t, `SELECT COUNT(*) FROM questions WHERE question = ?`, "Вопрос 1 (исправлен)"); count != 1 {
+		t.Errorf("Expected the other questions to be updated")
+	}
+	if parser.Stats.Removed != 0 {
+		t.Errorf("Expected nothing removed, got %d", parser.Stats.Removed)
+	}
+}
@@ -0,0 +1,254 @@
+package importer
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// PageSource provides the pages and pictures the importer reads, by their path on
+// gotquestions.online such as "/pack/5220", "/?page=3" or "/pics/1.png"
+type PageSource interface {
+	// Fetch returns the content found at path and its content type
+	Fetch(ctx context.Context, path string) ([]byte, string, error)
+}
+
+// PackagePath returns the path of a package page
+func PackagePath(packageID int) string {
+	return fmt.Sprintf("/pack/%d", packageID)
+}
+
+// ListPagePath returns the path of a page of the package list
+func ListPagePath(page int) string {
+	return fmt.Sprintf("/?page=%d", page)
+}
+
+// WebSource reads pages from the live site
+type WebSource struct {
+	BaseURL string  // defaults to BaseURL
+	Client  *Client // defaults to the shared importer client
+}
+
+// Fetch downloads path from the site
+func (s *WebSource) Fetch(ctx context.Context, pagePath string) ([]byte, string, error) {
+	baseURL, client := s.BaseURL, s.Client
+	if baseURL == "" {
+		baseURL = BaseURL
+	}
+	if client == nil {
+		client = DefaultClient()
+	}
+
+	return client.Get(ctx, baseURL+"/"+strings.TrimPrefix(pagePath, "/"))
+}
+
+var (
+	defaultSourceMu sync.RWMutex
+	defaultSource   PageSource = &WebSource{}
+)
+
+// DefaultSource returns the source new parsers and listers read from
+func DefaultSource() PageSource {
+	defaultSourceMu.RLock()
+	defer defaultSourceMu.RUnlock()
+	return defaultSource
+}
+
+// SetDefaultSource replaces the source new parsers and listers read from
+func SetDefaultSource(source PageSource) {
+	defaultSourceMu.Lock()
+	defer defaultSourceMu.Unlock()
+	defaultSource = source
+}
+
+// snapshotName returns the file a path is stored in within a snapshot: list pages in
+// pages/N.html, other pages in their path with .html added, pictures as they are
+func snapshotName(pagePath string) (string, error) {
+	u, err := url.Parse(pagePath)
+	if err != nil {
+		return "", fmt.Errorf("invalid path %s: %w", pagePath, err)
+	}
+
+	if page := u.Query().Get("page"); page != "" {
+		if _, err := strconv.Atoi(page); err != nil {
+			return "", fmt.Errorf("invalid page number in %s", pagePath)
+		}
+		return "pages/" + page + ".html", nil
+	}
+
+	name := strings.Trim(path.Clean("/"+u.Path), "/")
+	if name == "" {
+		return "index.html", nil
+	}
+	if path.Ext(name) == "" {
+		name += ".html"
+	}
+	if !fs.ValidPath(name) {
+		return "", fmt.Errorf("invalid path %s", pagePath)
+	}
+
+	return name, nil
+}
+
+// SnapshotSource reads pages saved in a directory or a zip archive. A page may be
+// stored as HTML or, under the same name with .json, as its extracted Next.js data.
+type SnapshotSource struct {
+	fsys   fs.FS
+	closer io.Closer
+}
+
+// OpenSnapshot opens a snapshot directory or .zip archive
+func OpenSnapshot(snapshotPath string) (*SnapshotSource, error) {
+	info, err := os.Stat(snapshotPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open snapshot %s: %w", snapshotPath, err)
+	}
+
+	if info.IsDir() {
+		return &SnapshotSource{fsys: os.DirFS(snapshotPath)}, nil
+	}
+
+	archive, err := zip.OpenReader(snapshotPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open snapshot archive %s: %w", snapshotPath, err)
+	}
+	return &SnapshotSource{fsys: archive, closer: archive}, nil
+}
+
+// NewSnapshotSource reads a snapshot from a file system, e.g. test fixtures
+func NewSnapshotSource(fsys fs.FS) *SnapshotSource {
+	return &SnapshotSource{fsys: fsys}
+}
+
+// Close releases the snapshot archive
+func (s *SnapshotSource) Close() error {
+	if s.closer == nil {
+		return nil
+	}
+	return s.closer.Close()
+}
+
+// Fetch reads the file stored for path
+func (s *SnapshotSource) Fetch(ctx context.Context, pagePath string) ([]byte, string, error) {
+	name, err := snapshotName(pagePath)
+	if err != nil {
+		return nil, "", err
+	}
+
+	candidates := []string{name}
+	if strings.HasSuffix(name, ".html") {
+		candidates = append(candidates, strings.TrimSuffix(name, ".html")+".json")
+	}
+
+	for _, candidate := range candidates {
+		data, err := fs.ReadFile(s.fsys, candidate)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read %s from snapshot: %w", candidate, err)
+		}
+		return data, contentType(candidate, data), nil
+	}
+
+	return nil, "", fmt.Errorf("%s is not in the snapshot: %w", pagePath, fs.ErrNotExist)
+}
+
+// Packages returns the IDs of the packages saved in the snapshot
+func (s *SnapshotSource) Packages() ([]int, error) {
+	return s.numberedFiles("pack")
+}
+
+// ListPages returns the numbers of the package list pages saved in the snapshot
+func (s *SnapshotSource) ListPages() ([]int, error) {
+	return s.numberedFiles("pages")
+}
+
+// numberedFiles returns the sorted numbers of the files named N.html or N.json in dir
+func (s *SnapshotSource) numberedFiles(dir string) ([]int, error) {
+	entries, err := fs.ReadDir(s.fsys, dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s in snapshot: %w", dir, err)
+	}
+
+	seen := make(map[int]bool)
+	var numbers []int
+	for _, entry := range entries {
+		name := entry.Name()
+		ext := path.Ext(name)
+		if entry.IsDir() || (ext != ".html" && ext != ".json") {
+			continue
+		}
+
+		number, err := strconv.Atoi(strings.TrimSuffix(name, ext))
+		if err != nil || seen[number] {
+			continue
+		}
+		seen[number] = true
+		numbers = append(numbers, number)
+	}
+
+	sort.Ints(numbers)
+	return numbers, nil
+}
+
+// contentType guesses the content type of a snapshot file from its name and content
+func contentType(name string, data []byte) string {
+	if byExtension := mime.TypeByExtension(path.Ext(name)); byExtension != "" {
+		return byExtension
+	}
+	return http.DetectContentType(data)
+}
+
+// RecordingSource saves everything read from another source into a snapshot directory
+type RecordingSource struct {
+	Source PageSource
+	Dir    string
+}
+
+// NewRecordingSource creates a source that records pages read from source into dir
+func NewRecordingSource(source PageSource, dir string) *RecordingSource {
+	return &RecordingSource{Source: source, Dir: dir}
+}
+
+// Fetch reads path from the underlying source and saves it
+func (s *RecordingSource) Fetch(ctx context.Context, pagePath string) ([]byte, string, error) {
+	data, contentType, err := s.Source.Fetch(ctx, pagePath)
+	if err != nil {
+		return nil, "", err
+	}
+
+	name, err := snapshotName(pagePath)
+	if err != nil {
+		return nil, "", err
+	}
+
+	file := filepath.Join(s.Dir, filepath.FromSlash(name))
+	err = os.MkdirAll(filepath.Dir(file), 0o755)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+
+	err = os.WriteFile(file, data, 0o644)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to save %s: %w", pagePath, err)
+	}
+
+	return data, contentType, nil
+}
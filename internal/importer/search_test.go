@@ -0,0 +1,181 @@
+package importer
+
+import (
+	"questions-vote/internal/db"
+	"questions-vote/internal/models"
+	"questions-vote/internal/testutil"
+	"testing"
+)
+
+// searchIDs returns the IDs of the questions found for a query, best match first
+func searchIDs(t *testing.T, text string, tournamentID int) []int {
+	t.Helper()
+
+	results, err := models.NewQuestionRepository().Search(text, tournamentID, 10)
+	if err != nil {
+		t.Fatalf("Failed to search for %q: %v", text, err)
+	}
+
+	var ids []int
+	for _, r := range results {
+		ids = append(ids, r.Question.ID)
+	}
+	return ids
+}
+
+func questionID(t *testing.T, gotQuestionsID int) int {
+	t.Helper()
+	return countRows(t, `SELECT id FROM questions WHERE gotquestions_id = ?`, gotQuestionsID)
+}
+
+// TestSearchFollowsImports tests that imported questions are found by words of any indexed field
+// and that re-imports update and remove them in the index
+func TestSearchFollowsImports(t *testing.T) {
+	testutil.OpenDB(t)
+	if !db.SearchAvailable() {
+		t.Skip("SQLite is built without FTS5; run with -tags sqlite_fts5")
+	}
+
+	site := &packSite{page: packPage(t,
+		map[string]any{"id": 101, "text": "Этот химик увидел таблицу во сне.", "answer": "Менделеев", "source": "Википедия"},
+		map[string]any{"id": 102, "text": "Что Ёжик искал в тумане?", "answer": "Лошадку", "comment": "Мультфильм Норштейна"},
+	)}
+	useSite(t, site)
+
+	parser := NewPackageParser(7, true)
+	if err := parser.ImportPackage(); err != nil {
+		t.Fatalf("Failed to import package: %v", err)
+	}
+
+	first, second := questionID(t, 101), questionID(t, 102)
+	for _, tc := range []struct {
+		text string
+		want []int
+	}{
+		{"химик", []int{first}},
+		{"МЕНДЕЛЕЕВ", []int{first}},
+		{"таблиц", []int{first}},         // the last word is a prefix
+		{"химик сне", []int{first}},      // all words must match
+		{"химик лошадку", nil},           // no question has both
+		{"норштейна", []int{second}},     // comments are indexed
+		{"википедия", []int{first}},      // and sources
+		{"ежик", []int{second}},          // ё and е are the same
+		{`"химик" -(сне*`, []int{first}}, // FTS5 syntax in the input is ignored
+		{"?!", nil},
+	} {
+		ids := searchIDs(t, tc.text, 0)
+		if len(ids) != len(tc.want) || (len(ids) > 0 && ids[0] != tc.want[0]) {
+			t.Errorf("Search for %q: expected %v, got %v", tc.text, tc.want, ids)
+		}
+	}
+
+	site.mu.Lock()
+	site.page = packPage(t,
+		map[string]any{"id": 101, "text": "Этот физик увидел таблицу во сне.", "answer": "Менделеев"},
+	)
+	site.mu.Unlock()
+
+	if err := parser.ImportPackage(); err != nil {
+		t.Fatalf("Failed to re-import package: %v", err)
+	}
+
+	if ids := searchIDs(t, "химик", 0); len(ids) != 0 {
+		t.Errorf("Expected the old wording to be gone from the index, got %v", ids)
+	}
+	if ids := searchIDs(t, "физик", 0); len(ids) != 1 || ids[0] != first {
+		t.Errorf("Expected the corrected question to be found, got %v", ids)
+	}
+	if ids := searchIDs(t, "лошадку", 0); len(ids) != 0 {
+		t.Errorf("Expected the removed question to be gone from the index, got %v", ids)
+	}
+
+	count, err := db.RebuildSearchIndex()
+	if err != nil {
+		t.Fatalf("Failed to rebuild the search index: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 question in the rebuilt index, got %d", count)
+	}
+	if ids := searchIDs(t, "физик", 0); len(ids) != 1 || ids[0] != first {
+		t.Errorf("Expected the question to be found after a rebuild, got %v", ids)
+	}
+}
+
+// TestMigrateCatchesUpSearchIndex tests that migrating indexes questions stored by a binary
+// built without FTS5
+func TestMigrateCatchesUpSearchIndex(t *testing.T) {
+	testutil.OpenDB(t)
+	if !db.SearchAvailable() {
+		t.Skip("SQLite is built without FTS5; run with -tags sqlite_fts5")
+	}
+
+	_, err := db.GetDB().Exec(`INSERT INTO questions (question, answer, package_id) VALUES ('Вопрос про химика', 'Менделеев', 7)`)
+	if err != nil {
+		t.Fatalf("Failed to insert question: %v", err)
+	}
+	if ids := searchIDs(t, "химика", 0); len(ids) != 0 {
+		t.Fatalf("Expected the question to be missing from the index, got %v", ids)
+	}
+
+	err = db.Migrate()
+	if err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	if ids := searchIDs(t, "химика", 0); len(ids) != 1 {
+		t.Errorf("Expected the question to be indexed after migrating, got %v", ids)
+	}
+}
+
+// TestSearchRatings tests that search results carry ratings from the given tournament only
+func TestSearchRatings(t *testing.T) {
+	testutil.OpenDB(t)
+	if !db.SearchAvailable() {
+		t.Skip("SQLite is built without FTS5; run with -tags sqlite_fts5")
+	}
+
+	useSite(t, &packSite{page: packPage(t,
+		map[string]any{"id": 101, "text": "Вопрос про химика", "answer": "Менделеев"},
+		map[string]any{"id": 102, "text": "Ещё вопрос про химика", "answer": "Бутлеров"},
+	)})
+	if err := NewPackageParser(7, true).ImportPackage(); err != nil {
+		t.Fatalf("Failed to import package: %v", err)
+	}
+
+	_, err := db.GetDB().Exec(`
+		INSERT INTO tournament_questions (tournament_id, question_id, rating, matches, wins) VALUES (1, ?, 1612, 9, 6)
+	`, questionID(t, 101))
+	if err != nil {
+		t.Fatalf("Failed to add question to tournament: %v", err)
+	}
+
+	results, err := models.NewQuestionRepository().Search("химика", 1, 10)
+	if err != nil {
+		t.Fatalf("Failed to search: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+
+	rated := questionID(t, 101)
+	for _, r := range results {
+		if r.Question.ID != rated {
+			if r.Rating != nil {
+				t.Errorf("Expected no rating for a question outside the tournament, got %v", *r.Rating)
+			}
+			continue
+		}
+		if r.Rating == nil || *r.Rating != 1612 || r.Matches != 9 || r.Wins != 6 {
+			t.Errorf("Expected rating 1612 with 6 wins in 9 matches, got %+v", r)
+		}
+	}
+
+	results, err = models.NewQuestionRepository().Search("химика", 2, 10)
+	if err != nil {
+		t.Fatalf("Failed to search: %v", err)
+	}
+	for _, r := range results {
+		if r.Rating != nil {
+			t.Errorf("Expected no ratings in another tournament, got %v for question %d", *r.Rating, r.Question.ID)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package importer
+
+import (
+	"fmt"
+	"log"
+)
+
+// SnapshotSummary is the outcome of importing a snapshot
+type SnapshotSummary struct {
+	ListPages int
+	Packages  int
+	Failed    map[int]error // packages that failed to import
+}
+
+// ImportSnapshot stores the packages listed on the snapshot's list pages, then imports
+// every package page in the snapshot
+func ImportSnapshot(snapshot *SnapshotSource, rewrite bool) (*SnapshotSummary, error) {
+	pages, err := snapshot.ListPages()
+	if err != nil {
+		return nil, err
+	}
+
+	packageIDs, err := snapshot.Packages()
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &SnapshotSummary{Failed: make(map[int]error)}
+
+	lister := NewPackageLister(0, 0)
+	lister.Source = snapshot
+	for _, page := range pages {
+		err = lister.CreatePackagesFromPage(page)
+		if err != nil {
+			return nil, fmt.Errorf("failed to import list page %d: %w", page, err)
+		}
+		summary.ListPages++
+	}
+
+	for _, packageID := range packageIDs {
+		parser := NewPackageParser(packageID, rewrite)
+		parser.Source = snapshot
+		err = parser.ImportPackage()
+		if err != nil {
+			log.Printf("Failed to import package %d from snapshot: %v", packageID, err)
+			summary.Failed[packageID] = err
+			continue
+		}
+		summary.Packages++
+	}
+
+	return summary, nil
+}
+
+// RecordPackage reads a package page and its pictures through a recording source, saving them
+func RecordPackage(recorder *RecordingSource, packageID int) error {
+	parser := NewPackageParser(packageID, false)
+	parser.Source = recorder
+
+	_, err := parser.fetchPackage()
+	if err != nil {
+		return fmt.Errorf("failed to record package %d: %w", packageID, err)
+	}
+	return nil
+}
+
+// RecordListPage reads a page of the package list through a recording source, saving it
+func RecordListPage(recorder *RecordingSource, page int) error {
+	lister := NewPackageLister(page, page)
+	lister.Source = recorder
+
+	_, err := lister.fetchPage(page)
+	if err != nil {
+		return fmt.Errorf("failed to record page %d: %w", page, err)
+	}
+	return nil
+}
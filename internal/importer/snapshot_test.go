@@ -0,0 +1,262 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"questions-vote/internal/models"
+	"questions-vote/internal/testutil"
+	"testing"
+)
+
+// fixtureSnapshot opens the snapshot saved in testdata
+func fixtureSnapshot(t *testing.T) *SnapshotSource {
+	t.Helper()
+
+	snapshot, err := OpenSnapshot(filepath.Join("testdata", "snapshot"))
+	if err != nil {
+		t.Fatalf("Failed to open fixture snapshot: %v", err)
+	}
+	t.Cleanup(func() { snapshot.Close() })
+	return snapshot
+}
+
+// TestSnapshotName tests how site paths map to snapshot files
+func TestSnapshotName(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"/?page=3", "pages/3.html"},
+		{"/pack/5220", "pack/5220.html"},
+		{"/pics/1.png", "pics/1.png"},
+		{"pics/1.png", "pics/1.png"},
+		{"/", "index.html"},
+		{"/../../etc/passwd", "etc/passwd.html"},
+	}
+
+	for _, tt := range tests {
+		got, err := snapshotName(tt.path)
+		if err != nil || got != tt.expected {
+			t.Errorf("snapshotName(%q) = %q, %v; expected %q", tt.path, got, err, tt.expected)
+		}
+	}
+
+	if _, err := snapshotName("/?page=../1"); err == nil {
+		t.Error("Expected an invalid page number to be rejected")
+	}
+}
+
+// TestPackageListerFromSnapshot tests listing packages from a fixture page
+func TestPackageListerFromSnapshot(t *testing.T) {
+	testutil.OpenDB(t)
+
+	lister := NewPackageLister(1, 1)
+	lister.Source = fixtureSnapshot(t)
+
+	packages, err := lister.fetchPage(1)
+	if err != nil {
+		t.Fatalf("Failed to read list page: %v", err)
+	}
+	if len(packages) != 2 {
+		t.Fatalf("Expected 2 valid packages, got %d", len(packages))
+	}
+	if packages[0].GotQuestionsID != 5220 || packages[0].Title != "Кубок весны" || packages[0].QuestionsCount != 2 {
+		t.Errorf("Unexpected package %+v", packages[0])
+	}
+	if packages[1].StartDate.Year() != 2022 || packages[1].StartDate.Month() != 7 {
+		t.Errorf("Unexpected start date %v", packages[1].StartDate)
+	}
+
+	if err := lister.Run(); err != nil {
+		t.Fatalf("Failed to list packages: %v", err)
+	}
+	stored, err := models.NewPackageRepository().GetPackagesByYear(2022)
+	if err != nil || len(stored) != 2 {
+		t.Errorf("Expected 2 packages stored for 2022, got %d (%v)", len(stored), err)
+	}
+
+	if _, err := lister.fetchPage(2); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected a missing page to be reported as not found, got %v", err)
+	}
+}
+
+// TestPackageParserFromSnapshot tests parsing questions and pictures from fixture pages in HTML and JSON
+func TestPackageParserFromSnapshot(t *testing.T) {
+	testutil.OpenDB(t)
+	snapshot := fixtureSnapshot(t)
+
+	parser := NewPackageParser(5220, true)
+	parser.Source = snapshot
+
+	content, err := parser.fetchPackage()
+	if err != nil {
+		t.Fatalf("Failed to read package: %v", err)
+	}
+	questions := content.questions
+	if len(questions) != 2 {
+		t.Fatalf("Expected 2 questions, got %d", len(questions))
+	}
+
+	first := questions[0]
+	if first.GotQuestionsID != 90001 || first.Answer != "Зонт" || first.AcceptedAnswer != "Зонтик" {
+		t.Errorf("Unexpected question %+v", first)
+	}
+	if first.Comment != `Шутка про "погоду".` {
+		t.Errorf("Expected escaped quotes to be decoded, got %q", first.Comment)
+	}
+	if first.AuthorID == nil || *first.AuthorID != 17 {
+		t.Errorf("Expected author 17, got %v", first.AuthorID)
+	}
+	if second := questions[1]; second.IsIncorrect == nil || !*second.IsIncorrect || second.Comment != "" ||
+		second.TakenDownReason != "Неверный факт в вопросе" || second.Number != 2 || second.TourNumber != 1 {
+		t.Errorf("Expected a numbered, taken down question without comment, got %+v", second)
+	}
+
+	img := content.images["/pics/90001.png"]
+	if img == nil || img.mimeType != "image/png" || http.DetectContentType(img.data) != "image/png" {
+		t.Errorf("Expected the PNG picture from the snapshot, got %+v", img)
+	}
+
+	parser = NewPackageParser(5221, true)
+	parser.Source = snapshot
+	content, err = parser.fetchPackage()
+	if err != nil {
+		t.Fatalf("Failed to read JSON package: %v", err)
+	}
+	if questions := content.questions; len(questions) != 1 || questions[0].Question != "Вопрос из JSON-снимка." {
+		t.Errorf("Unexpected questions from JSON snapshot: %+v", questions)
+	}
+}
+
+// TestImportSnapshot tests importing a whole snapshot into the database
+func TestImportSnapshot(t *testing.T) {
+	testutil.OpenDB(t)
+
+	summary, err := ImportSnapshot(fixtureSnapshot(t), true)
+	if err != nil {
+		t.Fatalf("Failed to import snapshot: %v", err)
+	}
+	if summary.ListPages != 1 || summary.Packages != 2 || len(summary.Failed) != 0 {
+		t.Errorf("Unexpected summary %+v", summary)
+	}
+
+	if count := countRows(t, `SELECT COUNT(*) FROM packages`); count != 2 {
+		t.Errorf("Expected 2 packages, got %d", count)
+	}
+	if count := countRows(t, `SELECT COUNT(*) FROM questions`); count != 3 {
+		t.Errorf("Expected 3 questions, got %d", count)
+	}
+	if count := countRows(t, `SELECT COUNT(*) FROM images WHERE mime_type = 'image/png'`); count != 1 {
+		t.Errorf("Expected 1 image, got %d", count)
+	}
+	if count := countRows(t, `SELECT COUNT(*) FROM questions WHERE number = 2 AND tour_number = 1 AND taken_down_reason <> ''`); count != 1 {
+		t.Errorf("Expected the position and taken down reason to be stored, got %d", count)
+	}
+}
+
+// TestRecordingSourceRoundTrip tests that a recorded package reads back the same from the snapshot
+func TestRecordingSourceRoundTrip(t *testing.T) {
+	testutil.OpenDB(t)
+
+	site := &packSite{page: packPage(t,
+		map[string]any{"id": 101, "text": "Вопрос 1", "answer": "Ответ 1", "razdatkaPic": "/pics/1.png"},
+	)}
+	useSite(t, site)
+
+	dir := t.TempDir()
+	recorder := NewRecordingSource(DefaultSource(), dir)
+	if err := RecordPackage(recorder, 7); err != nil {
+		t.Fatalf("Failed to record package: %v", err)
+	}
+
+	for _, name := range []string{"pack/7.html", "pics/1.png"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("Expected %s to be recorded: %v", name, err)
+		}
+	}
+
+	// The site goes down, but the snapshot still has everything
+	site.mu.Lock()
+	site.down = true
+	site.mu.Unlock()
+
+	snapshot, err := OpenSnapshot(dir)
+	if err != nil {
+		t.Fatalf("Failed to open snapshot: %v", err)
+	}
+
+	parser := NewPackageParser(7, true)
+	parser.Source = snapshot
+	if err := parser.ImportPackage(); err != nil {
+		t.Fatalf("Failed to import recorded package: %v", err)
+	}
+	if count := countRows(t, `SELECT COUNT(*) FROM images i JOIN questions q ON q.id = i.question_id WHERE q.gotquestions_id = 101`); count != 1 {
+		t.Errorf("Expected the recorded picture to be imported, got %d", count)
+	}
+
+	body, _, err := snapshot.Fetch(context.Background(), "/pack/7")
+	if err != nil || string(body) != site.page {
+		t.Errorf("Expected the recorded page to match the site, got %v", err)
+	}
+}
+
+// TestImportCredits tests that authors, tours and editors are stored and can filter questions
+func TestImportCredits(t *testing.T) {
+	testutil.OpenDB(t)
+
+	if _, err := ImportSnapshot(fixtureSnapshot(t), true); err != nil {
+		t.Fatalf("Failed to import snapshot: %v", err)
+	}
+
+	for query, expected := range map[string]int{
+		`SELECT COUNT(*) FROM authors`:                             4,
+		`SELECT COUNT(*) FROM question_authors`:                    2,
+		`SELECT COUNT(*) FROM tours`:                               2,
+		`SELECT COUNT(*) FROM editors WHERE package_id = 5220`:     2,
+		`SELECT COUNT(*) FROM tours WHERE package_id = 5221`:       1,
+		`SELECT COUNT(*) FROM editors WHERE tour_number = 0`:       1,
+		`SELECT COUNT(*) FROM question_authors WHERE position = 1`: 1,
+	} {
+		if count := countRows(t, query); count != expected {
+			t.Errorf("%s: expected %d, got %d", query, expected, count)
+		}
+	}
+
+	questionID := countRows(t, `SELECT id FROM questions WHERE gotquestions_id = 90001`)
+	repo := models.NewAuthorRepository()
+	credits, err := repo.GetCredits(questionID)
+	if err != nil {
+		t.Fatalf("Failed to get credits: %v", err)
+	}
+	if len(credits.Authors) != 2 || credits.Authors[0].Name != "Иван Петров" || credits.Authors[1].ID != 18 {
+		t.Errorf("Expected both authors in order, got %+v", credits.Authors)
+	}
+	if len(credits.Editors) != 2 || credits.PackageTitle != "Кубок весны" || credits.TourNumber != 1 || credits.Number != 1 {
+		t.Errorf("Unexpected credits %+v", credits)
+	}
+
+	all := []int{questionID, questionID + 1, questionID + 2}
+	filter, err := repo.ParseFilter("Анна Сидорова", "")
+	if err != nil {
+		t.Fatalf("Failed to parse filter: %v", err)
+	}
+	if ids, err := repo.ApplyFilter(filter, all); err != nil || len(ids) != 1 || ids[0] != questionID {
+		t.Errorf("Expected only the question by the author, got %v (%v)", ids, err)
+	}
+
+	filter, err = repo.ParseFilter("", "4")
+	if err != nil {
+		t.Fatalf("Failed to parse filter: %v", err)
+	}
+	if ids, err := repo.ApplyFilter(filter, all); err != nil || len(ids) != 2 {
+		t.Errorf("Expected the questions of the edited tour, got %v (%v)", ids, err)
+	}
+
+	if _, err := repo.ParseFilter("Нет такого", ""); err == nil {
+		t.Error("Expected an unknown author to be rejected")
+	}
+}
@@ -0,0 +1,105 @@
+package importer
+
+import (
+	"fmt"
+	"net/http"
+	"questions-vote/internal/models"
+	"regexp"
+	"strings"
+)
+
+// htmlArtifact matches tags and character entities left in question text
+var htmlArtifact = regexp.MustCompile(`</?[a-zA-Z][a-zA-Z0-9]*(?:\s[^<>]*)?/?>|&(?:[a-zA-Z]+|#[0-9]+|#[xX][0-9a-fA-F]+);`)
+
+// ValidatePackage checks the stored questions of a package and their pictures and replaces
+// the stored findings for the package with the issues found
+func ValidatePackage(packageID int) ([]*models.QuestionIssue, error) {
+	questionRepo := models.NewQuestionRepository()
+	questions, err := questionRepo.GetByPackage(packageID)
+	if err != nil {
+		return nil, err
+	}
+
+	images, err := questionRepo.GetPackageImages(packageID)
+	if err != nil {
+		return nil, err
+	}
+
+	var issues []*models.QuestionIssue
+	for _, question := range questions {
+		issues = append(issues, checkQuestion(question, images[question.ID])...)
+	}
+	for _, issue := range issues {
+		issue.PackageID = packageID
+	}
+
+	err = models.NewQuestionIssueRepository().ReplaceForPackage(packageID, issues)
+	if err != nil {
+		return nil, err
+	}
+
+	return issues, nil
+}
+
+// checkQuestion returns the data quality issues of a question and its picture, if it has one
+func checkQuestion(q *models.Question, img *models.Image) []*models.QuestionIssue {
+	var issues []*models.QuestionIssue
+	add := func(kind, detail string) {
+		issues = append(issues, &models.QuestionIssue{QuestionID: q.ID, Kind: kind, Detail: detail})
+	}
+
+	if strings.TrimSpace(q.Question) == "" {
+		add(models.IssueEmptyQuestion, "")
+	}
+	if strings.TrimSpace(q.Answer) == "" {
+		add(models.IssueEmptyAnswer, "")
+	}
+	if strings.TrimSpace(q.Source) == "" {
+		add(models.IssueMissingSource, "")
+	}
+
+	if img != nil {
+		if len(img.Data) == 0 {
+			add(models.IssueMissingImage, img.URL)
+		} else if problem := imageProblem(img); problem != "" {
+			add(models.IssueBadImage, fmt.Sprintf("%s: %s", img.URL, problem))
+		}
+	}
+
+	var artifacts []string
+	for _, field := range []struct{ name, value string }{
+		{"question", q.Question},
+		{"answer", q.Answer},
+		{"accepted_answer", q.AcceptedAnswer},
+		{"comment", q.Comment},
+		{"source", q.Source},
+		{"handout_str", q.HandoutStr},
+	} {
+		if match := htmlArtifact.FindString(field.value); match != "" {
+			artifacts = append(artifacts, fmt.Sprintf("%s: %s", field.name, match))
+		}
+	}
+	if len(artifacts) > 0 {
+		add(models.IssueHTML, strings.Join(artifacts, "; "))
+	}
+
+	return issues
+}
+
+// imageProblem describes why a stored picture is not a usable image, or returns ""
+func imageProblem(img *models.Image) string {
+	if !strings.HasPrefix(img.MimeType, "image/") {
+		return fmt.Sprintf("stored as %q", img.MimeType)
+	}
+
+	// SVG pictures are text, so they cannot be recognized by their first bytes
+	if img.MimeType == "image/svg+xml" {
+		return ""
+	}
+
+	detected := http.DetectContentType(img.Data)
+	if !strings.HasPrefix(detected, "image/") {
+		return fmt.Sprintf("stored as %s, but the data is %s", img.MimeType, detected)
+	}
+	return ""
+}
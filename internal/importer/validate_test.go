@@ -0,0 +1,173 @@
+package importer
+
+import (
+	"questions-vote/internal/models"
+	"questions-vote/internal/testutil"
+	"strings"
+	"testing"
+)
+
+// pngHeader is enough of a PNG file to be recognized as one
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+// TestCheckQuestion tests the data quality checks of a single question
+func TestCheckQuestion(t *testing.T) {
+	valid := models.Question{ID: 1, Question: "Вопрос", Answer: "Ответ", Source: "https://example.com"}
+
+	tests := []struct {
+		name   string
+		edit   func(q *models.Question)
+		image  *models.Image
+		kinds  []string
+		detail string
+	}{
+		{name: "valid", edit: func(q *models.Question) {}},
+		{name: "valid image", edit: func(q *models.Question) {}, image: &models.Image{URL: "/pics/1.png", Data: pngHeader, MimeType: "image/png"}},
+		{name: "valid svg", edit: func(q *models.Question) {}, image: &models.Image{URL: "/pics/1.svg", Data: []byte("<svg/>"), MimeType: "image/svg+xml"}},
+		{name: "math is not html", edit: func(q *models.Question) { q.Answer = "x < 3 & y > 2" }},
+		{name: "empty question", edit: func(q *models.Question) { q.Question = " \n" }, kinds: []string{models.IssueEmptyQuestion}},
+		{name: "empty answer", edit: func(q *models.Question) { q.Answer = "" }, kinds: []string{models.IssueEmptyAnswer}},
+		{name: "missing source", edit: func(q *models.Question) { q.Source = "" }, kinds: []string{models.IssueMissingSource}},
+		{
+			name:   "missing image",
+			edit:   func(q *models.Question) {},
+			image:  &models.Image{URL: "/pics/1.png"},
+			kinds:  []string{models.IssueMissingImage},
+			detail: "/pics/1.png",
+		},
+		{
+			name:   "html page instead of image",
+			edit:   func(q *models.Question) {},
+			image:  &models.Image{URL: "/pics/1.png", Data: []byte("<html><body>Not found</body></html>"), MimeType: "image/png"},
+			kinds:  []string{models.IssueBadImage},
+			detail: "text/html",
+		},
+		{
+			name:   "wrong mime type",
+			edit:   func(q *models.Question) {},
+			image:  &models.Image{URL: "/pics/1.png", Data: pngHeader, MimeType: "text/html"},
+			kinds:  []string{models.IssueBadImage},
+			detail: `"text/html"`,
+		},
+		{
+			name:   "html artifacts",
+			edit:   func(q *models.Question) { q.Answer = "Ответ<br/>"; q.Comment = "Лев&nbsp;Толстой" },
+			kinds:  []string{models.IssueHTML},
+			detail: "answer: <br/>; comment: &nbsp;",
+		},
+		{
+			name:  "several issues",
+			edit:  func(q *models.Question) { q.Answer = ""; q.Source = "" },
+			kinds: []string{models.IssueEmptyAnswer, models.IssueMissingSource},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := valid
+			tt.edit(&q)
+
+			issues := checkQuestion(&q, tt.image)
+			if len(issues) != len(tt.kinds) {
+				t.Fatalf("Expected issues %v, got %d", tt.kinds, len(issues))
+			}
+			for i, issue := range issues {
+				if issue.Kind != tt.kinds[i] || issue.QuestionID != 1 {
+					t.Errorf("Expected issue %s of question 1, got %+v", tt.kinds[i], issue)
+				}
+				if tt.detail != "" && !strings.Contains(issue.Detail, tt.detail) {
+					t.Errorf("Expected detail with %q, got %q", tt.detail, issue.Detail)
+				}
+			}
+		})
+	}
+}
+
+// TestValidatePackage tests that an import stores the findings and that they exclude questions
+func TestValidatePackage(t *testing.T) {
+	testutil.OpenDB(t)
+
+	site := &packSite{page: packPage(t,
+		map[string]any{"id": 101, "text": "Вопрос 1", "answer": "Ответ 1", "source": "Книга"},
+		map[string]any{"id": 102, "text": "Вопрос 2", "answer": "Ответ&nbsp;2", "source": "Книга"},
+		map[string]any{"id": 103, "text": "Вопрос 3", "answer": "Ответ 3", "source": "Книга", "razdatkaPic": "/missing/3.png"},
+		map[string]any{"id": 104, "text": "Вопрос 4", "answer": "Ответ 4"},
+	)}
+	useSite(t, site)
+
+	parser := NewPackageParser(7, true)
+	if err := parser.ImportPackage(); err != nil {
+		t.Fatalf("Failed to import package: %v", err)
+	}
+
+	if count := countRows(t, `SELECT COUNT(*) FROM images WHERE data IS NULL AND image_url = '/missing/3.png'`); count != 1 {
+		t.Errorf("Expected the picture that failed to download to be recorded, got %d", count)
+	}
+
+	repo := models.NewQuestionIssueRepository()
+	issues, err := repo.ListByPackage(7)
+	if err != nil {
+		t.Fatalf("Failed to list issues: %v", err)
+	}
+
+	kinds := make(map[string]int)
+	for _, issue := range issues {
+		kinds[issue.Kind]++
+	}
+	expected := map[string]int{models.IssueHTML: 1, models.IssueMissingImage: 1, models.IssueMissingSource: 1}
+	if len(kinds) != len(expected) {
+		t.Fatalf("Expected issues %v, got %v", expected, kinds)
+	}
+	for kind, count := range expected {
+		if kinds[kind] != count {
+			t.Errorf("Expected %d %s issues, got %d", count, kind, kinds[kind])
+		}
+	}
+
+	ids, err := models.NewQuestionRepository().GetQuestionIDsFromPackage(7)
+	if err != nil {
+		t.Fatalf("Failed to get question IDs: %v", err)
+	}
+
+	kept, err := repo.ExcludeFlagged(models.IssueKinds, ids)
+	if err != nil || len(kept) != 1 || kept[0] != ids[0] {
+		t.Errorf("Expected only the first question to be kept, got %v (%v)", kept, err)
+	}
+	kept, err = repo.ExcludeFlagged([]string{models.IssueMissingSource}, ids)
+	if err != nil || len(kept) != 3 {
+		t.Errorf("Expected the question without a source to be left out, got %v (%v)", kept, err)
+	}
+	kept, err = repo.ExcludeFlagged(nil, ids)
+	if err != nil || len(kept) != 4 {
+		t.Errorf("Expected nothing to be left out without kinds, got %v (%v)", kept, err)
+	}
+
+	// A fixed package replaces the findings
+	site.mu.Lock()
+	site.page = packPage(t,
+		map[string]any{"id": 101, "text": "Вопрос 1", "answer": "Ответ 1", "source": "Книга"},
+	)
+	site.mu.Unlock()
+	if err := parser.ImportPackage(); err != nil {
+		t.Fatalf("Failed to re-import package: %v", err)
+	}
+	if count := countRows(t, `SELECT COUNT(*) FROM question_issues`); count != 0 {
+		t.Errorf("Expected no issues after the fix, got %d", count)
+	}
+}
+
+// TestParseIssueKinds tests parsing of the kinds of issues to exclude
+func TestParseIssueKinds(t *testing.T) {
+	if kinds, err := models.ParseIssueKinds("all"); err != nil || len(kinds) != len(models.IssueKinds) {
+		t.Errorf("Expected all kinds, got %v (%v)", kinds, err)
+	}
+	if kinds, err := models.ParseIssueKinds(""); err != nil || len(kinds) != 0 {
+		t.Errorf("Expected no kinds, got %v (%v)", kinds, err)
+	}
+	if kinds, err := models.ParseIssueKinds("empty_answer, missing_image"); err != nil || len(kinds) != 2 {
+		t.Errorf("Expected two kinds, got %v (%v)", kinds, err)
+	}
+	if _, err := models.ParseIssueKinds("typo"); err == nil {
+		t.Error("Expected an unknown kind to be rejected")
+	}
+}
@@ -0,0 +1,215 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"questions-vote/internal/models"
+	"sync"
+	"time"
+)
+
+// DefaultWorkers is the number of packages imported at the same time
+const DefaultWorkers = 4
+
+// ImportFunc imports the questions of one package
+type ImportFunc func(packageID int, rewrite bool) error
+
+// importPackage imports a package with a PackageParser
+func importPackage(packageID int, rewrite bool) error {
+	return NewPackageParser(packageID, rewrite).ImportPackage()
+}
+
+// ImportSummary is the outcome of an import run
+type ImportSummary struct {
+	Run      *models.ImportRun
+	Progress models.ImportProgress
+	Failed   []*models.ImportItem
+	Duration time.Duration
+}
+
+// YearImporter imports all packages of a year with a pool of workers, tracking every
+// package in the database so that an interrupted run can be resumed
+type YearImporter struct {
+	Workers int
+	Import  ImportFunc
+
+	runs         *models.ImportRunRepository
+	packageRepo  *models.PackageRepository
+	questionRepo *models.QuestionRepository
+}
+
+// NewYearImporter creates a year importer with the given number of workers
+func NewYearImporter(workers int) *YearImporter {
+	if workers < 1 {
+		workers = 1
+	}
+
+	return &YearImporter{
+		Workers:      workers,
+		Import:       importPackage,
+		runs:         models.NewImportRunRepository(),
+		packageRepo:  models.NewPackageRepository(),
+		questionRepo: models.NewQuestionRepository(),
+	}
+}
+
+// Start creates a run for all packages of the year and imports them
+func (yi *YearImporter) Start(ctx context.Context, year int, rewrite bool) (*ImportSummary, error) {
+	packages, err := yi.packageRepo.GetPackagesByYear(year)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get packages for year %d: %w", year, err)
+	}
+
+	packageIDs := make([]int, len(packages))
+	for i, pkg := range packages {
+		packageIDs[i] = pkg.GotQuestionsID
+	}
+
+	run, err := yi.runs.Create(year, rewrite, packageIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create import run: %w", err)
+	}
+
+	log.Printf("Started import run %d for year %d with %d packages", run.ID, year, len(packageIDs))
+	return yi.process(ctx, run)
+}
+
+// Resume continues a run: packages that were not imported or were interrupted are
+// imported, and failed packages too if retryFailed is set
+func (yi *YearImporter) Resume(ctx context.Context, runID int, retryFailed bool) (*ImportSummary, error) {
+	run, err := yi.runs.FindByID(runID)
+	if err != nil {
+		return nil, err
+	}
+
+	requeued, err := yi.runs.Requeue(run.ID, retryFailed)
+	if err != nil {
+		return nil, err
+	}
+	run.Status = models.ImportRunRunning
+
+	log.Printf("Resuming import run %d for year %d (%d packages requeued)", run.ID, run.Year, requeued)
+	return yi.process(ctx, run)
+}
+
+// FindLatestRun returns the latest run for a year, or nil if there is none
+func (yi *YearImporter) FindLatestRun(year int) (*models.ImportRun, error) {
+	return yi.runs.FindLatest(year)
+}
+
+// process imports the pending packages of a run and finishes it. If the context is
+// cancelled, packages being imported are completed and the run is left to be resumed.
+func (yi *YearImporter) process(ctx context.Context, run *models.ImportRun) (*ImportSummary, error) {
+	started := time.Now()
+
+	items, err := yi.runs.GetPendingItems(run.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	queue := make(chan *models.ImportItem)
+	var wg sync.WaitGroup
+	for i := 0; i < yi.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range queue {
+				yi.importItem(run, item)
+			}
+		}()
+	}
+
+feed:
+	for _, item := range items {
+		select {
+		case <-ctx.Done():
+			break feed
+		case queue <- item:
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	if ctx.Err() == nil {
+		err = yi.finish(run)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	summary, err := yi.summarize(run)
+	if err != nil {
+		return nil, err
+	}
+	summary.Duration = time.Since(started)
+
+	return summary, ctx.Err()
+}
+
+// importItem imports one package and records the outcome
+func (yi *YearImporter) importItem(run *models.ImportRun, item *models.ImportItem) {
+	if !run.Rewrite {
+		hasQuestions, err := yi.questionRepo.HasQuestionsFromPackage(item.PackageID)
+		if err != nil {
+			log.Printf("Failed to check if package %d has questions: %v", item.PackageID, err)
+		} else if hasQuestions {
+			log.Printf("Skipping package %d (already has questions)", item.PackageID)
+			yi.finishItem(run, item, models.ImportItemSkipped, "")
+			return
+		}
+	}
+
+	err := yi.runs.StartItem(run.ID, item.PackageID)
+	if err != nil {
+		log.Printf("Failed to start package %d: %v", item.PackageID, err)
+		return
+	}
+
+	err = yi.Import(item.PackageID, run.Rewrite)
+	if err != nil {
+		log.Printf("Failed to import package %d: %v", item.PackageID, err)
+		yi.finishItem(run, item, models.ImportItemFailed, err.Error())
+		return
+	}
+
+	log.Printf("Imported package %d", item.PackageID)
+	yi.finishItem(run, item, models.ImportItemDone, "")
+}
+
+func (yi *YearImporter) finishItem(run *models.ImportRun, item *models.ImportItem, status, errorText string) {
+	err := yi.runs.FinishItem(run.ID, item.PackageID, status, errorText)
+	if err != nil {
+		log.Printf("Failed to record outcome of package %d: %v", item.PackageID, err)
+	}
+}
+
+// finish marks the run done, or failed if some packages failed or are left over
+func (yi *YearImporter) finish(run *models.ImportRun) error {
+	progress, err := yi.runs.GetProgress(run.ID)
+	if err != nil {
+		return err
+	}
+
+	run.Status = models.ImportRunDone
+	if progress[models.ImportItemDone]+progress[models.ImportItemSkipped] < progress.Total() {
+		run.Status = models.ImportRunFailed
+	}
+
+	return yi.runs.SetStatus(run.ID, run.Status)
+}
+
+// summarize collects the progress and failures of a run
+func (yi *YearImporter) summarize(run *models.ImportRun) (*ImportSummary, error) {
+	progress, err := yi.runs.GetProgress(run.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	failed, err := yi.runs.GetFailedItems(run.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ImportSummary{Run: run, Progress: progress, Failed: failed}, nil
+}
@@ -0,0 +1,146 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"questions-vote/internal/models"
+	"questions-vote/internal/testutil"
+	"sync"
+	"testing"
+	"time"
+)
+
+// addPackages stores packages that ended in the given year
+func addPackages(t *testing.T, year int, packageIDs ...int) {
+	t.Helper()
+
+	repo := models.NewPackageRepository()
+	for _, id := range packageIDs {
+		err := repo.Insert(&models.Package{
+			GotQuestionsID: id,
+			Title:          "Package",
+			StartDate:      time.Date(year, 3, 1, 0, 0, 0, 0, time.UTC),
+			EndDate:        time.Date(year, 3, 2, 0, 0, 0, 0, time.UTC),
+		})
+		if err != nil {
+			t.Fatalf("Failed to insert package: %v", err)
+		}
+	}
+}
+
+// fakeImport records imported packages, fails for configured ones and tracks concurrency
+type fakeImport struct {
+	mu       sync.Mutex
+	imported map[int]int
+	failures map[int]int // failures of a package before it imports
+	running  int
+	peak     int
+	cancel   context.CancelFunc
+	stopAt   int // cancel the context after this many imports
+}
+
+func (f *fakeImport) Import(packageID int, rewrite bool) error {
+	f.mu.Lock()
+	f.running++
+	f.peak = max(f.peak, f.running)
+	f.mu.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.running--
+
+	if f.failures[packageID] > 0 {
+		f.failures[packageID]--
+		return errors.New("HTTP 503")
+	}
+
+	f.imported[packageID]++
+	if f.cancel != nil && len(f.imported) == f.stopAt {
+		f.cancel()
+	}
+	return nil
+}
+
+// TestYearImporterRetriesFailed tests the worker bound, the summary and retrying only failed packages
+func TestYearImporterRetriesFailed(t *testing.T) {
+	testutil.OpenDB(t)
+	addPackages(t, 2022, 1, 2, 3, 4, 5, 6, 7, 8)
+
+	fake := &fakeImport{imported: map[int]int{}, failures: map[int]int{3: 1, 6: 1}}
+	yi := NewYearImporter(3)
+	yi.Import = fake.Import
+
+	summary, err := yi.Start(context.Background(), 2022, true)
+	if err != nil {
+		t.Fatalf("Failed to import year: %v", err)
+	}
+
+	if fake.peak > 3 {
+		t.Errorf("Expected at most 3 concurrent imports, got %d", fake.peak)
+	}
+	if summary.Run.Status != models.ImportRunFailed {
+		t.Errorf("Expected the run to be failed, got %s", summary.Run.Status)
+	}
+	if summary.Progress[models.ImportItemDone] != 6 || len(summary.Failed) != 2 {
+		t.Fatalf("Expected 6 imported and 2 failed, got %v", summary.Progress)
+	}
+	if summary.Failed[0].PackageID != 3 || summary.Failed[0].Error != "HTTP 503" {
+		t.Errorf("Unexpected failure %+v", summary.Failed[0])
+	}
+
+	summary, err = yi.Resume(context.Background(), summary.Run.ID, true)
+	if err != nil {
+		t.Fatalf("Failed to retry failed packages: %v", err)
+	}
+
+	if summary.Run.Status != models.ImportRunDone || summary.Progress[models.ImportItemDone] != 8 {
+		t.Errorf("Expected all packages imported after retrying, got %s %v", summary.Run.Status, summary.Progress)
+	}
+	for id, count := range fake.imported {
+		if count != 1 {
+			t.Errorf("Expected package %d to be imported once, got %d", id, count)
+		}
+	}
+}
+
+// TestYearImporterResumes tests that an interrupted run continues with the packages left
+func TestYearImporterResumes(t *testing.T) {
+	packages := []int{1, 2, 3, 4, 5, 6}
+	testutil.OpenDB(t)
+	addPackages(t, 2023, packages...)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	fake := &fakeImport{imported: map[int]int{}, cancel: cancel, stopAt: 2}
+	yi := NewYearImporter(1)
+	yi.Import = fake.Import
+
+	summary, err := yi.Start(ctx, 2023, true)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected the import to stop with the context, got %v", err)
+	}
+	if summary.Run.Status != models.ImportRunRunning || summary.Progress[models.ImportItemPending] == 0 {
+		t.Fatalf("Expected an unfinished run with pending packages, got %s %v", summary.Run.Status, summary.Progress)
+	}
+
+	run, err := yi.FindLatestRun(2023)
+	if err != nil || run == nil {
+		t.Fatalf("Failed to find the run: %v", err)
+	}
+
+	fake.cancel = nil
+	summary, err = yi.Resume(context.Background(), run.ID, false)
+	if err != nil {
+		t.Fatalf("Failed to resume: %v", err)
+	}
+
+	if summary.Run.Status != models.ImportRunDone {
+		t.Errorf("Expected the run to be done, got %s", summary.Run.Status)
+	}
+	for _, id := range packages {
+		if fake.imported[id] != 1 {
+			t.Errorf("Expected package %d to be imported exactly once, got %d", id, fake.imported[id])
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"questions-vote/internal/db"
+	"strconv"
+	"strings"
+)
+
+// Author is a person credited on gotquestions.online as an author or an editor
+type Author struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// Tour is a tour of a package with its editors
+type Tour struct {
+	PackageID int      `json:"package_id"`
+	Number    int      `json:"number"`
+	Title     string   `json:"title"`
+	Editors   []Author `json:"editors,omitempty"`
+}
+
+// QuestionCredits says who wrote and edited a question and where it was played
+type QuestionCredits struct {
+	QuestionID   int      `json:"question_id"`
+	Authors      []Author `json:"authors"`
+	Editors      []Author `json:"editors"`
+	PackageTitle string   `json:"package_title"`
+	TourNumber   int      `json:"tour_number"`
+	Number       int      `json:"number"`
+}
+
+// AuthorResult is how the questions of an author did in a tournament
+type AuthorResult struct {
+	Author        Author  `json:"author"`
+	Questions     int     `json:"questions"`
+	AverageRating float64 `json:"average_rating"`
+	BestRating    float64 `json:"best_rating"`
+	Matches       int     `json:"matches"`
+	Wins          int     `json:"wins"`
+}
+
+// QuestionFilter keeps questions written by any of the authors and edited by any of the editors.
+// An empty list does not restrict.
+type QuestionFilter struct {
+	AuthorIDs []int
+	EditorIDs []int
+}
+
+// Empty reports whether the filter keeps every question
+func (f *QuestionFilter) Empty() bool {
+	return len(f.AuthorIDs) == 0 && len(f.EditorIDs) == 0
+}
+
+// AuthorRepository handles author, tour and editor database operations
+type AuthorRepository struct {
+	db *sql.DB
+}
+
+// NewAuthorRepository creates a new author repository
+func NewAuthorRepository() *AuthorRepository {
+	return &AuthorRepository{
+		db: db.GetDB(),
+	}
+}
+
+// Resolve finds an author by ID or by exact name
+func (r *AuthorRepository) Resolve(value string) (*Author, error) {
+	value = strings.TrimSpace(value)
+
+	var rows *sql.Rows
+	var err error
+	if id, convErr := strconv.Atoi(value); convErr == nil {
+		rows, err = r.db.Query(`SELECT id, name FROM authors WHERE id = ?`, id)
+	} else {
+		rows, err = r.db.Query(`SELECT id, name FROM authors WHERE name = ? ORDER BY id`, value)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query authors: %w", err)
+	}
+	authors, err := scanAuthors(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(authors) {
+	case 0:
+		return nil, fmt.Errorf("author %q not found", value)
+	case 1:
+		return &authors[0], nil
+	default:
+		return nil, fmt.Errorf("%d authors are named %q, use the ID", len(authors), value)
+	}
+}
+
+// ParseFilter resolves comma-separated lists of author and editor IDs or names
+func (r *AuthorRepository) ParseFilter(authors, editors string) (*QuestionFilter, error) {
+	filter := &QuestionFilter{}
+	for _, list := range []struct {
+		value string
+		ids   *[]int
+	}{{authors, &filter.AuthorIDs}, {editors, &filter.EditorIDs}} {
+		for _, part := range strings.Split(list.value, ",") {
+			if strings.TrimSpace(part) == "" {
+				continue
+			}
+
+			author, err := r.Resolve(part)
+			if err != nil {
+				return nil, err
+			}
+			*list.ids = append(*list.ids, author.ID)
+		}
+	}
+	return filter, nil
+}
+
+// ApplyFilter returns the questions, in order, that pass the filter
+func (r *AuthorRepository) ApplyFilter(filter *QuestionFilter, questionIDs []int) ([]int, error) {
+	if filter.Empty() {
+		return questionIDs, nil
+	}
+
+	var sets []map[int]bool
+	if len(filter.AuthorIDs) > 0 {
+		set, err := r.queryIDs(`SELECT question_id FROM question_authors WHERE author_id IN (%s)`, filter.AuthorIDs)
+		if err != nil {
+			return nil, err
+		}
+		sets = append(sets, set)
+	}
+	if len(filter.EditorIDs) > 0 {
+		set, err := r.queryIDs(`
+			SELECT q.id
+			FROM questions q
+			JOIN editors e ON e.package_id = q.package_id AND e.tour_number IN (0, q.tour_number)
+			WHERE e.author_id IN (%s)
+		`, filter.EditorIDs)
+		if err != nil {
+			return nil, err
+		}
+		sets = append(sets, set)
+	}
+
+	var filtered []int
+	for _, id := range questionIDs {
+		keep := true
+		for _, set := range sets {
+			keep = keep && set[id]
+		}
+		if keep {
+			filtered = append(filtered, id)
+		}
+	}
+	return filtered, nil
+}
+
+// queryIDs runs a query selecting question IDs, with %s replaced by placeholders for args
+func (r *AuthorRepository) queryIDs(query string, ids []int) (map[int]bool, error) {
+	placeholders := make([]string, len(ids))
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+
+	rows, err := r.db.Query(fmt.Sprintf(query, strings.Join(placeholders, ",")), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query questions by author: %w", err)
+	}
+	defer rows.Close()
+
+	set := make(map[int]bool)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan question ID: %w", err)
+		}
+		set[id] = true
+	}
+	return set, rows.Err()
+}
+
+// GetCredits returns the authors, editors and place in its package of a question
+func (r *AuthorRepository) GetCredits(questionID int) (*QuestionCredits, error) {
+	credits := &QuestionCredits{QuestionID: questionID}
+
+	var packageID sql.NullInt64
+	err := r.db.QueryRow(`
+		SELECT q.package_id, q.tour_number, q.number, COALESCE(p.title, '')
+		FROM questions q
+		LEFT JOIN packages p ON p.gotquestions_id = q.package_id
+		WHERE q.id = ?
+	`, questionID).Scan(&packageID, &credits.TourNumber, &credits.Number, &credits.PackageTitle)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find question %d: %w", questionID, err)
+	}
+
+	rows, err := r.db.Query(`
+		SELECT a.id, a.name
+		FROM question_authors qa
+		JOIN authors a ON a.id = qa.author_id
+		WHERE qa.question_id = ?
+		ORDER BY qa.position
+	`, questionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query authors: %w", err)
+	}
+	credits.Authors, err = scanAuthors(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err = r.db.Query(`
+		SELECT DISTINCT a.id, a.name
+		FROM editors e
+		JOIN authors a ON a.id = e.author_id
+		WHERE e.package_id = ? AND e.tour_number IN (0, ?)
+		ORDER BY a.name
+	`, packageID, credits.TourNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query editors: %w", err)
+	}
+	credits.Editors, err = scanAuthors(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	return credits, nil
+}
+
+// GetAuthorResults ranks the authors of a tournament's questions by the average rating
+// of their questions, listing authors with at least minQuestions questions
+func (r *AuthorRepository) GetAuthorResults(tournamentID, minQuestions int) ([]*AuthorResult, error) {
+	query := `
+		SELECT a.id, a.name, COUNT(*), AVG(tq.rating), MAX(tq.rating), SUM(tq.matches), SUM(tq.wins)
+		FROM tournament_questions tq
+		JOIN question_authors qa ON qa.question_id = tq.question_id
+		JOIN authors a ON a.id = qa.author_id
+		WHERE tq.tournament_id = ?
+		GROUP BY a.id, a.name
+		HAVING COUNT(*) >= ?
+		ORDER BY AVG(tq.rating) DESC
+	`
+
+	rows, err := r.db.Query(query, tournamentID, minQuestions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query author results: %w", err)
+	}
+	defer rows.Close()
+
+	var results []*AuthorResult
+	for rows.Next() {
+		result := &AuthorResult{}
+		err := rows.Scan(&result.Author.ID, &result.Author.Name, &result.Questions,
+			&result.AverageRating, &result.BestRating, &result.Matches, &result.Wins)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan author result: %w", err)
+		}
+		results = append(results, result)
+	}
+
+	return results, rows.Err()
+}
+
+// scanAuthors reads id, name rows and closes them
+func scanAuthors(rows *sql.Rows) ([]Author, error) {
+	defer rows.Close()
+
+	var authors []Author
+	for rows.Next() {
+		var author Author
+		if err := rows.Scan(&author.ID, &author.Name); err != nil {
+			return nil, fmt.Errorf("failed to scan author: %w", err)
+		}
+		authors = append(authors, author)
+	}
+	return authors, rows.Err()
+}
@@ -0,0 +1,301 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"questions-vote/internal/db"
+	"time"
+)
+
+// Broadcast audiences
+const (
+	AudienceAll    = "all"    // every user who has not been banned
+	AudienceVoters = "voters" // users who voted in the broadcast's tournament
+)
+
+// Broadcast statuses
+const (
+	BroadcastPending = "pending"
+	BroadcastSending = "sending"
+	BroadcastDone    = "done"
+)
+
+// Delivery statuses
+const (
+	DeliveryPending = "pending"
+	DeliverySent    = "sent"
+	DeliveryFailed  = "failed"
+	DeliveryBlocked = "blocked" // the user blocked the bot
+	DeliverySkipped = "skipped" // the user opted out or was banned after the broadcast was queued
+)
+
+// Broadcast is a message queued for many users
+type Broadcast struct {
+	ID           int        `json:"id"`
+	TournamentID *int       `json:"tournament_id,omitempty"`
+	Audience     string     `json:"audience"`
+	Text         string     `json:"text"`
+	Status       string     `json:"status"`
+	CreatedBy    int64      `json:"created_by"`
+	CreatedAt    time.Time  `json:"created_at"`
+	FinishedAt   *time.Time `json:"finished_at,omitempty"`
+}
+
+// Delivery is a pending message of a broadcast to one user
+type Delivery struct {
+	BroadcastID int
+	UserID      int64
+	Attempts    int
+	Subscribed  bool // the user still wants broadcasts and is not banned
+}
+
+// BroadcastProgress counts deliveries of a broadcast by status
+type BroadcastProgress map[string]int
+
+// Total returns the number of recipients
+func (p BroadcastProgress) Total() int {
+	total := 0
+	for _, count := range p {
+		total += count
+	}
+	return total
+}
+
+// BroadcastRepository handles broadcast database operations
+type BroadcastRepository struct {
+	db *sql.DB
+}
+
+// NewBroadcastRepository creates a new broadcast repository
+func NewBroadcastRepository() *BroadcastRepository {
+	return &BroadcastRepository{
+		db: db.GetDB(),
+	}
+}
+
+// Create stores a broadcast together with a pending delivery for every recipient,
+// so that the list of recipients does not change while it is being sent
+func (r *BroadcastRepository) Create(b *Broadcast) (int, error) {
+	var recipients string
+	var args []interface{}
+
+	switch b.Audience {
+	case AudienceAll:
+		recipients = `SELECT id FROM users WHERE status != ? AND notifications = 1`
+		args = []interface{}{StatusBanned}
+	case AudienceVoters:
+		if b.TournamentID == nil {
+			return 0, fmt.Errorf("broadcast to voters needs a tournament")
+		}
+		// Voters from before users were recorded may have no user row
+		recipients = `
+			SELECT DISTINCT v.user_id AS id
+			FROM votes v
+			LEFT JOIN users u ON u.id = v.user_id
+			WHERE v.tournament_id = ? AND v.user_id IS NOT NULL
+			AND COALESCE(u.status != ? AND u.notifications = 1, 1)
+		`
+		args = []interface{}{*b.TournamentID, StatusBanned}
+	default:
+		return 0, fmt.Errorf("unknown broadcast audience: %s", b.Audience)
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		`INSERT INTO broadcasts (tournament_id, audience, text, status, created_by, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		b.TournamentID, b.Audience, b.Text, BroadcastPending, b.CreatedBy, time.Now(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert broadcast: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get broadcast ID: %w", err)
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO broadcast_deliveries (broadcast_id, user_id) SELECT ?, id FROM (`+recipients+`)`,
+		append([]interface{}{id}, args...)...,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to queue broadcast deliveries: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, fmt.Errorf("failed to commit broadcast: %w", err)
+	}
+
+	b.ID = int(id)
+	b.Status = BroadcastPending
+	return b.ID, nil
+}
+
+// FindUnfinished returns broadcasts that still have deliveries to make, oldest first
+func (r *BroadcastRepository) FindUnfinished() ([]*Broadcast, error) {
+	return r.query(`WHERE status != ? ORDER BY id`, BroadcastDone)
+}
+
+// ListRecent returns the latest broadcasts, newest first
+func (r *BroadcastRepository) ListRecent(limit int) ([]*Broadcast, error) {
+	return r.query(`ORDER BY id DESC LIMIT ?`, limit)
+}
+
+func (r *BroadcastRepository) query(clause string, args ...interface{}) ([]*Broadcast, error) {
+	query := `
+		SELECT id, tournament_id, audience, text, status, created_by, created_at, finished_at
+		FROM broadcasts
+	` + clause
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query broadcasts: %w", err)
+	}
+	defer rows.Close()
+
+	var broadcasts []*Broadcast
+	for rows.Next() {
+		b := &Broadcast{}
+		err := rows.Scan(&b.ID, &b.TournamentID, &b.Audience, &b.Text, &b.Status, &b.CreatedBy, &b.CreatedAt, &b.FinishedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan broadcast: %w", err)
+		}
+		broadcasts = append(broadcasts, b)
+	}
+
+	return broadcasts, rows.Err()
+}
+
+// SetStatus changes the status of a broadcast, recording when it finished
+func (r *BroadcastRepository) SetStatus(broadcastID int, status string) error {
+	var finishedAt *time.Time
+	if status == BroadcastDone {
+		now := time.Now()
+		finishedAt = &now
+	}
+
+	_, err := r.db.Exec(`UPDATE broadcasts SET status = ?, finished_at = ? WHERE id = ?`, status, finishedAt, broadcastID)
+	if err != nil {
+		return fmt.Errorf("failed to update broadcast status: %w", err)
+	}
+	return nil
+}
+
+// GetPendingDeliveries returns up to limit deliveries of a broadcast that have not been made yet
+// and are not waiting to be retried
+func (r *BroadcastRepository) GetPendingDeliveries(broadcastID, limit int) ([]*Delivery, error) {
+	query := `
+		SELECT d.broadcast_id, d.user_id, d.attempts,
+		       COALESCE(u.notifications = 1 AND u.status != ?, 1)
+		FROM broadcast_deliveries d
+		LEFT JOIN users u ON u.id = d.user_id
+		WHERE d.broadcast_id = ? AND d.status = ?
+		AND (d.next_attempt_at IS NULL OR d.next_attempt_at <= ?)
+		ORDER BY d.user_id
+		LIMIT ?
+	`
+
+	rows, err := r.db.Query(query, StatusBanned, broadcastID, DeliveryPending, time.Now().UTC(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pending deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []*Delivery
+	for rows.Next() {
+		d := &Delivery{}
+		err := rows.Scan(&d.BroadcastID, &d.UserID, &d.Attempts, &d.Subscribed)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan delivery: %w", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// RecordDelivery stores the outcome of an attempt to deliver a broadcast to a user
+func (r *BroadcastRepository) RecordDelivery(broadcastID int, userID int64, status, errorText string) error {
+	var sentAt *time.Time
+	if status == DeliverySent {
+		now := time.Now()
+		sentAt = &now
+	}
+
+	query := `
+		UPDATE broadcast_deliveries
+		SET status = ?, attempts = attempts + 1, error = ?, sent_at = ?
+		WHERE broadcast_id = ? AND user_id = ?
+	`
+
+	_, err := r.db.Exec(query, status, errorText, sentAt, broadcastID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to record delivery: %w", err)
+	}
+	return nil
+}
+
+// RetryDeliveryAt records a failed attempt to deliver a broadcast to a user and leaves the
+// delivery pending until the given time
+func (r *BroadcastRepository) RetryDeliveryAt(broadcastID int, userID int64, errorText string, at time.Time) error {
+	query := `
+		UPDATE broadcast_deliveries
+		SET status = ?, attempts = attempts + 1, error = ?, next_attempt_at = ?
+		WHERE broadcast_id = ? AND user_id = ?
+	`
+
+	_, err := r.db.Exec(query, DeliveryPending, errorText, at.UTC(), broadcastID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to schedule delivery retry: %w", err)
+	}
+	return nil
+}
+
+// NextRetryAt returns when the earliest delivery of a broadcast waiting to be retried is due,
+// or nil if none is waiting
+func (r *BroadcastRepository) NextRetryAt(broadcastID int) (*time.Time, error) {
+	query := `
+		SELECT next_attempt_at
+		FROM broadcast_deliveries
+		WHERE broadcast_id = ? AND status = ? AND next_attempt_at IS NOT NULL
+		ORDER BY next_attempt_at
+		LIMIT 1
+	`
+
+	var next time.Time
+	err := r.db.QueryRow(query, broadcastID, DeliveryPending).Scan(&next)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get next delivery retry: %w", err)
+	}
+	return &next, nil
+}
+
+// GetProgress counts the deliveries of a broadcast by status
+func (r *BroadcastRepository) GetProgress(broadcastID int) (BroadcastProgress, error) {
+	rows, err := r.db.Query(`SELECT status, COUNT(*) FROM broadcast_deliveries WHERE broadcast_id = ? GROUP BY status`, broadcastID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query broadcast progress: %w", err)
+	}
+	defer rows.Close()
+
+	progress := BroadcastProgress{}
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan broadcast progress: %w", err)
+		}
+		progress[status] = count
+	}
+
+	return progress, rows.Err()
+}
@@ -0,0 +1,168 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"questions-vote/internal/db"
+	"sort"
+	"time"
+)
+
+// DuplicateCluster is a group of copies of the same question. Its ID is the ID of the
+// representative, the copy from the package that ended first.
+type DuplicateCluster struct {
+	ID      int                `json:"id"`
+	Members []*DuplicateMember `json:"members"`
+}
+
+// DuplicateMember is a question in a duplicate cluster
+type DuplicateMember struct {
+	QuestionID   int        `json:"question_id"`
+	Similarity   float64    `json:"similarity"` // highest shingle similarity to another member
+	Question     string     `json:"question"`
+	PackageID    int        `json:"package_id"`
+	PackageTitle string     `json:"package_title"`
+	EndDate      *time.Time `json:"end_date,omitempty"`
+}
+
+// DuplicateRepository handles the clusters of duplicate questions
+type DuplicateRepository struct {
+	db *sql.DB
+}
+
+// NewDuplicateRepository creates a new duplicate repository
+func NewDuplicateRepository() *DuplicateRepository {
+	return &DuplicateRepository{
+		db: db.GetDB(),
+	}
+}
+
+// Replace replaces every stored cluster with the clusters of the latest detection.
+// The first member of a cluster is its representative.
+func (r *DuplicateRepository) Replace(clusters []*DuplicateCluster) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`DELETE FROM question_duplicates`)
+	if err != nil {
+		return fmt.Errorf("failed to delete duplicate clusters: %w", err)
+	}
+
+	stmt, err := tx.Prepare(`INSERT INTO question_duplicates (question_id, cluster_id, similarity) VALUES (?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare duplicate insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, cluster := range clusters {
+		for _, member := range cluster.Members {
+			_, err = stmt.Exec(member.QuestionID, cluster.Members[0].QuestionID, member.Similarity)
+			if err != nil {
+				return fmt.Errorf("failed to insert duplicate %d: %w", member.QuestionID, err)
+			}
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit duplicate clusters: %w", err)
+	}
+	return nil
+}
+
+// ListClusters returns the largest clusters with their members, representative first
+func (r *DuplicateRepository) ListClusters(limit int) ([]*DuplicateCluster, error) {
+	rows, err := r.db.Query(`
+		SELECT d.cluster_id, d.question_id, d.similarity, COALESCE(q.question, ''),
+		       COALESCE(q.package_id, 0), COALESCE(p.title, ''), p.end_date
+		FROM question_duplicates d
+		JOIN questions q ON q.id = d.question_id
+		LEFT JOIN packages p ON p.gotquestions_id = q.package_id
+		WHERE d.cluster_id IN (
+			SELECT cluster_id FROM question_duplicates
+			GROUP BY cluster_id
+			ORDER BY COUNT(*) DESC, cluster_id
+			LIMIT ?
+		)
+		ORDER BY d.cluster_id, d.question_id <> d.cluster_id, p.end_date, d.question_id
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query duplicate clusters: %w", err)
+	}
+	defer rows.Close()
+
+	var clusters []*DuplicateCluster
+	byID := make(map[int]*DuplicateCluster)
+	for rows.Next() {
+		var clusterID int
+		m := &DuplicateMember{}
+		err := rows.Scan(&clusterID, &m.QuestionID, &m.Similarity, &m.Question, &m.PackageID, &m.PackageTitle, &m.EndDate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan duplicate: %w", err)
+		}
+
+		cluster := byID[clusterID]
+		if cluster == nil {
+			cluster = &DuplicateCluster{ID: clusterID}
+			byID[clusterID] = cluster
+			clusters = append(clusters, cluster)
+		}
+		cluster.Members = append(cluster.Members, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(clusters, func(i, j int) bool { return len(clusters[i].Members) > len(clusters[j].Members) })
+	return clusters, nil
+}
+
+// KeepOnePerCluster removes all but one copy of each duplicated question, keeping the order.
+// The representative of a cluster is kept if it is among the questions, otherwise the
+// first copy is.
+func (r *DuplicateRepository) KeepOnePerCluster(questionIDs []int) ([]int, error) {
+	if len(questionIDs) == 0 {
+		return questionIDs, nil
+	}
+
+	rows, err := r.db.Query(`SELECT question_id, cluster_id FROM question_duplicates`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query duplicates: %w", err)
+	}
+	defer rows.Close()
+
+	clusterOf := make(map[int]int)
+	for rows.Next() {
+		var questionID, clusterID int
+		if err := rows.Scan(&questionID, &clusterID); err != nil {
+			return nil, fmt.Errorf("failed to scan duplicate: %w", err)
+		}
+		clusterOf[questionID] = clusterID
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	chosen := make(map[int]int)
+	for _, id := range questionIDs {
+		cluster, ok := clusterOf[id]
+		if !ok {
+			continue
+		}
+		if _, seen := chosen[cluster]; !seen || id == cluster {
+			chosen[cluster] = id
+		}
+	}
+
+	var kept []int
+	for _, id := range questionIDs {
+		cluster, ok := clusterOf[id]
+		if !ok || chosen[cluster] == id {
+			kept = append(kept, id)
+		}
+	}
+	return kept, nil
+}
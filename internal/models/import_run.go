@@ -0,0 +1,282 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"questions-vote/internal/db"
+	"time"
+)
+
+// Import run statuses
+const (
+	ImportRunRunning = "running"
+	ImportRunDone    = "done"
+	ImportRunFailed  = "failed" // finished, but some packages failed to import
+)
+
+// Import item statuses
+const (
+	ImportItemPending = "pending"
+	ImportItemRunning = "running"
+	ImportItemDone    = "done"
+	ImportItemFailed  = "failed"
+	ImportItemSkipped = "skipped" // the package already had questions and was not rewritten
+)
+
+// ImportRun is an import of all packages of a year
+type ImportRun struct {
+	ID         int        `json:"id"`
+	Year       int        `json:"year"`
+	Rewrite    bool       `json:"rewrite"`
+	Status     string     `json:"status"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// ImportItem is the import of one package within a run
+type ImportItem struct {
+	RunID     int    `json:"run_id"`
+	PackageID int    `json:"package_id"`
+	Status    string `json:"status"`
+	Attempts  int    `json:"attempts"`
+	Error     string `json:"error"`
+}
+
+// ImportProgress counts the items of a run by status
+type ImportProgress map[string]int
+
+// Total returns the number of packages in the run
+func (p ImportProgress) Total() int {
+	total := 0
+	for _, count := range p {
+		total += count
+	}
+	return total
+}
+
+// ImportRunRepository handles import run database operations
+type ImportRunRepository struct {
+	db *sql.DB
+}
+
+// NewImportRunRepository creates a new import run repository
+func NewImportRunRepository() *ImportRunRepository {
+	return &ImportRunRepository{
+		db: db.GetDB(),
+	}
+}
+
+// Create stores a run together with a pending item for every package
+func (r *ImportRunRepository) Create(year int, rewrite bool, packageIDs []int) (*ImportRun, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	run := &ImportRun{Year: year, Rewrite: rewrite, Status: ImportRunRunning, CreatedAt: time.Now()}
+	result, err := tx.Exec(
+		`INSERT INTO import_runs (year, rewrite, status, created_at) VALUES (?, ?, ?, ?)`,
+		run.Year, run.Rewrite, run.Status, run.CreatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert import run: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get import run ID: %w", err)
+	}
+	run.ID = int(id)
+
+	for _, packageID := range packageIDs {
+		_, err = tx.Exec(`INSERT OR IGNORE INTO import_items (run_id, package_id) VALUES (?, ?)`, run.ID, packageID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to insert import item: %w", err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit import run: %w", err)
+	}
+
+	return run, nil
+}
+
+// FindByID returns an import run
+func (r *ImportRunRepository) FindByID(runID int) (*ImportRun, error) {
+	runs, err := r.query(`WHERE id = ?`, runID)
+	if err != nil {
+		return nil, err
+	}
+	if len(runs) == 0 {
+		return nil, fmt.Errorf("import run %d not found", runID)
+	}
+	return runs[0], nil
+}
+
+// FindLatest returns the latest run for a year, or nil if the year was never imported
+func (r *ImportRunRepository) FindLatest(year int) (*ImportRun, error) {
+	runs, err := r.query(`WHERE year = ? ORDER BY id DESC LIMIT 1`, year)
+	if err != nil || len(runs) == 0 {
+		return nil, err
+	}
+	return runs[0], nil
+}
+
+// ListRecent returns the latest runs, newest first
+func (r *ImportRunRepository) ListRecent(limit int) ([]*ImportRun, error) {
+	return r.query(`ORDER BY id DESC LIMIT ?`, limit)
+}
+
+func (r *ImportRunRepository) query(clause string, args ...interface{}) ([]*ImportRun, error) {
+	query := `SELECT id, year, rewrite, status, created_at, finished_at FROM import_runs ` + clause
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query import runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []*ImportRun
+	for rows.Next() {
+		run := &ImportRun{}
+		err := rows.Scan(&run.ID, &run.Year, &run.Rewrite, &run.Status, &run.CreatedAt, &run.FinishedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan import run: %w", err)
+		}
+		runs = append(runs, run)
+	}
+
+	return runs, rows.Err()
+}
+
+// Requeue makes items that were interrupted while running pending again, and failed
+// items too if retryFailed is set. It marks the run as running and returns the number of items requeued.
+func (r *ImportRunRepository) Requeue(runID int, retryFailed bool) (int, error) {
+	statuses := []interface{}{ImportItemRunning}
+	placeholders := "?"
+	if retryFailed {
+		statuses = append(statuses, ImportItemFailed)
+		placeholders = "?, ?"
+	}
+
+	result, err := r.db.Exec(
+		`UPDATE import_items SET status = ? WHERE run_id = ? AND status IN (`+placeholders+`)`,
+		append([]interface{}{ImportItemPending, runID}, statuses...)...,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to requeue import items: %w", err)
+	}
+
+	requeued, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count requeued import items: %w", err)
+	}
+
+	err = r.SetStatus(runID, ImportRunRunning)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(requeued), nil
+}
+
+// GetPendingItems returns the items of a run that have not been imported yet
+func (r *ImportRunRepository) GetPendingItems(runID int) ([]*ImportItem, error) {
+	return r.queryItems(`WHERE run_id = ? AND status = ? ORDER BY package_id`, runID, ImportItemPending)
+}
+
+// GetFailedItems returns the items of a run that failed to import
+func (r *ImportRunRepository) GetFailedItems(runID int) ([]*ImportItem, error) {
+	return r.queryItems(`WHERE run_id = ? AND status = ? ORDER BY package_id`, runID, ImportItemFailed)
+}
+
+func (r *ImportRunRepository) queryItems(clause string, args ...interface{}) ([]*ImportItem, error) {
+	query := `SELECT run_id, package_id, status, attempts, error FROM import_items ` + clause
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query import items: %w", err)
+	}
+	defer rows.Close()
+
+	var items []*ImportItem
+	for rows.Next() {
+		item := &ImportItem{}
+		err := rows.Scan(&item.RunID, &item.PackageID, &item.Status, &item.Attempts, &item.Error)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan import item: %w", err)
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// StartItem marks an item as being imported
+func (r *ImportRunRepository) StartItem(runID, packageID int) error {
+	query := `
+		UPDATE import_items
+		SET status = ?, attempts = attempts + 1, updated_at = ?
+		WHERE run_id = ? AND package_id = ?
+	`
+
+	_, err := r.db.Exec(query, ImportItemRunning, time.Now(), runID, packageID)
+	if err != nil {
+		return fmt.Errorf("failed to start import item: %w", err)
+	}
+	return nil
+}
+
+// FinishItem stores the outcome of importing a package
+func (r *ImportRunRepository) FinishItem(runID, packageID int, status, errorText string) error {
+	query := `
+		UPDATE import_items
+		SET status = ?, error = ?, updated_at = ?
+		WHERE run_id = ? AND package_id = ?
+	`
+
+	_, err := r.db.Exec(query, status, errorText, time.Now(), runID, packageID)
+	if err != nil {
+		return fmt.Errorf("failed to finish import item: %w", err)
+	}
+	return nil
+}
+
+// SetStatus changes the status of a run, recording when it finished
+func (r *ImportRunRepository) SetStatus(runID int, status string) error {
+	var finishedAt *time.Time
+	if status != ImportRunRunning {
+		now := time.Now()
+		finishedAt = &now
+	}
+
+	_, err := r.db.Exec(`UPDATE import_runs SET status = ?, finished_at = ? WHERE id = ?`, status, finishedAt, runID)
+	if err != nil {
+		return fmt.Errorf("failed to update import run status: %w", err)
+	}
+	return nil
+}
+
+// GetProgress counts the items of a run by status
+func (r *ImportRunRepository) GetProgress(runID int) (ImportProgress, error) {
+	rows, err := r.db.Query(`SELECT status, COUNT(*) FROM import_items WHERE run_id = ? GROUP BY status`, runID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query import progress: %w", err)
+	}
+	defer rows.Close()
+
+	progress := ImportProgress{}
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan import progress: %w", err)
+		}
+		progress[status] = count
+	}
+
+	return progress, rows.Err()
+}
@@ -0,0 +1,187 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"questions-vote/internal/db"
+	"time"
+)
+
+// Statuses of a scheduled job run
+const (
+	JobRunning     = "running"
+	JobSucceeded   = "succeeded"
+	JobFailed      = "failed"
+	JobInterrupted = "interrupted" // the process stopped while the job was running
+)
+
+// JobRun is one run of a scheduled job
+type JobRun struct {
+	ID         int        `json:"id"`
+	Job        string     `json:"job"`
+	Owner      string     `json:"owner"` // the process that ran the job
+	Status     string     `json:"status"`
+	Output     string     `json:"output"`
+	Error      string     `json:"error"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// JobRunRepository handles the history and locks of scheduled jobs
+type JobRunRepository struct {
+	db *sql.DB
+}
+
+// NewJobRunRepository creates a new job run repository
+func NewJobRunRepository() *JobRunRepository {
+	return &JobRunRepository{
+		db: db.GetDB(),
+	}
+}
+
+// TryLock takes the lock of a job until expiresAt unless another owner holds an unexpired lock.
+// Times are stored as text, so they are compared in UTC.
+func (r *JobRunRepository) TryLock(job, owner string, now, expiresAt time.Time) (bool, error) {
+	result, err := r.db.Exec(`
+		INSERT INTO job_locks (job, owner, expires_at) VALUES (?, ?, ?)
+		ON CONFLICT(job) DO UPDATE SET owner = excluded.owner, expires_at = excluded.expires_at
+		WHERE job_locks.expires_at < ?
+	`, job, owner, expiresAt.UTC(), now.UTC())
+	if err != nil {
+		return false, fmt.Errorf("failed to lock job %s: %w", job, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rowsAffected > 0, nil
+}
+
+// RenewLock extends a lock held by the owner
+func (r *JobRunRepository) RenewLock(job, owner string, expiresAt time.Time) error {
+	_, err := r.db.Exec(`UPDATE job_locks SET expires_at = ? WHERE job = ? AND owner = ?`, expiresAt.UTC(), job, owner)
+	if err != nil {
+		return fmt.Errorf("failed to renew lock of job %s: %w", job, err)
+	}
+	return nil
+}
+
+// Unlock releases a lock held by the owner
+func (r *JobRunRepository) Unlock(job, owner string) error {
+	_, err := r.db.Exec(`DELETE FROM job_locks WHERE job = ? AND owner = ?`, job, owner)
+	if err != nil {
+		return fmt.Errorf("failed to unlock job %s: %w", job, err)
+	}
+	return nil
+}
+
+// MarkInterrupted marks runs left running by stopped processes, whose locks have expired or
+// been released, as interrupted. It returns the number of runs marked.
+func (r *JobRunRepository) MarkInterrupted(now time.Time) (int, error) {
+	result, err := r.db.Exec(`
+		UPDATE job_runs SET status = ?, finished_at = ?
+		WHERE status = ? AND NOT EXISTS (
+			SELECT 1 FROM job_locks l
+			WHERE l.job = job_runs.job AND l.owner = job_runs.owner AND l.expires_at >= ?
+		)
+	`, JobInterrupted, now, JobRunning, now.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark interrupted job runs: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return int(rowsAffected), nil
+}
+
+// Start records the start of a job run and returns its ID
+func (r *JobRunRepository) Start(job, owner string, startedAt time.Time) (int, error) {
+	result, err := r.db.Exec(
+		`INSERT INTO job_runs (job, owner, status, started_at) VALUES (?, ?, ?, ?)`,
+		job, owner, JobRunning, startedAt,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record start of job %s: %w", job, err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get job run ID: %w", err)
+	}
+
+	return int(id), nil
+}
+
+// Finish records the outcome of a job run
+func (r *JobRunRepository) Finish(runID int, status, output, errorText string, finishedAt time.Time) error {
+	_, err := r.db.Exec(
+		`UPDATE job_runs SET status = ?, output = ?, error = ?, finished_at = ? WHERE id = ?`,
+		status, output, errorText, finishedAt, runID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record end of job run %d: %w", runID, err)
+	}
+	return nil
+}
+
+// DeleteFinishedBefore deletes the finished runs of a job that started before the given time,
+// always keeping the latest run since the next run is scheduled from it. It returns the number
+// of runs deleted.
+func (r *JobRunRepository) DeleteFinishedBefore(job string, before time.Time) (int, error) {
+	result, err := r.db.Exec(`
+		DELETE FROM job_runs
+		WHERE job = ? AND status != ? AND started_at < ?
+			AND id < (SELECT MAX(id) FROM job_runs WHERE job = ?)
+	`, job, JobRunning, before, job)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete old runs of job %s: %w", job, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return int(rowsAffected), nil
+}
+
+// FindLatest returns the latest run of a job, or nil if it never ran
+func (r *JobRunRepository) FindLatest(job string) (*JobRun, error) {
+	runs, err := r.query(`WHERE job = ? ORDER BY id DESC LIMIT 1`, job)
+	if err != nil || len(runs) == 0 {
+		return nil, err
+	}
+	return runs[0], nil
+}
+
+// ListByJob returns the latest runs of a job, newest first
+func (r *JobRunRepository) ListByJob(job string, limit int) ([]*JobRun, error) {
+	return r.query(`WHERE job = ? ORDER BY id DESC LIMIT ?`, job, limit)
+}
+
+func (r *JobRunRepository) query(clause string, args ...interface{}) ([]*JobRun, error) {
+	query := `SELECT id, job, owner, status, output, error, started_at, finished_at FROM job_runs ` + clause
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query job runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []*JobRun
+	for rows.Next() {
+		run := &JobRun{}
+		err := rows.Scan(&run.ID, &run.Job, &run.Owner, &run.Status, &run.Output, &run.Error, &run.StartedAt, &run.FinishedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan job run: %w", err)
+		}
+		runs = append(runs, run)
+	}
+
+	return runs, rows.Err()
+}
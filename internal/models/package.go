@@ -2,6 +2,7 @@ package models
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"questions-vote/internal/db"
 	"time"
@@ -17,6 +18,9 @@ type Package struct {
 	QuestionsCount int       `json:"questions_count"`
 }
 
+// ErrPackageNotFound is returned when a package is not stored
+var ErrPackageNotFound = errors.New("package not found")
+
 // PackageRepository handles package database operations
 type PackageRepository struct {
 	db *sql.DB
@@ -29,52 +33,6 @@ func NewPackageRepository() *PackageRepository {
 	}
 }
 
-// BuildPackageFromDict creates a package from API response data
-func BuildPackageFromDict(packageDict map[string]any) (*Package, error) {
-	id, ok := packageDict["id"].(float64)
-	if !ok {
-		return nil, fmt.Errorf("invalid id in package data")
-	}
-
-	title, ok := packageDict["title"].(string)
-	if !ok {
-		return nil, fmt.Errorf("invalid title in package data")
-	}
-
-	startDateStr, ok := packageDict["startDate"].(string)
-	if !ok {
-		return nil, fmt.Errorf("invalid startDate in package data")
-	}
-
-	endDateStr, ok := packageDict["endDate"].(string)
-	if !ok {
-		return nil, fmt.Errorf("invalid endDate in package data")
-	}
-
-	questionsCount, ok := packageDict["questions"].(float64)
-	if !ok {
-		return nil, fmt.Errorf("invalid questions count in package data")
-	}
-
-	startDate, err := time.ParseInLocation("2006-01-02T15:04:05", startDateStr, time.UTC)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse start date: %w", err)
-	}
-
-	endDate, err := time.ParseInLocation("2006-01-02T15:04:05", endDateStr, time.UTC)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse end date: %w", err)
-	}
-
-	return &Package{
-		GotQuestionsID: int(id),
-		Title:          title,
-		StartDate:      startDate,
-		EndDate:        endDate,
-		QuestionsCount: int(questionsCount),
-	}, nil
-}
-
 // Insert inserts a package into the database
 func (r *PackageRepository) Insert(pkg *Package) error {
 	if r.Exists(pkg.GotQuestionsID) {
@@ -94,6 +52,52 @@ func (r *PackageRepository) Insert(pkg *Package) error {
 	return nil
 }
 
+// Upsert stores a new package or updates the title, dates and question count of a stored one.
+// It returns the package as it was stored before, or nil if the package is new.
+func (r *PackageRepository) Upsert(pkg *Package) (*Package, error) {
+	stored, err := r.FindByGotQuestionsID(pkg.GotQuestionsID)
+	if errors.Is(err, ErrPackageNotFound) {
+		return nil, r.Insert(pkg)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(stored.ChangedFields(pkg)) == 0 {
+		return stored, nil
+	}
+
+	query := `
+		UPDATE packages
+		SET title = ?, start_date = ?, end_date = ?, questions_count = ?
+		WHERE gotquestions_id = ?
+	`
+	_, err = r.db.Exec(query, pkg.Title, pkg.StartDate, pkg.EndDate, pkg.QuestionsCount, pkg.GotQuestionsID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update package: %w", err)
+	}
+
+	return stored, nil
+}
+
+// ChangedFields lists the fields that differ in another version of the package
+func (p *Package) ChangedFields(other *Package) []string {
+	var fields []string
+	if p.Title != other.Title {
+		fields = append(fields, "title")
+	}
+	if !p.StartDate.Equal(other.StartDate) {
+		fields = append(fields, "start_date")
+	}
+	if !p.EndDate.Equal(other.EndDate) {
+		fields = append(fields, "end_date")
+	}
+	if p.QuestionsCount != other.QuestionsCount {
+		fields = append(fields, "questions_count")
+	}
+	return fields
+}
+
 // Exists checks if a package exists by gotquestions_id
 func (r *PackageRepository) Exists(gotQuestionsID int) bool {
 	query := `SELECT 1 FROM packages WHERE gotquestions_id = ? LIMIT 1`
@@ -118,7 +122,7 @@ func (r *PackageRepository) FindByGotQuestionsID(gotQuestionsID int) (*Package,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("package not found")
+			return nil, ErrPackageNotFound
 		}
 		return nil, fmt.Errorf("failed to find package: %w", err)
 	}
@@ -217,3 +221,36 @@ func (r *PackageRepository) GetPackagesByDateRange(startDate, endDate time.Time)
 
 	return packages, rows.Err()
 }
+
+// GetEndedWithoutQuestions returns packages that ended between since and until and have no questions yet
+func (r *PackageRepository) GetEndedWithoutQuestions(since, until time.Time) ([]*Package, error) {
+	query := `
+		SELECT id, gotquestions_id, title, start_date, end_date, questions_count
+		FROM packages p
+		WHERE end_date >= ? AND end_date <= ?
+		  AND NOT EXISTS (SELECT 1 FROM questions q WHERE q.package_id = p.gotquestions_id)
+		ORDER BY end_date
+	`
+
+	// Dates are stored as text in UTC, compare them in the same zone
+	rows, err := r.db.Query(query, since.UTC(), until.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ended packages: %w", err)
+	}
+	defer rows.Close()
+
+	var packages []*Package
+	for rows.Next() {
+		pkg := &Package{}
+		err := rows.Scan(
+			&pkg.ID, &pkg.GotQuestionsID, &pkg.Title,
+			&pkg.StartDate, &pkg.EndDate, &pkg.QuestionsCount,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan package: %w", err)
+		}
+		packages = append(packages, pkg)
+	}
+
+	return packages, rows.Err()
+}
@@ -2,11 +2,27 @@ package models
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"questions-vote/internal/db"
 	"strings"
+	"unicode"
 )
 
+// ErrSearchUnavailable is returned by Search when SQLite is built without FTS5
+var ErrSearchUnavailable = errors.New("full-text search is not available: build with -tags sqlite_fts5")
+
+// maxSearchTerms caps the words of a search query
+const maxSearchTerms = 10
+
+// SearchResult is a question found by text with its rating in a tournament
+type SearchResult struct {
+	Question *Question
+	Rating   *float64 // nil if the question is not in the tournament
+	Matches  int
+	Wins     int
+}
+
 // QuestionRepository handles question database operations
 type QuestionRepository struct {
 	db *sql.DB
@@ -33,7 +49,8 @@ func (r *QuestionRepository) FindByIDs(ids []int) ([]*Question, error) {
 
 	query := fmt.Sprintf(`
 		SELECT q.id, q.question, q.answer, q.comment, q.accepted_answer, 
-		       q.handout_str, q.source, i.data as image_data 
+		       q.handout_str, q.source, i.question_id, COALESCE(i.image_url, ''), i.data,
+		       COALESCE(i.mime_type, ''), COALESCE(i.telegram_file_id, ''), COALESCE(i.telegram_file_type, '')
 		FROM questions q
 		LEFT JOIN images i ON q.id = i.question_id
 		WHERE q.id IN (%s)
@@ -48,7 +65,8 @@ func (r *QuestionRepository) FindByIDs(ids []int) ([]*Question, error) {
 	var questions []*Question
 	for rows.Next() {
 		q := &Question{}
-		var imageData sql.NullString
+		img := &Image{}
+		var imageQuestionID sql.NullInt64
 		err := rows.Scan(
 			&q.ID,
 			&q.Question,
@@ -57,14 +75,21 @@ func (r *QuestionRepository) FindByIDs(ids []int) ([]*Question, error) {
 			&q.AcceptedAnswer,
 			&q.HandoutStr,
 			&q.Source,
-			&imageData,
+			&imageQuestionID,
+			&img.URL,
+			&img.Data,
+			&img.MimeType,
+			&img.TelegramFileID,
+			&img.TelegramFileType,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan question: %w", err)
 		}
 
-		if imageData.Valid {
-			q.HandoutImg = imageData.String
+		if imageQuestionID.Valid {
+			img.QuestionID = q.ID
+			q.Image = img
+			q.HandoutImg = img.URL
 		}
 
 		questions = append(questions, q)
@@ -125,104 +150,196 @@ func (r *QuestionRepository) HasQuestionsFromPackage(packageID int) (bool, error
 	return true, nil
 }
 
-// BuildQuestionFromDict creates a question from API response data
-func BuildQuestionFromDict(questionDict map[string]interface{}, packageID int) (*Question, error) {
-	id, ok := questionDict["id"].(float64)
-	if !ok {
-		return nil, fmt.Errorf("invalid id in question data")
-	}
+// GetQuestionIDsFromPackage returns question IDs for a specific package
+func (r *QuestionRepository) GetQuestionIDsFromPackage(packageID int) ([]int, error) {
+	query := `SELECT id FROM questions WHERE package_id = ?`
 
-	text, ok := questionDict["text"].(string)
-	if !ok {
-		return nil, fmt.Errorf("invalid text in question data")
+	rows, err := r.db.Query(query, packageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query questions: %w", err)
 	}
+	defer rows.Close()
 
-	answer, ok := questionDict["answer"].(string)
-	if !ok {
-		return nil, fmt.Errorf("invalid answer in question data")
+	var questionIDs []int
+	for rows.Next() {
+		var id int
+		err := rows.Scan(&id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan question ID: %w", err)
+		}
+		questionIDs = append(questionIDs, id)
 	}
 
-	var zachet, comment, razdatkaText, razdatkaPic, source string
-	var complexity float64
-	var takenDown bool
-	var authorID *int
+	return questionIDs, rows.Err()
+}
+
+// GetByPackage returns the stored questions of a package in package order
+func (r *QuestionRepository) GetByPackage(packageID int) ([]*Question, error) {
+	query := `
+		SELECT id, COALESCE(gotquestions_id, 0), COALESCE(question, ''), COALESCE(answer, ''),
+		       COALESCE(accepted_answer, ''), COALESCE(comment, ''), COALESCE(handout_str, ''),
+		       COALESCE(source, ''), number, tour_number
+		FROM questions
+		WHERE package_id = ?
+		ORDER BY tour_number, number, id
+	`
 
-	if val, exists := questionDict["zachet"]; exists && val != nil {
-		zachet, _ = val.(string)
+	rows, err := r.db.Query(query, packageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query questions of package %d: %w", packageID, err)
 	}
+	defer rows.Close()
 
-	if val, exists := questionDict["comment"]; exists && val != nil {
-		comment, _ = val.(string)
+	var questions []*Question
+	for rows.Next() {
+		q := &Question{PackageID: &packageID}
+		err := rows.Scan(&q.ID, &q.GotQuestionsID, &q.Question, &q.Answer, &q.AcceptedAnswer,
+			&q.Comment, &q.HandoutStr, &q.Source, &q.Number, &q.TourNumber)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan question: %w", err)
+		}
+		questions = append(questions, q)
 	}
 
-	if val, exists := questionDict["razdatkaText"]; exists && val != nil {
-		razdatkaText, _ = val.(string)
-	}
+	return questions, rows.Err()
+}
 
-	if val, exists := questionDict["razdatkaPic"]; exists && val != nil {
-		razdatkaPic, _ = val.(string)
+// SetImageFile remembers the Telegram file ID of the handout picture of a question
+func (r *QuestionRepository) SetImageFile(questionID int, fileID, fileType string) error {
+	_, err := r.db.Exec(`
+		UPDATE images SET telegram_file_id = ?, telegram_file_type = ? WHERE question_id = ?
+	`, fileID, fileType, questionID)
+	if err != nil {
+		return fmt.Errorf("failed to save file ID of the image of question %d: %w", questionID, err)
 	}
+	return nil
+}
 
-	if val, exists := questionDict["source"]; exists && val != nil {
-		source, _ = val.(string)
+// GetPackageImages returns the stored pictures of the questions of a package, keyed by question ID
+func (r *QuestionRepository) GetPackageImages(packageID int) (map[int]*Image, error) {
+	query := `
+		SELECT i.question_id, COALESCE(i.image_url, ''), i.data, COALESCE(i.mime_type, '')
+		FROM images i
+		JOIN questions q ON q.id = i.question_id
+		WHERE q.package_id = ?
+	`
+
+	rows, err := r.db.Query(query, packageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query images of package %d: %w", packageID, err)
 	}
+	defer rows.Close()
 
-	if val, exists := questionDict["complexity"]; exists && val != nil {
-		complexity, _ = val.(float64)
+	images := make(map[int]*Image)
+	for rows.Next() {
+		img := &Image{}
+		err := rows.Scan(&img.QuestionID, &img.URL, &img.Data, &img.MimeType)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan image: %w", err)
+		}
+		images[img.QuestionID] = img
 	}
 
-	if val, exists := questionDict["takenDown"]; exists && val != nil {
-		takenDown, _ = val.(bool)
+	return images, rows.Err()
+}
+
+// ListInPublicationOrder returns the text and answer of every question, questions from packages
+// that ended earlier first
+func (r *QuestionRepository) ListInPublicationOrder() ([]*Question, error) {
+	query := `
+		SELECT q.id, COALESCE(q.question, ''), COALESCE(q.answer, ''), COALESCE(q.package_id, 0)
+		FROM questions q
+		LEFT JOIN packages p ON p.gotquestions_id = q.package_id
+		ORDER BY p.end_date IS NULL, p.end_date, q.id
+	`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query questions: %w", err)
 	}
+	defer rows.Close()
 
-	if authorsInterface, exists := questionDict["authors"]; exists && authorsInterface != nil {
-		if authors, ok := authorsInterface.([]any); ok && len(authors) > 0 {
-			if author, ok := authors[0].(map[string]any); ok {
-				if idVal, exists := author["id"]; exists && idVal != nil {
-					if idFloat, ok := idVal.(float64); ok {
-						id := int(idFloat)
-						authorID = &id
-					}
-				}
-			}
+	var questions []*Question
+	for rows.Next() {
+		q := &Question{}
+		var packageID int
+		err := rows.Scan(&q.ID, &q.Question, &q.Answer, &packageID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan question: %w", err)
 		}
+		q.PackageID = &packageID
+		questions = append(questions, q)
 	}
 
-	return &Question{
-		GotQuestionsID: int(id),
-		Question:       text,
-		Answer:         answer,
-		AcceptedAnswer: zachet,
-		Comment:        comment,
-		HandoutStr:     razdatkaText,
-		HandoutImg:     razdatkaPic,
-		Source:         source,
-		AuthorID:       authorID,
-		PackageID:      &packageID,
-		Difficulty:     &complexity,
-		IsIncorrect:    &takenDown,
-	}, nil
+	return questions, rows.Err()
 }
 
-// GetQuestionIDsFromPackage returns question IDs for a specific package
-func (r *QuestionRepository) GetQuestionIDsFromPackage(packageID int) ([]int, error) {
-	query := `SELECT id FROM questions WHERE package_id = ?`
+// Search finds questions whose text, answer, comment or source contain all words of the query,
+// the last word as a prefix, best matches first. Ratings are taken from the tournament.
+func (r *QuestionRepository) Search(text string, tournamentID, limit int) ([]*SearchResult, error) {
+	if !db.SearchAvailable() {
+		return nil, ErrSearchUnavailable
+	}
 
-	rows, err := r.db.Query(query, packageID)
+	match := searchQuery(text)
+	if match == "" {
+		return nil, nil
+	}
+
+	// Matches in the question and answer count more than in the comment and source
+	query := `
+		SELECT q.id, COALESCE(q.question, ''), COALESCE(q.answer, ''), COALESCE(q.accepted_answer, ''),
+		       COALESCE(q.comment, ''), COALESCE(q.handout_str, ''), COALESCE(q.source, ''),
+		       q.number, tq.rating, COALESCE(tq.matches, 0), COALESCE(tq.wins, 0)
+		FROM questions_fts f
+		JOIN questions q ON q.id = f.rowid
+		LEFT JOIN tournament_questions tq ON tq.question_id = q.id AND tq.tournament_id = ?
+		WHERE questions_fts MATCH ?
+		ORDER BY bm25(questions_fts, 10.0, 5.0, 2.0, 1.0)
+		LIMIT ?
+	`
+
+	rows, err := r.db.Query(query, tournamentID, match, limit)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query questions: %w", err)
+		return nil, fmt.Errorf("failed to search questions: %w", err)
 	}
 	defer rows.Close()
 
-	var questionIDs []int
+	var results []*SearchResult
 	for rows.Next() {
-		var id int
-		err := rows.Scan(&id)
+		q := &Question{}
+		result := &SearchResult{Question: q}
+		var rating sql.NullFloat64
+		err := rows.Scan(&q.ID, &q.Question, &q.Answer, &q.AcceptedAnswer, &q.Comment, &q.HandoutStr, &q.Source,
+			&q.Number, &rating, &result.Matches, &result.Wins)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan question ID: %w", err)
+			return nil, fmt.Errorf("failed to scan search result: %w", err)
 		}
-		questionIDs = append(questionIDs, id)
+		if rating.Valid {
+			result.Rating = &rating.Float64
+		}
+		results = append(results, result)
 	}
 
-	return questionIDs, rows.Err()
+	return results, rows.Err()
+}
+
+// searchQuery turns user input into an FTS5 query: words are quoted so that punctuation and
+// FTS5 operators in the input are taken literally, and the last word matches as a prefix
+func searchQuery(text string) string {
+	words := strings.FieldsFunc(db.FoldSearchText(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) > maxSearchTerms {
+		words = words[:maxSearchTerms]
+	}
+
+	terms := make([]string, len(words))
+	for i, word := range words {
+		terms[i] = `"` + word + `"`
+	}
+	if len(terms) > 0 {
+		terms[len(terms)-1] += "*"
+	}
+	return strings.Join(terms, " ")
 }
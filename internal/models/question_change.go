@@ -0,0 +1,65 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"questions-vote/internal/db"
+	"time"
+)
+
+// QuestionChange is a field of a question that changed when its package was re-imported
+type QuestionChange struct {
+	ID         int       `json:"id"`
+	QuestionID int       `json:"question_id"`
+	Field      string    `json:"field"`
+	OldValue   string    `json:"old_value"`
+	NewValue   string    `json:"new_value"`
+	ChangedAt  time.Time `json:"changed_at"`
+}
+
+// QuestionChangeRepository handles the question change log
+type QuestionChangeRepository struct {
+	db *sql.DB
+}
+
+// NewQuestionChangeRepository creates a new question change repository
+func NewQuestionChangeRepository() *QuestionChangeRepository {
+	return &QuestionChangeRepository{
+		db: db.GetDB(),
+	}
+}
+
+// GetByQuestion returns the changes of a question, oldest first
+func (r *QuestionChangeRepository) GetByQuestion(questionID int) ([]*QuestionChange, error) {
+	return r.query(`WHERE question_id = ? ORDER BY id`, questionID)
+}
+
+// ListRecent returns the latest changes, newest first
+func (r *QuestionChangeRepository) ListRecent(limit int) ([]*QuestionChange, error) {
+	return r.query(`ORDER BY id DESC LIMIT ?`, limit)
+}
+
+func (r *QuestionChangeRepository) query(clause string, args ...interface{}) ([]*QuestionChange, error) {
+	query := `
+		SELECT id, question_id, field, COALESCE(old_value, ''), COALESCE(new_value, ''), changed_at
+		FROM question_changes
+	` + clause
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query question changes: %w", err)
+	}
+	defer rows.Close()
+
+	var changes []*QuestionChange
+	for rows.Next() {
+		c := &QuestionChange{}
+		err := rows.Scan(&c.ID, &c.QuestionID, &c.Field, &c.OldValue, &c.NewValue, &c.ChangedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan question change: %w", err)
+		}
+		changes = append(changes, c)
+	}
+
+	return changes, rows.Err()
+}
@@ -0,0 +1,169 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"questions-vote/internal/db"
+	"strings"
+	"time"
+)
+
+// Kinds of problems found in imported questions
+const (
+	IssueEmptyQuestion = "empty_question"
+	IssueEmptyAnswer   = "empty_answer"
+	IssueMissingSource = "missing_source"
+	IssueMissingImage  = "missing_image" // the handout picture failed to download
+	IssueBadImage      = "bad_image"     // the stored picture is not an image
+	IssueHTML          = "html"          // tags or entities left in the text
+)
+
+// IssueKinds lists every kind of issue in report order
+var IssueKinds = []string{
+	IssueEmptyQuestion, IssueEmptyAnswer, IssueMissingSource, IssueMissingImage, IssueBadImage, IssueHTML,
+}
+
+// QuestionIssue is a data quality problem found in an imported question
+type QuestionIssue struct {
+	QuestionID int       `json:"question_id"`
+	PackageID  int       `json:"package_id"` // gotquestions package ID
+	Kind       string    `json:"kind"`
+	Detail     string    `json:"detail"`
+	FoundAt    time.Time `json:"found_at"`
+}
+
+// QuestionIssueRepository handles the findings of the import validation
+type QuestionIssueRepository struct {
+	db *sql.DB
+}
+
+// NewQuestionIssueRepository creates a new question issue repository
+func NewQuestionIssueRepository() *QuestionIssueRepository {
+	return &QuestionIssueRepository{
+		db: db.GetDB(),
+	}
+}
+
+// ParseIssueKinds parses a comma-separated list of issue kinds, "all" meaning every kind
+func ParseIssueKinds(value string) ([]string, error) {
+	if strings.TrimSpace(value) == "all" {
+		return IssueKinds, nil
+	}
+
+	var kinds []string
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		known := false
+		for _, kind := range IssueKinds {
+			known = known || kind == part
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown issue kind %q, expected all or some of %s", part, strings.Join(IssueKinds, ", "))
+		}
+		kinds = append(kinds, part)
+	}
+	return kinds, nil
+}
+
+// ReplaceForPackage replaces the findings for a package with the latest validation
+func (r *QuestionIssueRepository) ReplaceForPackage(packageID int, issues []*QuestionIssue) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`DELETE FROM question_issues WHERE package_id = ?`, packageID)
+	if err != nil {
+		return fmt.Errorf("failed to delete issues of package %d: %w", packageID, err)
+	}
+
+	for _, issue := range issues {
+		_, err = tx.Exec(
+			`INSERT INTO question_issues (question_id, package_id, kind, detail) VALUES (?, ?, ?, ?)`,
+			issue.QuestionID, packageID, issue.Kind, issue.Detail,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert issue of question %d: %w", issue.QuestionID, err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit issues of package %d: %w", packageID, err)
+	}
+	return nil
+}
+
+// ListByPackage returns the findings for a package by question
+func (r *QuestionIssueRepository) ListByPackage(packageID int) ([]*QuestionIssue, error) {
+	rows, err := r.db.Query(`
+		SELECT question_id, package_id, kind, detail, found_at
+		FROM question_issues
+		WHERE package_id = ?
+		ORDER BY question_id, kind
+	`, packageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query issues of package %d: %w", packageID, err)
+	}
+	defer rows.Close()
+
+	var issues []*QuestionIssue
+	for rows.Next() {
+		issue := &QuestionIssue{}
+		err := rows.Scan(&issue.QuestionID, &issue.PackageID, &issue.Kind, &issue.Detail, &issue.FoundAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan question issue: %w", err)
+		}
+		issues = append(issues, issue)
+	}
+
+	return issues, rows.Err()
+}
+
+// ExcludeFlagged removes the questions with issues of the given kinds, keeping the order
+func (r *QuestionIssueRepository) ExcludeFlagged(kinds []string, questionIDs []int) ([]int, error) {
+	if len(kinds) == 0 || len(questionIDs) == 0 {
+		return questionIDs, nil
+	}
+
+	placeholders := make([]string, len(kinds))
+	args := make([]interface{}, len(kinds))
+	for i, kind := range kinds {
+		placeholders[i] = "?"
+		args[i] = kind
+	}
+
+	rows, err := r.db.Query(
+		`SELECT DISTINCT question_id FROM question_issues WHERE kind IN (`+strings.Join(placeholders, ",")+`)`,
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query flagged questions: %w", err)
+	}
+	defer rows.Close()
+
+	flagged := make(map[int]bool)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan question ID: %w", err)
+		}
+		flagged[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var kept []int
+	for _, id := range questionIDs {
+		if !flagged[id] {
+			kept = append(kept, id)
+		}
+	}
+	return kept, nil
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"questions-vote/internal/db"
+	"questions-vote/pkg/ratelimiter"
+)
+
+// RateLimitRepository stores snapshots of per-user rate limits so they survive restarts.
+// It implements ratelimiter.Store.
+type RateLimitRepository struct {
+	db *sql.DB
+}
+
+// NewRateLimitRepository creates a new rate limit repository
+func NewRateLimitRepository() *RateLimitRepository {
+	return &RateLimitRepository{
+		db: db.GetDB(),
+	}
+}
+
+// Load returns the last saved snapshot
+func (r *RateLimitRepository) Load() ([]ratelimiter.State, error) {
+	rows, err := r.db.Query(`SELECT scope, key, tokens, updated_at FROM rate_limits`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rate limits: %w", err)
+	}
+	defer rows.Close()
+
+	var states []ratelimiter.State
+	for rows.Next() {
+		var state ratelimiter.State
+		err := rows.Scan(&state.Scope, &state.Key, &state.Tokens, &state.Updated)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan rate limit: %w", err)
+		}
+		states = append(states, state)
+	}
+
+	return states, rows.Err()
+}
+
+// Save replaces the saved snapshot
+func (r *RateLimitRepository) Save(states []ratelimiter.State) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`DELETE FROM rate_limits`)
+	if err != nil {
+		return fmt.Errorf("failed to clear rate limits: %w", err)
+	}
+
+	stmt, err := tx.Prepare(`INSERT INTO rate_limits (scope, key, tokens, updated_at) VALUES (?, ?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare rate limit insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, state := range states {
+		_, err = stmt.Exec(string(state.Scope), state.Key, state.Tokens, state.Updated)
+		if err != nil {
+			return fmt.Errorf("failed to save rate limit: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"questions-vote/internal/db"
+	"time"
+)
+
+// Report is a message from a user to the organizers, e.g. about a broken question
+type Report struct {
+	ID           int       `json:"id"`
+	UserID       int64     `json:"user_id"`
+	TournamentID *int      `json:"tournament_id,omitempty"`
+	Text         string    `json:"text"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// ReportRepository handles report database operations
+type ReportRepository struct {
+	db *sql.DB
+}
+
+// NewReportRepository creates a new report repository
+func NewReportRepository() *ReportRepository {
+	return &ReportRepository{
+		db: db.GetDB(),
+	}
+}
+
+// Create stores a report. tournamentID may be nil when no tournament is active.
+func (r *ReportRepository) Create(userID int64, tournamentID *int, text string) error {
+	query := `INSERT INTO reports (user_id, tournament_id, text, created_at) VALUES (?, ?, ?, ?)`
+	_, err := r.db.Exec(query, userID, tournamentID, text, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to create report: %w", err)
+	}
+	return nil
+}
+
+// GetRecent returns the latest reports, newest first
+func (r *ReportRepository) GetRecent(limit int) ([]*Report, error) {
+	query := `
+		SELECT id, user_id, tournament_id, text, created_at
+		FROM reports
+		ORDER BY created_at DESC, id DESC
+		LIMIT ?
+	`
+
+	rows, err := r.db.Query(query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reports: %w", err)
+	}
+	defer rows.Close()
+
+	var reports []*Report
+	for rows.Next() {
+		report := &Report{}
+		err := rows.Scan(&report.ID, &report.UserID, &report.TournamentID, &report.Text, &report.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan report: %w", err)
+		}
+		reports = append(reports, report)
+	}
+
+	return reports, rows.Err()
+}
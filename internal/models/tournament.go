@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"fmt"
 	"questions-vote/internal/db"
+	"time"
 )
 
 // TournamentRepository handles tournament database operations
@@ -23,7 +24,8 @@ func (r *TournamentRepository) FindActiveTournament() (*Tournament, error) {
 	query := `
 		SELECT id, title, initial_k, minimum_k, std_dev_multiplier, 
 		       initial_phase_matches, transition_phase_matches, top_n, 
-		       questions_count, band_size
+		       questions_count, band_size, selection_strategy, position_bias_correction,
+		       weight_votes, invite_only, invite_code, ends_at
 		FROM tournaments 
 		WHERE state = 1
 	`
@@ -48,6 +50,12 @@ func (r *TournamentRepository) FindActiveTournament() (*Tournament, error) {
 			&t.TopN,
 			&t.QuestionsCount,
 			&t.BandSize,
+			&t.SelectionStrategy,
+			&t.PositionBiasCorrection,
+			&t.WeightVotes,
+			&t.InviteOnly,
+			&t.InviteCode,
+			&t.EndsAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan tournament: %w", err)
@@ -75,7 +83,8 @@ func (r *TournamentRepository) ListActiveTournaments() ([]*Tournament, error) {
 	query := `
 		SELECT id, title, initial_k, minimum_k, std_dev_multiplier, 
 		       initial_phase_matches, transition_phase_matches, top_n, 
-		       questions_count, band_size
+		       questions_count, band_size, selection_strategy, position_bias_correction,
+		       weight_votes, invite_only, invite_code, ends_at
 		FROM tournaments 
 		WHERE state = 1
 	`
@@ -100,6 +109,12 @@ func (r *TournamentRepository) ListActiveTournaments() ([]*Tournament, error) {
 			&t.TopN,
 			&t.QuestionsCount,
 			&t.BandSize,
+			&t.SelectionStrategy,
+			&t.PositionBiasCorrection,
+			&t.WeightVotes,
+			&t.InviteOnly,
+			&t.InviteCode,
+			&t.EndsAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan tournament: %w", err)
@@ -125,13 +140,20 @@ func (r *TournamentRepository) Create(tournament *Tournament) (int, error) {
 	query := `
 		INSERT INTO tournaments (title, initial_k, minimum_k, std_dev_multiplier, 
 		                        initial_phase_matches, transition_phase_matches, top_n, 
-		                        band_size, questions_count, state)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, 0, 0)
+		                        band_size, selection_strategy, position_bias_correction, weight_votes,
+		                        invite_only, invite_code, ends_at, questions_count, state)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 0, 0)
 	`
 
+	strategy := tournament.SelectionStrategy
+	if strategy == "" {
+		strategy = SelectionRandom
+	}
+
 	result, err := r.db.Exec(query, tournament.Name, tournament.InitialK, tournament.MinimumK,
 		tournament.StdDevMultiplier, tournament.InitialPhaseMatches, tournament.TransitionPhaseMatches,
-		tournament.TopN, tournament.BandSize)
+		tournament.TopN, tournament.BandSize, strategy, tournament.PositionBiasCorrection, tournament.WeightVotes,
+		tournament.InviteOnly, tournament.InviteCode, utcTime(tournament.EndsAt))
 	if err != nil {
 		return 0, fmt.Errorf("failed to insert tournament: %w", err)
 	}
@@ -159,7 +181,8 @@ func (r *TournamentRepository) ListAllTournaments() ([]*Tournament, error) {
 	query := `
 		SELECT id, title, initial_k, minimum_k, std_dev_multiplier, 
 		       initial_phase_matches, transition_phase_matches, top_n, 
-		       questions_count, band_size, state
+		       questions_count, band_size, selection_strategy, position_bias_correction,
+		       weight_votes, invite_only, invite_code, ends_at, state
 		FROM tournaments 
 		ORDER BY id DESC
 	`
@@ -185,6 +208,12 @@ func (r *TournamentRepository) ListAllTournaments() ([]*Tournament, error) {
 			&t.TopN,
 			&t.QuestionsCount,
 			&t.BandSize,
+			&t.SelectionStrategy,
+			&t.PositionBiasCorrection,
+			&t.WeightVotes,
+			&t.InviteOnly,
+			&t.InviteCode,
+			&t.EndsAt,
 			&state,
 		)
 		if err != nil {
@@ -197,6 +226,49 @@ func (r *TournamentRepository) ListAllTournaments() ([]*Tournament, error) {
 	return tournaments, rows.Err()
 }
 
+// FindByID retrieves a tournament by ID
+func (r *TournamentRepository) FindByID(tournamentID int) (*Tournament, error) {
+	query := `
+		SELECT id, title, initial_k, minimum_k, std_dev_multiplier, 
+		       initial_phase_matches, transition_phase_matches, top_n, 
+		       questions_count, band_size, selection_strategy, position_bias_correction,
+		       weight_votes, invite_only, invite_code, ends_at, state
+		FROM tournaments 
+		WHERE id = ?
+	`
+
+	t := &Tournament{}
+	var state int
+	err := r.db.QueryRow(query, tournamentID).Scan(
+		&t.ID,
+		&t.Name,
+		&t.InitialK,
+		&t.MinimumK,
+		&t.StdDevMultiplier,
+		&t.InitialPhaseMatches,
+		&t.TransitionPhaseMatches,
+		&t.TopN,
+		&t.QuestionsCount,
+		&t.BandSize,
+		&t.SelectionStrategy,
+		&t.PositionBiasCorrection,
+		&t.WeightVotes,
+		&t.InviteOnly,
+		&t.InviteCode,
+		&t.EndsAt,
+		&state,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("tournament with ID %d not found", tournamentID)
+		}
+		return nil, fmt.Errorf("failed to get tournament: %w", err)
+	}
+	t.Active = state == 1
+
+	return t, nil
+}
+
 // ActivateTournament activates a tournament by ID
 func (r *TournamentRepository) ActivateTournament(tournamentID int) error {
 	query := `UPDATE tournaments SET state = 1 WHERE id = ?`
@@ -236,3 +308,147 @@ func (r *TournamentRepository) DeactivateTournament(tournamentID int) error {
 
 	return nil
 }
+
+// SetInviteOnly makes a tournament open to everyone or only to allowlisted users and holders of the invite code
+func (r *TournamentRepository) SetInviteOnly(tournamentID int, inviteOnly bool, inviteCode string) error {
+	query := `UPDATE tournaments SET invite_only = ?, invite_code = ? WHERE id = ?`
+	result, err := r.db.Exec(query, inviteOnly, inviteCode, tournamentID)
+	if err != nil {
+		return fmt.Errorf("failed to update tournament access: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("tournament with ID %d not found", tournamentID)
+	}
+
+	return nil
+}
+
+// SetEndsAt sets when a tournament closes, or clears it with nil
+func (r *TournamentRepository) SetEndsAt(tournamentID int, endsAt *time.Time) error {
+	query := `UPDATE tournaments SET ends_at = ?, reminder_sent = 0 WHERE id = ?`
+	result, err := r.db.Exec(query, utcTime(endsAt), tournamentID)
+	if err != nil {
+		return fmt.Errorf("failed to set tournament end: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("tournament with ID %d not found", tournamentID)
+	}
+
+	return nil
+}
+
+// utcTime converts an optional time to UTC, since times are stored as text and compared as such
+func utcTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	utc := t.UTC()
+	return &utc
+}
+
+// ListActiveEndingBefore returns the active tournaments that end before the given time.
+// With unreminded set, only those that have not had an end reminder yet.
+func (r *TournamentRepository) ListActiveEndingBefore(before time.Time, unreminded bool) ([]*Tournament, error) {
+	query := `
+		SELECT id, title, ends_at
+		FROM tournaments
+		WHERE state = 1 AND ends_at IS NOT NULL AND ends_at <= ?
+	`
+	if unreminded {
+		query += ` AND reminder_sent = 0`
+	}
+
+	// Times are stored as text, compare them in the same zone
+	rows, err := r.db.Query(query, before.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ending tournaments: %w", err)
+	}
+	defer rows.Close()
+
+	var tournaments []*Tournament
+	for rows.Next() {
+		t := &Tournament{Active: true}
+		if err := rows.Scan(&t.ID, &t.Name, &t.EndsAt); err != nil {
+			return nil, fmt.Errorf("failed to scan tournament: %w", err)
+		}
+		tournaments = append(tournaments, t)
+	}
+
+	return tournaments, rows.Err()
+}
+
+// MarkReminderSent records that voters were reminded of the end of a tournament
+func (r *TournamentRepository) MarkReminderSent(tournamentID int) error {
+	_, err := r.db.Exec(`UPDATE tournaments SET reminder_sent = 1 WHERE id = ?`, tournamentID)
+	if err != nil {
+		return fmt.Errorf("failed to mark reminder sent: %w", err)
+	}
+	return nil
+}
+
+// AllowUser adds a user to the allowlist of a tournament
+func (r *TournamentRepository) AllowUser(tournamentID int, userID int64) error {
+	query := `INSERT OR IGNORE INTO tournament_allowlist (tournament_id, user_id) VALUES (?, ?)`
+	_, err := r.db.Exec(query, tournamentID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to add user to allowlist: %w", err)
+	}
+	return nil
+}
+
+// DisallowUser removes a user from the allowlist of a tournament
+func (r *TournamentRepository) DisallowUser(tournamentID int, userID int64) error {
+	query := `DELETE FROM tournament_allowlist WHERE tournament_id = ? AND user_id = ?`
+	_, err := r.db.Exec(query, tournamentID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to remove user from allowlist: %w", err)
+	}
+	return nil
+}
+
+// IsUserAllowed reports whether a user is on the allowlist of a tournament
+func (r *TournamentRepository) IsUserAllowed(tournamentID int, userID int64) (bool, error) {
+	query := `SELECT COUNT(*) FROM tournament_allowlist WHERE tournament_id = ? AND user_id = ?`
+
+	var count int
+	err := r.db.QueryRow(query, tournamentID, userID).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to check allowlist: %w", err)
+	}
+
+	return count > 0, nil
+}
+
+// ListAllowedUsers returns the IDs of users on the allowlist of a tournament
+func (r *TournamentRepository) ListAllowedUsers(tournamentID int) ([]int64, error) {
+	query := `SELECT user_id FROM tournament_allowlist WHERE tournament_id = ? ORDER BY added_at`
+
+	rows, err := r.db.Query(query, tournamentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query allowlist: %w", err)
+	}
+	defer rows.Close()
+
+	var userIDs []int64
+	for rows.Next() {
+		var userID int64
+		if err := rows.Scan(&userID); err != nil {
+			return nil, fmt.Errorf("failed to scan allowlist: %w", err)
+		}
+		userIDs = append(userIDs, userID)
+	}
+
+	return userIDs, rows.Err()
+}
@@ -6,6 +6,7 @@ import (
 	"math"
 	"math/rand"
 	"questions-vote/internal/db"
+	"strings"
 )
 
 // TournamentQuestion represents a question in a tournament with ELO rating
@@ -56,49 +57,56 @@ func (r *TournamentQuestionRepository) CreateTournamentQuestions(tournamentID in
 	return tx.Commit()
 }
 
-// GetRandomQuestion returns a random question matching the criteria
-func (r *TournamentQuestionRepository) GetRandomQuestion(tournamentID int, minRating, maxRating float64, maxMatches int) (*TournamentQuestion, error) {
-	// First get the count of matching questions
-	countQuery := `
-		SELECT COUNT(*) 
-		FROM tournament_questions 
+// GetRandomQuestions returns up to n distinct random questions matching the criteria, drawn with rng
+func (r *TournamentQuestionRepository) GetRandomQuestions(tournamentID int, minRating, maxRating float64, maxMatches, n int, rng *rand.Rand, excludeIDs ...int) ([]*TournamentQuestion, error) {
+	conditions := `
+		FROM tournament_questions
 		WHERE tournament_id = ? 
-		AND rating BETWEEN ? AND ? 
+		AND rating BETWEEN ? AND ?
 		AND matches <= ?
 	`
+	args := []any{tournamentID, minRating, maxRating, maxMatches}
+
+	if len(excludeIDs) > 0 {
+		placeholders := make([]string, len(excludeIDs))
+		for i, id := range excludeIDs {
+			placeholders[i] = "?"
+			args = append(args, id)
+		}
+		conditions += fmt.Sprintf(" AND question_id NOT IN (%s)", strings.Join(placeholders, ","))
+	}
 
 	var count int
-	err := r.db.QueryRow(countQuery, tournamentID, minRating, maxRating, maxMatches).Scan(&count)
+	err := r.db.QueryRow("SELECT COUNT(*) "+conditions, args...).Scan(&count)
 	if err != nil {
 		return nil, fmt.Errorf("failed to count questions: %w", err)
 	}
 
-	if count == 0 {
-		return nil, fmt.Errorf("no questions found matching criteria")
-	}
-
-	// Get a random offset
-	offset := rand.Intn(count)
+	// Fetch the questions at random offsets rather than sorting every candidate by RANDOM()
+	query := "SELECT question_id, rating, matches, wins " + conditions + " ORDER BY question_id LIMIT 1 OFFSET ?"
+	drawn := make(map[int]bool, n)
 
-	// Get the question at that offset
-	query := `
-		SELECT question_id, rating, matches, wins
-		FROM tournament_questions
-		WHERE tournament_id = ? 
-		AND rating BETWEEN ? AND ?
-		AND matches <= ?
-		LIMIT 1 OFFSET ?
-	`
+	var questions []*TournamentQuestion
+	for len(drawn) < min(n, count) {
+		offset := rng.Intn(count)
+		if drawn[offset] {
+			continue
+		}
+		drawn[offset] = true
 
-	tq := &TournamentQuestion{TournamentID: tournamentID}
-	err = r.db.QueryRow(query, tournamentID, minRating, maxRating, maxMatches, offset).Scan(
-		&tq.QuestionID, &tq.Rating, &tq.Matches, &tq.Wins,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get random question: %w", err)
+		tq := &TournamentQuestion{TournamentID: tournamentID}
+		err := r.db.QueryRow(query, append(args, offset)...).Scan(&tq.QuestionID, &tq.Rating, &tq.Matches, &tq.Wins)
+		if err == sql.ErrNoRows {
+			// The candidates changed since they were counted
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get random question: %w", err)
+		}
+		questions = append(questions, tq)
 	}
 
-	return tq, nil
+	return questions, nil
 }
 
 // Find returns a tournament question by tournament and question ID
@@ -143,6 +151,46 @@ func (r *TournamentQuestionRepository) Save(tq *TournamentQuestion) error {
 	return nil
 }
 
+// RecordMatch adds a match to the winner and the loser in one transaction. The rating change
+// is applied as an increment, so that concurrent writers do not overwrite each other.
+func (r *TournamentQuestionRepository) RecordMatch(tournamentID, winnerID, loserID int, ratingChange float64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE tournament_questions
+		SET rating = rating + ?, matches = matches + 1, wins = wins + ?
+		WHERE tournament_id = ? AND question_id = ?
+	`
+
+	for _, update := range []struct {
+		questionID   int
+		ratingChange float64
+		wins         int
+	}{
+		{winnerID, ratingChange, 1},
+		{loserID, -ratingChange, 0},
+	} {
+		result, err := tx.Exec(query, update.ratingChange, update.wins, tournamentID, update.questionID)
+		if err != nil {
+			return fmt.Errorf("failed to record match of question %d: %w", update.questionID, err)
+		}
+
+		updated, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to record match of question %d: %w", update.questionID, err)
+		}
+		if updated == 0 {
+			return fmt.Errorf("question %d is not in tournament %d", update.questionID, tournamentID)
+		}
+	}
+
+	return tx.Commit()
+}
+
 // CountUnqualifiedQuestions returns count of questions with matches < qualification cutoff
 func (r *TournamentQuestionRepository) CountUnqualifiedQuestions(tournamentID, qualificationCutoff int) (int, error) {
 	query := `
@@ -301,3 +349,30 @@ func (r *TournamentQuestionRepository) GetRatingDistribution(tournamentID, binSi
 
 	return distribution, rows.Err()
 }
+
+// GetAllQuestions returns every question of a tournament with its rating
+func (r *TournamentQuestionRepository) GetAllQuestions(tournamentID int) ([]*TournamentQuestion, error) {
+	query := `
+		SELECT question_id, rating, matches, wins
+		FROM tournament_questions
+		WHERE tournament_id = ?
+	`
+
+	rows, err := r.db.Query(query, tournamentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tournament questions: %w", err)
+	}
+	defer rows.Close()
+
+	var questions []*TournamentQuestion
+	for rows.Next() {
+		tq := &TournamentQuestion{TournamentID: tournamentID}
+		err := rows.Scan(&tq.QuestionID, &tq.Rating, &tq.Matches, &tq.Wins)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan tournament question: %w", err)
+		}
+		questions = append(questions, tq)
+	}
+
+	return questions, rows.Err()
+}
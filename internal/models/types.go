@@ -12,13 +12,37 @@ type Question struct {
 	Comment        string   `json:"comment"`
 	Source         string   `json:"source"`
 	HandoutStr     string   `json:"handout_str,omitempty"`
-	HandoutImg     string   `json:"handout_img,omitempty"`
+	HandoutImg     string   `json:"handout_img,omitempty"` // path of the handout picture on the site
 	AuthorID       *int     `json:"author_id,omitempty"`
 	PackageID      *int     `json:"package_id,omitempty"`
 	Difficulty     *float64 `json:"difficulty,omitempty"`
 	IsIncorrect    *bool    `json:"is_incorrect,omitempty"`
+
+	Number          int      `json:"number,omitempty"`            // number within the package
+	TourNumber      int      `json:"tour_number,omitempty"`       // number of the tour within the package
+	TakenDownReason string   `json:"taken_down_reason,omitempty"` // why the question was taken down
+	Authors         []Author `json:"authors,omitempty"`           // in credited order, set by the importer
+	Image           *Image   `json:"-"`                           // handout picture, set by FindByIDs
+}
+
+// Image is the stored handout picture of a question
+type Image struct {
+	QuestionID int    `json:"question_id"`
+	URL        string `json:"image_url"`
+	Data       []byte `json:"-"` // empty if the picture could not be downloaded
+	MimeType   string `json:"mime_type"`
+
+	// The picture as uploaded to Telegram, reused instead of uploading it again
+	TelegramFileID   string `json:"-"`
+	TelegramFileType string `json:"-"` // TelegramPhoto or TelegramDocument
 }
 
+// How a picture was sent to Telegram; a file ID only works with the method it was sent by
+const (
+	TelegramPhoto    = "photo"
+	TelegramDocument = "document"
+)
+
 // Vote represents a user's vote between two questions
 type Vote struct {
 	ID           int       `json:"id"`
@@ -27,24 +51,45 @@ type Vote struct {
 	Question2ID  int       `json:"question2_id"`
 	TournamentID int       `json:"tournament_id"`
 	SelectedID   *int      `json:"selected_id,omitempty"`
+	Swapped      bool      `json:"swapped"`
+	Excluded     bool      `json:"excluded"` // cast by a shadow-banned user, not counted in ratings
 	Timestamp    time.Time `json:"timestamp"`
 }
 
+// QuestionPair is a pair of questions in the order they are shown to the voter
+type QuestionPair struct {
+	First   *Question
+	Second  *Question
+	Swapped bool // the pair is shown in the reverse of the order it was selected in
+}
+
 // Tournament represents a tournament with questions
 type Tournament struct {
-	ID                     int     `json:"id"`
-	Name                   string  `json:"name"`
-	QuestionsCount         int     `json:"questions_count"`
-	Active                 bool    `json:"active"`
-	InitialK               float64 `json:"initial_k"`
-	MinimumK               float64 `json:"minimum_k"`
-	StdDevMultiplier       float64 `json:"std_dev_multiplier"`
-	InitialPhaseMatches    int     `json:"initial_phase_matches"`
-	TransitionPhaseMatches int     `json:"transition_phase_matches"`
-	TopN                   int     `json:"top_n"`
-	BandSize               int     `json:"band_size"`
+	ID                     int        `json:"id"`
+	Name                   string     `json:"name"`
+	QuestionsCount         int        `json:"questions_count"`
+	Active                 bool       `json:"active"`
+	InitialK               float64    `json:"initial_k"`
+	MinimumK               float64    `json:"minimum_k"`
+	StdDevMultiplier       float64    `json:"std_dev_multiplier"`
+	InitialPhaseMatches    int        `json:"initial_phase_matches"`
+	TransitionPhaseMatches int        `json:"transition_phase_matches"`
+	TopN                   int        `json:"top_n"`
+	BandSize               int        `json:"band_size"`
+	SelectionStrategy      string     `json:"selection_strategy"`
+	PositionBiasCorrection bool       `json:"position_bias_correction"`
+	WeightVotes            bool       `json:"weight_votes"`
+	InviteOnly             bool       `json:"invite_only"`
+	InviteCode             string     `json:"invite_code,omitempty"`
+	EndsAt                 *time.Time `json:"ends_at,omitempty"` // closed by the scheduler at this time
 }
 
+// Pair selection strategies a tournament can use
+const (
+	SelectionRandom   = "random"
+	SelectionAdaptive = "adaptive"
+)
+
 // QuestionStats represents statistics for a question
 type QuestionStats struct {
 	Wins    int `json:"wins"`
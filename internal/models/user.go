@@ -0,0 +1,182 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"questions-vote/internal/db"
+	"time"
+)
+
+// User roles
+const (
+	RoleAdmin = "admin"
+	RoleVoter = "voter"
+)
+
+// User statuses. Votes of shadow-banned users are recorded but not counted.
+const (
+	StatusActive       = "active"
+	StatusBanned       = "banned"
+	StatusShadowBanned = "shadow_banned"
+)
+
+// User represents a Telegram user of the bot
+type User struct {
+	ID            int64     `json:"id"` // Telegram user ID
+	Username      string    `json:"username,omitempty"`
+	FirstName     string    `json:"first_name,omitempty"`
+	Role          string    `json:"role"`
+	Status        string    `json:"status"`
+	StatusReason  string    `json:"status_reason,omitempty"`
+	Notifications bool      `json:"notifications"` // the user agrees to receive broadcasts
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// IsAdmin reports whether the user has the admin role
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+// UserRepository handles user database operations
+type UserRepository struct {
+	db *sql.DB
+}
+
+// NewUserRepository creates a new user repository
+func NewUserRepository() *UserRepository {
+	return &UserRepository{
+		db: db.GetDB(),
+	}
+}
+
+const userColumns = `id, username, first_name, role, status, status_reason, notifications, created_at, updated_at`
+
+// Touch creates a user on first contact or refreshes their name, and returns the stored user
+func (r *UserRepository) Touch(id int64, username, firstName string) (*User, error) {
+	query := `
+		INSERT INTO users (id, username, first_name)
+		VALUES (?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			username = excluded.username,
+			first_name = excluded.first_name
+		WHERE username != excluded.username OR first_name != excluded.first_name
+	`
+
+	_, err := r.db.Exec(query, id, username, firstName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save user: %w", err)
+	}
+
+	return r.Find(id)
+}
+
+// Find retrieves a user by Telegram ID
+func (r *UserRepository) Find(id int64) (*User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = ?`
+
+	u, err := scanUser(r.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("user %d not found", id)
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	return u, nil
+}
+
+// SetRole changes the role of a user, creating the user if they have not used the bot yet
+func (r *UserRepository) SetRole(id int64, role string) error {
+	if role != RoleAdmin && role != RoleVoter {
+		return fmt.Errorf("unknown role: %s", role)
+	}
+
+	query := `
+		INSERT INTO users (id, role) VALUES (?, ?)
+		ON CONFLICT (id) DO UPDATE SET role = excluded.role, updated_at = CURRENT_TIMESTAMP
+	`
+
+	_, err := r.db.Exec(query, id, role)
+	if err != nil {
+		return fmt.Errorf("failed to set user role: %w", err)
+	}
+	return nil
+}
+
+// SetStatus bans, shadow-bans or reinstates a user, creating the user if they have not used the bot yet
+func (r *UserRepository) SetStatus(id int64, status, reason string) error {
+	if status != StatusActive && status != StatusBanned && status != StatusShadowBanned {
+		return fmt.Errorf("unknown status: %s", status)
+	}
+
+	query := `
+		INSERT INTO users (id, status, status_reason) VALUES (?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			status = excluded.status,
+			status_reason = excluded.status_reason,
+			updated_at = CURRENT_TIMESTAMP
+	`
+
+	_, err := r.db.Exec(query, id, status, reason)
+	if err != nil {
+		return fmt.Errorf("failed to set user status: %w", err)
+	}
+	return nil
+}
+
+// SetNotifications turns broadcasts to a user on or off
+func (r *UserRepository) SetNotifications(id int64, enabled bool) error {
+	query := `UPDATE users SET notifications = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`
+	result, err := r.db.Exec(query, enabled, id)
+	if err != nil {
+		return fmt.Errorf("failed to set user notifications: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("user %d not found", id)
+	}
+
+	return nil
+}
+
+// List returns users, optionally only those with the given role or status
+func (r *UserRepository) List(role, status string) ([]*User, error) {
+	query := `
+		SELECT ` + userColumns + `
+		FROM users
+		WHERE (? = '' OR role = ?) AND (? = '' OR status = ?)
+		ORDER BY updated_at DESC
+	`
+
+	rows, err := r.db.Query(query, role, role, status, status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []*User
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, u)
+	}
+
+	return users, rows.Err()
+}
+
+func scanUser(row interface{ Scan(...any) error }) (*User, error) {
+	u := &User{}
+	err := row.Scan(&u.ID, &u.Username, &u.FirstName, &u.Role, &u.Status, &u.StatusReason, &u.Notifications, &u.CreatedAt, &u.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
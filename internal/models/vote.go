@@ -3,10 +3,38 @@ package models
 import (
 	"database/sql"
 	"fmt"
+	"math"
 	"questions-vote/internal/db"
 	"time"
 )
 
+// PositionStats counts how often the question shown first or second was chosen
+type PositionStats struct {
+	UserID     int64 `json:"user_id,omitempty"`
+	Votes      int   `json:"votes"`
+	FirstWins  int   `json:"first_wins"`
+	SecondWins int   `json:"second_wins"`
+	Skips      int   `json:"skips"`
+}
+
+// FirstWinRate returns the share of decided votes won by the question shown first
+func (s *PositionStats) FirstWinRate() float64 {
+	decided := s.FirstWins + s.SecondWins
+	if decided == 0 {
+		return 0.5
+	}
+	return float64(s.FirstWins) / float64(decided)
+}
+
+// Advantage returns the first-position advantage in rating points implied by the win rate
+func (s *PositionStats) Advantage() float64 {
+	rate := s.FirstWinRate()
+	if rate <= 0 || rate >= 1 {
+		return 0
+	}
+	return 400 * math.Log10(rate/(1-rate))
+}
+
 // VoteRepository handles vote database operations
 type VoteRepository struct {
 	db *sql.DB
@@ -19,11 +47,12 @@ func NewVoteRepository() *VoteRepository {
 	}
 }
 
-// Create inserts a new vote into the database
-func (r *VoteRepository) Create(userID int64, question1ID, question2ID, tournamentID int, selectedID *int) error {
+// Create inserts a new vote into the database. question1ID is the question shown first,
+// excluded marks a vote that is kept for the record but not counted.
+func (r *VoteRepository) Create(userID int64, question1ID, question2ID, tournamentID int, selectedID *int, swapped, excluded bool) error {
 	query := `
-		INSERT INTO votes (user_id, question1_id, question2_id, tournament_id, selected_id, timestamp)
-		VALUES (?, ?, ?, ?, ?, ?)
+		INSERT INTO votes (user_id, question1_id, question2_id, tournament_id, selected_id, swapped, excluded, timestamp)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
 	_, err := r.db.Exec(
@@ -33,6 +62,8 @@ func (r *VoteRepository) Create(userID int64, question1ID, question2ID, tourname
 		question2ID,
 		tournamentID,
 		selectedID,
+		swapped,
+		excluded,
 		time.Now(),
 	)
 
@@ -63,7 +94,7 @@ func (r *VoteRepository) GetVoteCount(userID int64, tournamentID int) (int, erro
 // GetRecentVotes returns recent votes for a user
 func (r *VoteRepository) GetRecentVotes(userID int64, tournamentID int, limit int) ([]*Vote, error) {
 	query := `
-		SELECT id, user_id, question1_id, question2_id, tournament_id, selected_id, timestamp
+		SELECT id, user_id, question1_id, question2_id, tournament_id, selected_id, swapped, excluded, timestamp
 		FROM votes 
 		WHERE user_id = ? AND tournament_id = ?
 		ORDER BY timestamp DESC
@@ -86,6 +117,8 @@ func (r *VoteRepository) GetRecentVotes(userID int64, tournamentID int, limit in
 			&v.Question2ID,
 			&v.TournamentID,
 			&v.SelectedID,
+			&v.Swapped,
+			&v.Excluded,
 			&v.Timestamp,
 		)
 		if err != nil {
@@ -96,3 +129,108 @@ func (r *VoteRepository) GetRecentVotes(userID int64, tournamentID int, limit in
 
 	return votes, rows.Err()
 }
+
+// positionStatsColumns aggregates votes into PositionStats fields
+const positionStatsColumns = `
+	COUNT(*),
+	COALESCE(SUM(CASE WHEN selected_id = question1_id THEN 1 ELSE 0 END), 0),
+	COALESCE(SUM(CASE WHEN selected_id = question2_id THEN 1 ELSE 0 END), 0),
+	COALESCE(SUM(CASE WHEN selected_id IS NULL THEN 1 ELSE 0 END), 0)
+`
+
+// GetPositionStats returns first-vs-second win counts over all counted votes of a tournament
+func (r *VoteRepository) GetPositionStats(tournamentID int) (*PositionStats, error) {
+	query := `SELECT ` + positionStatsColumns + ` FROM votes WHERE tournament_id = ? AND excluded = 0`
+
+	stats := &PositionStats{}
+	err := r.db.QueryRow(query, tournamentID).Scan(&stats.Votes, &stats.FirstWins, &stats.SecondWins, &stats.Skips)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get position stats: %w", err)
+	}
+
+	return stats, nil
+}
+
+// GetPositionStatsByUser returns first-vs-second win counts for every user with at least minVotes votes
+func (r *VoteRepository) GetPositionStatsByUser(tournamentID, minVotes int) ([]*PositionStats, error) {
+	query := `
+		SELECT user_id, ` + positionStatsColumns + `
+		FROM votes
+		WHERE tournament_id = ?
+		GROUP BY user_id
+		HAVING COUNT(*) >= ?
+		ORDER BY COUNT(*) DESC
+	`
+
+	rows, err := r.db.Query(query, tournamentID, minVotes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query position stats by user: %w", err)
+	}
+	defer rows.Close()
+
+	var result []*PositionStats
+	for rows.Next() {
+		stats := &PositionStats{}
+		err := rows.Scan(&stats.UserID, &stats.Votes, &stats.FirstWins, &stats.SecondWins, &stats.Skips)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan position stats: %w", err)
+		}
+		result = append(result, stats)
+	}
+
+	return result, rows.Err()
+}
+
+// RatedVote is a vote together with the current ratings of both questions
+type RatedVote struct {
+	Vote
+	Rating1 float64
+	Rating2 float64
+}
+
+// GetRatedVotes returns the votes of a tournament in chronological order with current ratings.
+// A zero userID returns the votes of every user.
+func (r *VoteRepository) GetRatedVotes(tournamentID int, userID int64) ([]*RatedVote, error) {
+	query := `
+		SELECT v.id, v.user_id, v.question1_id, v.question2_id, v.tournament_id,
+		       v.selected_id, v.swapped, v.excluded, v.timestamp,
+		       COALESCE(tq1.rating, 0), COALESCE(tq2.rating, 0)
+		FROM votes v
+		LEFT JOIN tournament_questions tq1
+		       ON tq1.tournament_id = v.tournament_id AND tq1.question_id = v.question1_id
+		LEFT JOIN tournament_questions tq2
+		       ON tq2.tournament_id = v.tournament_id AND tq2.question_id = v.question2_id
+		WHERE v.tournament_id = ? AND (? = 0 OR v.user_id = ?)
+		ORDER BY v.user_id, v.timestamp
+	`
+
+	rows, err := r.db.Query(query, tournamentID, userID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rated votes: %w", err)
+	}
+	defer rows.Close()
+
+	var votes []*RatedVote
+	for rows.Next() {
+		v := &RatedVote{}
+		err := rows.Scan(
+			&v.ID,
+			&v.UserID,
+			&v.Question1ID,
+			&v.Question2ID,
+			&v.TournamentID,
+			&v.SelectedID,
+			&v.Swapped,
+			&v.Excluded,
+			&v.Timestamp,
+			&v.Rating1,
+			&v.Rating2,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan rated vote: %w", err)
+		}
+		votes = append(votes, v)
+	}
+
+	return votes, rows.Err()
+}
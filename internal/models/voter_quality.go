@@ -0,0 +1,120 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"questions-vote/internal/db"
+	"strings"
+	"time"
+)
+
+// VoterQuality describes how reliable a user's votes are in a tournament
+type VoterQuality struct {
+	TournamentID   int       `json:"tournament_id"`
+	UserID         int64     `json:"user_id"`
+	Votes          int       `json:"votes"`
+	Agreement      float64   `json:"agreement"`       // share of decided votes for the higher-rated question
+	MedianInterval float64   `json:"median_interval"` // median seconds between consecutive votes
+	LongestStreak  int       `json:"longest_streak"`  // longest run of decided votes for the same position
+	SkipRatio      float64   `json:"skip_ratio"`
+	Reliability    float64   `json:"reliability"` // weight of the user's votes, from 0 to 1
+	Flags          []string  `json:"flags,omitempty"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// Flagged reports whether the user's voting looks suspicious
+func (q *VoterQuality) Flagged() bool {
+	return len(q.Flags) > 0
+}
+
+// VoterQualityRepository handles voter quality database operations
+type VoterQualityRepository struct {
+	db *sql.DB
+}
+
+// NewVoterQualityRepository creates a new voter quality repository
+func NewVoterQualityRepository() *VoterQualityRepository {
+	return &VoterQualityRepository{
+		db: db.GetDB(),
+	}
+}
+
+// Save inserts or replaces the quality of a user in a tournament
+func (r *VoterQualityRepository) Save(q *VoterQuality) error {
+	query := `
+		INSERT INTO voter_quality (tournament_id, user_id, votes, agreement, median_interval,
+		                           longest_streak, skip_ratio, reliability, flags, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (tournament_id, user_id) DO UPDATE SET
+			votes = excluded.votes,
+			agreement = excluded.agreement,
+			median_interval = excluded.median_interval,
+			longest_streak = excluded.longest_streak,
+			skip_ratio = excluded.skip_ratio,
+			reliability = excluded.reliability,
+			flags = excluded.flags,
+			updated_at = excluded.updated_at
+	`
+
+	_, err := r.db.Exec(query, q.TournamentID, q.UserID, q.Votes, q.Agreement, q.MedianInterval,
+		q.LongestStreak, q.SkipRatio, q.Reliability, strings.Join(q.Flags, ","), time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to save voter quality: %w", err)
+	}
+
+	return nil
+}
+
+// GetReliability returns the reliability of a user, or 1 if it has not been measured yet
+func (r *VoterQualityRepository) GetReliability(tournamentID int, userID int64) (float64, error) {
+	query := `SELECT reliability FROM voter_quality WHERE tournament_id = ? AND user_id = ?`
+
+	var reliability float64
+	err := r.db.QueryRow(query, tournamentID, userID).Scan(&reliability)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 1, nil
+		}
+		return 0, fmt.Errorf("failed to get voter reliability: %w", err)
+	}
+
+	return reliability, nil
+}
+
+// List returns the quality of users in a tournament, least reliable first.
+// With flaggedOnly set, only users with at least one flag are returned.
+func (r *VoterQualityRepository) List(tournamentID int, flaggedOnly bool) ([]*VoterQuality, error) {
+	query := `
+		SELECT tournament_id, user_id, votes, agreement, median_interval,
+		       longest_streak, skip_ratio, reliability, flags, updated_at
+		FROM voter_quality
+		WHERE tournament_id = ?
+	`
+	if flaggedOnly {
+		query += ` AND flags != ''`
+	}
+	query += ` ORDER BY reliability, votes DESC`
+
+	rows, err := r.db.Query(query, tournamentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query voter quality: %w", err)
+	}
+	defer rows.Close()
+
+	var result []*VoterQuality
+	for rows.Next() {
+		q := &VoterQuality{}
+		var flags string
+		err := rows.Scan(&q.TournamentID, &q.UserID, &q.Votes, &q.Agreement, &q.MedianInterval,
+			&q.LongestStreak, &q.SkipRatio, &q.Reliability, &flags, &q.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan voter quality: %w", err)
+		}
+		if flags != "" {
+			q.Flags = strings.Split(flags, ",")
+		}
+		result = append(result, q)
+	}
+
+	return result, rows.Err()
+}
@@ -0,0 +1,236 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"questions-vote/internal/models"
+	"time"
+)
+
+const (
+	// DefaultInterval keeps broadcasts under Telegram's limit of 30 messages per second
+	DefaultInterval = 40 * time.Millisecond
+	// pollInterval is how often the queue is checked for broadcasts queued by other processes
+	pollInterval = 30 * time.Second
+	// batchSize is how many deliveries are loaded at a time
+	batchSize = 100
+	// maxAttempts is how many times a delivery is tried before it is marked failed
+	maxAttempts = 3
+	// retryBackoff is how long a failed delivery waits before its second attempt; every
+	// further attempt waits twice as long
+	retryBackoff = time.Minute
+)
+
+// OptOutHint is appended to every broadcast
+const OptOutHint = "\n\nОтключить уведомления: /settings"
+
+// Sender delivers a message to a user
+type Sender interface {
+	Send(ctx context.Context, userID int64, text string) error
+}
+
+// SendError tells the notifier how to treat a failed delivery
+type SendError struct {
+	Err        error
+	RetryAfter time.Duration // flood control: wait this long before sending anything
+	Blocked    bool          // the user blocked the bot, do not try again
+}
+
+func (e *SendError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *SendError) Unwrap() error {
+	return e.Err
+}
+
+// Notifier sends queued broadcasts one message at a time, recording every delivery
+// so that a broadcast interrupted by a restart continues where it stopped
+type Notifier struct {
+	repo     *models.BroadcastRepository
+	sender   Sender
+	interval time.Duration
+	backoff  time.Duration
+	wake     chan struct{}
+	lastSend time.Time
+	retryAt  time.Time // when the earliest delivery waiting to be retried is due
+}
+
+// New creates a notifier that sends at most one message per interval
+func New(sender Sender, interval time.Duration) *Notifier {
+	return &Notifier{
+		repo:     models.NewBroadcastRepository(),
+		sender:   sender,
+		interval: interval,
+		backoff:  retryBackoff,
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// Enqueue queues a broadcast and wakes the sender
+func (n *Notifier) Enqueue(b *models.Broadcast) (int, error) {
+	id, err := n.repo.Create(b)
+	if err != nil {
+		return 0, err
+	}
+
+	select {
+	case n.wake <- struct{}{}:
+	default:
+	}
+
+	return id, nil
+}
+
+// Run sends queued broadcasts until the context is cancelled
+func (n *Notifier) Run(ctx context.Context) {
+	for {
+		err := n.DeliverQueued(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Failed to deliver broadcasts: %v", err)
+		}
+
+		delay := pollInterval
+		if !n.retryAt.IsZero() {
+			delay = min(delay, time.Until(n.retryAt))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-n.wake:
+		case <-time.After(delay):
+		}
+	}
+}
+
+// DeliverQueued sends every unfinished broadcast, oldest first. Deliveries waiting to be
+// retried are left for a later call.
+func (n *Notifier) DeliverQueued(ctx context.Context) error {
+	n.retryAt = time.Time{}
+
+	broadcasts, err := n.repo.FindUnfinished()
+	if err != nil {
+		return err
+	}
+
+	for _, b := range broadcasts {
+		err = n.deliver(ctx, b)
+		if err != nil {
+			return fmt.Errorf("failed to deliver broadcast %d: %w", b.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// deliver sends the due deliveries of a broadcast and marks it done when none are left
+func (n *Notifier) deliver(ctx context.Context, b *models.Broadcast) error {
+	if b.Status == models.BroadcastPending {
+		err := n.repo.SetStatus(b.ID, models.BroadcastSending)
+		if err != nil {
+			return err
+		}
+		log.Printf("Sending broadcast %d", b.ID)
+	}
+
+	for {
+		deliveries, err := n.repo.GetPendingDeliveries(b.ID, batchSize)
+		if err != nil {
+			return err
+		}
+
+		if len(deliveries) == 0 {
+			break
+		}
+
+		for _, d := range deliveries {
+			err = n.deliverOne(ctx, b, d)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	retryAt, err := n.repo.NextRetryAt(b.ID)
+	if err != nil {
+		return err
+	}
+	if retryAt != nil {
+		if n.retryAt.IsZero() || retryAt.Before(n.retryAt) {
+			n.retryAt = *retryAt
+		}
+		return nil
+	}
+
+	progress, err := n.repo.GetProgress(b.ID)
+	if err != nil {
+		return err
+	}
+	log.Printf("Broadcast %d finished: %d sent, %d failed, %d blocked, %d skipped", b.ID,
+		progress[models.DeliverySent], progress[models.DeliveryFailed],
+		progress[models.DeliveryBlocked], progress[models.DeliverySkipped])
+
+	return n.repo.SetStatus(b.ID, models.BroadcastDone)
+}
+
+// deliverOne sends a broadcast to one user, waiting out the throttle and flood control
+func (n *Notifier) deliverOne(ctx context.Context, b *models.Broadcast, d *models.Delivery) error {
+	if !d.Subscribed {
+		return n.repo.RecordDelivery(b.ID, d.UserID, models.DeliverySkipped, "")
+	}
+
+	for {
+		err := n.wait(ctx, time.Until(n.lastSend.Add(n.interval)))
+		if err != nil {
+			return err
+		}
+
+		n.lastSend = time.Now()
+		err = n.sender.Send(ctx, d.UserID, b.Text+OptOutHint)
+		if err == nil {
+			return n.repo.RecordDelivery(b.ID, d.UserID, models.DeliverySent, "")
+		}
+
+		var sendErr *SendError
+		if !errors.As(err, &sendErr) {
+			sendErr = &SendError{Err: err}
+		}
+
+		switch {
+		case sendErr.RetryAfter > 0:
+			log.Printf("Flood control while sending broadcast %d, waiting %v", b.ID, sendErr.RetryAfter)
+			err = n.wait(ctx, sendErr.RetryAfter)
+			if err != nil {
+				return err
+			}
+		case sendErr.Blocked:
+			return n.repo.RecordDelivery(b.ID, d.UserID, models.DeliveryBlocked, err.Error())
+		case d.Attempts+1 >= maxAttempts:
+			return n.repo.RecordDelivery(b.ID, d.UserID, models.DeliveryFailed, err.Error())
+		default:
+			// Leave the delivery pending, so that it is retried once the backoff has passed
+			retryAt := time.Now().Add(n.backoff << d.Attempts)
+			return n.repo.RetryDeliveryAt(b.ID, d.UserID, err.Error(), retryAt)
+		}
+	}
+}
+
+// wait sleeps for d unless the context is cancelled first
+func (n *Notifier) wait(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
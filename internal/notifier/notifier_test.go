@@ -0,0 +1,251 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"questions-vote/internal/models"
+	"questions-vote/internal/testutil"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSender records messages and fails for configured users
+type fakeSender struct {
+	mu       sync.Mutex
+	sent     map[int64]int
+	failures map[int64][]error // errors returned for a user before a send succeeds
+	cancel   context.CancelFunc
+	stopAt   int // cancel the context after this many successful sends
+}
+
+func (s *fakeSender) Send(ctx context.Context, userID int64, text string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if errs := s.failures[userID]; len(errs) > 0 {
+		s.failures[userID] = errs[1:]
+		return errs[0]
+	}
+
+	s.sent[userID]++
+
+	total := 0
+	for _, count := range s.sent {
+		total += count
+	}
+	if s.cancel != nil && total == s.stopAt {
+		s.cancel()
+	}
+
+	return nil
+}
+
+func setupNotifierDB(t *testing.T, users ...int64) {
+	t.Helper()
+
+	testutil.OpenDB(t)
+
+	userRepo := models.NewUserRepository()
+	for _, id := range users {
+		_, err := userRepo.Touch(id, "", "")
+		if err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+	}
+}
+
+// TestDeliverQueuedRecordsOutcomes tests that every delivery outcome ends up in the delivery log
+func TestDeliverQueuedRecordsOutcomes(t *testing.T) {
+	setupNotifierDB(t, 1, 2, 3, 4, 5)
+
+	userRepo := models.NewUserRepository()
+	if err := userRepo.SetStatus(5, models.StatusBanned, ""); err != nil {
+		t.Fatalf("Failed to ban user: %v", err)
+	}
+
+	sender := &fakeSender{
+		sent: map[int64]int{},
+		failures: map[int64][]error{
+			2: {&SendError{Err: errors.New("forbidden"), Blocked: true}},
+			3: {errors.New("timeout"), errors.New("timeout"), errors.New("timeout")},
+			4: {&SendError{Err: errors.New("too many requests"), RetryAfter: time.Millisecond}},
+		},
+	}
+	n := New(sender, 0)
+	n.backoff = 0
+
+	id, err := n.Enqueue(&models.Broadcast{Audience: models.AudienceAll, Text: "Осталось 3 дня"})
+	if err != nil {
+		t.Fatalf("Failed to enqueue broadcast: %v", err)
+	}
+
+	// Opting out after the broadcast was queued still prevents the message
+	if err := userRepo.SetNotifications(1, false); err != nil {
+		t.Fatalf("Failed to opt out: %v", err)
+	}
+
+	err = n.DeliverQueued(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to deliver broadcasts: %v", err)
+	}
+
+	progress, err := n.repo.GetProgress(id)
+	if err != nil {
+		t.Fatalf("Failed to get progress: %v", err)
+	}
+
+	expected := models.BroadcastProgress{
+		models.DeliverySkipped: 1, // user 1 opted out
+		models.DeliveryBlocked: 1, // user 2 blocked the bot
+		models.DeliveryFailed:  1, // user 3 failed every attempt
+		models.DeliverySent:    1, // user 4 succeeded after flood control
+	}
+	for status, count := range expected {
+		if progress[status] != count {
+			t.Errorf("Expected %d deliveries with status %s, got %d (%v)", count, status, progress[status], progress)
+		}
+	}
+	if progress.Total() != 4 {
+		t.Errorf("Banned user should not be a recipient, got %d recipients", progress.Total())
+	}
+
+	if sender.sent[4] != 1 || sender.sent[1] != 0 {
+		t.Errorf("Unexpected messages sent: %v", sender.sent)
+	}
+
+	broadcasts, err := n.repo.FindUnfinished()
+	if err != nil {
+		t.Fatalf("Failed to find unfinished broadcasts: %v", err)
+	}
+	if len(broadcasts) != 0 {
+		t.Errorf("Expected the broadcast to be done, %d unfinished", len(broadcasts))
+	}
+}
+
+// TestDeliverQueuedResumes tests that an interrupted broadcast continues without repeating messages
+func TestDeliverQueuedResumes(t *testing.T) {
+	users := []int64{1, 2, 3, 4, 5, 6}
+	setupNotifierDB(t, users...)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sender := &fakeSender{sent: map[int64]int{}, cancel: cancel, stopAt: 2}
+	n := New(sender, 0)
+
+	id, err := n.Enqueue(&models.Broadcast{Audience: models.AudienceAll, Text: "Итоги опубликованы"})
+	if err != nil {
+		t.Fatalf("Failed to enqueue broadcast: %v", err)
+	}
+
+	err = n.DeliverQueued(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected delivery to stop with the context, got %v", err)
+	}
+
+	progress, _ := n.repo.GetProgress(id)
+	if progress[models.DeliverySent] != 2 || progress[models.DeliveryPending] != 4 {
+		t.Fatalf("Expected 2 sent and 4 pending after interruption, got %v", progress)
+	}
+
+	// A new notifier, as after a restart, picks the broadcast up from the delivery log
+	sender.cancel = nil
+	err = New(sender, 0).DeliverQueued(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to resume broadcast: %v", err)
+	}
+
+	for _, id := range users {
+		if sender.sent[id] != 1 {
+			t.Errorf("Expected user %d to get exactly one message, got %d", id, sender.sent[id])
+		}
+	}
+}
+
+// TestDeliverQueuedBacksOff tests that a failed delivery waits for its backoff while the
+// rest of the broadcast is sent, and that the broadcast stays unfinished until it is retried
+func TestDeliverQueuedBacksOff(t *testing.T) {
+	setupNotifierDB(t, 1, 2, 3)
+
+	sender := &fakeSender{
+		sent:     map[int64]int{},
+		failures: map[int64][]error{1: {errors.New("timeout")}},
+	}
+	n := New(sender, 0)
+	n.backoff = 50 * time.Millisecond
+
+	id, err := n.Enqueue(&models.Broadcast{Audience: models.AudienceAll, Text: "Скоро финал"})
+	if err != nil {
+		t.Fatalf("Failed to enqueue broadcast: %v", err)
+	}
+
+	err = n.DeliverQueued(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to deliver broadcasts: %v", err)
+	}
+
+	progress, _ := n.repo.GetProgress(id)
+	if progress[models.DeliverySent] != 2 || progress[models.DeliveryPending] != 1 {
+		t.Fatalf("Expected 2 sent and 1 waiting for a retry, got %v", progress)
+	}
+	if n.retryAt.IsZero() {
+		t.Error("Expected the notifier to know when to retry")
+	}
+
+	unfinished, _ := n.repo.FindUnfinished()
+	if len(unfinished) != 1 {
+		t.Errorf("Expected the broadcast to stay unfinished, %d unfinished", len(unfinished))
+	}
+
+	time.Sleep(time.Until(n.retryAt))
+	err = n.DeliverQueued(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to retry broadcasts: %v", err)
+	}
+
+	if sender.sent[1] != 1 {
+		t.Errorf("Expected user 1 to get the message on the retry, got %v", sender.sent)
+	}
+	unfinished, _ = n.repo.FindUnfinished()
+	if len(unfinished) != 0 {
+		t.Errorf("Expected the broadcast to be done, %d unfinished", len(unfinished))
+	}
+}
+
+// TestEnqueueVotersOnly tests that a broadcast to voters reaches only users who voted in the tournament,
+// including those who voted before users were recorded
+func TestEnqueueVotersOnly(t *testing.T) {
+	setupNotifierDB(t, 1, 2, 3)
+
+	voteRepo := models.NewVoteRepository()
+	selected := 10
+	if err := voteRepo.Create(1, 10, 11, 7, &selected, false, false); err != nil {
+		t.Fatalf("Failed to create vote: %v", err)
+	}
+	if err := voteRepo.Create(1, 12, 13, 7, nil, false, false); err != nil {
+		t.Fatalf("Failed to create vote: %v", err)
+	}
+	if err := voteRepo.Create(2, 10, 11, 8, &selected, false, false); err != nil {
+		t.Fatalf("Failed to create vote: %v", err)
+	}
+	if err := voteRepo.Create(4, 10, 11, 7, &selected, false, false); err != nil {
+		t.Fatalf("Failed to create vote: %v", err)
+	}
+
+	sender := &fakeSender{sent: map[int64]int{}}
+	n := New(sender, 0)
+
+	tournamentID := 7
+	_, err := n.Enqueue(&models.Broadcast{Audience: models.AudienceVoters, TournamentID: &tournamentID, Text: "Новый этап"})
+	if err != nil {
+		t.Fatalf("Failed to enqueue broadcast: %v", err)
+	}
+
+	err = n.DeliverQueued(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to deliver broadcasts: %v", err)
+	}
+
+	if len(sender.sent) != 2 || sender.sent[1] != 1 || sender.sent[4] != 1 {
+		t.Errorf("Expected only users 1 and 4 to get the message once, got %v", sender.sent)
+	}
+}
@@ -0,0 +1,176 @@
+package quality
+
+import (
+	"fmt"
+	"math"
+	"questions-vote/internal/models"
+	"sort"
+)
+
+const (
+	// MinVotes is how many votes a user needs before their reliability is measured
+	MinVotes = 20
+	// FastInterval is the median number of seconds between votes below which a user is too fast to read
+	FastInterval = 4.0
+	// LongStreak is the run of votes for the same position that looks like pressing one button
+	LongStreak = 15
+	// LowAgreement is the share of votes agreeing with the ratings below which a user looks random
+	LowAgreement = 0.55
+	// HighSkipRatio is the share of skipped pairs above which a user is flagged
+	HighSkipRatio = 0.8
+	// MinReliability is the lowest weight a vote can have
+	MinReliability = 0.1
+)
+
+// Flags explaining why a user's voting looks suspicious
+const (
+	FlagLowAgreement = "low_agreement"
+	FlagTooFast      = "too_fast"
+	FlagSameButton   = "same_button"
+	FlagManySkips    = "many_skips"
+)
+
+// Score measures the quality of one user's votes in chronological order.
+// Agreement is measured against the current ratings, which are the consensus of all voters.
+func Score(tournamentID int, userID int64, votes []*models.RatedVote) *models.VoterQuality {
+	q := &models.VoterQuality{
+		TournamentID: tournamentID,
+		UserID:       userID,
+		Votes:        len(votes),
+		Reliability:  1,
+	}
+
+	if len(votes) == 0 {
+		return q
+	}
+
+	var decided, agreed, skips, streak, lastPosition int
+	var intervals []float64
+
+	for i, v := range votes {
+		if i > 0 {
+			intervals = append(intervals, v.Timestamp.Sub(votes[i-1].Timestamp).Seconds())
+		}
+
+		if v.SelectedID == nil {
+			skips++
+			continue
+		}
+
+		decided++
+		selectedRating, otherRating := v.Rating1, v.Rating2
+		position := 1
+		if *v.SelectedID == v.Question2ID {
+			selectedRating, otherRating = v.Rating2, v.Rating1
+			position = 2
+		}
+
+		if selectedRating > otherRating {
+			agreed++
+		} else if selectedRating == otherRating {
+			// An even pair carries no information about agreement
+			decided--
+		}
+
+		if position == lastPosition {
+			streak++
+		} else {
+			streak = 1
+			lastPosition = position
+		}
+		q.LongestStreak = max(q.LongestStreak, streak)
+	}
+
+	q.SkipRatio = float64(skips) / float64(len(votes))
+	q.MedianInterval = median(intervals)
+	q.Agreement = 0.5
+	if decided > 0 {
+		q.Agreement = float64(agreed) / float64(decided)
+	}
+
+	if len(votes) < MinVotes {
+		return q
+	}
+
+	if q.Agreement < LowAgreement {
+		q.Flags = append(q.Flags, FlagLowAgreement)
+	}
+	if q.MedianInterval < FastInterval {
+		q.Flags = append(q.Flags, FlagTooFast)
+	}
+	if q.LongestStreak >= LongStreak {
+		q.Flags = append(q.Flags, FlagSameButton)
+	}
+	if q.SkipRatio > HighSkipRatio {
+		q.Flags = append(q.Flags, FlagManySkips)
+	}
+
+	q.Reliability = reliability(q)
+	return q
+}
+
+// reliability turns the measurements into a vote weight.
+// A voter agreeing with the consensus 80% of the time gets full weight, a coin-flipper 0.4;
+// voting too fast or pressing the same button halves the weight.
+func reliability(q *models.VoterQuality) float64 {
+	weight := math.Min(1, 2*q.Agreement-0.6)
+
+	if q.MedianInterval < FastInterval {
+		weight /= 2
+	}
+	if q.LongestStreak >= LongStreak {
+		weight /= 2
+	}
+
+	return math.Max(MinReliability, weight)
+}
+
+// ScoreAll measures the quality of every user from votes grouped by user in chronological order
+func ScoreAll(tournamentID int, votes []*models.RatedVote) []*models.VoterQuality {
+	var result []*models.VoterQuality
+
+	start := 0
+	for i := 1; i <= len(votes); i++ {
+		if i < len(votes) && votes[i].UserID == votes[start].UserID {
+			continue
+		}
+		result = append(result, Score(tournamentID, votes[start].UserID, votes[start:i]))
+		start = i
+	}
+
+	return result
+}
+
+// Refresh recomputes and stores the quality of one user, or of every user when userID is zero
+func Refresh(tournamentID int, userID int64) ([]*models.VoterQuality, error) {
+	votes, err := models.NewVoteRepository().GetRatedVotes(tournamentID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	qualities := ScoreAll(tournamentID, votes)
+	repo := models.NewVoterQualityRepository()
+	for _, q := range qualities {
+		err = repo.Save(q)
+		if err != nil {
+			return nil, fmt.Errorf("failed to save quality of user %d: %w", q.UserID, err)
+		}
+	}
+
+	return qualities, nil
+}
+
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
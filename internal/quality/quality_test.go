@@ -0,0 +1,138 @@
+package quality
+
+import (
+	"math/rand"
+	"questions-vote/internal/models"
+	"slices"
+	"testing"
+	"time"
+)
+
+// makeVotes builds n votes between a question rated 1600 and one rated 1400, at the given interval.
+// choose returns whether the vote goes to the stronger question and whether it is shown first.
+func makeVotes(n int, interval time.Duration, choose func(i int) (stronger, strongerFirst bool)) []*models.RatedVote {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	votes := make([]*models.RatedVote, n)
+
+	for i := range votes {
+		stronger, strongerFirst := choose(i)
+
+		v := &models.RatedVote{
+			Vote: models.Vote{
+				UserID:      1,
+				Question1ID: 1,
+				Question2ID: 2,
+				Timestamp:   start.Add(time.Duration(i) * interval),
+			},
+			Rating1: 1600,
+			Rating2: 1400,
+		}
+		if !strongerFirst {
+			v.Question1ID, v.Question2ID = 2, 1
+			v.Rating1, v.Rating2 = 1400, 1600
+		}
+
+		selected := 2
+		if stronger {
+			selected = 1
+		}
+		v.SelectedID = &selected
+
+		votes[i] = v
+	}
+
+	return votes
+}
+
+// TestScoreCarefulVoter tests that a voter agreeing with the consensus keeps full weight
+func TestScoreCarefulVoter(t *testing.T) {
+	votes := makeVotes(50, 20*time.Second, func(i int) (bool, bool) {
+		return i%10 != 0, i%2 == 0
+	})
+
+	q := Score(1, 1, votes)
+
+	if q.Flagged() {
+		t.Errorf("Careful voter should not be flagged, got %v", q.Flags)
+	}
+	if q.Agreement != 0.9 {
+		t.Errorf("Expected agreement 0.9, got %.2f", q.Agreement)
+	}
+	if q.Reliability != 1 {
+		t.Errorf("Expected reliability 1, got %.2f", q.Reliability)
+	}
+}
+
+// TestScoreRandomVoter tests that a voter ignoring the questions is flagged as low agreement
+func TestScoreRandomVoter(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	votes := makeVotes(200, 20*time.Second, func(i int) (bool, bool) {
+		return rng.Intn(2) == 0, rng.Intn(2) == 0
+	})
+
+	q := Score(1, 1, votes)
+
+	if !slices.Contains(q.Flags, FlagLowAgreement) {
+		t.Errorf("Random voter should be flagged %s, got agreement %.2f and flags %v", FlagLowAgreement, q.Agreement, q.Flags)
+	}
+	if q.Reliability >= 0.6 {
+		t.Errorf("Random voter should have low reliability, got %.2f", q.Reliability)
+	}
+}
+
+// TestScoreFastSameButtonVoter tests that rapid votes for the same position are flagged and weighted down
+func TestScoreFastSameButtonVoter(t *testing.T) {
+	votes := makeVotes(40, time.Second, func(i int) (bool, bool) {
+		// Always pressing the first button, the stronger question is shown first half the time
+		strongerFirst := i%2 == 0
+		return strongerFirst, strongerFirst
+	})
+
+	q := Score(1, 1, votes)
+
+	for _, flag := range []string{FlagTooFast, FlagSameButton} {
+		if !slices.Contains(q.Flags, flag) {
+			t.Errorf("Expected flag %s, got %v", flag, q.Flags)
+		}
+	}
+	if q.LongestStreak != 40 {
+		t.Errorf("Expected streak of 40, got %d", q.LongestStreak)
+	}
+	if q.Reliability != MinReliability {
+		t.Errorf("Expected minimum reliability %.2f, got %.2f", MinReliability, q.Reliability)
+	}
+}
+
+// TestScoreFewVotes tests that users with too few votes are not judged
+func TestScoreFewVotes(t *testing.T) {
+	votes := makeVotes(MinVotes-1, time.Second, func(i int) (bool, bool) {
+		return false, true
+	})
+
+	q := Score(1, 1, votes)
+
+	if q.Flagged() || q.Reliability != 1 {
+		t.Errorf("User with %d votes should not be judged, got flags %v and reliability %.2f", len(votes), q.Flags, q.Reliability)
+	}
+}
+
+// TestScoreAllGroupsByUser tests that votes are scored per user
+func TestScoreAllGroupsByUser(t *testing.T) {
+	first := makeVotes(3, time.Minute, func(i int) (bool, bool) { return true, true })
+	second := makeVotes(2, time.Minute, func(i int) (bool, bool) { return true, true })
+	for _, v := range second {
+		v.UserID = 2
+	}
+
+	qualities := ScoreAll(1, append(first, second...))
+
+	if len(qualities) != 2 {
+		t.Fatalf("Expected 2 users, got %d", len(qualities))
+	}
+	if qualities[0].UserID != 1 || qualities[0].Votes != 3 {
+		t.Errorf("Expected user 1 with 3 votes, got user %d with %d", qualities[0].UserID, qualities[0].Votes)
+	}
+	if qualities[1].UserID != 2 || qualities[1].Votes != 2 {
+		t.Errorf("Expected user 2 with 2 votes, got user %d with %d", qualities[1].UserID, qualities[1].Votes)
+	}
+}
@@ -0,0 +1,158 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"questions-vote/internal/dedup"
+	"questions-vote/internal/importer"
+	"questions-vote/internal/models"
+	"strings"
+	"time"
+)
+
+const (
+	// importWindow is how long after its end a package without questions is still imported
+	importWindow = 30 * 24 * time.Hour
+	// maxImportsPerRun keeps a nightly import from hammering the site after a long break
+	maxImportsPerRun = 20
+	// reminderLead is how long before the end of a tournament its voters are reminded
+	reminderLead = 3 * 24 * time.Hour
+)
+
+// Names of the default jobs
+const (
+	JobSyncPackages     = "sync-packages"
+	JobImportEnded      = "import-ended-packages"
+	JobCloseTournaments = "close-tournaments"
+	JobSendReminders    = "send-reminders"
+	JobFindDuplicates   = "find-duplicates"
+)
+
+// EnqueueFunc queues a broadcast for the bot to send
+type EnqueueFunc func(b *models.Broadcast) (int, error)
+
+// DropFunc forgets the ratings cached for a tournament, called when the tournament closes
+type DropFunc func(tournamentID int)
+
+// DefaultJobs returns the maintenance jobs run by the bot
+func DefaultJobs(enqueue EnqueueFunc, drop DropFunc) []*Job {
+	return []*Job{
+		NewJob(JobSyncPackages, "0 3 * * *", syncPackages),
+		NewJob(JobImportEnded, "30 3 * * *", importEndedPackages),
+		NewJob(JobCloseTournaments, "*/5 * * * *", func(ctx context.Context) (string, error) {
+			return closeTournaments(ctx, drop)
+		}),
+		NewJob(JobSendReminders, "0 12 * * *", func(ctx context.Context) (string, error) {
+			return sendReminders(enqueue)
+		}),
+		NewJob(JobFindDuplicates, "0 5 * * *", findDuplicates),
+	}
+}
+
+// syncPackages adds new and updates changed packages from the newest pages of the list
+func syncPackages(ctx context.Context) (string, error) {
+	summary, err := importer.NewPackageLister(1, 349).Sync()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d pages, %d new, %d changed packages", summary.Pages, len(summary.New), len(summary.Changed)), nil
+}
+
+// importEndedPackages imports the questions of packages that ended recently
+func importEndedPackages(ctx context.Context) (string, error) {
+	now := time.Now()
+	packages, err := models.NewPackageRepository().GetEndedWithoutQuestions(now.Add(-importWindow), now)
+	if err != nil {
+		return "", err
+	}
+	if len(packages) > maxImportsPerRun {
+		packages = packages[:maxImportsPerRun]
+	}
+
+	imported := 0
+	var failed []string
+	for _, pkg := range packages {
+		if ctx.Err() != nil {
+			return fmt.Sprintf("imported %d packages", imported), ctx.Err()
+		}
+
+		err := importer.NewPackageParser(pkg.GotQuestionsID, false).ImportPackage()
+		if err != nil {
+			log.Printf("Failed to import package %d: %v", pkg.GotQuestionsID, err)
+			failed = append(failed, fmt.Sprint(pkg.GotQuestionsID))
+			continue
+		}
+		imported++
+	}
+
+	output := fmt.Sprintf("imported %d of %d packages", imported, len(packages))
+	if len(failed) > 0 {
+		return output, fmt.Errorf("failed to import packages %s", strings.Join(failed, ", "))
+	}
+	return output, nil
+}
+
+// findDuplicates regroups duplicate questions, including the ones imported overnight
+func findDuplicates(ctx context.Context) (string, error) {
+	summary, err := dedup.Rebuild(dedup.DefaultThreshold)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d questions, %d clusters, %d duplicates", summary.Questions, summary.Clusters, summary.Duplicates), nil
+}
+
+// closeTournaments deactivates active tournaments whose end has come
+func closeTournaments(ctx context.Context, drop DropFunc) (string, error) {
+	repo := models.NewTournamentRepository()
+	tournaments, err := repo.ListActiveEndingBefore(time.Now(), false)
+	if err != nil {
+		return "", err
+	}
+
+	var closed []string
+	for _, t := range tournaments {
+		err = repo.DeactivateTournament(t.ID)
+		if err != nil {
+			return strings.Join(closed, ", "), err
+		}
+		drop(t.ID)
+		log.Printf("Closed tournament %d (%s) at its end", t.ID, t.Name)
+		closed = append(closed, fmt.Sprintf("#%d %s", t.ID, t.Name))
+	}
+
+	if len(closed) == 0 {
+		return "nothing to close", nil
+	}
+	return "closed " + strings.Join(closed, ", "), nil
+}
+
+// sendReminders asks the voters of tournaments ending soon to vote while they can
+func sendReminders(enqueue EnqueueFunc) (string, error) {
+	repo := models.NewTournamentRepository()
+	tournaments, err := repo.ListActiveEndingBefore(time.Now().Add(reminderLead), true)
+	if err != nil {
+		return "", err
+	}
+
+	sent := 0
+	for _, t := range tournaments {
+		_, err = enqueue(&models.Broadcast{
+			TournamentID: &t.ID,
+			Audience:     models.AudienceVoters,
+			Text: fmt.Sprintf("Турнир «%s» закончится %s. Успейте проголосовать: /vote",
+				t.Name, t.EndsAt.In(time.Local).Format("02.01 в 15:04")),
+		})
+		if err != nil {
+			return fmt.Sprintf("reminded %d tournaments", sent), err
+		}
+
+		err = repo.MarkReminderSent(t.ID)
+		if err != nil {
+			return fmt.Sprintf("reminded %d tournaments", sent), err
+		}
+		sent++
+	}
+
+	return fmt.Sprintf("reminded %d tournaments", sent), nil
+}
@@ -0,0 +1,302 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"questions-vote/internal/models"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultInterval is how often the scheduler checks for due jobs
+	DefaultInterval = 30 * time.Second
+	// DefaultLockTTL is how long a job lock lasts without renewal, so that the lock of a
+	// process that died is taken over
+	DefaultLockTTL = 10 * time.Minute
+	// DefaultRetention is how long the history of job runs is kept
+	DefaultRetention = 30 * 24 * time.Hour
+)
+
+// Job is a task run on a schedule. Run returns a short summary for the run history.
+type Job struct {
+	Name     string
+	Schedule *Schedule
+	Run      func(ctx context.Context) (string, error)
+}
+
+// NewJob creates a job, panicking on an invalid schedule since jobs are defined in code
+func NewJob(name, spec string, run func(ctx context.Context) (string, error)) *Job {
+	schedule, err := ParseSchedule(spec)
+	if err != nil {
+		panic(err)
+	}
+	return &Job{Name: name, Schedule: schedule, Run: run}
+}
+
+// JobStatus is a job with its latest run and next run time
+type JobStatus struct {
+	Job     *Job
+	LastRun *models.JobRun // nil if the job never ran
+	NextRun time.Time
+}
+
+// Scheduler runs jobs on their schedules. Jobs are locked in the database, so with several
+// bot processes each run happens once, and every run is recorded.
+type Scheduler struct {
+	Interval  time.Duration
+	LockTTL   time.Duration
+	Retention time.Duration // finished runs older than this are deleted after each run
+
+	jobs    []*Job
+	owner   string
+	repo    *models.JobRunRepository
+	now     func() time.Time
+	started time.Time
+
+	mu      sync.Mutex
+	running map[string]bool
+	wg      sync.WaitGroup
+}
+
+// New creates a scheduler for the jobs, identifying this process by host name and PID
+func New(jobs ...*Job) *Scheduler {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+
+	return &Scheduler{
+		Interval:  DefaultInterval,
+		LockTTL:   DefaultLockTTL,
+		Retention: DefaultRetention,
+		jobs:      jobs,
+		owner:     fmt.Sprintf("%s:%d", host, os.Getpid()),
+		repo:      models.NewJobRunRepository(),
+		now:       time.Now,
+		running:   make(map[string]bool),
+	}
+}
+
+// Jobs returns the scheduled jobs
+func (s *Scheduler) Jobs() []*Job {
+	return s.jobs
+}
+
+// Run checks for due jobs every Interval until the context is cancelled, then waits for
+// running jobs to return
+func (s *Scheduler) Run(ctx context.Context) {
+	s.started = s.now()
+
+	interrupted, err := s.repo.MarkInterrupted(s.started)
+	if err != nil {
+		log.Printf("Failed to mark interrupted job runs: %v", err)
+	} else if interrupted > 0 {
+		log.Printf("Marked %d job runs of stopped processes as interrupted", interrupted)
+	}
+
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		s.Tick(ctx)
+
+		select {
+		case <-ctx.Done():
+			s.wg.Wait()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Tick starts every job that is due and not running
+func (s *Scheduler) Tick(ctx context.Context) {
+	now := s.now()
+	for _, job := range s.jobs {
+		due, err := s.isDue(job, now)
+		if err != nil {
+			log.Printf("Failed to check job %s: %v", job.Name, err)
+			continue
+		}
+		if !due || !s.markRunning(job.Name) {
+			continue
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer s.markDone(job.Name)
+			s.runLocked(ctx, job)
+		}()
+	}
+}
+
+// Wait waits for the jobs started by Tick to return
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
+
+// isDue reports whether the next run after the latest one, or after the scheduler started if
+// the job never ran, has come
+func (s *Scheduler) isDue(job *Job, now time.Time) (bool, error) {
+	next, err := s.nextRun(job)
+	if err != nil {
+		return false, err
+	}
+	return !next.IsZero() && !next.After(now), nil
+}
+
+// nextRun returns when the job runs next
+func (s *Scheduler) nextRun(job *Job) (time.Time, error) {
+	last, err := s.repo.FindLatest(job.Name)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	base := s.started
+	if last != nil {
+		base = last.StartedAt
+	}
+	if base.IsZero() {
+		base = s.now()
+	}
+	return job.Schedule.Next(base.In(time.Local)), nil
+}
+
+func (s *Scheduler) markRunning(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.running[name] {
+		return false
+	}
+	s.running[name] = true
+	return true
+}
+
+func (s *Scheduler) markDone(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.running, name)
+}
+
+// runLocked runs a job if no other process holds its lock and it is still due, keeping
+// the lock renewed while the job runs
+func (s *Scheduler) runLocked(ctx context.Context, job *Job) {
+	now := s.now()
+	locked, err := s.repo.TryLock(job.Name, s.owner, now, now.Add(s.LockTTL))
+	if err != nil {
+		log.Printf("Failed to lock job %s: %v", job.Name, err)
+		return
+	}
+	if !locked {
+		return
+	}
+	defer func() {
+		if err := s.repo.Unlock(job.Name, s.owner); err != nil {
+			log.Printf("Failed to unlock job %s: %v", job.Name, err)
+		}
+	}()
+
+	// Another process may have run the job between the check and the lock
+	due, err := s.isDue(job, now)
+	if err != nil || !due {
+		return
+	}
+
+	renewCtx, stopRenew := context.WithCancel(ctx)
+	defer stopRenew()
+	go s.renewLock(renewCtx, job.Name)
+
+	s.execute(ctx, job)
+}
+
+// renewLock extends the lock of a running job until the context is cancelled
+func (s *Scheduler) renewLock(ctx context.Context, name string) {
+	ticker := time.NewTicker(s.LockTTL / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.repo.RenewLock(name, s.owner, s.now().Add(s.LockTTL)); err != nil {
+				log.Printf("Failed to renew lock of job %s: %v", name, err)
+			}
+		}
+	}
+}
+
+// execute runs a job and records the run
+func (s *Scheduler) execute(ctx context.Context, job *Job) {
+	runID, err := s.repo.Start(job.Name, s.owner, s.now())
+	if err != nil {
+		log.Printf("Failed to record start of job %s: %v", job.Name, err)
+		return
+	}
+	log.Printf("Running job %s", job.Name)
+
+	output, err := s.runRecovered(ctx, job)
+
+	status, errorText := models.JobSucceeded, ""
+	switch {
+	case err != nil && ctx.Err() != nil:
+		status, errorText = models.JobInterrupted, err.Error()
+	case err != nil:
+		status, errorText = models.JobFailed, err.Error()
+	}
+	log.Printf("Job %s %s: %s %s", job.Name, status, output, errorText)
+
+	err = s.repo.Finish(runID, status, output, errorText, s.now())
+	if err != nil {
+		log.Printf("Failed to record end of job %s: %v", job.Name, err)
+	}
+
+	_, err = s.repo.DeleteFinishedBefore(job.Name, s.now().Add(-s.Retention))
+	if err != nil {
+		log.Printf("Failed to delete old runs of job %s: %v", job.Name, err)
+	}
+}
+
+// runRecovered runs a job, turning a panic into an error so that one job cannot stop the bot
+func (s *Scheduler) runRecovered(ctx context.Context, job *Job) (output string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return job.Run(ctx)
+}
+
+// Status returns every job with its latest run and next run time
+func (s *Scheduler) Status() ([]*JobStatus, error) {
+	var statuses []*JobStatus
+	for _, job := range s.jobs {
+		last, err := s.repo.FindLatest(job.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		next, err := s.nextRun(job)
+		if err != nil {
+			return nil, err
+		}
+
+		statuses = append(statuses, &JobStatus{Job: job, LastRun: last, NextRun: next})
+	}
+	return statuses, nil
+}
+
+// History returns the latest runs of a job, newest first
+func (s *Scheduler) History(name string, limit int) ([]*models.JobRun, error) {
+	for _, job := range s.jobs {
+		if job.Name == name {
+			return s.repo.ListByJob(name, limit)
+		}
+	}
+	return nil, fmt.Errorf("unknown job %q", name)
+}
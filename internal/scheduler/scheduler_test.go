@@ -0,0 +1,281 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"questions-vote/internal/models"
+	"questions-vote/internal/testutil"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestScheduler creates a scheduler that started at 02:00 and whose clock reads now
+func newTestScheduler(now *time.Time, jobs ...*Job) *Scheduler {
+	s := New(jobs...)
+	s.now = func() time.Time { return *now }
+	s.started = time.Date(2024, 1, 1, 2, 0, 0, 0, time.Local)
+	return s
+}
+
+func TestSchedulerRunsDueJobOnce(t *testing.T) {
+	testutil.OpenDB(t)
+
+	var runs atomic.Int32
+	job := NewJob("nightly", "0 3 * * *", func(ctx context.Context) (string, error) {
+		runs.Add(1)
+		return "done", nil
+	})
+
+	now := time.Date(2024, 1, 1, 2, 59, 0, 0, time.Local)
+	s := newTestScheduler(&now, job)
+	ctx := context.Background()
+
+	s.Tick(ctx)
+	s.Wait()
+	if runs.Load() != 0 {
+		t.Fatalf("Job ran %d times before it was due", runs.Load())
+	}
+
+	now = time.Date(2024, 1, 1, 3, 0, 30, 0, time.Local)
+	s.Tick(ctx)
+	s.Wait()
+	s.Tick(ctx)
+	s.Wait()
+	if runs.Load() != 1 {
+		t.Fatalf("Job ran %d times, want 1", runs.Load())
+	}
+
+	history, err := s.History("nightly", 10)
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	if len(history) != 1 || history[0].Status != models.JobSucceeded || history[0].Output != "done" || history[0].FinishedAt == nil {
+		t.Fatalf("Unexpected history: %+v", history)
+	}
+
+	statuses, err := s.Status()
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	want := time.Date(2024, 1, 2, 3, 0, 0, 0, time.Local)
+	if len(statuses) != 1 || statuses[0].LastRun == nil || !statuses[0].NextRun.Equal(want) {
+		t.Fatalf("Unexpected status: %+v, want next run %v", statuses[0], want)
+	}
+
+	// The lock is released after the run
+	locked, err := models.NewJobRunRepository().TryLock("nightly", "other", now, now.Add(time.Minute))
+	if err != nil || !locked {
+		t.Fatalf("TryLock after run = %v, %v, want the lock to be free", locked, err)
+	}
+}
+
+func TestSchedulerSkipsJobLockedByAnotherProcess(t *testing.T) {
+	testutil.OpenDB(t)
+
+	var runs atomic.Int32
+	job := NewJob("nightly", "0 3 * * *", func(ctx context.Context) (string, error) {
+		runs.Add(1)
+		return "", nil
+	})
+
+	now := time.Date(2024, 1, 1, 3, 1, 0, 0, time.Local)
+	s := newTestScheduler(&now, job)
+
+	repo := models.NewJobRunRepository()
+	locked, err := repo.TryLock("nightly", "other", now, now.Add(5*time.Minute))
+	if err != nil || !locked {
+		t.Fatalf("TryLock = %v, %v", locked, err)
+	}
+
+	s.Tick(context.Background())
+	s.Wait()
+	if runs.Load() != 0 {
+		t.Fatalf("Job ran while another process held its lock")
+	}
+
+	// The lock of a process that died expires
+	now = now.Add(10 * time.Minute)
+	s.Tick(context.Background())
+	s.Wait()
+	if runs.Load() != 1 {
+		t.Fatalf("Job ran %d times after the lock expired, want 1", runs.Load())
+	}
+}
+
+func TestSchedulerRecordsFailures(t *testing.T) {
+	testutil.OpenDB(t)
+
+	failing := NewJob("failing", "* * * * *", func(ctx context.Context) (string, error) {
+		return "half done", errors.New("site is down")
+	})
+	panicking := NewJob("panicking", "* * * * *", func(ctx context.Context) (string, error) {
+		panic("boom")
+	})
+
+	now := time.Date(2024, 1, 1, 2, 5, 0, 0, time.Local)
+	s := newTestScheduler(&now, failing, panicking)
+	s.Tick(context.Background())
+	s.Wait()
+
+	history, err := s.History("failing", 10)
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	if len(history) != 1 || history[0].Status != models.JobFailed || history[0].Error != "site is down" || history[0].Output != "half done" {
+		t.Fatalf("Unexpected history of failing job: %+v", history)
+	}
+
+	history, err = s.History("panicking", 10)
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	if len(history) != 1 || history[0].Status != models.JobFailed || !strings.Contains(history[0].Error, "boom") {
+		t.Fatalf("Unexpected history of panicking job: %+v", history)
+	}
+
+	_, err = s.History("unknown", 10)
+	if err == nil {
+		t.Fatal("History of an unknown job succeeded")
+	}
+}
+
+func TestMarkInterrupted(t *testing.T) {
+	testutil.OpenDB(t)
+
+	repo := models.NewJobRunRepository()
+	now := time.Now()
+	_, err := repo.Start("nightly", "dead:1", now.Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	interrupted, err := repo.MarkInterrupted(now)
+	if err != nil {
+		t.Fatalf("MarkInterrupted failed: %v", err)
+	}
+	if interrupted != 1 {
+		t.Fatalf("Marked %d runs as interrupted, want 1", interrupted)
+	}
+
+	run, err := repo.FindLatest("nightly")
+	if err != nil {
+		t.Fatalf("FindLatest failed: %v", err)
+	}
+	if run.Status != models.JobInterrupted || run.FinishedAt == nil {
+		t.Fatalf("Unexpected run: %+v", run)
+	}
+}
+
+func TestSchedulerDeletesOldRuns(t *testing.T) {
+	testutil.OpenDB(t)
+
+	repo := models.NewJobRunRepository()
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.Local)
+	for _, job := range []string{"nightly", "other"} {
+		for _, age := range []time.Duration{60, 40, 10} {
+			startedAt := now.Add(-age * 24 * time.Hour)
+			runID, err := repo.Start(job, "old:1", startedAt)
+			if err != nil {
+				t.Fatalf("Start failed: %v", err)
+			}
+			err = repo.Finish(runID, models.JobSucceeded, "", "", startedAt.Add(time.Minute))
+			if err != nil {
+				t.Fatalf("Finish failed: %v", err)
+			}
+		}
+	}
+
+	job := NewJob("nightly", "* * * * *", func(ctx context.Context) (string, error) {
+		return "done", nil
+	})
+	s := newTestScheduler(&now, job)
+	s.Tick(context.Background())
+	s.Wait()
+
+	history, err := s.History("nightly", 10)
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	if len(history) != 2 || history[0].Output != "done" || !history[1].StartedAt.Equal(now.Add(-10*24*time.Hour)) {
+		t.Fatalf("Expected the new run and the one from 10 days ago, got %+v", history)
+	}
+
+	others, err := repo.ListByJob("other", 10)
+	if err != nil {
+		t.Fatalf("ListByJob failed: %v", err)
+	}
+	if len(others) != 3 {
+		t.Fatalf("Expected the runs of other jobs to be kept, got %d", len(others))
+	}
+}
+
+func TestTournamentJobs(t *testing.T) {
+	testutil.OpenDB(t)
+
+	repo := models.NewTournamentRepository()
+	ended := time.Now().Add(-time.Minute)
+	endingSoon := time.Now().Add(24 * time.Hour)
+
+	endedID, err := repo.Create(&models.Tournament{Name: "Прошлый", EndsAt: &ended})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	soonID, err := repo.Create(&models.Tournament{Name: "Текущий", EndsAt: &endingSoon})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	for _, id := range []int{endedID, soonID} {
+		err = repo.ActivateTournament(id)
+		if err != nil {
+			t.Fatalf("ActivateTournament failed: %v", err)
+		}
+	}
+
+	var dropped []int
+	output, err := closeTournaments(context.Background(), func(id int) { dropped = append(dropped, id) })
+	if err != nil {
+		t.Fatalf("closeTournaments failed: %v", err)
+	}
+	if !strings.Contains(output, "Прошлый") {
+		t.Errorf("closeTournaments output = %q", output)
+	}
+	if len(dropped) != 1 || dropped[0] != endedID {
+		t.Errorf("Expected the ratings of the ended tournament to be dropped, got %v", dropped)
+	}
+
+	tournament, err := repo.FindByID(endedID)
+	if err != nil {
+		t.Fatalf("FindByID failed: %v", err)
+	}
+	if tournament.Active {
+		t.Error("Ended tournament is still active")
+	}
+	tournament, err = repo.FindByID(soonID)
+	if err != nil {
+		t.Fatalf("FindByID failed: %v", err)
+	}
+	if !tournament.Active {
+		t.Error("Running tournament was closed")
+	}
+
+	var broadcasts []*models.Broadcast
+	enqueue := func(b *models.Broadcast) (int, error) {
+		broadcasts = append(broadcasts, b)
+		return len(broadcasts), nil
+	}
+
+	for i := 0; i < 2; i++ {
+		_, err = sendReminders(enqueue)
+		if err != nil {
+			t.Fatalf("sendReminders failed: %v", err)
+		}
+	}
+	if len(broadcasts) != 1 {
+		t.Fatalf("Sent %d reminders, want 1", len(broadcasts))
+	}
+	if *broadcasts[0].TournamentID != soonID || broadcasts[0].Audience != models.AudienceVoters || !strings.Contains(broadcasts[0].Text, "Текущий") {
+		t.Errorf("Unexpected reminder: %+v", broadcasts[0])
+	}
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"fmt"
+	"questions-vote/internal/elo"
+	"questions-vote/internal/models"
+)
+
+// AdminService handles operations available to organizers
+type AdminService struct {
+	tournamentRepo *models.TournamentRepository
+	reportRepo     *models.ReportRepository
+	broadcastRepo  *models.BroadcastRepository
+	eloRegistry    *elo.Registry
+}
+
+// NewAdminService creates a new admin service
+func NewAdminService(eloRegistry *elo.Registry) *AdminService {
+	return &AdminService{
+		tournamentRepo: models.NewTournamentRepository(),
+		reportRepo:     models.NewReportRepository(),
+		broadcastRepo:  models.NewBroadcastRepository(),
+		eloRegistry:    eloRegistry,
+	}
+}
+
+// ListTournaments returns all tournaments, newest first
+func (s *AdminService) ListTournaments() ([]*models.Tournament, error) {
+	return s.tournamentRepo.ListAllTournaments()
+}
+
+// ActivateTournament activates a tournament. Only one tournament can be active at a time,
+// so activating a second one fails until the first is deactivated.
+func (s *AdminService) ActivateTournament(tournamentID int) (*models.Tournament, error) {
+	tournament, err := s.tournamentRepo.FindByID(tournamentID)
+	if err != nil {
+		return nil, err
+	}
+
+	active, err := s.tournamentRepo.ListActiveTournaments()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list active tournaments: %w", err)
+	}
+	for _, t := range active {
+		if t.ID != tournamentID {
+			return nil, fmt.Errorf("tournament %d (%s) is already active", t.ID, t.Name)
+		}
+	}
+
+	err = s.tournamentRepo.ActivateTournament(tournamentID)
+	if err != nil {
+		return nil, err
+	}
+	s.eloRegistry.Drop(tournamentID)
+
+	tournament.Active = true
+	return tournament, nil
+}
+
+// DeactivateTournament deactivates a tournament
+func (s *AdminService) DeactivateTournament(tournamentID int) (*models.Tournament, error) {
+	tournament, err := s.tournamentRepo.FindByID(tournamentID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.tournamentRepo.DeactivateTournament(tournamentID)
+	if err != nil {
+		return nil, err
+	}
+	s.eloRegistry.Drop(tournamentID)
+
+	tournament.Active = false
+	return tournament, nil
+}
+
+// GetStatistics returns ELO statistics of a tournament, or of the active one when tournamentID is zero
+func (s *AdminService) GetStatistics(tournamentID int) (*models.Tournament, map[string]interface{}, error) {
+	var tournament *models.Tournament
+	var err error
+	if tournamentID == 0 {
+		tournament, err = s.tournamentRepo.FindActiveTournament()
+	} else {
+		tournament, err = s.tournamentRepo.FindByID(tournamentID)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stats, err := s.eloRegistry.Get(tournament).GetStatistics()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get statistics of tournament %d: %w", tournament.ID, err)
+	}
+
+	return tournament, stats, nil
+}
+
+// GetRecentReports returns the latest user reports
+func (s *AdminService) GetRecentReports(limit int) ([]*models.Report, error) {
+	return s.reportRepo.GetRecent(limit)
+}
+
+// GetActiveTournament returns the active tournament
+func (s *AdminService) GetActiveTournament() (*models.Tournament, error) {
+	return s.tournamentRepo.FindActiveTournament()
+}
+
+// ListRecentBroadcasts returns the latest broadcasts
+func (s *AdminService) ListRecentBroadcasts(limit int) ([]*models.Broadcast, error) {
+	return s.broadcastRepo.ListRecent(limit)
+}
+
+// GetBroadcastProgress counts the deliveries of a broadcast by status
+func (s *AdminService) GetBroadcastProgress(broadcastID int) (models.BroadcastProgress, error) {
+	return s.broadcastRepo.GetProgress(broadcastID)
+}
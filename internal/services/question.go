@@ -2,6 +2,7 @@ package services
 
 import (
 	"fmt"
+	"math/rand"
 	"questions-vote/internal/elo"
 	"questions-vote/internal/models"
 )
@@ -10,24 +11,30 @@ import (
 type QuestionService struct {
 	questionRepo   *models.QuestionRepository
 	tournamentRepo *models.TournamentRepository
+	authorRepo     *models.AuthorRepository
+	eloRegistry    *elo.Registry
 }
 
 // NewQuestionService creates a new question service
-func NewQuestionService() *QuestionService {
+func NewQuestionService(eloRegistry *elo.Registry) *QuestionService {
 	return &QuestionService{
 		questionRepo:   models.NewQuestionRepository(),
 		tournamentRepo: models.NewTournamentRepository(),
+		authorRepo:     models.NewAuthorRepository(),
+		eloRegistry:    eloRegistry,
 	}
 }
 
-// GetQuestions returns a pair of questions for voting using ELO selection
-func (s *QuestionService) GetQuestions() ([]*models.Question, error) {
+// GetQuestions returns a pair of questions for voting using ELO selection.
+// The pair is shown in random order so that voters' preference for the first
+// question does not favour the question the selector happened to pick first.
+func (s *QuestionService) GetQuestions() (*models.QuestionPair, error) {
 	tournament, err := s.tournamentRepo.FindActiveTournament()
 	if err != nil {
 		return nil, fmt.Errorf("failed to find active tournament: %w", err)
 	}
 
-	eloSystem := elo.New(tournament)
+	eloSystem := s.eloRegistry.Get(tournament)
 	q1ID, q2ID, err := eloSystem.SelectPair()
 	if err != nil {
 		return nil, fmt.Errorf("failed to select question pair: %w", err)
@@ -42,7 +49,17 @@ func (s *QuestionService) GetQuestions() ([]*models.Question, error) {
 		return nil, fmt.Errorf("expected 2 questions, got %d", len(questions))
 	}
 
-	return questions, nil
+	pair := &models.QuestionPair{First: questions[0], Second: questions[1]}
+	if pair.First.ID != q1ID {
+		pair.First, pair.Second = pair.Second, pair.First
+	}
+
+	if rand.Intn(2) == 1 {
+		pair.First, pair.Second = pair.Second, pair.First
+		pair.Swapped = true
+	}
+
+	return pair, nil
 }
 
 // FindQuestions finds questions by IDs
@@ -50,6 +67,24 @@ func (s *QuestionService) FindQuestions(ids []int) ([]*models.Question, error) {
 	return s.questionRepo.FindByIDs(ids)
 }
 
+// RememberImageFile saves the Telegram file ID of a handout picture, so that it is not uploaded again
+func (s *QuestionService) RememberImageFile(questionID int, fileID, fileType string) error {
+	return s.questionRepo.SetImageFile(questionID, fileID, fileType)
+}
+
+// GetCredits returns who wrote and edited the questions, in the given order
+func (s *QuestionService) GetCredits(ids ...int) ([]*models.QuestionCredits, error) {
+	var credits []*models.QuestionCredits
+	for _, id := range ids {
+		c, err := s.authorRepo.GetCredits(id)
+		if err != nil {
+			return nil, err
+		}
+		credits = append(credits, c)
+	}
+	return credits, nil
+}
+
 // GetQuestionsCount returns the number of questions in the active tournament
 func (s *QuestionService) GetQuestionsCount() (int, error) {
 	tournament, err := s.tournamentRepo.FindActiveTournament()
@@ -58,3 +93,14 @@ func (s *QuestionService) GetQuestionsCount() (int, error) {
 	}
 	return tournament.QuestionsCount, nil
 }
+
+// Search finds questions by text with their ratings in the active tournament, if there is one
+func (s *QuestionService) Search(text string, limit int) ([]*models.SearchResult, error) {
+	tournamentID := 0
+	tournament, err := s.tournamentRepo.FindActiveTournament()
+	if err == nil {
+		tournamentID = tournament.ID
+	}
+
+	return s.questionRepo.Search(text, tournamentID, limit)
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"questions-vote/internal/models"
+)
+
+// Access errors returned when a user may not vote
+var (
+	ErrBanned            = errors.New("user is banned")
+	ErrNotInvited        = errors.New("tournament is invite-only and the user is not invited")
+	ErrInvalidInviteCode = errors.New("invalid invite code")
+)
+
+// UserService handles users and their access to tournaments
+type UserService struct {
+	userRepo       *models.UserRepository
+	tournamentRepo *models.TournamentRepository
+	reportRepo     *models.ReportRepository
+}
+
+// NewUserService creates a new user service
+func NewUserService() *UserService {
+	return &UserService{
+		userRepo:       models.NewUserRepository(),
+		tournamentRepo: models.NewTournamentRepository(),
+		reportRepo:     models.NewReportRepository(),
+	}
+}
+
+// Touch registers a user on first contact and returns the stored user
+func (s *UserService) Touch(userID int64, username, firstName string) (*models.User, error) {
+	user, err := s.userRepo.Touch(userID, username, firstName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to register user %d: %w", userID, err)
+	}
+	return user, nil
+}
+
+// CheckAccess returns nil if the user may vote in the active tournament
+func (s *UserService) CheckAccess(user *models.User) error {
+	if user.Status == models.StatusBanned {
+		return ErrBanned
+	}
+
+	tournament, err := s.tournamentRepo.FindActiveTournament()
+	if err != nil {
+		return fmt.Errorf("failed to find active tournament: %w", err)
+	}
+
+	return checkTournamentAccess(s.tournamentRepo, user, tournament)
+}
+
+// Join adds the user to the allowlist of the active tournament if the invite code matches
+func (s *UserService) Join(userID int64, code string) (*models.Tournament, error) {
+	tournament, err := s.tournamentRepo.FindActiveTournament()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find active tournament: %w", err)
+	}
+
+	if !tournament.InviteOnly {
+		return tournament, nil
+	}
+
+	if tournament.InviteCode == "" || code != tournament.InviteCode {
+		return nil, ErrInvalidInviteCode
+	}
+
+	err = s.tournamentRepo.AllowUser(tournament.ID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return tournament, nil
+}
+
+// Report stores a message from a user to the organizers, linked to the active tournament if there is one
+func (s *UserService) Report(userID int64, text string) error {
+	var tournamentID *int
+	tournament, err := s.tournamentRepo.FindActiveTournament()
+	if err == nil {
+		tournamentID = &tournament.ID
+	}
+
+	return s.reportRepo.Create(userID, tournamentID, text)
+}
+
+// SetNotifications turns broadcasts to a user on or off
+func (s *UserService) SetNotifications(userID int64, enabled bool) error {
+	return s.userRepo.SetNotifications(userID, enabled)
+}
+
+// checkTournamentAccess returns ErrBanned or ErrNotInvited if the user may not vote in the tournament
+func checkTournamentAccess(tournamentRepo *models.TournamentRepository, user *models.User, tournament *models.Tournament) error {
+	if user.Status == models.StatusBanned {
+		return ErrBanned
+	}
+
+	if !tournament.InviteOnly || user.IsAdmin() {
+		return nil
+	}
+
+	allowed, err := tournamentRepo.IsUserAllowed(tournament.ID, user.ID)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return ErrNotInvited
+	}
+
+	return nil
+}
@@ -5,36 +5,66 @@ import (
 	"log"
 	"questions-vote/internal/elo"
 	"questions-vote/internal/models"
+	"questions-vote/internal/quality"
 )
 
 // VoteService handles voting operations
 type VoteService struct {
-	voteRepo       *models.VoteRepository
-	tournamentRepo *models.TournamentRepository
+	voteRepo         *models.VoteRepository
+	tournamentRepo   *models.TournamentRepository
+	voterQualityRepo *models.VoterQualityRepository
+	userRepo         *models.UserRepository
+	eloRegistry      *elo.Registry
 }
 
+// qualityRefreshVotes is how often, in votes of a user, their quality is recomputed
+const qualityRefreshVotes = 10
+
 // NewVoteService creates a new vote service
-func NewVoteService() *VoteService {
+func NewVoteService(eloRegistry *elo.Registry) *VoteService {
 	return &VoteService{
-		voteRepo:       models.NewVoteRepository(),
-		tournamentRepo: models.NewTournamentRepository(),
+		voteRepo:         models.NewVoteRepository(),
+		tournamentRepo:   models.NewTournamentRepository(),
+		voterQualityRepo: models.NewVoterQualityRepository(),
+		userRepo:         models.NewUserRepository(),
+		eloRegistry:      eloRegistry,
 	}
 }
 
-// SaveVote saves a user's vote
-func (s *VoteService) SaveVote(userID int64, question1ID, question2ID int, selectedID *int) error {
+// SaveVote saves a user's vote. question1ID is the question that was shown first,
+// swapped tells whether that order is the reverse of the selected one.
+// Votes of banned and uninvited users are rejected, votes of shadow-banned users
+// are recorded but do not change ratings.
+func (s *VoteService) SaveVote(userID int64, question1ID, question2ID int, selectedID *int, swapped bool) error {
 	tournament, err := s.tournamentRepo.FindActiveTournament()
 	if err != nil {
 		log.Printf("Failed to get active tournament: %v", err)
 		return err
 	}
 
-	err = s.voteRepo.Create(userID, question1ID, question2ID, tournament.ID, selectedID)
+	user, err := s.userRepo.Find(userID)
+	if err != nil {
+		return fmt.Errorf("failed to get voter: %w", err)
+	}
+
+	err = checkTournamentAccess(s.tournamentRepo, user, tournament)
+	if err != nil {
+		log.Printf("Rejected vote of user %d: %v", userID, err)
+		return err
+	}
+
+	excluded := user.Status == models.StatusShadowBanned
+	err = s.voteRepo.Create(userID, question1ID, question2ID, tournament.ID, selectedID, swapped, excluded)
 	if err != nil {
 		log.Printf("Failed to save vote: %v", err)
 		return err
 	}
 
+	if excluded {
+		log.Printf("Recorded vote of shadow-banned user %d without counting it", userID)
+		return nil
+	}
+
 	if selectedID != nil {
 		log.Printf("Saving vote: user=%d, q1=%d, q2=%d, selected=%d", userID, question1ID, question2ID, *selectedID)
 	} else {
@@ -49,8 +79,22 @@ func (s *VoteService) SaveVote(userID int64, question1ID, question2ID int, selec
 			loserID = question2ID
 		}
 
-		eloSystem := elo.New(tournament)
-		err = eloSystem.RecordWinner(*selectedID, loserID)
+		weight := 1.0
+		if tournament.WeightVotes {
+			weight, err = s.voterQualityRepo.GetReliability(tournament.ID, userID)
+			if err != nil {
+				log.Printf("Failed to get reliability of user %d, counting the vote fully: %v", userID, err)
+				weight = 1
+			}
+		}
+
+		eloSystem := s.eloRegistry.Get(tournament)
+		err = eloSystem.Record(elo.MatchResult{
+			WinnerID:         *selectedID,
+			LoserID:          loserID,
+			WinnerShownFirst: *selectedID == question1ID,
+			Weight:           weight,
+		})
 		if err != nil {
 			log.Printf("Failed to record ELO winner: %v", err)
 		} else {
@@ -58,9 +102,36 @@ func (s *VoteService) SaveVote(userID int64, question1ID, question2ID int, selec
 		}
 	}
 
+	s.refreshVoterQuality(tournament.ID, userID)
+
 	return nil
 }
 
+// refreshVoterQuality recomputes the quality of a user every qualityRefreshVotes votes
+func (s *VoteService) refreshVoterQuality(tournamentID int, userID int64) {
+	count, err := s.voteRepo.GetVoteCount(userID, tournamentID)
+	if err != nil {
+		log.Printf("Failed to get vote count of user %d: %v", userID, err)
+		return
+	}
+
+	if count < quality.MinVotes || count%qualityRefreshVotes != 0 {
+		return
+	}
+
+	qualities, err := quality.Refresh(tournamentID, userID)
+	if err != nil {
+		log.Printf("Failed to refresh quality of user %d: %v", userID, err)
+		return
+	}
+
+	for _, q := range qualities {
+		if q.Flagged() {
+			log.Printf("User %d flagged in tournament %d: %v (reliability %.2f)", q.UserID, tournamentID, q.Flags, q.Reliability)
+		}
+	}
+}
+
 // GetQuestionStats returns statistics for questions using ELO system
 func (s *VoteService) GetQuestionStats(question1ID, question2ID int) ([]models.QuestionStats, error) {
 	tournament, err := s.tournamentRepo.FindActiveTournament()
@@ -68,7 +139,7 @@ func (s *VoteService) GetQuestionStats(question1ID, question2ID int) ([]models.Q
 		return nil, fmt.Errorf("failed to find active tournament: %w", err)
 	}
 
-	eloSystem := elo.New(tournament)
+	eloSystem := s.eloRegistry.Get(tournament)
 	stats, err := eloSystem.GetQuestionsStats(question1ID, question2ID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get question stats from ELO: %w", err)
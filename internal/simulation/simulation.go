@@ -0,0 +1,262 @@
+package simulation
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"questions-vote/internal/db"
+	"questions-vote/internal/elo"
+	"questions-vote/internal/models"
+	"sort"
+)
+
+// Config describes a simulated tournament
+type Config struct {
+	Questions       int
+	Votes           int
+	CheckEvery      int
+	TargetPrecision float64
+	QualitySpread   float64
+	Seed            int64
+	Voters          VoterModel
+	Tournament      models.Tournament
+}
+
+// VoterModel describes how simulated voters deviate from the ideal voter
+type VoterModel struct {
+	Count        int     // number of distinct voters casting votes in turn
+	PositionBias float64 // added to the probability of picking the first question
+	SkipRate     float64 // probability of pressing "Не могу выбрать"
+	MinAccuracy  float64 // share of votes a voter casts by quality rather than at random
+	MaxAccuracy  float64
+}
+
+// Checkpoint is the state of the ranking after a number of votes
+type Checkpoint struct {
+	Votes        int
+	Skips        int
+	Precision    float64
+	TopNRetained float64
+}
+
+// Result holds the outcome of a simulation
+type Result struct {
+	Strategy       string
+	Checkpoints    []Checkpoint
+	VotesToRecover int
+}
+
+// DefaultConfig returns a configuration with the parameters tournament_manager uses
+func DefaultConfig() Config {
+	return Config{
+		Questions:       200,
+		Votes:           4000,
+		CheckEvery:      100,
+		TargetPrecision: 0.9,
+		QualitySpread:   200,
+		Seed:            1,
+		Voters: VoterModel{
+			Count:       50,
+			MinAccuracy: 1,
+			MaxAccuracy: 1,
+		},
+		Tournament: models.Tournament{
+			Name:                   "Simulation",
+			InitialK:               64.0,
+			MinimumK:               16.0,
+			StdDevMultiplier:       1.5,
+			InitialPhaseMatches:    5,
+			TransitionPhaseMatches: 10,
+			TopN:                   20,
+			BandSize:               200,
+			SelectionStrategy:      models.SelectionRandom,
+		},
+	}
+}
+
+// Run simulates voting in an in-memory tournament whose questions have a known latent quality.
+// An accurate voter prefers the better question with the probability the ELO model predicts.
+// It replaces the global database connection for the duration of the run.
+func Run(cfg Config) (*Result, error) {
+	if cfg.Questions < 2 {
+		return nil, fmt.Errorf("need at least 2 questions, got %d", cfg.Questions)
+	}
+
+	if cfg.Tournament.TopN <= 0 || cfg.Tournament.TopN > cfg.Questions {
+		return nil, fmt.Errorf("top N must be between 1 and %d, got %d", cfg.Questions, cfg.Tournament.TopN)
+	}
+
+	if cfg.CheckEvery <= 0 {
+		cfg.CheckEvery = cfg.Votes
+	}
+
+	if cfg.Voters.Count <= 0 {
+		cfg.Voters.Count = 1
+	}
+
+	err := db.InitializeWithPath(":memory:")
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	err = db.Migrate()
+	if err != nil {
+		return nil, fmt.Errorf("failed to migrate in-memory database: %w", err)
+	}
+
+	tournament := cfg.Tournament
+	tournament.ID, err = models.NewTournamentRepository().Create(&tournament)
+	if err != nil {
+		return nil, err
+	}
+
+	rng := rand.New(rand.NewSource(cfg.Seed))
+	quality := make(map[int]float64, cfg.Questions)
+	questionIDs := make([]int, cfg.Questions)
+	for i := range questionIDs {
+		questionIDs[i] = i + 1
+		quality[i+1] = rng.NormFloat64() * cfg.QualitySpread
+	}
+
+	err = models.NewTournamentQuestionRepository().CreateTournamentQuestions(tournament.ID, questionIDs, 1500.0)
+	if err != nil {
+		return nil, err
+	}
+
+	accuracy := make([]float64, cfg.Voters.Count)
+	for i := range accuracy {
+		accuracy[i] = cfg.Voters.MinAccuracy + rng.Float64()*(cfg.Voters.MaxAccuracy-cfg.Voters.MinAccuracy)
+	}
+
+	truth := trueTop(quality, tournament.TopN)
+	eloSystem := elo.New(&tournament)
+	eloSystem.Seed(cfg.Seed)
+	result := &Result{Strategy: tournament.SelectionStrategy}
+	skips := 0
+
+	for vote := 1; vote <= cfg.Votes; vote++ {
+		q1, q2, err := eloSystem.SelectPair()
+		if err != nil {
+			return nil, fmt.Errorf("failed to select pair on vote %d: %w", vote, err)
+		}
+
+		if rng.Float64() < cfg.Voters.SkipRate {
+			skips++
+		} else {
+			voterAccuracy := accuracy[vote%len(accuracy)]
+			winner, loser := q1, q2
+			if rng.Float64() >= firstWinProbability(quality[q1], quality[q2], voterAccuracy, cfg.Voters.PositionBias) {
+				winner, loser = q2, q1
+			}
+
+			err = eloSystem.RecordWinner(winner, loser)
+			if err != nil {
+				return nil, fmt.Errorf("failed to record winner on vote %d: %w", vote, err)
+			}
+		}
+
+		if vote%cfg.CheckEvery != 0 && vote != cfg.Votes {
+			continue
+		}
+
+		checkpoint, err := measure(eloSystem, truth)
+		if err != nil {
+			return nil, err
+		}
+		checkpoint.Votes = vote
+		checkpoint.Skips = skips
+
+		result.Checkpoints = append(result.Checkpoints, *checkpoint)
+		if result.VotesToRecover == 0 && checkpoint.Precision >= cfg.TargetPrecision {
+			result.VotesToRecover = vote
+		}
+	}
+
+	return result, nil
+}
+
+// FinalPrecision returns the precision at the last checkpoint
+func (r *Result) FinalPrecision() float64 {
+	if len(r.Checkpoints) == 0 {
+		return 0
+	}
+	return r.Checkpoints[len(r.Checkpoints)-1].Precision
+}
+
+// FinalTopNRetained returns the share of the true top N in contention at the last checkpoint
+func (r *Result) FinalTopNRetained() float64 {
+	if len(r.Checkpoints) == 0 {
+		return 0
+	}
+	return r.Checkpoints[len(r.Checkpoints)-1].TopNRetained
+}
+
+// winProbability returns the probability that a question of quality a is preferred to one of quality b
+func winProbability(a, b float64) float64 {
+	return 1.0 / (1.0 + math.Pow(10, (b-a)/400))
+}
+
+// firstWinProbability returns the probability that a voter picks the first of two questions
+func firstWinProbability(first, second, accuracy, positionBias float64) float64 {
+	p := accuracy*winProbability(first, second) + (1-accuracy)*0.5 + positionBias
+	return math.Max(0, math.Min(1, p))
+}
+
+// trueTop returns the IDs of the n questions with the highest latent quality
+func trueTop(quality map[int]float64, n int) map[int]bool {
+	ids := make([]int, 0, len(quality))
+	for id := range quality {
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		return quality[ids[i]] > quality[ids[j]]
+	})
+
+	top := make(map[int]bool, n)
+	for _, id := range ids[:n] {
+		top[id] = true
+	}
+	return top
+}
+
+// measure compares the current ranking with the true top N.
+// Precision is the share of the current top N that belongs to the true top N.
+// TopNRetained is the share of the true top N that is still above the selection threshold,
+// i.e. has not been pushed out of contention.
+func measure(eloSystem *elo.ELO, truth map[int]bool) (*Checkpoint, error) {
+	top, err := eloSystem.GetTopItems(len(truth))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get top items: %w", err)
+	}
+
+	hits := 0
+	for _, tq := range top {
+		if truth[tq.QuestionID] {
+			hits++
+		}
+	}
+
+	threshold, err := eloSystem.CalculateThreshold()
+	if err != nil {
+		return nil, fmt.Errorf("failed to calculate threshold: %w", err)
+	}
+
+	contenders, err := eloSystem.GetTopItems(math.MaxInt32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ranking: %w", err)
+	}
+
+	retained := 0
+	for _, tq := range contenders {
+		if tq.Rating >= threshold && truth[tq.QuestionID] {
+			retained++
+		}
+	}
+
+	return &Checkpoint{
+		Precision:    float64(hits) / float64(len(truth)),
+		TopNRetained: float64(retained) / float64(len(truth)),
+	}, nil
+}
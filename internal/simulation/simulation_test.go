@@ -0,0 +1,127 @@
+package simulation
+
+import (
+	"questions-vote/internal/models"
+	"reflect"
+	"testing"
+)
+
+// TestAdaptiveSelectionRecoversTopN compares the strategies on the same ground truths
+func TestAdaptiveSelectionRecoversTopN(t *testing.T) {
+	seeds := []int64{1, 2, 3}
+	meanPrecision := make(map[string]float64)
+	meanVotes := make(map[string]float64)
+
+	for _, strategy := range []string{models.SelectionRandom, models.SelectionAdaptive} {
+		for _, seed := range seeds {
+			cfg := DefaultConfig()
+			cfg.Questions = 100
+			cfg.Votes = 4000
+			cfg.Seed = seed
+			cfg.Tournament.TopN = 20
+			cfg.Tournament.SelectionStrategy = strategy
+
+			result, err := Run(cfg)
+			if err != nil {
+				t.Fatalf("Simulation with %s strategy failed: %v", strategy, err)
+			}
+
+			if len(result.Checkpoints) != cfg.Votes/cfg.CheckEvery {
+				t.Errorf("Expected %d checkpoints, got %d", cfg.Votes/cfg.CheckEvery, len(result.Checkpoints))
+			}
+
+			t.Logf("%s (seed %d): final precision %.2f, votes to reach %.0f%%: %d",
+				strategy, seed, result.FinalPrecision(), cfg.TargetPrecision*100, result.VotesToRecover)
+			if result.VotesToRecover == 0 {
+				t.Errorf("%s selection (seed %d) did not reach %.0f%% precision in %d votes",
+					strategy, seed, cfg.TargetPrecision*100, cfg.Votes)
+			}
+			meanPrecision[strategy] += result.FinalPrecision() / float64(len(seeds))
+			meanVotes[strategy] += float64(result.VotesToRecover) / float64(len(seeds))
+		}
+	}
+
+	random := meanPrecision[models.SelectionRandom]
+	adaptive := meanPrecision[models.SelectionAdaptive]
+
+	if random < 0.5 {
+		t.Errorf("Random selection should recover at least half of the top N, got %.2f", random)
+	}
+
+	if adaptive < random-0.1 {
+		t.Errorf("Adaptive precision %.2f is worse than random %.2f", adaptive, random)
+	}
+
+	if meanVotes[models.SelectionAdaptive] >= meanVotes[models.SelectionRandom] {
+		t.Errorf("Adaptive selection needed %.0f votes on average to recover the top N, random %.0f",
+			meanVotes[models.SelectionAdaptive], meanVotes[models.SelectionRandom])
+	}
+}
+
+// TestRunValidatesConfig tests that impossible configurations are rejected
+func TestRunValidatesConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Questions = 1
+
+	_, err := Run(cfg)
+	if err == nil {
+		t.Error("Expected error for a single question")
+	}
+
+	cfg = DefaultConfig()
+	cfg.Tournament.TopN = cfg.Questions + 1
+
+	_, err = Run(cfg)
+	if err == nil {
+		t.Error("Expected error for top N larger than the number of questions")
+	}
+}
+
+// TestRunIsReproducible tests that runs with the same seed select the same pairs
+func TestRunIsReproducible(t *testing.T) {
+	for _, strategy := range []string{models.SelectionRandom, models.SelectionAdaptive} {
+		cfg := DefaultConfig()
+		cfg.Questions = 30
+		cfg.Votes = 300
+		cfg.CheckEvery = 50
+		cfg.Tournament.TopN = 5
+		cfg.Tournament.SelectionStrategy = strategy
+
+		first, err := Run(cfg)
+		if err != nil {
+			t.Fatalf("Simulation with %s strategy failed: %v", strategy, err)
+		}
+		second, err := Run(cfg)
+		if err != nil {
+			t.Fatalf("Simulation with %s strategy failed: %v", strategy, err)
+		}
+
+		if !reflect.DeepEqual(first, second) {
+			t.Errorf("Expected identical %s runs with the same seed, got %+v and %+v", strategy, first, second)
+		}
+	}
+}
+
+// TestRunWithSkippingVoters tests that skipped votes do not change ratings
+func TestRunWithSkippingVoters(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Questions = 20
+	cfg.Votes = 50
+	cfg.CheckEvery = 10
+	cfg.Tournament.TopN = 5
+	cfg.Voters.SkipRate = 1
+
+	result, err := Run(cfg)
+	if err != nil {
+		t.Fatalf("Simulation failed: %v", err)
+	}
+
+	last := result.Checkpoints[len(result.Checkpoints)-1]
+	if last.Skips != cfg.Votes {
+		t.Errorf("Expected %d skips, got %d", cfg.Votes, last.Skips)
+	}
+
+	if last.TopNRetained != 1 {
+		t.Errorf("Expected every question to stay in contention without votes, got %.2f of the top N", last.TopNRetained)
+	}
+}
@@ -0,0 +1,27 @@
+package testutil
+
+import (
+	"database/sql"
+	"path/filepath"
+	"questions-vote/internal/db"
+	"testing"
+)
+
+// OpenDB opens a migrated database in a temporary file and closes it when the test ends.
+// Unlike :memory:, a file is shared by all connections of the pool, as in production.
+func OpenDB(t testing.TB) *sql.DB {
+	t.Helper()
+
+	err := db.InitializeWithPath(filepath.Join(t.TempDir(), "questions.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	err = db.Migrate()
+	if err != nil {
+		t.Fatalf("Failed to migrate database: %v", err)
+	}
+
+	return db.GetDB()
+}
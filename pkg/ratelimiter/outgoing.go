@@ -0,0 +1,42 @@
+package ratelimiter
+
+import (
+	"context"
+	"time"
+)
+
+// TelegramGlobal is Telegram's limit on messages a bot sends across all chats
+var TelegramGlobal = Limit{Burst: 30, Every: time.Second / 30}
+
+// Outgoing limits the total rate of outgoing requests, whoever sends them
+type Outgoing struct {
+	limiter *Limiter
+}
+
+// NewOutgoing creates a limiter shared by all outgoing requests
+func NewOutgoing(limit Limit, opts ...Option) *Outgoing {
+	return &Outgoing{limiter: NewLimiter(limit, opts...)}
+}
+
+// Reserve claims a slot and returns how long to wait before using it
+func (o *Outgoing) Reserve() time.Duration {
+	return o.limiter.Reserve(0)
+}
+
+// Wait blocks until a request may be sent or the context is cancelled
+func (o *Outgoing) Wait(ctx context.Context) error {
+	wait := o.Reserve()
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
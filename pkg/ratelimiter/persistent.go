@@ -0,0 +1,85 @@
+package ratelimiter
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// State is the saved bucket of one key
+type State struct {
+	Scope   Scope
+	Key     int64
+	Tokens  float64
+	Updated time.Time
+}
+
+// Store keeps snapshots of limiter state between restarts
+type Store interface {
+	// Load returns the last saved snapshot
+	Load() ([]State, error)
+	// Save replaces the saved snapshot
+	Save(states []State) error
+}
+
+// Persistent is a Scoped limiter whose state is restored from a store on creation
+// and saved to it periodically and when it stops
+type Persistent struct {
+	*Scoped
+	store Store
+}
+
+// NewPersistent creates scoped limiters and restores their state from store.
+// Saved keys of scopes that no longer have a limit are ignored.
+func NewPersistent(limits map[Scope]Limit, store Store, opts ...Option) (*Persistent, error) {
+	p := &Persistent{
+		Scoped: NewScoped(limits, opts...),
+		store:  store,
+	}
+
+	states, err := store.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load rate limits: %w", err)
+	}
+
+	for _, state := range states {
+		if limiter, exists := p.limiters[state.Scope]; exists {
+			limiter.restore(state)
+		}
+	}
+
+	return p, nil
+}
+
+// Save evicts idle keys and saves the state of the rest
+func (p *Persistent) Save() error {
+	p.EvictIdle()
+
+	var states []State
+	for scope, limiter := range p.limiters {
+		states = append(states, limiter.snapshot(scope)...)
+	}
+
+	return p.store.Save(states)
+}
+
+// Run saves the state every interval and once more when the context is cancelled
+func (p *Persistent) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := p.Save(); err != nil {
+				log.Printf("Failed to save rate limits on shutdown: %v", err)
+			}
+			return
+		case <-ticker.C:
+			if err := p.Save(); err != nil {
+				log.Printf("Failed to save rate limits: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package ratelimiter
+
+import (
+	"testing"
+	"time"
+)
+
+// memoryStore keeps a snapshot in memory, standing in for the database
+type memoryStore struct {
+	states []State
+	saves  int
+}
+
+func (s *memoryStore) Load() ([]State, error) {
+	return s.states, nil
+}
+
+func (s *memoryStore) Save(states []State) error {
+	s.states = append([]State(nil), states...)
+	s.saves++
+	return nil
+}
+
+// TestPersistentSurvivesRestart tests that a limited user stays limited after the limiter is recreated
+func TestPersistentSurvivesRestart(t *testing.T) {
+	clock := newFakeClock()
+	store := &memoryStore{}
+	limits := map[Scope]Limit{"vote": {Burst: 1, Every: 10 * time.Second}}
+
+	limiter, err := NewPersistent(limits, store, WithClock(clock))
+	if err != nil {
+		t.Fatalf("Failed to create limiter: %v", err)
+	}
+
+	limiter.Allow("vote", 1)
+	if err := limiter.Save(); err != nil {
+		t.Fatalf("Failed to save: %v", err)
+	}
+
+	clock.Advance(4 * time.Second)
+
+	restarted, err := NewPersistent(limits, store, WithClock(clock))
+	if err != nil {
+		t.Fatalf("Failed to recreate limiter: %v", err)
+	}
+
+	ok, wait := restarted.Allow("vote", 1)
+	if ok {
+		t.Fatal("User should still be limited after a restart")
+	}
+	if wait != 6*time.Second {
+		t.Errorf("Expected to wait the remaining 6s, got %v", wait)
+	}
+
+	if ok, _ := restarted.Allow("vote", 2); !ok {
+		t.Error("Other users should not be limited")
+	}
+}
+
+// TestPersistentSavesOnlyActiveKeys tests that idle keys and removed scopes are not carried over
+func TestPersistentSavesOnlyActiveKeys(t *testing.T) {
+	clock := newFakeClock()
+	store := &memoryStore{states: []State{
+		{Scope: "removed", Key: 1, Tokens: 0, Updated: clock.Now()},
+	}}
+	limits := map[Scope]Limit{"report": {Burst: 2, Every: time.Minute}}
+
+	limiter, err := NewPersistent(limits, store, WithClock(clock))
+	if err != nil {
+		t.Fatalf("Failed to create limiter: %v", err)
+	}
+
+	limiter.Allow("report", 1)
+	limiter.Allow("report", 2)
+	clock.Advance(30 * time.Second)
+	limiter.Allow("report", 2)
+	clock.Advance(30 * time.Second)
+
+	if err := limiter.Save(); err != nil {
+		t.Fatalf("Failed to save: %v", err)
+	}
+
+	if len(store.states) != 1 {
+		t.Fatalf("Expected only user 2 to be saved, got %v", store.states)
+	}
+	if store.states[0].Key != 2 || store.states[0].Scope != "report" {
+		t.Errorf("Expected report limit of user 2, got %+v", store.states[0])
+	}
+}
@@ -1,47 +1,237 @@
 package ratelimiter
 
 import (
+	"context"
+	"math"
 	"sync"
 	"time"
 )
 
-// RateLimiter manages rate limiting for users
-type RateLimiter struct {
-	mu       sync.RWMutex
-	lastSent map[int64]time.Time
-	cooldown time.Duration
+// Clock tells the current time. Tests substitute a fake one.
+type Clock interface {
+	Now() time.Time
 }
 
-// New creates a new rate limiter with the specified cooldown
-func New(cooldown time.Duration) *RateLimiter {
-	return &RateLimiter{
-		lastSent: make(map[int64]time.Time),
-		cooldown: cooldown,
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// Option configures a limiter
+type Option func(*options)
+
+type options struct {
+	clock Clock
+}
+
+// WithClock makes a limiter read time from clock instead of the system clock
+func WithClock(clock Clock) Option {
+	return func(o *options) {
+		o.clock = clock
 	}
 }
 
-// Record records that a message was sent to the user
-func (rl *RateLimiter) Record(chatID int64) {
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
-	rl.lastSent[chatID] = time.Now()
+func buildOptions(opts []Option) options {
+	o := options{clock: systemClock{}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
 }
 
-// CanSendInSeconds returns how many seconds until the user can receive another message
-func (rl *RateLimiter) CanSendInSeconds(chatID int64) int {
-	rl.mu.RLock()
-	defer rl.mu.RUnlock()
+// Limit is a token bucket: up to Burst actions at once, then one more every Every
+type Limit struct {
+	Burst int
+	Every time.Duration
+}
 
-	lastSent, exists := rl.lastSent[chatID]
-	if !exists {
-		return 0
+// bucket holds the tokens of one key
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// refill adds the tokens earned since the bucket was last updated
+func (l Limit) refill(b *bucket, now time.Time) {
+	if l.Every <= 0 {
+		b.tokens = float64(l.Burst)
+	} else if elapsed := now.Sub(b.updated); elapsed > 0 {
+		b.tokens = math.Min(float64(l.Burst), b.tokens+float64(elapsed)/float64(l.Every))
 	}
+	b.updated = now
+}
 
-	elapsed := time.Since(lastSent)
-	if elapsed >= rl.cooldown {
+// wait returns how long until the bucket has a whole token
+func (l Limit) wait(b *bucket) time.Duration {
+	if b.tokens >= 1 {
 		return 0
 	}
+	return time.Duration(math.Ceil((1 - b.tokens) * float64(l.Every)))
+}
+
+// Limiter limits actions per key, such as a chat or user ID, with a token bucket for each key
+type Limiter struct {
+	mu      sync.Mutex
+	limit   Limit
+	clock   Clock
+	buckets map[int64]*bucket
+}
+
+// NewLimiter creates a limiter applying limit to every key
+func NewLimiter(limit Limit, opts ...Option) *Limiter {
+	o := buildOptions(opts)
+	return &Limiter{
+		limit:   limit,
+		clock:   o.clock,
+		buckets: make(map[int64]*bucket),
+	}
+}
+
+// Allow takes a token for key if one is available. Otherwise it returns false
+// and how long until the next token.
+func (l *Limiter) Allow(key int64) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := l.bucket(key)
+	if wait := l.limit.wait(b); wait > 0 {
+		return false, wait
+	}
+
+	b.tokens--
+	return true, 0
+}
+
+// Reserve takes a token for key even if none is available yet and returns how long
+// the caller must wait before acting on it
+func (l *Limiter) Reserve(key int64) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := l.bucket(key)
+	wait := l.limit.wait(b)
+	b.tokens--
+	return wait
+}
+
+// Len returns the number of keys being tracked
+func (l *Limiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.buckets)
+}
+
+// EvictIdle forgets keys whose buckets have refilled completely; they behave exactly like new keys.
+// It returns the number of keys removed.
+func (l *Limiter) EvictIdle() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	evicted := 0
+	for key, b := range l.buckets {
+		l.limit.refill(b, now)
+		if b.tokens >= float64(l.limit.Burst) {
+			delete(l.buckets, key)
+			evicted++
+		}
+	}
+
+	return evicted
+}
+
+// snapshot returns the buckets of all tracked keys
+func (l *Limiter) snapshot(scope Scope) []State {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	states := make([]State, 0, len(l.buckets))
+	for key, b := range l.buckets {
+		states = append(states, State{Scope: scope, Key: key, Tokens: b.tokens, Updated: b.updated})
+	}
+	return states
+}
+
+// restore sets the bucket of a key from a saved state
+func (l *Limiter) restore(state State) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.buckets[state.Key] = &bucket{
+		tokens:  math.Min(state.Tokens, float64(l.limit.Burst)),
+		updated: state.Updated,
+	}
+}
+
+// bucket returns the refilled bucket of key, creating a full one for a new key
+func (l *Limiter) bucket(key int64) *bucket {
+	now := l.clock.Now()
+
+	b, exists := l.buckets[key]
+	if !exists {
+		b = &bucket{tokens: float64(l.limit.Burst), updated: now}
+		l.buckets[key] = b
+		return b
+	}
+
+	l.limit.refill(b, now)
+	return b
+}
+
+// Scope names a kind of action limited separately, e.g. a command
+type Scope string
+
+// ScopedLimiter limits actions per scope and key. The in-memory Scoped and the
+// persistent Persistent limiters are interchangeable.
+type ScopedLimiter interface {
+	// Allow takes a token for key in scope, or returns false and how long until the next token
+	Allow(scope Scope, key int64) (bool, time.Duration)
+	// Run does periodic maintenance every interval until the context is cancelled
+	Run(ctx context.Context, interval time.Duration)
+}
+
+// Scoped keeps a separate limiter for every scope
+type Scoped struct {
+	limiters map[Scope]*Limiter
+}
+
+// NewScoped creates limiters for the given scopes
+func NewScoped(limits map[Scope]Limit, opts ...Option) *Scoped {
+	limiters := make(map[Scope]*Limiter, len(limits))
+	for scope, limit := range limits {
+		limiters[scope] = NewLimiter(limit, opts...)
+	}
+	return &Scoped{limiters: limiters}
+}
+
+// Allow takes a token for key in scope. Scopes without a limit are not limited.
+func (s *Scoped) Allow(scope Scope, key int64) (bool, time.Duration) {
+	limiter, exists := s.limiters[scope]
+	if !exists {
+		return true, 0
+	}
+	return limiter.Allow(key)
+}
+
+// EvictIdle forgets idle keys in every scope and returns the number of keys removed
+func (s *Scoped) EvictIdle() int {
+	evicted := 0
+	for _, limiter := range s.limiters {
+		evicted += limiter.EvictIdle()
+	}
+	return evicted
+}
 
-	remaining := rl.cooldown - elapsed
-	return int(remaining.Seconds())
+// Run evicts idle keys every interval until the context is cancelled
+func (s *Scoped) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.EvictIdle()
+		}
+	}
 }
@@ -0,0 +1,166 @@
+package ratelimiter
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock that only moves when told to
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+// TestAllowBurstThenRefill tests that a key gets its burst at once and then one token per interval
+func TestAllowBurstThenRefill(t *testing.T) {
+	clock := newFakeClock()
+	limiter := NewLimiter(Limit{Burst: 3, Every: 2 * time.Second}, WithClock(clock))
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := limiter.Allow(1); !ok {
+			t.Fatalf("Action %d of the burst should be allowed", i+1)
+		}
+	}
+
+	ok, wait := limiter.Allow(1)
+	if ok {
+		t.Fatal("Action after the burst should be limited")
+	}
+	if wait != 2*time.Second {
+		t.Errorf("Expected to wait 2s, got %v", wait)
+	}
+
+	clock.Advance(time.Second)
+	ok, wait = limiter.Allow(1)
+	if ok || wait != time.Second {
+		t.Errorf("Expected to wait 1s more after half the interval, got allowed=%v wait=%v", ok, wait)
+	}
+
+	clock.Advance(time.Second)
+	if ok, _ := limiter.Allow(1); !ok {
+		t.Error("Action should be allowed once a token has refilled")
+	}
+	if ok, _ := limiter.Allow(1); ok {
+		t.Error("Only one token should have refilled")
+	}
+}
+
+// TestAllowKeysAreIndependent tests that one key running out does not limit another
+func TestAllowKeysAreIndependent(t *testing.T) {
+	clock := newFakeClock()
+	limiter := NewLimiter(Limit{Burst: 1, Every: time.Minute}, WithClock(clock))
+
+	limiter.Allow(1)
+	if ok, _ := limiter.Allow(1); ok {
+		t.Error("Key 1 should be limited")
+	}
+	if ok, _ := limiter.Allow(2); !ok {
+		t.Error("Key 2 should not be limited by key 1")
+	}
+}
+
+// TestRefillCapsAtBurst tests that a long pause does not bank more than the burst
+func TestRefillCapsAtBurst(t *testing.T) {
+	clock := newFakeClock()
+	limiter := NewLimiter(Limit{Burst: 2, Every: time.Second}, WithClock(clock))
+
+	limiter.Allow(1)
+	clock.Advance(time.Hour)
+
+	allowed := 0
+	for i := 0; i < 5; i++ {
+		if ok, _ := limiter.Allow(1); ok {
+			allowed++
+		}
+	}
+	if allowed != 2 {
+		t.Errorf("Expected 2 actions after a long pause, got %d", allowed)
+	}
+}
+
+// TestEvictIdle tests that only keys with full buckets are forgotten
+func TestEvictIdle(t *testing.T) {
+	clock := newFakeClock()
+	limiter := NewLimiter(Limit{Burst: 2, Every: 10 * time.Second}, WithClock(clock))
+
+	limiter.Allow(1)
+	limiter.Allow(1)
+	clock.Advance(15 * time.Second)
+	limiter.Allow(2)
+
+	if evicted := limiter.EvictIdle(); evicted != 0 {
+		t.Errorf("No bucket is full yet, evicted %d", evicted)
+	}
+
+	clock.Advance(5 * time.Second)
+	if evicted := limiter.EvictIdle(); evicted != 1 || limiter.Len() != 1 {
+		t.Errorf("Expected key 1 to be evicted, evicted %d and %d keys left", evicted, limiter.Len())
+	}
+
+	clock.Advance(10 * time.Second)
+	if evicted := limiter.EvictIdle(); evicted != 1 || limiter.Len() != 0 {
+		t.Errorf("Expected key 2 to be evicted, evicted %d and %d keys left", evicted, limiter.Len())
+	}
+}
+
+// TestScopedLimitsSeparately tests that scopes have their own limits and unknown scopes are free
+func TestScopedLimitsSeparately(t *testing.T) {
+	clock := newFakeClock()
+	limiter := NewScoped(map[Scope]Limit{
+		"vote":   {Burst: 1, Every: 5 * time.Second},
+		"report": {Burst: 2, Every: time.Minute},
+	}, WithClock(clock))
+
+	if ok, _ := limiter.Allow("vote", 1); !ok {
+		t.Fatal("First vote should be allowed")
+	}
+	if ok, _ := limiter.Allow("vote", 1); ok {
+		t.Error("Second vote should be limited")
+	}
+	if ok, _ := limiter.Allow("report", 1); !ok {
+		t.Error("Reports should not be limited by votes")
+	}
+	for i := 0; i < 10; i++ {
+		if ok, _ := limiter.Allow("unknown", 1); !ok {
+			t.Fatal("Scopes without a limit should not be limited")
+		}
+	}
+
+	clock.Advance(time.Minute)
+	if evicted := limiter.EvictIdle(); evicted != 2 {
+		t.Errorf("Expected both idle keys to be evicted, got %d", evicted)
+	}
+}
+
+// TestOutgoingReserveSpacesRequests tests that requests beyond the burst are spaced by the interval
+func TestOutgoingReserveSpacesRequests(t *testing.T) {
+	clock := newFakeClock()
+	outgoing := NewOutgoing(Limit{Burst: 30, Every: time.Second / 30}, WithClock(clock))
+
+	for i := 0; i < 30; i++ {
+		if wait := outgoing.Reserve(); wait != 0 {
+			t.Fatalf("Request %d of the burst should not wait, got %v", i+1, wait)
+		}
+	}
+
+	interval := time.Second / 30
+	for i := 1; i <= 3; i++ {
+		wait := outgoing.Reserve()
+		expected := time.Duration(i) * interval
+		if diff := wait - expected; diff < -time.Microsecond || diff > time.Microsecond {
+			t.Errorf("Request %d after the burst should wait %v, got %v", i, expected, wait)
+		}
+	}
+
+	clock.Advance(time.Second)
+	if wait := outgoing.Reserve(); wait != 0 {
+		t.Errorf("After a second the limiter should have caught up, got wait %v", wait)
+	}
+}